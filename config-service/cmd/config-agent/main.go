@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/company/config-service/internal/agent"
+	"github.com/company/config-service/internal/config"
+	"github.com/company/config-service/internal/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	version   = "dev"
+	buildTime = "unknown"
+	gitCommit = "unknown"
+)
+
+// config-agent is a sidecar that materializes the rendered templates of one
+// environment into a directory and reloads the application when they change.
+func main() {
+	// Load configuration
+	cfg, err := config.LoadAgent()
+	if err != nil {
+		fmt.Printf("Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Initialize logger
+	log := logger.New(logger.Config{
+		Level:  cfg.Logger.Level,
+		Format: cfg.Logger.Format,
+	})
+	logger.SetGlobal(log)
+
+	log.Info().
+		Str("version", version).
+		Str("build_time", buildTime).
+		Str("git_commit", gitCommit).
+		Str("environment", cfg.Agent.Environment).
+		Strs("tags", cfg.Agent.Tags).
+		Str("target_dir", cfg.Agent.TargetDir).
+		Msg("Starting Config Agent")
+
+	configAgent, err := agent.New(cfg.Agent, log, version)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create config agent")
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.GET("/health", configAgent.Health)
+	if cfg.Metrics.Enabled {
+		router.GET(cfg.Metrics.Path, gin.WrapH(promhttp.Handler()))
+	}
+
+	server := &http.Server{
+		Addr:         fmt.Sprintf("%s:%s", cfg.Agent.HTTPHost, cfg.Agent.HTTPPort),
+		Handler:      router,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Info().
+			Str("host", cfg.Agent.HTTPHost).
+			Str("port", cfg.Agent.HTTPPort).
+			Msg("Starting HTTP server")
+
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal().Err(err).Msg("Failed to start server")
+		}
+	}()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	// Sync until a shutdown signal is received
+	configAgent.Run(ctx)
+
+	log.Info().Msg("Shutting down config agent...")
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("Health server forced to shutdown")
+	}
+
+	log.Info().Msg("Config agent exited")
+}
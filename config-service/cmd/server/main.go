@@ -8,13 +8,36 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	// Embed the time zone database for the time zones of freeze windows
+	_ "time/tzdata"
 
 	// Import generated swagger docs
 	_ "github.com/company/config-service/docs/swagger"
+	apiarchive "github.com/company/config-service/internal/api/archive"
+	"github.com/company/config-service/internal/api/audit"
+	"github.com/company/config-service/internal/api/bulk"
+	"github.com/company/config-service/internal/api/bundle"
+	"github.com/company/config-service/internal/api/change"
+	"github.com/company/config-service/internal/api/deployment"
+	"github.com/company/config-service/internal/api/drift"
+	"github.com/company/config-service/internal/api/environment"
+	"github.com/company/config-service/internal/api/flag"
+	"github.com/company/config-service/internal/api/freeze"
 	"github.com/company/config-service/internal/api/health"
+	"github.com/company/config-service/internal/api/rollout"
+	"github.com/company/config-service/internal/api/secret"
+	"github.com/company/config-service/internal/api/snapshot"
+	"github.com/company/config-service/internal/api/tag"
+	apitemplate "github.com/company/config-service/internal/api/template"
+	"github.com/company/config-service/internal/api/webhook"
+	"github.com/company/config-service/internal/auth"
 	"github.com/company/config-service/internal/config"
 	"github.com/company/config-service/internal/database"
 	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/render"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/secrets"
+	"github.com/company/config-service/internal/service"
 	"github.com/company/config-service/pkg/metrics"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -105,6 +128,29 @@ func main() {
 		log.Fatal().Err(err).Msg("Failed to connect to Redis")
 	}
 
+	// Load API tokens and the master key of secret values
+	tokens, err := auth.LoadTokens(cfg.Auth.TokensFile)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load API tokens")
+	}
+
+	var secretCipher *secrets.Cipher
+	masterKeys, err := secrets.LoadKeys(cfg.Secrets.MasterKey, cfg.Secrets.MasterKeyFile, cfg.Secrets.KeyringFile)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load master key")
+	}
+	if masterKeys != nil {
+		if secretCipher, err = secrets.NewCipher(masterKeys); err != nil {
+			log.Fatal().Err(err).Msg("Invalid master key")
+		}
+		log.Info().
+			Str("key_id", secretCipher.KeyID()).
+			Int("keys", len(masterKeys)).
+			Msg("Secret values encryption enabled")
+	} else {
+		log.Warn().Msg("No master key configured, templates with secret values cannot be written")
+	}
+
 	// Initialize metrics
 	metricsCollector := metrics.New()
 
@@ -139,12 +185,166 @@ func main() {
 		router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	}
 
+	// Repositories
+	environmentRepo := repository.NewEnvironmentRepository(db)
+	tagRepo := repository.NewTagRepository(db)
+	templateRepo := repository.NewTemplateRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	rotationRepo := repository.NewSecretRotationRepository(db)
+	historyRepo := repository.NewHistoryRepository(db)
+	changeRepo := repository.NewChangeRequestRepository(db)
+	freezeRepo := repository.NewFreezeWindowRepository(db)
+	deploymentRepo := repository.NewScheduledDeploymentRepository(db)
+	leaseRepo := repository.NewLeaseRepository(db)
+	candidateRepo := repository.NewCandidateRepository(db)
+	flagRepo := repository.NewFlagRepository(db)
+	snapshotRepo := repository.NewSnapshotRepository(db)
+	checkInRepo := repository.NewCheckInRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+
+	// Services
+	bundleService := service.NewBundleService(environmentRepo, templateRepo, candidateRepo, render.Options{
+		Timeout:        cfg.Render.Timeout,
+		MaxOutputBytes: cfg.Render.MaxOutputBytes,
+		AllowEnv:       cfg.Render.AllowEnv,
+		EnvAllowlist:   cfg.Render.EnvAllowlist,
+	}, secretCipher)
+	archiveService := service.NewArchiveService(db, environmentRepo, tagRepo, templateRepo, freezeRepo, secretCipher)
+	bulkService := service.NewBulkService(db, environmentRepo, tagRepo, templateRepo, freezeRepo, secretCipher)
+	templateService := service.NewTemplateService(environmentRepo, templateRepo)
+	environmentService := service.NewEnvironmentService(db, environmentRepo, templateRepo, auditRepo)
+	changeService := service.NewChangeService(db, environmentRepo, templateRepo, changeRepo, freezeRepo, auditRepo, secretCipher)
+	rolloutService := service.NewRolloutService(db, environmentRepo, templateRepo, candidateRepo, freezeRepo, auditRepo, secretCipher)
+	freezeService := service.NewFreezeService(db, environmentRepo, freezeRepo, auditRepo)
+	flagService := service.NewFlagService(db, environmentRepo, tagRepo, flagRepo, freezeRepo, auditRepo)
+	snapshotService := service.NewSnapshotService(db, environmentRepo, templateRepo, snapshotRepo, auditRepo)
+	driftService := service.NewDriftService(environmentRepo, checkInRepo, bundleService, service.DriftOptions{
+		StaleAfter: cfg.Drift.StaleAfter,
+		Retention:  cfg.Drift.Retention,
+	})
+	scheduleService := service.NewScheduleService(db, environmentRepo, templateRepo, deploymentRepo, freezeRepo, leaseRepo, auditRepo, secretCipher, service.SchedulerOptions{
+		Interval: cfg.Scheduler.Interval,
+		Lease:    cfg.Scheduler.Lease,
+	}, log)
+	webhookService := service.NewWebhookService(db, environmentRepo, tagRepo, webhookRepo, leaseRepo, auditRepo, secretCipher, service.WebhookOptions{
+		Interval:     cfg.Webhooks.Interval,
+		Lease:        cfg.Webhooks.Lease,
+		Timeout:      cfg.Webhooks.Timeout,
+		Concurrency:  cfg.Webhooks.Concurrency,
+		MaxAttempts:  cfg.Webhooks.MaxAttempts,
+		RetryBase:    cfg.Webhooks.RetryBase,
+		RetryMax:     cfg.Webhooks.RetryMax,
+		DisableAfter: cfg.Webhooks.DisableAfter,
+		Retention:    cfg.Webhooks.Retention,
+	}, log)
+	tagService := service.NewTagService(db, tagRepo, auditRepo, webhookRepo, cfg.Tags.LabelKeys)
+	auditService := service.NewAuditService(auditRepo)
+	rotationService := service.NewSecretRotationService(db, rotationRepo, templateRepo, historyRepo, webhookRepo, secretCipher, service.RotationOptions{
+		BatchSize: cfg.Secrets.RotationBatchSize,
+		Lease:     cfg.Secrets.RotationLease,
+	}, log)
+
+	// API handlers
+	bundleHandler := bundle.New(bundleService, log)
+	archiveHandler := apiarchive.New(archiveService, log)
+	bulkHandler := bulk.New(bulkService, log)
+	templateHandler := apitemplate.New(templateService, log)
+	environmentHandler := environment.New(environmentService, log)
+	tagHandler := tag.New(tagService, log)
+	auditHandler := audit.New(auditService, log)
+	secretHandler := secret.New(rotationService, log)
+	changeHandler := change.New(changeService, log)
+	freezeHandler := freeze.New(freezeService, log)
+	rolloutHandler := rollout.New(rolloutService, log)
+	deploymentHandler := deployment.New(scheduleService, log)
+	flagHandler := flag.New(flagService, log)
+	snapshotHandler := snapshot.New(snapshotService, log)
+	driftHandler := drift.New(driftService, log)
+	webhookHandler := webhook.New(webhookService, log)
+
 	// API v1 routes
 	v1 := router.Group("/api/v1")
+	v1.Use(auth.Middleware(tokens))
 	{
 		v1.GET("/ping", pingHandler)
-		v1.GET("/environments", getEnvironments(db))
-		v1.GET("/tags", getTags(db))
+		v1.GET("/environments", environmentHandler.List)
+		v1.GET("/environments/compare", snapshotHandler.Compare)
+		v1.GET("/environments/:slug/bundle", bundleHandler.Get)
+		v1.GET("/environments/:slug/export/kubernetes", bundleHandler.Kubernetes)
+		v1.POST("/environments/:slug/check-ins", auth.RequireAuthenticated(), driftHandler.CheckIn)
+		v1.GET("/environments/:slug/drift", driftHandler.Report)
+		v1.GET("/tags", tagHandler.List)
+		v1.POST("/tags", auth.RequireAuthenticated(), tagHandler.Create)
+		v1.GET("/tags/tree", tagHandler.Tree)
+		v1.GET("/tags/usage", tagHandler.Usage)
+		v1.POST("/tags/cleanup", auth.RequireAuthenticated(), tagHandler.Cleanup)
+		v1.POST("/tags/merge", auth.RequireAuthenticated(), tagHandler.Merge)
+		v1.GET("/tags/:id", tagHandler.Get)
+		v1.PUT("/tags/:id", auth.RequireAuthenticated(), tagHandler.Update)
+		v1.POST("/tags/labels/migrate", auth.RequireAuthenticated(), tagHandler.MigrateLabels)
+		v1.GET("/audit", auditHandler.List)
+		v1.GET("/archive", auth.Require(auth.ArchiveAdmin), archiveHandler.Export)
+		v1.POST("/archive/import", auth.Require(auth.ArchiveAdmin), archiveHandler.Import)
+		v1.GET("/templates", templateHandler.List)
+		v1.GET("/templates/search", templateHandler.Search)
+		v1.GET("/templates/query", templateHandler.Query)
+		v1.GET("/templates/:id", templateHandler.Get)
+		v1.GET("/templates/:id/dependencies", templateHandler.Dependencies)
+		v1.GET("/templates/:id/candidate", rolloutHandler.Get)
+		v1.PUT("/templates/:id/candidate", auth.RequireAuthenticated(), rolloutHandler.Set)
+		v1.PUT("/templates/:id/candidate/rollout", auth.RequireAuthenticated(), rolloutHandler.Rollout)
+		v1.POST("/templates/:id/candidate/promote", auth.RequireAuthenticated(), rolloutHandler.Promote)
+		v1.DELETE("/templates/:id/candidate", auth.RequireAuthenticated(), rolloutHandler.Abort)
+		v1.POST("/templates/bulk", auth.RequireAuthenticated(), bulkHandler.Templates)
+		v1.POST("/templates/bulk/tags", auth.RequireAuthenticated(), bulkHandler.Tags)
+		v1.POST("/templates/bulk/status", auth.RequireAuthenticated(), bulkHandler.Status)
+		v1.PUT("/environments/:slug/protection", auth.Require(auth.EnvironmentsAdmin), environmentHandler.SetProtection)
+		v1.GET("/environments/:slug/freeze-windows", freezeHandler.List)
+		v1.POST("/environments/:slug/freeze-windows", auth.Require(auth.EnvironmentsAdmin), freezeHandler.Create)
+		v1.DELETE("/environments/:slug/freeze-windows/:id", auth.Require(auth.EnvironmentsAdmin), freezeHandler.Delete)
+		v1.GET("/environments/:slug/flags", flagHandler.List)
+		v1.POST("/environments/:slug/flags", auth.RequireAuthenticated(), flagHandler.Create)
+		v1.POST("/environments/:slug/flags/evaluate", flagHandler.Evaluate)
+		v1.GET("/environments/:slug/flags/:key", flagHandler.Get)
+		v1.PUT("/environments/:slug/flags/:key", auth.RequireAuthenticated(), flagHandler.Update)
+		v1.DELETE("/environments/:slug/flags/:key", auth.RequireAuthenticated(), flagHandler.Delete)
+		v1.POST("/environments/:slug/clone", auth.Require(auth.EnvironmentsAdmin), environmentHandler.Clone)
+		v1.GET("/environments/:slug/snapshots", snapshotHandler.List)
+		v1.POST("/environments/:slug/snapshots", auth.RequireAuthenticated(), snapshotHandler.Create)
+		v1.GET("/environments/:slug/snapshots/:name", snapshotHandler.Get)
+		v1.DELETE("/environments/:slug/snapshots/:name", auth.RequireAuthenticated(), snapshotHandler.Delete)
+		v1.GET("/change-requests", changeHandler.List)
+		v1.GET("/change-requests/:id", changeHandler.Get)
+		v1.POST("/change-requests", auth.RequireAuthenticated(), changeHandler.Create)
+		v1.POST("/change-requests/:id/comments", auth.RequireAuthenticated(), changeHandler.Comment)
+		v1.POST("/change-requests/:id/approve", auth.Require(auth.ChangesApprove), changeHandler.Approve)
+		v1.POST("/change-requests/:id/reject", auth.Require(auth.ChangesApprove), changeHandler.Reject)
+		v1.GET("/deployments", deploymentHandler.List)
+		v1.GET("/deployments/:id", deploymentHandler.Get)
+		v1.POST("/deployments", auth.RequireAuthenticated(), deploymentHandler.Create)
+		v1.DELETE("/deployments/:id", auth.RequireAuthenticated(), deploymentHandler.Cancel)
+	}
+
+	// Admin routes
+	admin := v1.Group("/admin", auth.Require(auth.SecretsAdmin))
+	{
+		admin.GET("/secrets/rotation", secretHandler.Status)
+		admin.POST("/secrets/rotation", secretHandler.Start)
+		admin.POST("/secrets/rotation/pause", secretHandler.Pause)
+	}
+
+	// Webhook routes
+	webhooks := v1.Group("/webhooks", auth.Require(auth.WebhooksAdmin))
+	{
+		webhooks.GET("", webhookHandler.List)
+		webhooks.POST("", webhookHandler.Create)
+		webhooks.GET("/:id", webhookHandler.Get)
+		webhooks.PUT("/:id", webhookHandler.Update)
+		webhooks.DELETE("/:id", webhookHandler.Delete)
+		webhooks.POST("/:id/ping", webhookHandler.Ping)
+		webhooks.GET("/:id/deliveries", webhookHandler.Deliveries)
+		webhooks.GET("/:id/deliveries/:delivery_id", webhookHandler.Delivery)
+		webhooks.POST("/:id/deliveries/:delivery_id/redeliver", webhookHandler.Redeliver)
 	}
 
 	// Create HTTP server
@@ -168,7 +368,9 @@ func main() {
 		}
 	}()
 
-	// Start metrics updater in a goroutine
+	// Start metrics updater in a goroutine; it runs until shutdown
+	metricsCtx, stopMetrics := context.WithCancel(context.Background())
+	defer stopMetrics()
 	go func() {
 		ticker := time.NewTicker(30 * time.Second)
 		defer ticker.Stop()
@@ -182,12 +384,36 @@ func main() {
 					stats.Idle,
 					stats.InUse,
 				)
-			case <-ctx.Done():
+				if err := tagService.RefreshMetrics(metricsCtx); err != nil {
+					log.Warn().Err(err).Msg("Failed to refresh tag metrics")
+				}
+				if err := driftService.RefreshMetrics(metricsCtx); err != nil {
+					log.Warn().Err(err).Msg("Failed to refresh drift metrics")
+				}
+			case <-metricsCtx.Done():
 				return
 			}
 		}
 	}()
 
+	// Start the secret re-encryption job; it works on rotations started
+	// through the admin API and resumes those interrupted by a restart
+	rotationCtx, stopRotation := context.WithCancel(context.Background())
+	defer stopRotation()
+	go rotationService.Run(rotationCtx, cfg.Secrets.RotationInterval)
+
+	// Start the deployment scheduler; every replica runs it and the one
+	// holding the lease applies due deployments
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	go scheduleService.Run(schedulerCtx)
+
+	// Start the webhook worker; every replica runs it and the one holding
+	// the lease delivers the events queued by template changes
+	webhooksCtx, stopWebhooks := context.WithCancel(context.Background())
+	defer stopWebhooks()
+	go webhookService.Run(webhooksCtx)
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -293,93 +519,3 @@ func pingHandler(c *gin.Context) {
 		"time":    time.Now().Format(time.RFC3339),
 	})
 }
-
-// getEnvironments godoc
-// @Summary Get all environments
-// @Description Retrieve all available environments
-// @Tags environments
-// @Accept json
-// @Produce json
-// @Success 200 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
-// @Router /api/v1/environments [get]
-func getEnvironments(db *database.Connection) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		rows, err := db.DB.Query("SELECT id, name, slug, description, active, priority FROM environments ORDER BY priority DESC")
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		defer rows.Close()
-
-		var environments []gin.H
-		for rows.Next() {
-			var id int64
-			var name, slug, description string
-			var active bool
-			var priority int
-
-			if err := rows.Scan(&id, &name, &slug, &description, &active, &priority); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
-
-			environments = append(environments, gin.H{
-				"id":          id,
-				"name":        name,
-				"slug":        slug,
-				"description": description,
-				"active":      active,
-				"priority":    priority,
-			})
-		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"environments": environments,
-			"total":        len(environments),
-		})
-	}
-}
-
-// getTags godoc
-// @Summary Get all tags
-// @Description Retrieve all available tags
-// @Tags tags
-// @Accept json
-// @Produce json
-// @Success 200 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
-// @Router /api/v1/tags [get]
-func getTags(db *database.Connection) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		rows, err := db.DB.Query("SELECT id, name, description, color FROM tags ORDER BY name")
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		defer rows.Close()
-
-		var tags []gin.H
-		for rows.Next() {
-			var id int64
-			var name, description, color string
-
-			if err := rows.Scan(&id, &name, &description, &color); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
-
-			tags = append(tags, gin.H{
-				"id":          id,
-				"name":        name,
-				"description": description,
-				"color":       color,
-			})
-		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"tags":  tags,
-			"total": len(tags),
-		})
-	}
-}
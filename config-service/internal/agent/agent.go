@@ -0,0 +1,264 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/company/config-service/internal/config"
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+// emptySum is the SHA-256 of empty content
+var emptySum = sha256.Sum256(nil)
+
+// Agent periodically fetches the rendered bundle of one environment,
+// materializes it into a directory and triggers a reload on change
+type Agent struct {
+	cfg      config.AgentSettings
+	client   *Client
+	writer   *Writer
+	reloader *Reloader
+	logger   *logger.Logger
+	version  string
+
+	mu            sync.RWMutex
+	etag          string
+	checksum      string
+	files         []model.BundleFile
+	pendingReload bool
+	lastSync      time.Time
+	lastSuccess   time.Time
+	lastError     error
+}
+
+// New creates a new agent from configuration
+func New(cfg config.AgentSettings, log *logger.Logger, version string) (*Agent, error) {
+	writer, err := NewWriter(cfg.TargetDir, cfg.FileMode, cfg.DirMode, cfg.FileModes, cfg.Prune)
+	if err != nil {
+		return nil, err
+	}
+
+	reloader, err := NewReloader(cfg.ReloadCommand, cfg.ReloadSignal, cfg.ReloadPIDFile, cfg.ReloadTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	instanceID := cfg.InstanceID
+	if instanceID == "" {
+		instanceID, _ = os.Hostname()
+	}
+
+	return &Agent{
+		cfg:      cfg,
+		client:   NewClient(cfg.ServerURL, cfg.AuthToken, instanceID, cfg.RequestTimeout),
+		writer:   writer,
+		reloader: reloader,
+		logger:   log.WithComponent("config-agent"),
+		version:  version,
+	}, nil
+}
+
+// Run syncs immediately and then on every interval until ctx is cancelled
+func (a *Agent) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		a.Sync(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Sync performs a single fetch, write and reload cycle
+func (a *Agent) Sync(ctx context.Context) {
+	start := time.Now()
+	result, err := a.sync(ctx)
+	duration := time.Since(start)
+
+	syncDuration.Observe(duration.Seconds())
+	syncsTotal.WithLabelValues(result).Inc()
+	lastSyncTimestamp.Set(float64(start.Unix()))
+
+	a.mu.Lock()
+	a.lastSync = start
+	a.lastError = err
+	if err == nil {
+		a.lastSuccess = start
+	}
+	a.mu.Unlock()
+
+	if err != nil {
+		lastSyncSuccess.Set(0)
+		a.logger.Error().Err(err).Str("environment", a.cfg.Environment).Msg("Bundle sync failed")
+		return
+	}
+
+	lastSyncSuccess.Set(1)
+	lastSuccessTimestamp.Set(float64(start.Unix()))
+	a.logger.Debug().
+		Str("result", result).
+		Str("duration", duration.String()).
+		Msg("Bundle sync completed")
+
+	if a.cfg.CheckIn {
+		a.checkIn(ctx)
+	}
+}
+
+// checkIn reports the templates of the current bundle with the checksums of
+// their files on disk. Failures are logged and retried on the next sync.
+func (a *Agent) checkIn(ctx context.Context) {
+	a.mu.RLock()
+	files, checksum := a.files, a.checksum
+	a.mu.RUnlock()
+
+	req := model.CheckInRequest{
+		AgentVersion:   a.version,
+		BundleChecksum: checksum,
+		Templates:      make([]model.AppliedTemplate, 0, len(files)),
+	}
+	for _, file := range files {
+		sum, err := a.writer.Checksum(file.FileName)
+		if err != nil {
+			// A missing file is reported with the checksum of nothing
+			sum = hex.EncodeToString(emptySum[:])
+			a.logger.Warn().Err(err).Str("file", file.FileName).Msg("Failed to hash applied file")
+		}
+		req.Templates = append(req.Templates, model.AppliedTemplate{
+			TemplateID: file.TemplateID,
+			Version:    file.Version,
+			Checksum:   sum,
+		})
+	}
+
+	if err := a.client.CheckIn(ctx, a.cfg.Environment, req); err != nil {
+		checkInsTotal.WithLabelValues("error").Inc()
+		a.logger.Warn().Err(err).Str("environment", a.cfg.Environment).Msg("Check-in failed")
+		return
+	}
+	checkInsTotal.WithLabelValues("success").Inc()
+}
+
+func (a *Agent) sync(ctx context.Context) (string, error) {
+	a.mu.RLock()
+	etag := a.etag
+	a.mu.RUnlock()
+
+	bundle, newETag, err := a.client.FetchBundle(ctx, a.cfg.Environment, a.cfg.Tags, a.cfg.Selector, etag)
+	if err != nil {
+		return "error", err
+	}
+
+	result := "unchanged"
+	if bundle != nil {
+		changed, err := a.writer.Apply(bundle)
+		if len(changed) > 0 {
+			filesChangedTotal.Add(float64(len(changed)))
+			a.mu.Lock()
+			a.pendingReload = true
+			a.mu.Unlock()
+		}
+		if err != nil {
+			return "error", err
+		}
+
+		filesManaged.Set(float64(len(bundle.Files)))
+		// Check-ins hash the files on disk, so their content is not kept
+		files := make([]model.BundleFile, len(bundle.Files))
+		for i, file := range bundle.Files {
+			file.Content = ""
+			files[i] = file
+		}
+		a.mu.Lock()
+		a.etag = newETag
+		a.checksum = bundle.Checksum
+		a.files = files
+		a.mu.Unlock()
+
+		if len(changed) > 0 {
+			result = "updated"
+			a.logger.Info().
+				Str("checksum", bundle.Checksum).
+				Strs("files", changed).
+				Msg("Configuration files updated")
+		}
+	}
+
+	// A failed reload is retried on the next sync even if nothing changed since
+	a.mu.RLock()
+	pending := a.pendingReload
+	a.mu.RUnlock()
+	if pending && a.reloader.Enabled() {
+		if err := a.reloader.Reload(ctx); err != nil {
+			reloadsTotal.WithLabelValues("error").Inc()
+			return "error", err
+		}
+		reloadsTotal.WithLabelValues("success").Inc()
+		a.logger.Info().Msg("Reload triggered")
+	}
+
+	a.mu.Lock()
+	a.pendingReload = false
+	a.mu.Unlock()
+
+	return result, nil
+}
+
+// Health godoc
+// @Summary Config agent health
+// @Description Reports healthy while the last successful sync is more recent than the staleness threshold
+// @Tags health
+// @Produce json
+// @Success 200 {object} model.HealthResponse
+// @Failure 503 {object} model.HealthResponse
+// @Router /health [get]
+func (a *Agent) Health(c *gin.Context) {
+	a.mu.RLock()
+	lastSync, lastSuccess, lastErr, checksum := a.lastSync, a.lastSuccess, a.lastError, a.checksum
+	a.mu.RUnlock()
+
+	info := model.ServiceHealthInfo{Status: "healthy"}
+	if !lastSync.IsZero() {
+		info.LastCheck = lastSync.Format(time.RFC3339)
+	}
+
+	switch {
+	case lastSuccess.IsZero():
+		info.Status = "unhealthy"
+		info.Message = "No successful sync yet"
+	case time.Since(lastSuccess) > a.cfg.StaleAfter:
+		info.Status = "unhealthy"
+		info.Message = "Last successful sync at " + lastSuccess.Format(time.RFC3339)
+	default:
+		info.Message = "Bundle " + checksum + " in sync"
+	}
+	if lastErr != nil && info.Status == "healthy" {
+		info.Message = "Serving last good bundle: " + lastErr.Error()
+	} else if lastErr != nil {
+		info.Message += ": " + lastErr.Error()
+	}
+
+	response := model.HealthResponse{
+		Status:   info.Status,
+		Version:  a.version,
+		Services: map[string]model.ServiceHealthInfo{"sync": info},
+	}
+
+	if info.Status == "healthy" {
+		c.JSON(http.StatusOK, response)
+	} else {
+		c.JSON(http.StatusServiceUnavailable, response)
+	}
+}
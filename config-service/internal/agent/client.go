@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/company/config-service/internal/model"
+)
+
+// Client fetches rendered bundles from the config service
+type Client struct {
+	baseURL    string
+	authToken  string
+	instanceID string
+	httpClient *http.Client
+}
+
+// NewClient creates a new bundle client identifying itself by instanceID,
+// which may be empty
+func NewClient(baseURL, authToken, instanceID string, timeout time.Duration) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		authToken:  authToken,
+		instanceID: instanceID,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// FetchBundle requests the bundle of an environment matched by the tag list
+// and selector expression, together with its ETag.
+// When etag still matches, the server answers 304 and the returned bundle is nil.
+func (c *Client) FetchBundle(ctx context.Context, environment string, tags []string, selector, etag string) (*model.BundleResponse, string, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/environments/%s/bundle", c.baseURL, url.PathEscape(environment))
+	query := url.Values{}
+	if len(tags) > 0 {
+		query.Set("tags", strings.Join(tags, ","))
+	}
+	if selector != "" {
+		query.Set("selector", selector)
+	}
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build bundle request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if c.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.authToken)
+	}
+	if c.instanceID != "" {
+		req.Header.Set("X-Instance-ID", c.instanceID)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch bundle: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, etag, nil
+	case http.StatusOK:
+	default:
+		var errResp model.ErrorResponse
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+			return nil, "", fmt.Errorf("bundle request failed with status %d: %s %s",
+				resp.StatusCode, errResp.Error, errResp.Message)
+		}
+		return nil, "", fmt.Errorf("bundle request failed with status %d", resp.StatusCode)
+	}
+
+	var bundle model.BundleResponse
+	if err := json.NewDecoder(resp.Body).Decode(&bundle); err != nil {
+		return nil, "", fmt.Errorf("failed to decode bundle: %w", err)
+	}
+	return &bundle, resp.Header.Get("ETag"), nil
+}
+
+// CheckIn reports the templates applied from the bundle of an environment,
+// so the server can detect drift
+func (c *Client) CheckIn(ctx context.Context, environment string, checkIn model.CheckInRequest) error {
+	endpoint := fmt.Sprintf("%s/api/v1/environments/%s/check-ins", c.baseURL, url.PathEscape(environment))
+	if checkIn.InstanceID == "" {
+		checkIn.InstanceID = c.instanceID
+	}
+	body, err := json.Marshal(checkIn)
+	if err != nil {
+		return fmt.Errorf("failed to encode check-in: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build check-in request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.authToken)
+	}
+	if c.instanceID != "" {
+		req.Header.Set("X-Instance-ID", c.instanceID)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to check in: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		var errResp model.ErrorResponse
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+			return fmt.Errorf("check-in failed with status %d: %s %s",
+				resp.StatusCode, errResp.Error, errResp.Message)
+		}
+		return fmt.Errorf("check-in failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
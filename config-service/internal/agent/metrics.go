@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Agent metrics
+var (
+	syncsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "config_agent_syncs_total",
+			Help: "Total number of bundle sync attempts by result",
+		},
+		[]string{"result"},
+	)
+
+	syncDuration = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "config_agent_sync_duration_seconds",
+			Help:    "Duration of bundle syncs in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+
+	lastSyncTimestamp = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "config_agent_last_sync_timestamp_seconds",
+			Help: "Unix timestamp of the last sync attempt",
+		},
+	)
+
+	lastSuccessTimestamp = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "config_agent_last_success_timestamp_seconds",
+			Help: "Unix timestamp of the last successful sync",
+		},
+	)
+
+	lastSyncSuccess = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "config_agent_last_sync_success",
+			Help: "Whether the last sync attempt succeeded (1) or failed (0)",
+		},
+	)
+
+	filesManaged = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "config_agent_files_managed",
+			Help: "Number of files materialized from the current bundle",
+		},
+	)
+
+	filesChangedTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "config_agent_files_changed_total",
+			Help: "Total number of files written or removed",
+		},
+	)
+
+	checkInsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "config_agent_check_ins_total",
+			Help: "Total number of check-ins reporting the applied templates by status",
+		},
+		[]string{"status"},
+	)
+
+	reloadsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "config_agent_reloads_total",
+			Help: "Total number of reload attempts by status",
+		},
+		[]string{"status"},
+	)
+)
@@ -0,0 +1,99 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var signals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// Reloader notifies the application that its configuration files changed,
+// either by running a command or by signalling a process from a PID file
+type Reloader struct {
+	command string
+	signal  syscall.Signal
+	pidFile string
+	timeout time.Duration
+}
+
+// NewReloader creates a new reloader. Both command and signal may be empty,
+// in which case Reload is a no-op.
+func NewReloader(command, signal, pidFile string, timeout time.Duration) (*Reloader, error) {
+	r := &Reloader{
+		command: command,
+		pidFile: pidFile,
+		timeout: timeout,
+	}
+
+	if signal != "" {
+		sig, ok := signals[strings.TrimPrefix(strings.ToUpper(signal), "SIG")]
+		if !ok {
+			return nil, fmt.Errorf("unsupported reload signal %q", signal)
+		}
+		if pidFile == "" {
+			return nil, fmt.Errorf("reload signal %s requires a PID file", signal)
+		}
+		r.signal = sig
+	}
+
+	return r, nil
+}
+
+// Enabled reports whether any reload action is configured
+func (r *Reloader) Enabled() bool {
+	return r.command != "" || r.signal != 0
+}
+
+// Reload signals the process and runs the reload command, in that order
+func (r *Reloader) Reload(ctx context.Context) error {
+	if r.signal != 0 {
+		if err := r.sendSignal(); err != nil {
+			return err
+		}
+	}
+
+	if r.command != "" {
+		ctx, cancel := context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+
+		out, err := exec.CommandContext(ctx, "/bin/sh", "-c", r.command).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("reload command failed: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	return nil
+}
+
+func (r *Reloader) sendSignal() error {
+	data, err := os.ReadFile(r.pidFile)
+	if err != nil {
+		return fmt.Errorf("failed to read PID file: %w", err)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return fmt.Errorf("invalid PID in %s", r.pidFile)
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return fmt.Errorf("failed to find process %d: %w", pid, err)
+	}
+	if err := process.Signal(r.signal); err != nil {
+		return fmt.Errorf("failed to signal process %d: %w", pid, err)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package agent
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/company/config-service/internal/model"
+)
+
+// manifestName is the file recording which files in the target directory are
+// owned by the agent, so that files of removed templates can be pruned
+const manifestName = ".config-agent-manifest.json"
+
+// Writer materializes bundle files into a target directory
+type Writer struct {
+	dir       string
+	fileMode  os.FileMode
+	dirMode   os.FileMode
+	fileModes map[string]os.FileMode
+	prune     bool
+}
+
+// NewWriter creates a new writer. Modes are octal strings such as "0640";
+// fileModes overrides the default mode per file name.
+func NewWriter(dir, fileMode, dirMode string, fileModes map[string]string, prune bool) (*Writer, error) {
+	if dir == "" {
+		return nil, errors.New("target directory is required")
+	}
+
+	fm, err := parseMode(fileMode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file mode: %w", err)
+	}
+	dm, err := parseMode(dirMode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid directory mode: %w", err)
+	}
+
+	overrides := make(map[string]os.FileMode, len(fileModes))
+	for name, mode := range fileModes {
+		m, err := parseMode(mode)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file mode for %s: %w", name, err)
+		}
+		overrides[name] = m
+	}
+
+	return &Writer{
+		dir:       dir,
+		fileMode:  fm,
+		dirMode:   dm,
+		fileModes: overrides,
+		prune:     prune,
+	}, nil
+}
+
+// Apply writes every bundle file whose content or mode differs from disk and
+// removes files written by a previous bundle that are no longer part of it.
+// It returns the names of files that were written or removed.
+func (w *Writer) Apply(bundle *model.BundleResponse) ([]string, error) {
+	if err := os.MkdirAll(w.dir, w.dirMode); err != nil {
+		return nil, fmt.Errorf("failed to create target directory: %w", err)
+	}
+
+	var changed []string
+	current := make(map[string]bool, len(bundle.Files))
+	for _, file := range bundle.Files {
+		if err := validateFileName(file.FileName); err != nil {
+			return changed, err
+		}
+		current[file.FileName] = true
+
+		wrote, err := w.writeFile(file.FileName, []byte(file.Content), w.modeFor(file.FileName))
+		if err != nil {
+			return changed, err
+		}
+		if wrote {
+			changed = append(changed, file.FileName)
+		}
+	}
+
+	previous, err := w.readManifest()
+	if err != nil {
+		return changed, err
+	}
+	if w.prune {
+		for _, name := range previous {
+			if current[name] || validateFileName(name) != nil {
+				continue
+			}
+			err := os.Remove(filepath.Join(w.dir, name))
+			if err != nil && !os.IsNotExist(err) {
+				return changed, fmt.Errorf("failed to remove stale file %s: %w", name, err)
+			}
+			if err == nil {
+				changed = append(changed, name)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(current))
+	for name := range current {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if err := w.writeManifest(names); err != nil {
+		return changed, err
+	}
+
+	return changed, nil
+}
+
+// Checksum returns the hex encoded SHA-256 of the file name as it is on
+// disk, which differs from the bundle when the file was edited in place
+func (w *Writer) Checksum(name string) (string, error) {
+	if err := validateFileName(name); err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(filepath.Join(w.dir, name))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (w *Writer) modeFor(name string) os.FileMode {
+	if mode, ok := w.fileModes[name]; ok {
+		return mode
+	}
+	return w.fileMode
+}
+
+// writeFile atomically replaces name with content unless it is already up to date
+func (w *Writer) writeFile(name string, content []byte, mode os.FileMode) (bool, error) {
+	path := filepath.Join(w.dir, name)
+
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm() == mode {
+		existing, err := os.ReadFile(path)
+		if err == nil && bytes.Equal(existing, content) {
+			return false, nil
+		}
+	}
+
+	if err := atomicWrite(path, content, mode); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return true, nil
+}
+
+func (w *Writer) readManifest() ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(w.dir, manifestName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return names, nil
+}
+
+func (w *Writer) writeManifest(names []string) error {
+	data, err := json.Marshal(names)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := atomicWrite(filepath.Join(w.dir, manifestName), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// atomicWrite writes content to a temporary file in the same directory,
+// syncs it and renames it over path so readers never observe partial files
+func atomicWrite(path string, content []byte, mode os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return err
+	}
+
+	// Persist the rename itself
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// validateFileName rejects names that would escape the target directory
+func validateFileName(name string) error {
+	if name == "" || name == manifestName || filepath.Base(name) != name || name == "." || name == ".." {
+		return fmt.Errorf("refusing to write unsafe file name %q", name)
+	}
+	return nil
+}
+
+func parseMode(mode string) (os.FileMode, error) {
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0, err
+	}
+	if m > 0o777 {
+		return 0, fmt.Errorf("mode %s out of range", mode)
+	}
+	return os.FileMode(m), nil
+}
@@ -0,0 +1,168 @@
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/company/config-service/internal/archive"
+	"github.com/company/config-service/internal/auth"
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportSize bounds the request body of an import
+const maxImportSize = 64 << 20
+
+// Handler handles bulk export and import of the configuration store
+type Handler struct {
+	archives *service.ArchiveService
+	logger   *logger.Logger
+}
+
+// New creates a new archive handler
+func New(archives *service.ArchiveService, log *logger.Logger) *Handler {
+	return &Handler{
+		archives: archives,
+		logger:   log,
+	}
+}
+
+// Export godoc
+// @Summary Export the configuration store
+// @Description Exports all environments, tags, templates and template tag links into a versioned archive.
+// @Description Records reference each other by environment slug, tag name and template name.
+// @Description Requires the archive:admin permission. Secret values are masked unless the caller also holds
+// @Description secrets:read, in which case their envelopes are exported for a store sharing the master keyring.
+// @Tags archive
+// @Produce json
+// @Produce application/gzip
+// @Security BearerAuth
+// @Param format query string false "Archive encoding" Enums(json, tar.gz) default(json)
+// @Success 200 {object} model.Archive
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/archive [get]
+func (h *Handler) Export(c *gin.Context) {
+	encoding := c.DefaultQuery("format", archive.EncodingJSON)
+	if encoding != archive.EncodingJSON && encoding != archive.EncodingTarGz {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_format",
+			Message: "format must be json or tar.gz",
+		})
+		return
+	}
+
+	sealed := auth.Allowed(c.Request.Context(), auth.SecretsRead)
+	a, err := h.archives.Export(c.Request.Context(), sealed)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to export archive")
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := archive.Write(&buf, a, encoding); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to encode archive")
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+		return
+	}
+
+	contentType := "application/json"
+	if encoding == archive.EncodingTarGz {
+		contentType = "application/gzip"
+	}
+	filename := "config-archive-" + a.ExportedAt.Format("20060102T150405Z") + "." + encoding
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// Import godoc
+// @Summary Import a configuration archive
+// @Description Imports an archive produced by the export endpoint, as JSON or tar.gz, in a single transaction.
+// @Description Existing records are matched by environment slug, tag name and template name within its environment.
+// @Description With strategy=skip existing records are kept, with overwrite they are replaced and with fail the whole import is rejected.
+// @Description Requires the archive:admin permission. Templates written are attributed to the caller.
+// @Description Masked secret values are rejected unless they overwrite a template holding the secret.
+// @Tags archive
+// @Accept json
+// @Accept application/gzip
+// @Produce json
+// @Security BearerAuth
+// @Param strategy query string false "Conflict strategy" Enums(skip, overwrite, fail) default(skip)
+// @Param dry_run query bool false "Compute the summary without writing"
+// @Success 200 {object} model.ImportSummary
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 413 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/archive/import [post]
+func (h *Handler) Import(c *gin.Context) {
+	strategy := model.ConflictStrategy(c.DefaultQuery("strategy", string(model.ConflictSkip)))
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	a, err := archive.Read(body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, model.ErrorResponse{
+				Error:   "archive_too_large",
+				Message: "Archive exceeds " + strconv.Itoa(maxImportSize>>20) + " MiB",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_archive",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	actor := auth.FromContext(c.Request.Context()).Name
+	start := time.Now()
+	summary, err := h.archives.Import(c.Request.Context(), a, strategy, dryRun, actor)
+	if err != nil {
+		var validationErr *service.ValidationError
+		var conflictErr *service.ConflictError
+		switch {
+		case errors.As(err, &validationErr):
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_archive",
+				Message: validationErr.Error(),
+			})
+		case errors.As(err, &conflictErr):
+			details := make(map[string]string, len(conflictErr.Conflicts))
+			for _, record := range conflictErr.Conflicts {
+				details[record] = "already exists"
+			}
+			c.JSON(http.StatusConflict, model.ErrorResponse{
+				Error:   "import_conflict",
+				Message: "Archive contains records that already exist",
+				Details: details,
+			})
+		default:
+			h.logger.Error().Err(err).Msg("Failed to import archive")
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+		}
+		return
+	}
+
+	h.logger.Info().
+		Str("strategy", string(strategy)).
+		Bool("dry_run", dryRun).
+		Str("actor", actor).
+		Str("duration", time.Since(start).String()).
+		Interface("summary", summary).
+		Msg("Archive imported")
+
+	c.JSON(http.StatusOK, summary)
+}
@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves the audit log
+type Handler struct {
+	audit  *service.AuditService
+	logger *logger.Logger
+}
+
+// New creates a new audit handler
+func New(audit *service.AuditService, log *logger.Logger) *Handler {
+	return &Handler{
+		audit:  audit,
+		logger: log,
+	}
+}
+
+// List godoc
+// @Summary List audit log entries
+// @Description Lists audit entries newest first, optionally filtered by action, entity and actor.
+// @Tags audit
+// @Produce json
+// @Param action query string false "Action, e.g. tag.merge"
+// @Param entity_type query string false "Entity type, e.g. tag"
+// @Param entity_id query int false "Entity ID"
+// @Param actor query string false "Actor"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Param cursor query string false "Cursor from next_cursor or prev_cursor of a previous page; overrides page"
+// @Success 200 {object} model.AuditListResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/audit [get]
+func (h *Handler) List(c *gin.Context) {
+	var filter model.AuditFilter
+	var page model.PaginationParams
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+	if err := c.ShouldBindQuery(&page); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	resp, err := h.audit.List(c.Request.Context(), filter, page)
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: validationErr.Error()})
+			return
+		}
+		h.logger.Error().Err(err).Msg("Failed to list audit entries")
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
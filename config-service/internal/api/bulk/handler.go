@@ -0,0 +1,126 @@
+package bulk
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/company/config-service/internal/auth"
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Handler handles bulk template and tag operations
+type Handler struct {
+	bulk   *service.BulkService
+	logger *logger.Logger
+}
+
+// New creates a new bulk handler
+func New(bulk *service.BulkService, log *logger.Logger) *Handler {
+	return &Handler{
+		bulk:   bulk,
+		logger: log,
+	}
+}
+
+// Templates godoc
+// @Summary Create, update and delete templates in bulk
+// @Description Executes up to 1000 create, update and delete items in order within one transaction and reports a result per item.
+// @Description Failed items are rolled back individually; with atomic=true the first failure rolls back the whole request. Templates are created and updated by the caller.
+// @Tags bulk
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.BulkTemplateRequest true "Bulk items"
+// @Success 200 {object} model.BulkResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 422 {object} model.BulkResponse "Atomic request rolled back"
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/templates/bulk [post]
+func (h *Handler) Templates(c *gin.Context) {
+	var req model.BulkTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	resp, err := h.bulk.Templates(c.Request.Context(), req, auth.FromContext(c.Request.Context()).Name)
+	h.respond(c, resp, err)
+}
+
+// Tags godoc
+// @Summary Attach and detach tags in bulk
+// @Description Attaches and detaches tags, by name, on every template matching the filter within one transaction.
+// @Description The filter must contain at least one criterion and may match up to 1000 templates.
+// @Tags bulk
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.BulkTagRequest true "Filter and tags"
+// @Success 200 {object} model.BulkResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 422 {object} model.BulkResponse "Atomic request rolled back"
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/templates/bulk/tags [post]
+func (h *Handler) Tags(c *gin.Context) {
+	var req model.BulkTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	resp, err := h.bulk.Tags(c.Request.Context(), req, auth.FromContext(c.Request.Context()).Name)
+	h.respond(c, resp, err)
+}
+
+// Status godoc
+// @Summary Activate or deactivate templates in bulk
+// @Description Sets the active flag of every template matching the filter within one transaction.
+// @Description The filter must contain at least one criterion and may match up to 1000 templates.
+// @Tags bulk
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.BulkStatusRequest true "Filter and status"
+// @Success 200 {object} model.BulkResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 422 {object} model.BulkResponse "Atomic request rolled back"
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/templates/bulk/status [post]
+func (h *Handler) Status(c *gin.Context) {
+	var req model.BulkStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	resp, err := h.bulk.Status(c.Request.Context(), req, auth.FromContext(c.Request.Context()).Name)
+	h.respond(c, resp, err)
+}
+
+func (h *Handler) respond(c *gin.Context, resp *model.BulkResponse, err error) {
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: validationErr.Error(),
+			})
+			return
+		}
+		h.logger.Error().Err(err).Msg("Bulk operation failed")
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+		return
+	}
+
+	if !resp.Committed {
+		c.JSON(http.StatusUnprocessableEntity, resp)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
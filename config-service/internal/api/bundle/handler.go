@@ -0,0 +1,211 @@
+package bundle
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/render"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/selector"
+	"github.com/company/config-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves rendered template bundles
+type Handler struct {
+	bundles *service.BundleService
+	logger  *logger.Logger
+}
+
+// New creates a new bundle handler
+func New(bundles *service.BundleService, log *logger.Logger) *Handler {
+	return &Handler{
+		bundles: bundles,
+		logger:  log,
+	}
+}
+
+// Get godoc
+// @Summary Get rendered template bundle
+// @Description Renders all active templates of an environment matched by a tag selector such as `database AND NOT deprecated`.
+// @Description The legacy tags parameter is equivalent to joining its names with AND and is combined with selector.
+// @Description Secret values are decrypted only for tokens holding the secrets:read permission; otherwise templates using them fail with 403.
+// @Description Templates under rollout are served in their candidate version to the share of clients picked by a hash of the instance ID, and in their stable version to the others and to clients sending none.
+// @Description The bundle checksum is returned as ETag; send it back in If-None-Match to receive 304 when nothing changed.
+// @Description With as_of the bundle is rendered from the templates, tags and rollouts as they were at that moment, reproducing what the client received.
+// @Tags bundles
+// @Accept json
+// @Produce json
+// @Param slug path string true "Environment slug"
+// @Param selector query string false "Tag selector expression"
+// @Param tags query string false "Comma separated tag names, all must match"
+// @Param X-Instance-ID header string false "ID of the requesting client instance"
+// @Param instance_id query string false "ID of the requesting client instance, when the header is not sent"
+// @Param as_of query string false "RFC 3339 timestamp of the state to render"
+// @Success 200 {object} model.BundleResponse
+// @Success 304 "Bundle not modified"
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 422 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments/{slug}/bundle [get]
+func (h *Handler) Get(c *gin.Context) {
+	slug := c.Param("slug")
+	tags := parseTags(c.Query("tags"))
+	expr, ok := parseSelector(c, tags)
+	if !ok {
+		return
+	}
+	at, ok := parseAsOf(c)
+	if !ok {
+		return
+	}
+
+	env, rendered, err := h.bundles.AsOf(at).Render(c.Request.Context(), slug, expr, instanceID(c))
+	if err != nil {
+		h.renderFailed(c, slug, err)
+		return
+	}
+
+	files := make([]model.BundleFile, 0, len(rendered))
+	for _, r := range rendered {
+		files = append(files, model.BundleFile{
+			TemplateID: r.Template.ID,
+			Name:       r.Template.Name,
+			FileName:   r.FileName,
+			Format:     r.Template.Format,
+			Version:    r.Template.Version,
+			Content:    string(r.Content),
+			Checksum:   r.Checksum,
+			Variant:    r.Variant,
+		})
+	}
+
+	checksum := bundleChecksum(files)
+	etag := `"` + checksum + `"`
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.BundleResponse{
+		Environment: env.Slug,
+		Tags:        tags,
+		Selector:    selectorString(expr),
+		Checksum:    checksum,
+		GeneratedAt: time.Now().UTC(),
+		AsOf:        at,
+		Files:       files,
+	})
+}
+
+// renderFailed maps bundle service errors to HTTP responses
+func (h *Handler) renderFailed(c *gin.Context, slug string, err error) {
+	var renderErr *render.Error
+	var permissionErr *service.PermissionError
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "environment_not_found",
+			Message: "Environment " + slug + " does not exist",
+		})
+	case errors.As(err, &permissionErr):
+		details := map[string]string{"permission": permissionErr.Permission}
+		if errors.As(err, &renderErr) {
+			details["template"] = renderErr.Template
+		}
+		c.JSON(http.StatusForbidden, model.ErrorResponse{
+			Error:   "secrets_forbidden",
+			Message: "Rendering secret values requires the " + permissionErr.Permission + " permission",
+			Details: details,
+		})
+	case errors.As(err, &renderErr):
+		c.JSON(http.StatusUnprocessableEntity, model.ErrorResponse{
+			Error:   "render_failed",
+			Message: renderErr.Err.Error(),
+			Details: map[string]string{"template": renderErr.Template},
+		})
+	default:
+		h.logger.Error().Err(err).Str("environment", slug).Msg("Failed to render bundle")
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+	}
+}
+
+// parseSelector combines the selector query parameter with the legacy tag
+// list. It writes a 400 response and reports false when the selector is invalid.
+func parseSelector(c *gin.Context, tags []string) (selector.Expr, bool) {
+	expr, err := selector.Parse(c.Query("selector"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_selector",
+			Message: err.Error(),
+		})
+		return nil, false
+	}
+
+	legacy := selector.All(tags...)
+	switch {
+	case expr == nil:
+		return legacy, true
+	case legacy == nil:
+		return expr, true
+	}
+	return selector.And{L: legacy, R: expr}, true
+}
+
+// parseAsOf reads the as_of query parameter, writing a 400 response when it
+// is invalid
+func parseAsOf(c *gin.Context) (*time.Time, bool) {
+	at, err := service.ParseAsOf(c.Query("as_of"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return nil, false
+	}
+	return at, true
+}
+
+// instanceID returns the ID the client identifies itself with, from the
+// X-Instance-ID header or the instance_id query parameter
+func instanceID(c *gin.Context) string {
+	if id := strings.TrimSpace(c.GetHeader("X-Instance-ID")); id != "" {
+		return id
+	}
+	return strings.TrimSpace(c.Query("instance_id"))
+}
+
+// selectorString returns the canonical form of a selector, empty for nil
+func selectorString(expr selector.Expr) string {
+	if expr == nil {
+		return ""
+	}
+	return expr.String()
+}
+
+// parseTags splits a comma separated tag list, dropping empty entries
+func parseTags(raw string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(raw, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// bundleChecksum derives a stable checksum from file names and contents
+func bundleChecksum(files []model.BundleFile) string {
+	var b strings.Builder
+	for _, f := range files {
+		b.WriteString(f.FileName)
+		b.WriteByte(0)
+		b.WriteString(f.Checksum)
+		b.WriteByte('\n')
+	}
+	return render.Checksum([]byte(b.String()))
+}
@@ -0,0 +1,76 @@
+package bundle
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/company/config-service/internal/kubernetes"
+	"github.com/company/config-service/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+// Kubernetes godoc
+// @Summary Export templates as Kubernetes manifests
+// @Description Renders all active templates of an environment matched by a tag selector into a multi-document YAML stream.
+// @Description Each template becomes a ConfigMap, or an Opaque Secret when it carries one of the secret tags.
+// @Description Secret values are decrypted only for tokens holding the secrets:read permission; otherwise templates using them fail with 403.
+// @Description Objects are labelled with the environment slug and one tag.config-service/<tag> label per tag, and annotated with template ID, version and checksum.
+// @Description With as_of the manifests are rendered from the state at that moment.
+// @Description Templates whose names map to the same object name or data key are rejected with 409.
+// @Tags bundles
+// @Produce application/yaml
+// @Param slug path string true "Environment slug"
+// @Param selector query string false "Tag selector expression"
+// @Param tags query string false "Comma separated tag names, all must match"
+// @Param namespace query string false "Namespace set on every object"
+// @Param name_prefix query string false "Prefix prepended to object names"
+// @Param secret_tags query string false "Comma separated tag names exported as Secrets" default(sensitive)
+// @Param X-Instance-ID header string false "ID of the client instance choosing the variant of templates under rollout"
+// @Param instance_id query string false "ID of the client instance, when the header is not sent"
+// @Param as_of query string false "RFC 3339 timestamp of the state to render"
+// @Success 200 {string} string "Multi-document YAML"
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 422 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments/{slug}/export/kubernetes [get]
+func (h *Handler) Kubernetes(c *gin.Context) {
+	slug := c.Param("slug")
+	expr, ok := parseSelector(c, parseTags(c.Query("tags")))
+	if !ok {
+		return
+	}
+	at, ok := parseAsOf(c)
+	if !ok {
+		return
+	}
+
+	env, rendered, err := h.bundles.AsOf(at).Render(c.Request.Context(), slug, expr, instanceID(c))
+	if err != nil {
+		h.renderFailed(c, slug, err)
+		return
+	}
+
+	manifests, err := kubernetes.Manifests(env, rendered, kubernetes.Options{
+		Namespace:  c.Query("namespace"),
+		NamePrefix: c.Query("name_prefix"),
+		SecretTags: parseTags(c.Query("secret_tags")),
+	})
+	if err != nil {
+		var conflictErr *kubernetes.NameConflictError
+		if errors.As(err, &conflictErr) {
+			c.JSON(http.StatusConflict, model.ErrorResponse{
+				Error:   "name_conflict",
+				Message: conflictErr.Error(),
+			})
+			return
+		}
+		h.logger.Error().Err(err).Str("environment", slug).Msg("Failed to generate Kubernetes manifests")
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/yaml; charset=utf-8", manifests)
+}
@@ -0,0 +1,282 @@
+package change
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/company/config-service/internal/auth"
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves change requests of protected environments
+type Handler struct {
+	changes *service.ChangeService
+	logger  *logger.Logger
+}
+
+// New creates a new change request handler
+func New(changes *service.ChangeService, log *logger.Logger) *Handler {
+	return &Handler{
+		changes: changes,
+		logger:  log,
+	}
+}
+
+// List godoc
+// @Summary List change requests
+// @Description Lists change requests newest first with their diff and approvals, optionally filtered by status, environment, template and author.
+// @Tags change-requests
+// @Produce json
+// @Param status query string false "Status" Enums(open, applied, rejected)
+// @Param environment query string false "Environment slug"
+// @Param template_id query int false "Template ID"
+// @Param author query string false "Author"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Param cursor query string false "Cursor from next_cursor or prev_cursor of a previous page; overrides page"
+// @Success 200 {object} model.ChangeRequestListResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/change-requests [get]
+func (h *Handler) List(c *gin.Context) {
+	var filter model.ChangeRequestFilter
+	var page model.PaginationParams
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+	if err := c.ShouldBindQuery(&page); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	resp, err := h.changes.List(c.Request.Context(), filter, page)
+	if err != nil {
+		h.writeError(c, err, "Failed to list change requests")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Get godoc
+// @Summary Get a change request
+// @Description Returns a change request with its diff, approvals and comment threads. Secret values in the diff are masked.
+// @Tags change-requests
+// @Produce json
+// @Param id path int true "Change request ID"
+// @Success 200 {object} model.ChangeRequest
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/change-requests/{id} [get]
+func (h *Handler) Get(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	cr, err := h.changes.Get(c.Request.Context(), id)
+	if err != nil {
+		h.writeError(c, err, "Failed to get change request")
+		return
+	}
+	c.JSON(http.StatusOK, cr)
+}
+
+// Create godoc
+// @Summary Propose a template change
+// @Description Opens a change request creating, updating or deleting a template of a protected environment; the caller is its author.
+// @Description The change is applied once the number of users the environment requires, never including the author, approved it.
+// @Tags change-requests
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.CreateChangeRequest true "Proposed change"
+// @Success 201 {object} model.ChangeRequest
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/change-requests [post]
+func (h *Handler) Create(c *gin.Context) {
+	var req model.CreateChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	author := auth.FromContext(c.Request.Context()).Name
+	cr, err := h.changes.Create(c.Request.Context(), req, author)
+	if err != nil {
+		h.writeError(c, err, "Failed to create change request")
+		return
+	}
+
+	h.logger.Info().
+		Int64("change_request", cr.ID).
+		Str("op", string(cr.Op)).
+		Str("author", author).
+		Msg("Change request opened")
+	c.JSON(http.StatusCreated, cr)
+}
+
+// Approve godoc
+// @Summary Approve a change request
+// @Description Approves an open change request. The approval completing the required number applies the change atomically;
+// @Description when the template changed since the request was opened, 409 is returned, and during a freeze window 423; the approval is then not recorded.
+// @Tags change-requests
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Change request ID"
+// @Success 200 {object} model.ChangeRequest
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 423 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/change-requests/{id}/approve [post]
+func (h *Handler) Approve(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	approver := auth.FromContext(c.Request.Context()).Name
+	cr, err := h.changes.Approve(c.Request.Context(), id, approver)
+	if err != nil {
+		h.writeError(c, err, "Failed to approve change request")
+		return
+	}
+
+	h.logger.Info().
+		Int64("change_request", cr.ID).
+		Str("approver", approver).
+		Str("status", string(cr.Status)).
+		Msg("Change request approved")
+	c.JSON(http.StatusOK, cr)
+}
+
+// Reject godoc
+// @Summary Reject a change request
+// @Description Closes an open change request without applying it.
+// @Tags change-requests
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Change request ID"
+// @Param request body model.RejectChangeRequest true "Reason"
+// @Success 200 {object} model.ChangeRequest
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/change-requests/{id}/reject [post]
+func (h *Handler) Reject(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+	var req model.RejectChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	actor := auth.FromContext(c.Request.Context()).Name
+	cr, err := h.changes.Reject(c.Request.Context(), id, req, actor)
+	if err != nil {
+		h.writeError(c, err, "Failed to reject change request")
+		return
+	}
+
+	h.logger.Info().
+		Int64("change_request", cr.ID).
+		Str("actor", actor).
+		Msg("Change request rejected")
+	c.JSON(http.StatusOK, cr)
+}
+
+// Comment godoc
+// @Summary Comment on a change request
+// @Description Adds a comment to a change request, or a reply to one of its comments when parent_id is set.
+// @Tags change-requests
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Change request ID"
+// @Param request body model.CreateChangeCommentRequest true "Comment"
+// @Success 201 {object} model.ChangeComment
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/change-requests/{id}/comments [post]
+func (h *Handler) Comment(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+	var req model.CreateChangeCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	author := auth.FromContext(c.Request.Context()).Name
+	comment, err := h.changes.Comment(c.Request.Context(), id, req, author)
+	if err != nil {
+		h.writeError(c, err, "Failed to comment on change request")
+		return
+	}
+	c.JSON(http.StatusCreated, comment)
+}
+
+// writeError maps service errors to responses, logging unexpected ones
+func (h *Handler) writeError(c *gin.Context, err error, msg string) {
+	var validationErr *service.ValidationError
+	var conflictErr *service.ConflictError
+	var permissionErr *service.PermissionError
+	var frozenErr *service.FrozenError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: validationErr.Error()})
+	case errors.As(err, &frozenErr):
+		c.JSON(http.StatusLocked, model.ErrorResponse{Error: "environment_frozen", Message: frozenErr.Error()})
+	case errors.As(err, &permissionErr):
+		c.JSON(http.StatusForbidden, model.ErrorResponse{Error: "forbidden", Message: permissionErr.Message})
+	case errors.As(err, &conflictErr):
+		c.JSON(http.StatusConflict, model.ErrorResponse{
+			Error:   "change_request_conflict",
+			Message: conflictErr.Conflicts[0],
+		})
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "change_request_not_found",
+			Message: "Change request not found",
+		})
+	default:
+		h.logger.Error().Err(err).Msg(msg)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+	}
+}
+
+// parseID reads the id path parameter, writing a 400 response when invalid
+func parseID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "id must be a positive integer",
+		})
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,194 @@
+package deployment
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/company/config-service/internal/auth"
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves scheduled deployments
+type Handler struct {
+	schedules *service.ScheduleService
+	logger    *logger.Logger
+}
+
+// New creates a new scheduled deployment handler
+func New(schedules *service.ScheduleService, log *logger.Logger) *Handler {
+	return &Handler{
+		schedules: schedules,
+		logger:    log,
+	}
+}
+
+// List godoc
+// @Summary List scheduled deployments
+// @Description Lists scheduled deployments earliest run first, optionally filtered by status, environment and template.
+// @Tags deployments
+// @Produce json
+// @Param status query string false "Status" Enums(pending, applied, failed, canceled)
+// @Param environment query string false "Environment slug"
+// @Param template_id query int false "Template ID"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Param cursor query string false "Cursor from next_cursor or prev_cursor of a previous page; overrides page"
+// @Success 200 {object} model.ScheduledDeploymentListResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/deployments [get]
+func (h *Handler) List(c *gin.Context) {
+	var filter model.ScheduledDeploymentFilter
+	var page model.PaginationParams
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+	if err := c.ShouldBindQuery(&page); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	resp, err := h.schedules.List(c.Request.Context(), filter, page)
+	if err != nil {
+		h.writeError(c, err, "Failed to list scheduled deployments")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Get godoc
+// @Summary Get a scheduled deployment
+// @Description Returns a scheduled deployment with its diff. Secret values in the diff are masked.
+// @Tags deployments
+// @Produce json
+// @Param id path int true "Deployment ID"
+// @Success 200 {object} model.ScheduledDeployment
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/deployments/{id} [get]
+func (h *Handler) Get(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	d, err := h.schedules.Get(c.Request.Context(), id)
+	if err != nil {
+		h.writeError(c, err, "Failed to get scheduled deployment")
+		return
+	}
+	c.JSON(http.StatusOK, d)
+}
+
+// Create godoc
+// @Summary Schedule a template version
+// @Description Schedules an update setting the version of a template to go live at run_at. One replica, elected by lease, applies due deployments;
+// @Description during a freeze window they stay pending until it closes. Templates of protected environments change through change requests instead.
+// @Tags deployments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.CreateScheduledDeploymentRequest true "Deployment"
+// @Success 201 {object} model.ScheduledDeployment
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/deployments [post]
+func (h *Handler) Create(c *gin.Context) {
+	var req model.CreateScheduledDeploymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	author := auth.FromContext(c.Request.Context()).Name
+	d, err := h.schedules.Create(c.Request.Context(), req, author)
+	if err != nil {
+		h.writeError(c, err, "Failed to schedule deployment")
+		return
+	}
+
+	h.logger.Info().
+		Int64("deployment", d.ID).
+		Str("version", d.Version).
+		Time("run_at", d.RunAt).
+		Str("author", author).
+		Msg("Deployment scheduled")
+	c.JSON(http.StatusCreated, d)
+}
+
+// Cancel godoc
+// @Summary Cancel a scheduled deployment
+// @Description Withdraws a pending deployment.
+// @Tags deployments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Deployment ID"
+// @Success 200 {object} model.ScheduledDeployment
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/deployments/{id} [delete]
+func (h *Handler) Cancel(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	actor := auth.FromContext(c.Request.Context()).Name
+	d, err := h.schedules.Cancel(c.Request.Context(), id, actor)
+	if err != nil {
+		h.writeError(c, err, "Failed to cancel scheduled deployment")
+		return
+	}
+	c.JSON(http.StatusOK, d)
+}
+
+// writeError maps service errors to responses, logging unexpected ones
+func (h *Handler) writeError(c *gin.Context, err error, msg string) {
+	var validationErr *service.ValidationError
+	var conflictErr *service.ConflictError
+	var protectedErr *service.ProtectedError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: validationErr.Error()})
+	case errors.As(err, &protectedErr):
+		c.JSON(http.StatusConflict, model.ErrorResponse{Error: "environment_protected", Message: protectedErr.Error()})
+	case errors.As(err, &conflictErr):
+		c.JSON(http.StatusConflict, model.ErrorResponse{
+			Error:   "deployment_conflict",
+			Message: conflictErr.Conflicts[0],
+		})
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "deployment_not_found",
+			Message: "Scheduled deployment not found",
+		})
+	default:
+		h.logger.Error().Err(err).Msg(msg)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+	}
+}
+
+// parseID reads the id path parameter, writing a 400 response when invalid
+func parseID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "id must be a positive integer",
+		})
+		return 0, false
+	}
+	return id, true
+}
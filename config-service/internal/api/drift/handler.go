@@ -0,0 +1,110 @@
+package drift
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves client check-ins and drift reports
+type Handler struct {
+	drift  *service.DriftService
+	logger *logger.Logger
+}
+
+// New creates a new drift handler
+func New(drift *service.DriftService, log *logger.Logger) *Handler {
+	return &Handler{
+		drift:  drift,
+		logger: log,
+	}
+}
+
+// CheckIn godoc
+// @Summary Report applied templates
+// @Description Records the templates a client instance has applied, with their versions and the SHA-256 of their content, replacing its previous check-in.
+// @Description The config agent checks in after every sync with its AGENT_AUTH_TOKEN. instance_id defaults to the X-Instance-ID header.
+// @Tags drift
+// @Accept json
+// @Security BearerAuth
+// @Param slug path string true "Environment slug"
+// @Param X-Instance-ID header string false "ID of the reporting client instance"
+// @Param request body model.CheckInRequest true "Applied templates"
+// @Success 204
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments/{slug}/check-ins [post]
+func (h *Handler) CheckIn(c *gin.Context) {
+	var req model.CheckInRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+	if req.InstanceID == "" {
+		req.InstanceID = strings.TrimSpace(c.GetHeader("X-Instance-ID"))
+	}
+
+	if _, err := h.drift.CheckIn(c.Request.Context(), c.Param("slug"), req); err != nil {
+		h.writeError(c, err, "Failed to record check-in")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Report godoc
+// @Summary Get the drift report of an environment
+// @Description Compares the latest check-in of every instance with the bundle currently served to it, taking rollouts into account.
+// @Description Applied templates drift when they are behind the version served, when their checksum differs from the current render, or when the environment no longer serves them.
+// @Description Instances that have not checked in recently are only counted as stale. The report also updates the config_drifted_instances gauge.
+// @Tags drift
+// @Produce json
+// @Param slug path string true "Environment slug"
+// @Param all query bool false "Also list the instances in sync"
+// @Success 200 {object} model.DriftReport
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments/{slug}/drift [get]
+func (h *Handler) Report(c *gin.Context) {
+	all := false
+	if raw := c.Query("all"); raw != "" {
+		var err error
+		if all, err = strconv.ParseBool(raw); err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: "all must be a boolean"})
+			return
+		}
+	}
+
+	resp, err := h.drift.Report(c.Request.Context(), c.Param("slug"), all)
+	if err != nil {
+		h.writeError(c, err, "Failed to build drift report")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// writeError maps service errors to responses, logging unexpected ones
+func (h *Handler) writeError(c *gin.Context, err error, msg string) {
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: validationErr.Error()})
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "environment_not_found",
+			Message: "Environment " + c.Param("slug") + " does not exist",
+		})
+	default:
+		h.logger.Error().Err(err).Msg(msg)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+	}
+}
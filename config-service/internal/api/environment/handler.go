@@ -0,0 +1,200 @@
+package environment
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/company/config-service/internal/auth"
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves environment listings, protection and cloning
+type Handler struct {
+	environments *service.EnvironmentService
+	logger       *logger.Logger
+}
+
+// New creates a new environment handler
+func New(environments *service.EnvironmentService, log *logger.Logger) *Handler {
+	return &Handler{
+		environments: environments,
+		logger:       log,
+	}
+}
+
+// List godoc
+// @Summary Get all environments
+// @Description Retrieve all available environments ordered by priority.
+// @Description With page, page_size or cursor the environments are returned page by page; cursors stay stable under concurrent writes.
+// @Description With as_of the environments are returned as they were at that moment.
+// @Tags environments
+// @Produce json
+// @Param as_of query string false "RFC 3339 timestamp of the state to return"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Param cursor query string false "Cursor from next_cursor or prev_cursor of a previous page; overrides page, sort_by and sort_order"
+// @Param sort_by query string false "Sort column" Enums(id, priority, name, slug, created_at) default(priority)
+// @Param sort_order query string false "Sort order" Enums(asc, desc) default(desc)
+// @Success 200 {object} model.EnvironmentListResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments [get]
+func (h *Handler) List(c *gin.Context) {
+	at, err := service.ParseAsOf(c.Query("as_of"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+	environments := h.environments.AsOf(at)
+
+	var resp *model.EnvironmentListResponse
+	if paged(c) {
+		var page model.PaginationParams
+		if err := c.ShouldBindQuery(&page); err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+			return
+		}
+		sort := model.SortParams{
+			SortBy:    c.DefaultQuery("sort_by", "priority"),
+			SortOrder: c.DefaultQuery("sort_order", "desc"),
+		}
+		resp, err = environments.ListPage(c.Request.Context(), page, sort)
+	} else {
+		resp, err = environments.List(c.Request.Context())
+	}
+	if err != nil {
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: validationErr.Error()})
+			return
+		}
+		h.logger.Error().Err(err).Msg("Failed to list environments")
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// SetProtection godoc
+// @Summary Protect or unprotect an environment
+// @Description Marks an environment protected, so that its templates only change through change requests approved by required_approvals users other than the author, or lifts the protection.
+// @Description Change requests already open keep the number of approvals they were opened with.
+// @Tags environments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param slug path string true "Environment slug"
+// @Param request body model.EnvironmentProtectionRequest true "Protection"
+// @Success 200 {object} model.EnvironmentResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments/{slug}/protection [put]
+func (h *Handler) SetProtection(c *gin.Context) {
+	var req model.EnvironmentProtectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	actor := auth.FromContext(c.Request.Context()).Name
+	resp, err := h.environments.SetProtection(c.Request.Context(), c.Param("slug"), req, actor)
+	if err != nil {
+		var validationErr *service.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: validationErr.Error()})
+		case errors.Is(err, repository.ErrNotFound):
+			c.JSON(http.StatusNotFound, model.ErrorResponse{
+				Error:   "environment_not_found",
+				Message: "Environment not found",
+			})
+		default:
+			h.logger.Error().Err(err).Msg("Failed to update environment protection")
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+		}
+		return
+	}
+
+	h.logger.Info().
+		Str("environment", resp.Slug).
+		Bool("protected", resp.Protected).
+		Int("required_approvals", resp.RequiredApprovals).
+		Str("actor", actor).
+		Msg("Environment protection updated")
+	c.JSON(http.StatusOK, resp)
+}
+
+// Clone godoc
+// @Summary Clone an environment
+// @Description Creates an environment under a new slug holding a copy of every template of the source environment with its tag links.
+// @Description Secret values are copied encrypted. Description, active and priority default to those of the source; the clone starts unprotected, and candidates, flags and freeze windows are not copied.
+// @Tags environments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param slug path string true "Slug of the environment to clone"
+// @Param request body model.CloneEnvironmentRequest true "New environment"
+// @Success 201 {object} model.CloneEnvironmentResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments/{slug}/clone [post]
+func (h *Handler) Clone(c *gin.Context) {
+	var req model.CloneEnvironmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	actor := auth.FromContext(c.Request.Context()).Name
+	resp, err := h.environments.Clone(c.Request.Context(), c.Param("slug"), req, actor)
+	if err != nil {
+		var validationErr *service.ValidationError
+		var conflictErr *service.ConflictError
+		switch {
+		case errors.As(err, &validationErr):
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: validationErr.Error()})
+		case errors.As(err, &conflictErr):
+			c.JSON(http.StatusConflict, model.ErrorResponse{Error: "environment_exists", Message: conflictErr.Error()})
+		case errors.Is(err, repository.ErrNotFound):
+			c.JSON(http.StatusNotFound, model.ErrorResponse{
+				Error:   "environment_not_found",
+				Message: "Environment not found",
+			})
+		default:
+			h.logger.Error().Err(err).Msg("Failed to clone environment")
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+		}
+		return
+	}
+
+	h.logger.Info().
+		Str("source", resp.Source).
+		Str("environment", resp.Environment.Slug).
+		Int("templates", resp.Templates).
+		Str("actor", actor).
+		Msg("Environment cloned")
+	c.JSON(http.StatusCreated, resp)
+}
+
+// paged reports whether the request asks for a single page rather than all
+// environments
+func paged(c *gin.Context) bool {
+	for _, key := range []string{"page", "page_size", "cursor"} {
+		if _, ok := c.GetQuery(key); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,241 @@
+package flag
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/company/config-service/internal/auth"
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/selector"
+	"github.com/company/config-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves the feature flags of environments and their evaluation
+type Handler struct {
+	flags  *service.FlagService
+	logger *logger.Logger
+}
+
+// New creates a new feature flag handler
+func New(flags *service.FlagService, log *logger.Logger) *Handler {
+	return &Handler{
+		flags:  flags,
+		logger: log,
+	}
+}
+
+// List godoc
+// @Summary List feature flags
+// @Description Lists the feature flags of an environment, optionally matched by a tag selector such as `checkout AND NOT experimental`.
+// @Tags flags
+// @Produce json
+// @Param slug path string true "Environment slug"
+// @Param selector query string false "Tag selector expression"
+// @Success 200 {object} model.FlagListResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments/{slug}/flags [get]
+func (h *Handler) List(c *gin.Context) {
+	expr, err := selector.Parse(c.Query("selector"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_selector",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.flags.List(c.Request.Context(), c.Param("slug"), expr)
+	if err != nil {
+		h.writeError(c, err, "Failed to list flags")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Get godoc
+// @Summary Get a feature flag
+// @Description Returns a feature flag of an environment with its variations and targeting rules.
+// @Tags flags
+// @Produce json
+// @Param slug path string true "Environment slug"
+// @Param key path string true "Flag key"
+// @Success 200 {object} model.FlagResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments/{slug}/flags/{key} [get]
+func (h *Handler) Get(c *gin.Context) {
+	f, err := h.flags.Get(c.Request.Context(), c.Param("slug"), c.Param("key"))
+	if err != nil {
+		h.writeError(c, err, "Failed to get flag")
+		return
+	}
+	c.JSON(http.StatusOK, f)
+}
+
+// Create godoc
+// @Summary Create a feature flag
+// @Description Adds a feature flag to an environment. Boolean flags serve the variations true and false; multivariate flags serve named JSON values.
+// @Description An enabled flag serves the first rule whose conditions all match the context, otherwise its fallthrough; a disabled flag serves off_variation.
+// @Description Rules and the fallthrough serve a variation or a percentage rollout bucketed by a hash of the flag key and the bucket_by attribute.
+// @Tags flags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param slug path string true "Environment slug"
+// @Param request body model.CreateFlagRequest true "Feature flag"
+// @Success 201 {object} model.FlagResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 423 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments/{slug}/flags [post]
+func (h *Handler) Create(c *gin.Context) {
+	var req model.CreateFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	actor := auth.FromContext(c.Request.Context()).Name
+	f, err := h.flags.Create(c.Request.Context(), c.Param("slug"), req, actor)
+	if err != nil {
+		h.writeError(c, err, "Failed to create flag")
+		return
+	}
+
+	h.logger.Info().
+		Str("environment", f.Environment).
+		Str("flag", f.Key).
+		Bool("enabled", f.Enabled).
+		Str("actor", actor).
+		Msg("Feature flag created")
+	c.JSON(http.StatusCreated, f)
+}
+
+// Update godoc
+// @Summary Update a feature flag
+// @Description Changes the fields of a feature flag that are set. Sending rules replaces all of them, an empty list removes them; sending tags replaces the tags.
+// @Tags flags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param slug path string true "Environment slug"
+// @Param key path string true "Flag key"
+// @Param request body model.UpdateFlagRequest true "Changes"
+// @Success 200 {object} model.FlagResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 423 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments/{slug}/flags/{key} [put]
+func (h *Handler) Update(c *gin.Context) {
+	var req model.UpdateFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	actor := auth.FromContext(c.Request.Context()).Name
+	f, err := h.flags.Update(c.Request.Context(), c.Param("slug"), c.Param("key"), req, actor)
+	if err != nil {
+		h.writeError(c, err, "Failed to update flag")
+		return
+	}
+
+	h.logger.Info().
+		Str("environment", f.Environment).
+		Str("flag", f.Key).
+		Bool("enabled", f.Enabled).
+		Str("actor", actor).
+		Msg("Feature flag updated")
+	c.JSON(http.StatusOK, f)
+}
+
+// Delete godoc
+// @Summary Delete a feature flag
+// @Description Removes a feature flag from an environment.
+// @Tags flags
+// @Security BearerAuth
+// @Param slug path string true "Environment slug"
+// @Param key path string true "Flag key"
+// @Success 204
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 423 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments/{slug}/flags/{key} [delete]
+func (h *Handler) Delete(c *gin.Context) {
+	slug, key := c.Param("slug"), c.Param("key")
+	actor := auth.FromContext(c.Request.Context()).Name
+	if err := h.flags.Delete(c.Request.Context(), slug, key, actor); err != nil {
+		h.writeError(c, err, "Failed to delete flag")
+		return
+	}
+
+	h.logger.Info().
+		Str("environment", slug).
+		Str("flag", key).
+		Str("actor", actor).
+		Msg("Feature flag deleted")
+	c.Status(http.StatusNoContent)
+}
+
+// Evaluate godoc
+// @Summary Evaluate feature flags
+// @Description Evaluates the feature flags of an environment for a context in one call: those listed in flags, otherwise all matched by selector, otherwise all.
+// @Description Each result holds the value served, its variation and the reason: off, rule_match with the index of the rule, or fallthrough. Unknown keys are left out.
+// @Tags flags
+// @Accept json
+// @Produce json
+// @Param slug path string true "Environment slug"
+// @Param request body model.EvaluateFlagsRequest true "Evaluation context"
+// @Success 200 {object} model.EvaluateFlagsResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments/{slug}/flags/evaluate [post]
+func (h *Handler) Evaluate(c *gin.Context) {
+	var req model.EvaluateFlagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	resp, err := h.flags.Evaluate(c.Request.Context(), c.Param("slug"), req)
+	if err != nil {
+		h.writeError(c, err, "Failed to evaluate flags")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// writeError maps service errors to responses, logging unexpected ones
+func (h *Handler) writeError(c *gin.Context, err error, msg string) {
+	var validationErr *service.ValidationError
+	var conflictErr *service.ConflictError
+	var frozenErr *service.FrozenError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: validationErr.Error()})
+	case errors.As(err, &conflictErr):
+		c.JSON(http.StatusConflict, model.ErrorResponse{Error: "flag_exists", Message: conflictErr.Error()})
+	case errors.As(err, &frozenErr):
+		c.JSON(http.StatusLocked, model.ErrorResponse{Error: "environment_frozen", Message: frozenErr.Error()})
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "flag_not_found",
+			Message: "Environment or flag not found",
+		})
+	default:
+		h.logger.Error().Err(err).Msg(msg)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+	}
+}
@@ -0,0 +1,143 @@
+package freeze
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/company/config-service/internal/auth"
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves the freeze windows of environments
+type Handler struct {
+	freezes *service.FreezeService
+	logger  *logger.Logger
+}
+
+// New creates a new freeze window handler
+func New(freezes *service.FreezeService, log *logger.Logger) *Handler {
+	return &Handler{
+		freezes: freezes,
+		logger:  log,
+	}
+}
+
+// List godoc
+// @Summary List freeze windows
+// @Description Lists the freeze windows of an environment, whether each is open and until when, or when it opens next within a week.
+// @Description frozen reports whether template writes are blocked right now.
+// @Tags environments
+// @Produce json
+// @Param slug path string true "Environment slug"
+// @Success 200 {object} model.FreezeWindowListResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments/{slug}/freeze-windows [get]
+func (h *Handler) List(c *gin.Context) {
+	resp, err := h.freezes.List(c.Request.Context(), c.Param("slug"))
+	if err != nil {
+		h.writeError(c, err, "Failed to list freeze windows")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Create godoc
+// @Summary Create a freeze window
+// @Description Adds a window during which templates of the environment cannot be written, by bulk operations, archive imports, change requests or scheduled deployments.
+// @Description A recurring window opens at every minute matching cron, a five-field expression evaluated in timezone, and stays open for duration; a fixed window spans starts_at to ends_at.
+// @Tags environments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param slug path string true "Environment slug"
+// @Param request body model.CreateFreezeWindowRequest true "Freeze window"
+// @Success 201 {object} model.FreezeWindowResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments/{slug}/freeze-windows [post]
+func (h *Handler) Create(c *gin.Context) {
+	var req model.CreateFreezeWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	actor := auth.FromContext(c.Request.Context()).Name
+	resp, err := h.freezes.Create(c.Request.Context(), c.Param("slug"), req, actor)
+	if err != nil {
+		h.writeError(c, err, "Failed to create freeze window")
+		return
+	}
+
+	h.logger.Info().
+		Str("environment", c.Param("slug")).
+		Str("window", resp.Name).
+		Str("actor", actor).
+		Msg("Freeze window created")
+	c.JSON(http.StatusCreated, resp)
+}
+
+// Delete godoc
+// @Summary Delete a freeze window
+// @Description Removes a freeze window of an environment.
+// @Tags environments
+// @Security BearerAuth
+// @Param slug path string true "Environment slug"
+// @Param id path int true "Freeze window ID"
+// @Success 204
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments/{slug}/freeze-windows/{id} [delete]
+func (h *Handler) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "id must be a positive integer",
+		})
+		return
+	}
+
+	actor := auth.FromContext(c.Request.Context()).Name
+	if err := h.freezes.Delete(c.Request.Context(), c.Param("slug"), id, actor); err != nil {
+		h.writeError(c, err, "Failed to delete freeze window")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// writeError maps service errors to responses, logging unexpected ones
+func (h *Handler) writeError(c *gin.Context, err error, msg string) {
+	var validationErr *service.ValidationError
+	var conflictErr *service.ConflictError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: validationErr.Error()})
+	case errors.As(err, &conflictErr):
+		c.JSON(http.StatusConflict, model.ErrorResponse{
+			Error:   "freeze_window_exists",
+			Message: conflictErr.Conflicts[0],
+		})
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "not_found",
+			Message: "Environment or freeze window not found",
+		})
+	default:
+		h.logger.Error().Err(err).Msg(msg)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+	}
+}
@@ -0,0 +1,245 @@
+package rollout
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/company/config-service/internal/auth"
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves the candidate versions of templates under rollout
+type Handler struct {
+	rollouts *service.RolloutService
+	logger   *logger.Logger
+}
+
+// New creates a new rollout handler
+func New(rollouts *service.RolloutService, log *logger.Logger) *Handler {
+	return &Handler{
+		rollouts: rollouts,
+		logger:   log,
+	}
+}
+
+// Get godoc
+// @Summary Get the candidate of a template
+// @Description Returns the candidate version of a template and the share of clients it is served to. Secret values are masked.
+// @Tags rollouts
+// @Produce json
+// @Param id path int true "Template ID"
+// @Success 200 {object} model.TemplateCandidate
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/templates/{id}/candidate [get]
+func (h *Handler) Get(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	candidate, err := h.rollouts.Get(c.Request.Context(), id)
+	if err != nil {
+		h.writeError(c, err, "Failed to get candidate")
+		return
+	}
+	c.JSON(http.StatusOK, candidate)
+}
+
+// Set godoc
+// @Summary Set the candidate of a template
+// @Description Creates or replaces the candidate version of a template, served to rollout_percentage percent of clients picked by a hash of their instance ID.
+// @Description Content and default values left out are taken from the stable version.
+// @Tags rollouts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Template ID"
+// @Param request body model.SetCandidateRequest true "Candidate"
+// @Success 200 {object} model.TemplateCandidate
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 423 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/templates/{id}/candidate [put]
+func (h *Handler) Set(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+	var req model.SetCandidateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	actor := auth.FromContext(c.Request.Context()).Name
+	candidate, err := h.rollouts.Set(c.Request.Context(), id, req, actor)
+	if err != nil {
+		h.writeError(c, err, "Failed to set candidate")
+		return
+	}
+
+	h.logger.Info().
+		Int64("template_id", id).
+		Str("version", candidate.Version).
+		Int("rollout_percentage", candidate.RolloutPercentage).
+		Str("actor", actor).
+		Msg("Template candidate set")
+	c.JSON(http.StatusOK, candidate)
+}
+
+// Rollout godoc
+// @Summary Change the rollout of a candidate
+// @Description Changes the percentage of clients served the candidate version of a template. Clients served the candidate keep it when the percentage grows.
+// @Tags rollouts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Template ID"
+// @Param request body model.RolloutRequest true "Rollout"
+// @Success 200 {object} model.TemplateCandidate
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 423 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/templates/{id}/candidate/rollout [put]
+func (h *Handler) Rollout(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+	var req model.RolloutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	actor := auth.FromContext(c.Request.Context()).Name
+	candidate, err := h.rollouts.Rollout(c.Request.Context(), id, req, actor)
+	if err != nil {
+		h.writeError(c, err, "Failed to change rollout")
+		return
+	}
+
+	h.logger.Info().
+		Int64("template_id", id).
+		Str("version", candidate.Version).
+		Int("rollout_percentage", candidate.RolloutPercentage).
+		Str("actor", actor).
+		Msg("Template rollout changed")
+	c.JSON(http.StatusOK, candidate)
+}
+
+// Promote godoc
+// @Summary Promote the candidate of a template
+// @Description Makes the candidate the stable version of the template, served to every client, and ends the rollout.
+// @Tags rollouts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Template ID"
+// @Success 200 {object} model.TemplateResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 423 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/templates/{id}/candidate/promote [post]
+func (h *Handler) Promote(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	actor := auth.FromContext(c.Request.Context()).Name
+	tpl, err := h.rollouts.Promote(c.Request.Context(), id, actor)
+	if err != nil {
+		h.writeError(c, err, "Failed to promote candidate")
+		return
+	}
+
+	h.logger.Info().
+		Int64("template_id", id).
+		Str("version", tpl.Version).
+		Str("actor", actor).
+		Msg("Template candidate promoted")
+	c.JSON(http.StatusOK, tpl)
+}
+
+// Abort godoc
+// @Summary Abort the rollout of a template
+// @Description Removes the candidate version of a template, returning every client to the stable version. Allowed in protected and frozen environments.
+// @Tags rollouts
+// @Security BearerAuth
+// @Param id path int true "Template ID"
+// @Success 204
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/templates/{id}/candidate [delete]
+func (h *Handler) Abort(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	actor := auth.FromContext(c.Request.Context()).Name
+	if err := h.rollouts.Abort(c.Request.Context(), id, actor); err != nil {
+		h.writeError(c, err, "Failed to abort rollout")
+		return
+	}
+
+	h.logger.Info().
+		Int64("template_id", id).
+		Str("actor", actor).
+		Msg("Template rollout aborted")
+	c.Status(http.StatusNoContent)
+}
+
+// writeError maps service errors to responses, logging unexpected ones
+func (h *Handler) writeError(c *gin.Context, err error, msg string) {
+	var validationErr *service.ValidationError
+	var protectedErr *service.ProtectedError
+	var frozenErr *service.FrozenError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: validationErr.Error()})
+	case errors.As(err, &protectedErr):
+		c.JSON(http.StatusConflict, model.ErrorResponse{Error: "environment_protected", Message: protectedErr.Error()})
+	case errors.As(err, &frozenErr):
+		c.JSON(http.StatusLocked, model.ErrorResponse{Error: "environment_frozen", Message: frozenErr.Error()})
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "candidate_not_found",
+			Message: "Template or candidate not found",
+		})
+	default:
+		h.logger.Error().Err(err).Msg(msg)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+	}
+}
+
+// parseID reads the id path parameter, writing a 400 response when invalid
+func parseID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "id must be a positive integer",
+		})
+		return 0, false
+	}
+	return id, true
+}
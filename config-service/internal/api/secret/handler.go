@@ -0,0 +1,124 @@
+package secret
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/company/config-service/internal/auth"
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Handler administers master key rotation of secret values
+type Handler struct {
+	rotations *service.SecretRotationService
+	logger    *logger.Logger
+}
+
+// New creates a new secret rotation handler
+func New(rotations *service.SecretRotationService, log *logger.Logger) *Handler {
+	return &Handler{
+		rotations: rotations,
+		logger:    log,
+	}
+}
+
+// Status godoc
+// @Summary Get secret rotation status
+// @Description Lists the master keys of the keyring with the number of templates holding secret values wrapped by each, and the progress of the latest rotation.
+// @Description Keys missing from the keyring are listed without version; a key wrapping no templates can be removed from the keyring.
+// @Tags secrets
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.SecretRotationResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/admin/secrets/rotation [get]
+func (h *Handler) Status(c *gin.Context) {
+	resp, err := h.rotations.Status(c.Request.Context())
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get secret rotation status")
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Start godoc
+// @Summary Start a secret rotation
+// @Description Starts rewrapping the data keys of all secret values with the primary master key, the key of highest version in the keyring, or resumes the paused rotation.
+// @Description The job runs in the background in batches; poll the status endpoint for progress.
+// @Tags secrets
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} model.SecretRotation
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/admin/secrets/rotation [post]
+func (h *Handler) Start(c *gin.Context) {
+	actor := auth.FromContext(c.Request.Context()).Name
+	rotation, err := h.rotations.Start(c.Request.Context(), actor)
+	if err != nil {
+		var validationErr *service.ValidationError
+		var conflictErr *service.ConflictError
+		switch {
+		case errors.As(err, &validationErr):
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{
+				Error:   "invalid_request",
+				Message: validationErr.Error(),
+			})
+		case errors.As(err, &conflictErr):
+			c.JSON(http.StatusConflict, model.ErrorResponse{
+				Error:   "rotation_running",
+				Message: conflictErr.Conflicts[0],
+			})
+		default:
+			h.logger.Error().Err(err).Msg("Failed to start secret rotation")
+			c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+		}
+		return
+	}
+
+	h.logger.Info().
+		Int64("rotation", rotation.ID).
+		Str("key_id", rotation.KeyID).
+		Str("actor", actor).
+		Msg("Secret rotation started")
+	c.JSON(http.StatusAccepted, rotation)
+}
+
+// Pause godoc
+// @Summary Pause the secret rotation
+// @Description Stops the running rotation after its current batch. Start resumes it where it stopped.
+// @Tags secrets
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.SecretRotation
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/admin/secrets/rotation/pause [post]
+func (h *Handler) Pause(c *gin.Context) {
+	rotation, err := h.rotations.Pause(c.Request.Context())
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, model.ErrorResponse{
+				Error:   "rotation_not_found",
+				Message: "No secret rotation is running",
+			})
+			return
+		}
+		h.logger.Error().Err(err).Msg("Failed to pause secret rotation")
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+		return
+	}
+	c.JSON(http.StatusOK, rotation)
+}
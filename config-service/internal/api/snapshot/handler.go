@@ -0,0 +1,175 @@
+package snapshot
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/company/config-service/internal/auth"
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves environment snapshots and comparisons
+type Handler struct {
+	snapshots *service.SnapshotService
+	logger    *logger.Logger
+}
+
+// New creates a new snapshot handler
+func New(snapshots *service.SnapshotService, log *logger.Logger) *Handler {
+	return &Handler{
+		snapshots: snapshots,
+		logger:    log,
+	}
+}
+
+// List godoc
+// @Summary List snapshots
+// @Description Lists the snapshots of an environment, newest first, without their templates.
+// @Tags snapshots
+// @Produce json
+// @Param slug path string true "Environment slug"
+// @Success 200 {object} model.SnapshotListResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments/{slug}/snapshots [get]
+func (h *Handler) List(c *gin.Context) {
+	resp, err := h.snapshots.List(c.Request.Context(), c.Param("slug"))
+	if err != nil {
+		h.writeError(c, err, "Failed to list snapshots")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Get godoc
+// @Summary Get a snapshot
+// @Description Returns a snapshot of an environment with the templates it holds; secret values are masked.
+// @Tags snapshots
+// @Produce json
+// @Param slug path string true "Environment slug"
+// @Param name path string true "Snapshot name"
+// @Success 200 {object} model.SnapshotResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments/{slug}/snapshots/{name} [get]
+func (h *Handler) Get(c *gin.Context) {
+	resp, err := h.snapshots.Get(c.Request.Context(), c.Param("slug"), c.Param("name"))
+	if err != nil {
+		h.writeError(c, err, "Failed to get snapshot")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Create godoc
+// @Summary Take a snapshot
+// @Description Records a named copy of the current templates of an environment, including their tags by name. Secret values are kept encrypted.
+// @Tags snapshots
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param slug path string true "Environment slug"
+// @Param request body model.CreateSnapshotRequest true "Snapshot"
+// @Success 201 {object} model.SnapshotResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments/{slug}/snapshots [post]
+func (h *Handler) Create(c *gin.Context) {
+	var req model.CreateSnapshotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	actor := auth.FromContext(c.Request.Context()).Name
+	resp, err := h.snapshots.Create(c.Request.Context(), c.Param("slug"), req, actor)
+	if err != nil {
+		h.writeError(c, err, "Failed to create snapshot")
+		return
+	}
+
+	h.logger.Info().
+		Str("environment", resp.Environment).
+		Str("snapshot", resp.Name).
+		Int("templates", resp.TemplateCount).
+		Str("actor", actor).
+		Msg("Snapshot created")
+	c.JSON(http.StatusCreated, resp)
+}
+
+// Delete godoc
+// @Summary Delete a snapshot
+// @Description Removes a snapshot of an environment.
+// @Tags snapshots
+// @Security BearerAuth
+// @Param slug path string true "Environment slug"
+// @Param name path string true "Snapshot name"
+// @Success 204
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments/{slug}/snapshots/{name} [delete]
+func (h *Handler) Delete(c *gin.Context) {
+	slug, name := c.Param("slug"), c.Param("name")
+	actor := auth.FromContext(c.Request.Context()).Name
+	if err := h.snapshots.Delete(c.Request.Context(), slug, name, actor); err != nil {
+		h.writeError(c, err, "Failed to delete snapshot")
+		return
+	}
+
+	h.logger.Info().
+		Str("environment", slug).
+		Str("snapshot", name).
+		Str("actor", actor).
+		Msg("Snapshot deleted")
+	c.Status(http.StatusNoContent)
+}
+
+// Compare godoc
+// @Summary Compare environments or snapshots
+// @Description Lists the templates added, removed and changed from one side to the other. Each side is an environment slug for its current templates or slug@snapshot for a snapshot of it, e.g. from=staging&to=production@release-42.
+// @Description Templates are matched by name. Changes are listed field by field, with schema and default values compared key by key and tags by name. Secret values are masked and compared in their encrypted form.
+// @Tags snapshots
+// @Produce json
+// @Param from query string true "Environment slug or slug@snapshot"
+// @Param to query string true "Environment slug or slug@snapshot"
+// @Success 200 {object} model.EnvironmentComparison
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/environments/compare [get]
+func (h *Handler) Compare(c *gin.Context) {
+	resp, err := h.snapshots.Compare(c.Request.Context(), c.Query("from"), c.Query("to"))
+	if err != nil {
+		h.writeError(c, err, "Failed to compare environments")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// writeError maps service errors to responses, logging unexpected ones
+func (h *Handler) writeError(c *gin.Context, err error, msg string) {
+	var validationErr *service.ValidationError
+	var conflictErr *service.ConflictError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: validationErr.Error()})
+	case errors.As(err, &conflictErr):
+		c.JSON(http.StatusConflict, model.ErrorResponse{Error: "snapshot_exists", Message: conflictErr.Error()})
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "snapshot_not_found",
+			Message: "Environment or snapshot not found",
+		})
+	default:
+		h.logger.Error().Err(err).Msg(msg)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+	}
+}
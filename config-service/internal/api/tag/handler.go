@@ -0,0 +1,398 @@
+package tag
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/company/config-service/internal/auth"
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Handler handles tag and label management
+type Handler struct {
+	tags   *service.TagService
+	logger *logger.Logger
+}
+
+// New creates a new tag handler
+func New(tags *service.TagService, log *logger.Logger) *Handler {
+	return &Handler{
+		tags:   tags,
+		logger: log,
+	}
+}
+
+// List godoc
+// @Summary Get all tags
+// @Description Retrieve all tags ordered by name, including key and value of label tags.
+// @Description With page, page_size or cursor the tags are returned page by page; cursors stay stable under concurrent writes.
+// @Description With as_of the tags are returned as they were at that moment.
+// @Tags tags
+// @Produce json
+// @Param as_of query string false "RFC 3339 timestamp of the state to return"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Param cursor query string false "Cursor from next_cursor or prev_cursor of a previous page; overrides page, sort_by and sort_order"
+// @Param sort_by query string false "Sort column" Enums(id, name, created_at, updated_at) default(name)
+// @Param sort_order query string false "Sort order" Enums(asc, desc) default(asc)
+// @Success 200 {object} model.TagListResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/tags [get]
+func (h *Handler) List(c *gin.Context) {
+	at, err := service.ParseAsOf(c.Query("as_of"))
+	if err != nil {
+		h.failed(c, err)
+		return
+	}
+	tags := h.tags.AsOf(at)
+
+	if paged(c) {
+		var page model.PaginationParams
+		if err := c.ShouldBindQuery(&page); err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+			return
+		}
+		sort := model.SortParams{
+			SortBy:    c.DefaultQuery("sort_by", "name"),
+			SortOrder: c.DefaultQuery("sort_order", "asc"),
+		}
+
+		resp, err := tags.ListPage(c.Request.Context(), page, sort)
+		if err != nil {
+			h.failed(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	all, err := tags.List(c.Request.Context())
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to list tags")
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+		return
+	}
+
+	resp := model.TagListResponse{Tags: make([]model.TagResponse, 0, len(all)), Total: int64(len(all))}
+	for _, tag := range all {
+		resp.Tags = append(resp.Tags, model.NewTagResponse(tag))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Tree godoc
+// @Summary Get the tag hierarchy
+// @Description Returns all tags nested under their parents. Filtering by a tag also matches templates tagged with any of its descendants.
+// @Description With as_of the hierarchy is returned as it was at that moment.
+// @Tags tags
+// @Produce json
+// @Param as_of query string false "RFC 3339 timestamp of the state to return"
+// @Success 200 {object} model.TagTreeResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/tags/tree [get]
+func (h *Handler) Tree(c *gin.Context) {
+	at, err := service.ParseAsOf(c.Query("as_of"))
+	if err != nil {
+		h.failed(c, err)
+		return
+	}
+
+	nodes, err := h.tags.AsOf(at).Tree(c.Request.Context())
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to build tag tree")
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+		return
+	}
+	c.JSON(http.StatusOK, model.TagTreeResponse{Tags: nodes})
+}
+
+// Usage godoc
+// @Summary Get tag usage statistics
+// @Description Returns per tag the number of directly linked templates broken down by environment and format,
+// @Description and when a linked template was last created or updated. Also refreshes the config_tag_* gauges.
+// @Tags tags
+// @Produce json
+// @Success 200 {object} model.TagUsageResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/tags/usage [get]
+func (h *Handler) Usage(c *gin.Context) {
+	resp, err := h.tags.Usage(c.Request.Context())
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to compute tag usage")
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Cleanup godoc
+// @Summary Delete unused tags
+// @Description Lists, or unless dry_run is set deletes, tags without template links and child tags created more than older_than_days days ago.
+// @Description Tags named by a webhook selector are kept.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.TagCleanupRequest true "Age threshold"
+// @Success 200 {object} model.TagCleanupResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/tags/cleanup [post]
+func (h *Handler) Cleanup(c *gin.Context) {
+	var req model.TagCleanupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	resp, err := h.tags.Cleanup(c.Request.Context(), req)
+	if err != nil {
+		h.failed(c, err)
+		return
+	}
+
+	if !req.DryRun {
+		h.logger.Info().
+			Int("older_than_days", req.OlderThanDays).
+			Int("deleted", resp.Total).
+			Msg("Unused tags deleted")
+		if err := h.tags.RefreshMetrics(c.Request.Context()); err != nil {
+			h.logger.Warn().Err(err).Msg("Failed to refresh tag metrics")
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Merge godoc
+// @Summary Merge or rename tags
+// @Description Moves every template link of the source tags to the target tag, dropping duplicate links,
+// @Description moves child tags of the sources under the target and deletes the sources, in one transaction.
+// @Description When the target does not exist and there is a single source, that source is renamed instead.
+// @Description Webhook selectors naming a source are rewritten to name the target.
+// @Description Both operations write an audit entry attributed to the caller.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.TagMergeRequest true "Sources and target"
+// @Success 200 {object} model.TagMergeResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/tags/merge [post]
+func (h *Handler) Merge(c *gin.Context) {
+	var req model.TagMergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	actor := auth.FromContext(c.Request.Context()).Name
+	resp, err := h.tags.Merge(c.Request.Context(), req, actor)
+	if err != nil {
+		h.failed(c, err)
+		return
+	}
+
+	h.logger.Info().
+		Strs("sources", req.Sources).
+		Str("target", req.Target).
+		Bool("renamed", resp.Renamed).
+		Int64("links_moved", resp.LinksMoved).
+		Str("actor", actor).
+		Msg("Tags merged")
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Get godoc
+// @Summary Get tag by ID
+// @Description With as_of the tag is returned as it was at that moment.
+// @Tags tags
+// @Produce json
+// @Param id path int true "Tag ID"
+// @Param as_of query string false "RFC 3339 timestamp of the state to return"
+// @Success 200 {object} model.TagResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/tags/{id} [get]
+func (h *Handler) Get(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	at, err := service.ParseAsOf(c.Query("as_of"))
+	if err != nil {
+		h.failed(c, err)
+		return
+	}
+
+	tag, err := h.tags.AsOf(at).Get(c.Request.Context(), id)
+	if err != nil {
+		h.failed(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.NewTagResponse(*tag))
+}
+
+// Create godoc
+// @Summary Create a tag
+// @Description Creates a plain tag or a key/value label. A name of the form key=value, or a key and value without a name, creates a label.
+// @Description Label keys are restricted to TAGS_LABEL_KEYS when configured.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.CreateTagRequest true "Tag"
+// @Success 201 {object} model.TagResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/tags [post]
+func (h *Handler) Create(c *gin.Context) {
+	var req model.CreateTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	tag, err := h.tags.Create(c.Request.Context(), req)
+	if err != nil {
+		h.failed(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, model.NewTagResponse(*tag))
+}
+
+// Update godoc
+// @Summary Update a tag
+// @Description Updates the set fields of a tag. parent_id moves the tag within the hierarchy, 0 makes it a root; changes creating a cycle are rejected.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Tag ID"
+// @Param request body model.UpdateTagRequest true "Fields to change"
+// @Success 200 {object} model.TagResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/tags/{id} [put]
+func (h *Handler) Update(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	var req model.UpdateTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	tag, err := h.tags.Update(c.Request.Context(), id, req)
+	if err != nil {
+		h.failed(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.NewTagResponse(*tag))
+}
+
+// MigrateLabels godoc
+// @Summary Convert plain tags into key/value labels
+// @Description Converts plain tags named <key><separator><value>, such as team-payments, into labels when the key is allowed.
+// @Description Keys default to TAGS_LABEL_KEYS. Tag names are kept so existing references keep working.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.MigrateLabelsRequest true "Separator and keys"
+// @Success 200 {object} model.MigrateLabelsResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/tags/labels/migrate [post]
+func (h *Handler) MigrateLabels(c *gin.Context) {
+	var req model.MigrateLabelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	resp, err := h.tags.MigrateLabels(c.Request.Context(), req)
+	if err != nil {
+		h.failed(c, err)
+		return
+	}
+
+	h.logger.Info().
+		Str("separator", req.Separator).
+		Bool("dry_run", req.DryRun).
+		Int("migrated", len(resp.Migrated)).
+		Int("skipped", len(resp.Skipped)).
+		Msg("Tag labels migrated")
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// failed maps tag service errors to HTTP responses
+func (h *Handler) failed(c *gin.Context, err error) {
+	var validationErr *service.ValidationError
+	var conflictErr *service.ConflictError
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "tag_not_found"})
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_request",
+			Message: validationErr.Error(),
+		})
+	case errors.As(err, &conflictErr):
+		c.JSON(http.StatusConflict, model.ErrorResponse{
+			Error:   "tag_exists",
+			Message: conflictErr.Error(),
+		})
+	default:
+		h.logger.Error().Err(err).Msg("Tag operation failed")
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+	}
+}
+
+// paged reports whether the request asks for a single page rather than all
+// tags
+func paged(c *gin.Context) bool {
+	for _, key := range []string{"page", "page_size", "cursor"} {
+		if _, ok := c.GetQuery(key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parseID reads the id path parameter, writing a 400 response when invalid
+func parseID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "id must be a positive integer",
+		})
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,308 @@
+package template
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves template listings
+type Handler struct {
+	templates *service.TemplateService
+	logger    *logger.Logger
+}
+
+// New creates a new template handler
+func New(templates *service.TemplateService, log *logger.Logger) *Handler {
+	return &Handler{
+		templates: templates,
+		logger:    log,
+	}
+}
+
+// List godoc
+// @Summary List templates
+// @Description Lists templates page by page, optionally filtered by environment, format, active flag and a tag selector.
+// @Description Pages are addressed by page number or, stable under concurrent writes, by the next_cursor and prev_cursor of a previous page.
+// @Description Selectors combine tag names with AND, OR, NOT and parentheses, e.g. `database AND NOT deprecated`; quote names containing spaces.
+// @Description fields and include trim the response: only the listed fields are returned and the environment and tags relations
+// @Description are embedded, and queried, only when listed in include. Without either parameter every field and both relations are returned.
+// @Description With as_of the templates are returned as they were at that moment; search is not available then.
+// @Tags templates
+// @Produce json
+// @Param as_of query string false "RFC 3339 timestamp of the state to return"
+// @Param environment query string false "Environment slug"
+// @Param selector query string false "Tag selector expression"
+// @Param tags query string false "Comma separated tag names, all must match"
+// @Param search query string false "Full-text query over name, description and content"
+// @Param format query string false "Template format" Enums(json, yaml, toml, env)
+// @Param active query bool false "Active flag"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Param cursor query string false "Cursor from next_cursor or prev_cursor of a previous page; overrides page, sort_by and sort_order"
+// @Param sort_by query string false "Sort column" Enums(id, name, version, created_at, updated_at) default(created_at)
+// @Param sort_order query string false "Sort order" Enums(asc, desc) default(desc)
+// @Param fields query string false "Comma separated fields to return, e.g. name,version,updated_at"
+// @Param include query string false "Comma separated relations to embed" Enums(environment, tags)
+// @Success 200 {object} model.TemplateListResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/templates [get]
+func (h *Handler) List(c *gin.Context) {
+	filter, page, ok := bindFilter(c)
+	if !ok {
+		return
+	}
+	var sort model.SortParams
+	var fields model.FieldParams
+	if err := c.ShouldBindQuery(&sort); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+	if err := c.ShouldBindQuery(&fields); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	at, err := service.ParseAsOf(c.Query("as_of"))
+	if err != nil {
+		h.failed(c, "Failed to list templates", err)
+		return
+	}
+
+	resp, err := h.templates.AsOf(at).List(c.Request.Context(), filter, page, sort, fields)
+	if err != nil {
+		h.failed(c, "Failed to list templates", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Get godoc
+// @Summary Get template by ID
+// @Description Returns a template with its environment and tags. fields and include trim the response as for the template listing.
+// @Description With as_of the template is returned as it was at that moment, or 404 when it did not exist then.
+// @Tags templates
+// @Produce json
+// @Param id path int true "Template ID"
+// @Param as_of query string false "RFC 3339 timestamp of the state to return"
+// @Param fields query string false "Comma separated fields to return, e.g. name,version,content"
+// @Param include query string false "Comma separated relations to embed" Enums(environment, tags)
+// @Success 200 {object} model.TemplateResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/templates/{id} [get]
+func (h *Handler) Get(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+	var fields model.FieldParams
+	if err := c.ShouldBindQuery(&fields); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	at, err := service.ParseAsOf(c.Query("as_of"))
+	if err != nil {
+		h.failed(c, "Failed to get template", err)
+		return
+	}
+
+	resp, err := h.templates.AsOf(at).Get(c.Request.Context(), id, fields)
+	if err != nil {
+		h.failed(c, "Failed to get template", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Search godoc
+// @Summary Search templates
+// @Description Full-text search over template name, description and content, best match first.
+// @Description The query supports quoted phrases, OR and -exclusion. Name matches rank above description matches, which rank above content matches.
+// @Description Snippets of the description and content are HTML escaped and highlight matches with <mark> tags.
+// @Tags templates
+// @Produce json
+// @Param q query string true "Search query"
+// @Param environment query string false "Environment slug"
+// @Param selector query string false "Tag selector expression"
+// @Param tags query string false "Comma separated tag names, all must match"
+// @Param format query string false "Template format" Enums(json, yaml, toml, env)
+// @Param active query bool false "Active flag"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} model.TemplateSearchResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/templates/search [get]
+func (h *Handler) Search(c *gin.Context) {
+	filter, page, ok := bindFilter(c)
+	if !ok {
+		return
+	}
+	filter.Search = c.Query("q")
+
+	resp, err := h.templates.Search(c.Request.Context(), filter, page)
+	if err != nil {
+		h.failed(c, "Failed to search templates", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Query godoc
+// @Summary Query templates by JSON path
+// @Description Finds templates whose default values and/or schema contain items selected by a Postgres JSON path
+// @Description and returns the selected values, e.g. path=`$.database.max_connections ? (@ > 100)` or `$.** ? (@.type == "secret")`.
+// @Description Uses the GIN indexes on default_values and schema; combine with the usual template filters.
+// @Tags templates
+// @Produce json
+// @Param path query string true "JSON path expression"
+// @Param in query string false "Comma separated documents to query" Enums(default_values, schema) default(default_values,schema)
+// @Param environment query string false "Environment slug"
+// @Param selector query string false "Tag selector expression"
+// @Param tags query string false "Comma separated tag names, all must match"
+// @Param format query string false "Template format" Enums(json, yaml, toml, env)
+// @Param active query bool false "Active flag"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} model.TemplatePathQueryResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/templates/query [get]
+func (h *Handler) Query(c *gin.Context) {
+	filter, page, ok := bindFilter(c)
+	if !ok {
+		return
+	}
+	var fields []string
+	for _, field := range strings.Split(c.Query("in"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+
+	resp, err := h.templates.QueryPath(c.Request.Context(), filter, c.Query("path"), fields, page)
+	if err != nil {
+		h.failed(c, "Failed to query templates by JSON path", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Dependencies godoc
+// @Summary Get the include dependencies of a template
+// @Description Templates include other templates of their environment with `{{ include "_logging" . }}`; names starting with _ are partials,
+// @Description which are only rendered where included; `{{ lookup "_db" "pool.size" }}` reads default values of another template.
+// @Description Returns the templates this template includes and looks up, those including and looking it up, and every
+// @Description template affected by changing it because it looks it up or includes it directly or transitively.
+// @Description With as_of the relations are resolved as they were at that moment.
+// @Tags templates
+// @Produce json
+// @Param id path int true "Template ID"
+// @Param as_of query string false "RFC 3339 timestamp of the state to return"
+// @Success 200 {object} model.TemplateDependenciesResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/templates/{id}/dependencies [get]
+func (h *Handler) Dependencies(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	at, err := service.ParseAsOf(c.Query("as_of"))
+	if err != nil {
+		h.failed(c, "Failed to resolve template dependencies", err)
+		return
+	}
+
+	resp, err := h.templates.AsOf(at).Dependencies(c.Request.Context(), id)
+	if err != nil {
+		h.failed(c, "Failed to resolve template dependencies", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// parseID reads the id path parameter, writing a 400 response when invalid
+func parseID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "id must be a positive integer",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// bindFilter reads the template filter and pagination query parameters,
+// writing a 400 response when they are malformed
+func bindFilter(c *gin.Context) (model.TemplateFilter, model.PaginationParams, bool) {
+	var page model.PaginationParams
+	var params model.FilterParams
+	if err := c.ShouldBindQuery(&page); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return model.TemplateFilter{}, page, false
+	}
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return model.TemplateFilter{}, page, false
+	}
+
+	filter := model.TemplateFilter{
+		Environment: c.Query("environment"),
+		Selector:    c.Query("selector"),
+		Search:      params.Search,
+		Format:      model.ConfigFormat(c.Query("format")),
+		Active:      params.Active,
+	}
+	for _, tag := range strings.Split(c.Query("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			filter.Tags = append(filter.Tags, tag)
+		}
+	}
+	return filter, page, true
+}
+
+// failed maps template service errors to HTTP responses
+func (h *Handler) failed(c *gin.Context, msg string, err error) {
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, model.ErrorResponse{Error: "template_not_found"})
+		return
+	}
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErr) {
+		code := "invalid_request"
+		switch validationErr.Field {
+		case "selector":
+			code = "invalid_selector"
+		case "path":
+			code = "invalid_path"
+		}
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   code,
+			Message: validationErr.Error(),
+		})
+		return
+	}
+	h.logger.Error().Err(err).Msg(msg)
+	c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+}
@@ -0,0 +1,364 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/company/config-service/internal/auth"
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves webhook subscriptions and their deliveries
+type Handler struct {
+	webhooks *service.WebhookService
+	logger   *logger.Logger
+}
+
+// New creates a new webhook handler
+func New(webhooks *service.WebhookService, log *logger.Logger) *Handler {
+	return &Handler{
+		webhooks: webhooks,
+		logger:   log,
+	}
+}
+
+// List godoc
+// @Summary List webhooks
+// @Description Lists all webhook subscriptions ordered by name, without their secrets.
+// @Tags webhooks
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.WebhookListResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/webhooks [get]
+func (h *Handler) List(c *gin.Context) {
+	resp, err := h.webhooks.List(c.Request.Context())
+	if err != nil {
+		h.writeError(c, err, "Failed to list webhooks")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Get godoc
+// @Summary Get a webhook
+// @Description Returns a webhook subscription with its failure state, without its secret.
+// @Tags webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} model.WebhookSubscription
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/webhooks/{id} [get]
+func (h *Handler) Get(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	resp, err := h.webhooks.Get(c.Request.Context(), id)
+	if err != nil {
+		h.writeError(c, err, "Failed to get webhook")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Create godoc
+// @Summary Create a webhook
+// @Description Subscribes a URL to template events, optionally restricted to an environment, a tag selector and event types (template.created, template.updated, template.deleted).
+// @Description Deliveries are POSTed with the headers X-Webhook-Event, X-Webhook-Delivery, X-Webhook-Timestamp and X-Webhook-Signature, which is sha256= followed by the hex HMAC-SHA256 of the timestamp, a dot and the body, keyed with the secret.
+// @Description A secret is generated when none is given; it is only returned by this call.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.CreateWebhookRequest true "Webhook"
+// @Success 201 {object} model.WebhookResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/webhooks [post]
+func (h *Handler) Create(c *gin.Context) {
+	var req model.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	actor := auth.FromContext(c.Request.Context()).Name
+	resp, err := h.webhooks.Create(c.Request.Context(), req, actor)
+	if err != nil {
+		h.writeError(c, err, "Failed to create webhook")
+		return
+	}
+
+	h.logger.Info().
+		Int64("webhook_id", resp.ID).
+		Str("name", resp.Name).
+		Str("actor", actor).
+		Msg("Webhook created")
+	c.JSON(http.StatusCreated, resp)
+}
+
+// Update godoc
+// @Summary Update a webhook
+// @Description Changes the fields of a webhook subscription that are set. An empty environment subscribes to all environments and an empty event_types list to all types.
+// @Description Setting active to true re-enables a disabled subscription and clears its failures; events raised while it was disabled are not delivered. The secret is returned when changed.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook ID"
+// @Param request body model.UpdateWebhookRequest true "Changes"
+// @Success 200 {object} model.WebhookResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/webhooks/{id} [put]
+func (h *Handler) Update(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	var req model.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: err.Error()})
+		return
+	}
+
+	actor := auth.FromContext(c.Request.Context()).Name
+	resp, err := h.webhooks.Update(c.Request.Context(), id, req, actor)
+	if err != nil {
+		h.writeError(c, err, "Failed to update webhook")
+		return
+	}
+
+	h.logger.Info().
+		Int64("webhook_id", resp.ID).
+		Bool("active", resp.Active).
+		Str("actor", actor).
+		Msg("Webhook updated")
+	c.JSON(http.StatusOK, resp)
+}
+
+// Delete godoc
+// @Summary Delete a webhook
+// @Description Removes a webhook subscription and its deliveries.
+// @Tags webhooks
+// @Security BearerAuth
+// @Param id path int true "Webhook ID"
+// @Success 204
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/webhooks/{id} [delete]
+func (h *Handler) Delete(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	actor := auth.FromContext(c.Request.Context()).Name
+	if err := h.webhooks.Delete(c.Request.Context(), id, actor); err != nil {
+		h.writeError(c, err, "Failed to delete webhook")
+		return
+	}
+
+	h.logger.Info().
+		Int64("webhook_id", id).
+		Str("actor", actor).
+		Msg("Webhook deleted")
+	c.Status(http.StatusNoContent)
+}
+
+// Ping godoc
+// @Summary Ping a webhook
+// @Description Queues a ping event for the subscription alone to test its endpoint. The delivery is retried like any other.
+// @Tags webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook ID"
+// @Success 202 {object} model.WebhookDelivery
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/webhooks/{id}/ping [post]
+func (h *Handler) Ping(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	actor := auth.FromContext(c.Request.Context()).Name
+	resp, err := h.webhooks.Ping(c.Request.Context(), id, actor)
+	if err != nil {
+		h.writeError(c, err, "Failed to ping webhook")
+		return
+	}
+	c.JSON(http.StatusAccepted, resp)
+}
+
+// Deliveries godoc
+// @Summary List webhook deliveries
+// @Description Lists the latest deliveries of a subscription, newest first, with their status, attempts and last response.
+// @Tags webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook ID"
+// @Param status query string false "Delivery status" Enums(pending, succeeded, failed)
+// @Param limit query int false "Maximum number of deliveries (1-200)" default(50)
+// @Success 200 {object} model.WebhookDeliveryListResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/webhooks/{id}/deliveries [get]
+func (h *Handler) Deliveries(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil {
+			c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: "limit must be an integer"})
+			return
+		}
+	}
+
+	resp, err := h.webhooks.Deliveries(c.Request.Context(), id, c.Query("status"), limit)
+	if err != nil {
+		h.writeError(c, err, "Failed to list webhook deliveries")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Delivery godoc
+// @Summary Get a webhook delivery
+// @Description Returns a delivery with the log of its attempts: response code, the start of the response body, error and duration.
+// @Tags webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook ID"
+// @Param delivery_id path int true "Delivery ID"
+// @Success 200 {object} model.WebhookDelivery
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/webhooks/{id}/deliveries/{delivery_id} [get]
+func (h *Handler) Delivery(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := parseID(c, "delivery_id")
+	if !ok {
+		return
+	}
+	resp, err := h.webhooks.Delivery(c.Request.Context(), id, deliveryID)
+	if err != nil {
+		h.writeError(c, err, "Failed to get webhook delivery")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Redeliver godoc
+// @Summary Redeliver a webhook event
+// @Description Queues a new delivery of the event of a succeeded or failed delivery, due now. The payload, including the event ID, is unchanged.
+// @Tags webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook ID"
+// @Param delivery_id path int true "Delivery ID"
+// @Success 202 {object} model.WebhookDelivery
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /api/v1/webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func (h *Handler) Redeliver(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := parseID(c, "delivery_id")
+	if !ok {
+		return
+	}
+
+	actor := auth.FromContext(c.Request.Context()).Name
+	resp, err := h.webhooks.Redeliver(c.Request.Context(), id, deliveryID, actor)
+	if err != nil {
+		h.writeError(c, err, "Failed to redeliver webhook event")
+		return
+	}
+
+	h.logger.Info().
+		Int64("webhook_id", id).
+		Int64("delivery_id", deliveryID).
+		Int64("event_id", resp.EventID).
+		Str("actor", actor).
+		Msg("Webhook event redelivered")
+	c.JSON(http.StatusAccepted, resp)
+}
+
+// writeError maps service errors to responses, logging unexpected ones
+func (h *Handler) writeError(c *gin.Context, err error, msg string) {
+	var validationErr *service.ValidationError
+	var conflictErr *service.ConflictError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{Error: "invalid_request", Message: validationErr.Error()})
+	case errors.As(err, &conflictErr):
+		c.JSON(http.StatusConflict, model.ErrorResponse{Error: "webhook_conflict", Message: conflictErr.Error()})
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, model.ErrorResponse{
+			Error:   "webhook_not_found",
+			Message: "Webhook or delivery not found",
+		})
+	default:
+		h.logger.Error().Err(err).Msg(msg)
+		c.JSON(http.StatusInternalServerError, model.ErrorResponse{Error: "internal_error"})
+	}
+}
+
+// parseID reads a positive integer path parameter, writing a 400 response
+// when invalid
+func parseID(c *gin.Context, param string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(param), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Error:   "invalid_id",
+			Message: param + " must be a positive integer",
+		})
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,190 @@
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/company/config-service/internal/model"
+)
+
+// Supported archive encodings
+const (
+	EncodingJSON  = "json"
+	EncodingTarGz = "tar.gz"
+)
+
+// Entry names inside a tar.gz archive
+const (
+	manifestEntry     = "manifest.json"
+	environmentsEntry = "environments.json"
+	tagsEntry         = "tags.json"
+	templatesEntry    = "templates.json"
+	templateTagsEntry = "template_tags.json"
+)
+
+// maxEntrySize bounds a single tar entry to guard against decompression bombs
+const maxEntrySize = 256 << 20
+
+type manifest struct {
+	FormatVersion int            `json:"format_version"`
+	ExportedAt    time.Time      `json:"exported_at"`
+	Counts        map[string]int `json:"counts"`
+}
+
+// Write encodes the archive in the given encoding
+func Write(w io.Writer, a *model.Archive, encoding string) error {
+	switch encoding {
+	case EncodingJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(a)
+	case EncodingTarGz:
+		return writeTarGz(w, a)
+	default:
+		return fmt.Errorf("unsupported archive encoding %q", encoding)
+	}
+}
+
+// Read decodes an archive, detecting gzip compressed tarballs by their magic bytes
+func Read(r io.Reader) (*model.Archive, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	var a *model.Archive
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		a, err = readTarGz(br)
+	} else {
+		a = &model.Archive{}
+		err = json.NewDecoder(br).Decode(a)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode archive: %w", err)
+	}
+
+	if a.FormatVersion != model.ArchiveFormatVersion {
+		return nil, fmt.Errorf("unsupported archive format version %d, expected %d",
+			a.FormatVersion, model.ArchiveFormatVersion)
+	}
+	return a, nil
+}
+
+func writeTarGz(w io.Writer, a *model.Archive) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	entries := []struct {
+		name  string
+		value interface{}
+	}{
+		{manifestEntry, manifest{
+			FormatVersion: a.FormatVersion,
+			ExportedAt:    a.ExportedAt,
+			Counts: map[string]int{
+				"environments":  len(a.Environments),
+				"tags":          len(a.Tags),
+				"templates":     len(a.Templates),
+				"template_tags": len(a.TemplateTags),
+			},
+		}},
+		{environmentsEntry, a.Environments},
+		{tagsEntry, a.Tags},
+		{templatesEntry, a.Templates},
+		{templateTagsEntry, a.TemplateTags},
+	}
+
+	for _, entry := range entries {
+		data, err := json.MarshalIndent(entry.value, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", entry.name, err)
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    entry.name,
+			Mode:    0o644,
+			Size:    int64(len(data)),
+			ModTime: a.ExportedAt,
+		}); err != nil {
+			return fmt.Errorf("failed to write %s header: %w", entry.name, err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", entry.name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func readTarGz(r io.Reader) (*model.Archive, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	a := &model.Archive{}
+	seenManifest := false
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		var target interface{}
+		var m manifest
+		switch hdr.Name {
+		case manifestEntry:
+			target = &m
+		case environmentsEntry:
+			target = &a.Environments
+		case tagsEntry:
+			target = &a.Tags
+		case templatesEntry:
+			target = &a.Templates
+		case templateTagsEntry:
+			target = &a.TemplateTags
+		default:
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(tr, maxEntrySize+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+		}
+		if len(data) > maxEntrySize {
+			return nil, fmt.Errorf("%s exceeds %d bytes", hdr.Name, maxEntrySize)
+		}
+		if err := json.NewDecoder(bytes.NewReader(data)).Decode(target); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", hdr.Name, err)
+		}
+
+		if hdr.Name == manifestEntry {
+			seenManifest = true
+			a.FormatVersion = m.FormatVersion
+			a.ExportedAt = m.ExportedAt
+		}
+	}
+
+	if !seenManifest {
+		return nil, fmt.Errorf("%s missing from archive", manifestEntry)
+	}
+	return a, nil
+}
@@ -0,0 +1,151 @@
+// Package auth authenticates API callers by bearer token and carries their
+// permissions through the request context.
+//
+// Tokens are listed in a YAML file; each entry names its holder, the hex
+// encoded SHA-256 hash of the token and the permissions it grants:
+//
+//	tokens:
+//	  - name: deploy-bot
+//	    sha256: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+//	    permissions: [secrets:read]
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Permission names an operation a principal may perform
+type Permission string
+
+// Known permissions
+const (
+	// SecretsRead allows secret template values to be decrypted during render
+	SecretsRead Permission = "secrets:read"
+	// SecretsAdmin allows master key rotations to be started and paused
+	SecretsAdmin Permission = "secrets:admin"
+	// ChangesApprove allows change requests of protected environments to be
+	// approved and rejected
+	ChangesApprove Permission = "changes:approve"
+	// EnvironmentsAdmin allows environments to be protected and unprotected
+	EnvironmentsAdmin Permission = "environments:admin"
+	// WebhooksAdmin allows webhook subscriptions and their deliveries to be
+	// managed
+	WebhooksAdmin Permission = "webhooks:admin"
+	// ArchiveAdmin allows the whole configuration store to be exported and
+	// imported
+	ArchiveAdmin Permission = "archive:admin"
+)
+
+var knownPermissions = []Permission{SecretsRead, SecretsAdmin, ChangesApprove, EnvironmentsAdmin, WebhooksAdmin, ArchiveAdmin}
+
+// ErrInvalidToken is returned for a bearer token that is not listed
+var ErrInvalidToken = errors.New("invalid token")
+
+// Principal is an authenticated API caller
+type Principal struct {
+	Name        string
+	Permissions []Permission
+}
+
+// Anonymous is the principal of requests without a token
+var Anonymous = &Principal{Name: "anonymous"}
+
+// Has reports whether the principal holds the permission
+func (p *Principal) Has(perm Permission) bool {
+	return p != nil && slices.Contains(p.Permissions, perm)
+}
+
+type token struct {
+	Name        string       `yaml:"name"`
+	SHA256      string       `yaml:"sha256"`
+	Permissions []Permission `yaml:"permissions"`
+}
+
+type tokensFile struct {
+	Tokens []token `yaml:"tokens"`
+}
+
+// Tokens authenticates bearer tokens against a fixed list
+type Tokens struct {
+	principals map[[sha256.Size]byte]*Principal
+}
+
+// LoadTokens reads the token list from a YAML file. An empty path yields a
+// list accepting no tokens.
+func LoadTokens(path string) (*Tokens, error) {
+	t := &Tokens{principals: map[[sha256.Size]byte]*Principal{}}
+	if path == "" {
+		return t, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tokens file: %w", err)
+	}
+	var file tokensFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse tokens file: %w", err)
+	}
+
+	for i, entry := range file.Tokens {
+		if entry.Name == "" {
+			return nil, fmt.Errorf("token %d has no name", i)
+		}
+		sum, err := hex.DecodeString(entry.SHA256)
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("token %s: sha256 must be a hex encoded SHA-256 hash", entry.Name)
+		}
+		for _, perm := range entry.Permissions {
+			if !slices.Contains(knownPermissions, perm) {
+				return nil, fmt.Errorf("token %s: unknown permission %q", entry.Name, perm)
+			}
+		}
+
+		key := [sha256.Size]byte(sum)
+		if _, dup := t.principals[key]; dup {
+			return nil, fmt.Errorf("token %s: duplicate token hash", entry.Name)
+		}
+		t.principals[key] = &Principal{Name: entry.Name, Permissions: entry.Permissions}
+	}
+	return t, nil
+}
+
+// Authenticate returns the principal holding the token
+func (t *Tokens) Authenticate(raw string) (*Principal, error) {
+	sum := sha256.Sum256([]byte(raw))
+	for key, p := range t.principals {
+		if subtle.ConstantTimeCompare(key[:], sum[:]) == 1 {
+			return p, nil
+		}
+	}
+	return nil, ErrInvalidToken
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal of the request, or Anonymous
+func FromContext(ctx context.Context) *Principal {
+	if p, ok := ctx.Value(principalKey{}).(*Principal); ok {
+		return p
+	}
+	return Anonymous
+}
+
+// Allowed reports whether the caller of the request holds the permission
+func Allowed(ctx context.Context, perm Permission) bool {
+	return FromContext(ctx).Has(perm)
+}
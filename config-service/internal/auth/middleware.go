@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/company/config-service/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware authenticates the bearer token of each request and stores the
+// principal in the request context. Requests without a token proceed as
+// Anonymous; an unknown token is rejected.
+func Middleware(tokens *Tokens) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			c.Next()
+			return
+		}
+
+		raw, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, model.ErrorResponse{
+				Error:   "unauthorized",
+				Message: "Authorization header must be a bearer token",
+			})
+			return
+		}
+		principal, err := tokens.Authenticate(strings.TrimSpace(raw))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, model.ErrorResponse{
+				Error:   "unauthorized",
+				Message: "Invalid token",
+			})
+			return
+		}
+
+		c.Set("principal", principal.Name)
+		c.Request = c.Request.WithContext(WithPrincipal(c.Request.Context(), principal))
+		c.Next()
+	}
+}
+
+// RequireAuthenticated rejects anonymous requests with 401
+func RequireAuthenticated() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if FromContext(c.Request.Context()) != Anonymous {
+			c.Next()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, model.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "A bearer token is required",
+		})
+	}
+}
+
+// Require rejects requests whose principal lacks the permission, with 401
+// for anonymous requests and 403 otherwise
+func Require(perm Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := FromContext(c.Request.Context())
+		if principal.Has(perm) {
+			c.Next()
+			return
+		}
+
+		status, code := http.StatusForbidden, "forbidden"
+		if principal == Anonymous {
+			status, code = http.StatusUnauthorized, "unauthorized"
+		}
+		c.AbortWithStatusJSON(status, model.ErrorResponse{
+			Error:   code,
+			Message: "Permission " + string(perm) + " is required",
+		})
+	}
+}
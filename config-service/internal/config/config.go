@@ -9,12 +9,19 @@ import (
 
 // Config holds all application configuration
 type Config struct {
-	Server   ServerConfig   `envconfig:"SERVER"`
-	Database DatabaseConfig `envconfig:"DATABASE"`
-	Redis    RedisConfig    `envconfig:"REDIS"`
-	Kafka    KafkaConfig    `envconfig:"KAFKA"`
-	Logger   LoggerConfig   `envconfig:"LOGGER"`
-	Metrics  MetricsConfig  `envconfig:"METRICS"`
+	Server    ServerConfig    `envconfig:"SERVER"`
+	Database  DatabaseConfig  `envconfig:"DATABASE"`
+	Redis     RedisConfig     `envconfig:"REDIS"`
+	Kafka     KafkaConfig     `envconfig:"KAFKA"`
+	Tags      TagsConfig      `envconfig:"TAGS"`
+	Render    RenderConfig    `envconfig:"RENDER"`
+	Auth      AuthConfig      `envconfig:"AUTH"`
+	Secrets   SecretsConfig   `envconfig:"SECRETS"`
+	Scheduler SchedulerConfig `envconfig:"SCHEDULER"`
+	Drift     DriftConfig     `envconfig:"DRIFT"`
+	Webhooks  WebhooksConfig  `envconfig:"WEBHOOKS"`
+	Logger    LoggerConfig    `envconfig:"LOGGER"`
+	Metrics   MetricsConfig   `envconfig:"METRICS"`
 }
 
 // ServerConfig contains HTTP server configuration
@@ -55,6 +62,87 @@ type KafkaConfig struct {
 	Topic   string   `envconfig:"TOPIC" default:"config-events"`
 }
 
+// TagsConfig contains tag and label configuration
+type TagsConfig struct {
+	// LabelKeys restricts the keys of key/value labels; empty allows any key
+	LabelKeys []string `envconfig:"LABEL_KEYS"`
+}
+
+// RenderConfig contains template rendering limits and options
+type RenderConfig struct {
+	Timeout        time.Duration `envconfig:"TIMEOUT" default:"2s"`
+	MaxOutputBytes int           `envconfig:"MAX_OUTPUT_BYTES" default:"1048576"`
+	// AllowEnv enables the env template function; EnvAllowlist restricts it
+	// to the listed variables
+	AllowEnv     bool     `envconfig:"ALLOW_ENV" default:"false"`
+	EnvAllowlist []string `envconfig:"ENV_ALLOWLIST"`
+}
+
+// AuthConfig contains API authentication configuration
+type AuthConfig struct {
+	// TokensFile lists the API tokens and their permissions; without it
+	// every request is anonymous and holds no permissions
+	TokensFile string `envconfig:"TOKENS_FILE" default:""`
+}
+
+// SecretsConfig contains the master key encrypting secret template values,
+// given either base64 encoded, as a file holding the key or as a keyring file
+// of versioned keys, and the pacing of the re-encryption job
+type SecretsConfig struct {
+	MasterKey     string `envconfig:"MASTER_KEY" default:""`
+	MasterKeyFile string `envconfig:"MASTER_KEY_FILE" default:""`
+	KeyringFile   string `envconfig:"KEYRING_FILE" default:""`
+	// RotationBatchSize is the number of templates rewrapped per transaction
+	RotationBatchSize int `envconfig:"ROTATION_BATCH_SIZE" default:"100"`
+	// RotationInterval is how often a paused or interrupted job is checked for
+	RotationInterval time.Duration `envconfig:"ROTATION_INTERVAL" default:"10s"`
+	// RotationLease is how long a replica may go silent before another one
+	// takes over its job
+	RotationLease time.Duration `envconfig:"ROTATION_LEASE" default:"1m"`
+}
+
+// SchedulerConfig contains the pacing of the scheduled deployment runner
+type SchedulerConfig struct {
+	// Interval is how often due deployments are looked for
+	Interval time.Duration `envconfig:"INTERVAL" default:"15s"`
+	// Lease is how long the replica applying deployments may go silent
+	// before another one takes over
+	Lease time.Duration `envconfig:"LEASE" default:"1m"`
+}
+
+// DriftConfig contains the handling of client check-ins in drift reports
+type DriftConfig struct {
+	// StaleAfter is how long an instance may go without checking in before
+	// drift reports only count it as stale
+	StaleAfter time.Duration `envconfig:"STALE_AFTER" default:"10m"`
+	// Retention is how long the check-in of a silent instance is kept
+	Retention time.Duration `envconfig:"RETENTION" default:"168h"`
+}
+
+// WebhooksConfig contains the pacing of webhook deliveries and when failing
+// subscriptions are disabled
+type WebhooksConfig struct {
+	// Interval is how often new events are matched and due deliveries sent
+	Interval time.Duration `envconfig:"INTERVAL" default:"5s"`
+	// Lease is how long the replica delivering webhooks may go silent before
+	// another one takes over
+	Lease time.Duration `envconfig:"LEASE" default:"1m"`
+	// Timeout bounds each delivery request
+	Timeout time.Duration `envconfig:"TIMEOUT" default:"10s"`
+	// Concurrency is the number of deliveries sent at once
+	Concurrency int `envconfig:"CONCURRENCY" default:"8"`
+	// MaxAttempts is the number of attempts after which a delivery fails;
+	// retries wait RetryBase, doubling up to RetryMax
+	MaxAttempts int           `envconfig:"MAX_ATTEMPTS" default:"8"`
+	RetryBase   time.Duration `envconfig:"RETRY_BASE" default:"30s"`
+	RetryMax    time.Duration `envconfig:"RETRY_MAX" default:"1h"`
+	// DisableAfter is how long a subscription may fail every delivery before
+	// it is disabled
+	DisableAfter time.Duration `envconfig:"DISABLE_AFTER" default:"24h"`
+	// Retention is how long events and their delivery logs are kept
+	Retention time.Duration `envconfig:"RETENTION" default:"720h"`
+}
+
 // LoggerConfig contains logging configuration
 type LoggerConfig struct {
 	Level  string `envconfig:"LEVEL" default:"info"`
@@ -67,6 +155,42 @@ type MetricsConfig struct {
 	Path    string `envconfig:"PATH" default:"/metrics"`
 }
 
+// AgentConfig holds configuration of the config agent sidecar
+type AgentConfig struct {
+	Agent   AgentSettings `envconfig:"AGENT"`
+	Logger  LoggerConfig  `envconfig:"LOGGER"`
+	Metrics MetricsConfig `envconfig:"METRICS"`
+}
+
+// AgentSettings contains bundle sync, file output and reload configuration
+type AgentSettings struct {
+	ServerURL string `envconfig:"SERVER_URL" default:"http://localhost:8080"`
+	AuthToken string `envconfig:"AUTH_TOKEN" default:""`
+	// InstanceID identifies the agent to the server, which picks the variant
+	// of templates under rollout by it; defaults to the host name
+	InstanceID     string            `envconfig:"INSTANCE_ID" default:""`
+	Environment    string            `envconfig:"ENVIRONMENT" required:"true"`
+	Tags           []string          `envconfig:"TAGS"`
+	Selector       string            `envconfig:"SELECTOR" default:""`
+	TargetDir      string            `envconfig:"TARGET_DIR" required:"true"`
+	FileMode       string            `envconfig:"FILE_MODE" default:"0644"`
+	DirMode        string            `envconfig:"DIR_MODE" default:"0755"`
+	FileModes      map[string]string `envconfig:"FILE_MODES"`
+	Prune          bool              `envconfig:"PRUNE" default:"true"`
+	SyncInterval   time.Duration     `envconfig:"SYNC_INTERVAL" default:"30s"`
+	RequestTimeout time.Duration     `envconfig:"REQUEST_TIMEOUT" default:"10s"`
+	StaleAfter     time.Duration     `envconfig:"STALE_AFTER" default:"5m"`
+	ReloadCommand  string            `envconfig:"RELOAD_COMMAND" default:""`
+	ReloadSignal   string            `envconfig:"RELOAD_SIGNAL" default:""`
+	ReloadPIDFile  string            `envconfig:"RELOAD_PID_FILE" default:""`
+	ReloadTimeout  time.Duration     `envconfig:"RELOAD_TIMEOUT" default:"30s"`
+	HTTPHost       string            `envconfig:"HTTP_HOST" default:"0.0.0.0"`
+	HTTPPort       string            `envconfig:"HTTP_PORT" default:"8081"`
+	// CheckIn reports the applied templates to the server after every sync
+	// for drift detection
+	CheckIn bool `envconfig:"CHECK_IN" default:"true"`
+}
+
 // Load reads configuration from environment variables
 func Load() (*Config, error) {
 	var cfg Config
@@ -76,6 +200,15 @@ func Load() (*Config, error) {
 	return &cfg, nil
 }
 
+// LoadAgent reads config agent configuration from environment variables
+func LoadAgent() (*AgentConfig, error) {
+	var cfg AgentConfig
+	if err := envconfig.Process("", &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
 // GetDSN returns PostgreSQL connection string
 func (d DatabaseConfig) GetDSN() string {
 	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
@@ -0,0 +1,217 @@
+// Package cron parses five-field cron expressions and matches them against
+// times, to the minute.
+//
+// The fields are minute (0-59), hour (0-23), day of month (1-31), month
+// (1-12 or JAN-DEC) and day of week (0-7 or SUN-SAT, with 0 and 7 both
+// Sunday). Each field is "*" or a comma separated list of values and
+// ranges, optionally stepped:
+//
+//	0 12 * * FRI        Fridays at noon
+//	*/15 9-17 * * 1-5   every 15 minutes during office hours
+//	0 0 24-26 DEC *     midnight from Christmas Eve to Boxing Day
+//
+// As in classic cron, when both day of month and day of week are restricted
+// a time matches if either does.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record unrestricted day fields
+	domStar, dowStar bool
+	expr             string
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12,
+		names: []string{"", "JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}}
+	dowField = field{name: "day of week", min: 0, max: 7,
+		names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}}
+)
+
+// Parse parses a five-field cron expression
+func Parse(expr string) (*Schedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields, has %d", expr, len(parts))
+	}
+
+	s := &Schedule{expr: strings.Join(parts, " ")}
+	var err error
+	if s.minute, err = minuteField.parse(parts[0]); err != nil {
+		return nil, err
+	}
+	if s.hour, err = hourField.parse(parts[1]); err != nil {
+		return nil, err
+	}
+	if s.dom, err = domField.parse(parts[2]); err != nil {
+		return nil, err
+	}
+	if s.month, err = monthField.parse(parts[3]); err != nil {
+		return nil, err
+	}
+	if s.dow, err = dowField.parse(parts[4]); err != nil {
+		return nil, err
+	}
+	// 7 is another name for Sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar, s.dowStar = parts[2] == "*", parts[4] == "*"
+	return s, nil
+}
+
+// String returns the normalized expression
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Matches reports whether the minute of t matches the schedule, in the
+// location of t
+func (s *Schedule) Matches(t time.Time) bool {
+	return s.minute&(1<<uint(t.Minute())) != 0 &&
+		s.hour&(1<<uint(t.Hour())) != 0 &&
+		s.month&(1<<uint(t.Month())) != 0 &&
+		s.matchesDay(t)
+}
+
+func (s *Schedule) matchesDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Prev returns the latest matching minute at or before t that is not before
+// t minus within, and whether there is one
+func (s *Schedule) Prev(t time.Time, within time.Duration) (time.Time, bool) {
+	earliest := t.Add(-within)
+	m := t.Truncate(time.Minute)
+	for !m.Before(earliest) {
+		if s.month&(1<<uint(m.Month())) == 0 || !s.matchesDay(m) {
+			// Skip to the last minute of the previous day
+			m = startOfDay(m).Add(-time.Minute)
+			continue
+		}
+		if s.hour&(1<<uint(m.Hour())) == 0 {
+			m = m.Add(-time.Duration(m.Minute()+1) * time.Minute)
+			continue
+		}
+		if s.minute&(1<<uint(m.Minute())) != 0 {
+			return m, true
+		}
+		m = m.Add(-time.Minute)
+	}
+	return time.Time{}, false
+}
+
+// Next returns the earliest matching minute after t that is not after t
+// plus within, and whether there is one
+func (s *Schedule) Next(t time.Time, within time.Duration) (time.Time, bool) {
+	latest := t.Add(within)
+	m := t.Truncate(time.Minute).Add(time.Minute)
+	for !m.After(latest) {
+		if s.month&(1<<uint(m.Month())) == 0 || !s.matchesDay(m) {
+			m = startOfDay(time.Date(m.Year(), m.Month(), m.Day()+1, 12, 0, 0, 0, m.Location()))
+			continue
+		}
+		if s.hour&(1<<uint(m.Hour())) == 0 {
+			m = m.Add(time.Duration(60-m.Minute()) * time.Minute)
+			continue
+		}
+		if s.minute&(1<<uint(m.Minute())) != 0 {
+			return m, true
+		}
+		m = m.Add(time.Minute)
+	}
+	return time.Time{}, false
+}
+
+// startOfDay returns the first minute of the day of t. Hours are skipped by
+// adding minutes rather than with time.Date, which moves a wall time skipped
+// by a daylight saving change to before it and so could step back.
+func startOfDay(t time.Time) time.Time {
+	d := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for d.Day() != t.Day() {
+		// Midnight was skipped; the day starts when the clocks go forward
+		d = d.Add(time.Hour)
+	}
+	return d
+}
+
+// parse parses one field into a bit set of the values it matches
+func (f field) parse(spec string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(spec, ",") {
+		lo, hi, step := f.min, f.max, 1
+
+		rng := item
+		if i := strings.IndexByte(item, '/'); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("cron %s: invalid step in %q", f.name, item)
+			}
+			rng, step = item[:i], n
+		}
+
+		if rng != "*" {
+			var err error
+			from, to, isRange := strings.Cut(rng, "-")
+			if lo, err = f.value(from); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(to); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// "5/15" steps from 5 to the end of the field
+				hi = f.max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("cron %s: range %q is reversed", f.name, rng)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// value parses a single number or name of the field
+func (f field) value(s string) (int, error) {
+	for i, name := range f.names {
+		if name != "" && strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("cron %s: invalid value %q", f.name, s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("cron %s: %d is outside %d-%d", f.name, v, f.min, f.max)
+	}
+	return v, nil
+}
@@ -0,0 +1,218 @@
+package cron
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+		err  string
+	}{
+		{expr: "0 12 * * FRI", want: "0 12 * * FRI"},
+		{expr: "  */15   9-17 * * 1-5 ", want: "*/15 9-17 * * 1-5"},
+		{expr: "0 0 24-26 dec *", want: "0 0 24-26 dec *"},
+		{expr: "5/15 * * * *", want: "5/15 * * * *"},
+		{expr: "0 0 * *", err: "must have 5 fields, has 4"},
+		{expr: "60 * * * *", err: "cron minute: 60 is outside 0-59"},
+		{expr: "* 24 * * *", err: "cron hour: 24 is outside 0-23"},
+		{expr: "* * 0 * *", err: "cron day of month: 0 is outside 1-31"},
+		{expr: "* * * FOO *", err: `cron month: invalid value "FOO"`},
+		{expr: "* * * * 8", err: "cron day of week: 8 is outside 0-7"},
+		{expr: "*/0 * * * *", err: `cron minute: invalid step in "*/0"`},
+		{expr: "30-10 * * * *", err: `cron minute: range "30-10" is reversed`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Parse(%q) error = %v, want %q", tt.expr, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.expr, err)
+			}
+			if s.String() != tt.want {
+				t.Errorf("Parse(%q) = %q, want %q", tt.expr, s.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		expr string
+		at   time.Time
+		want bool
+	}{
+		{"0 12 * * FRI", date(2026, 10, 16, 12, 0), true},
+		{"0 12 * * FRI", date(2026, 10, 16, 12, 1), false},
+		{"0 12 * * FRI", date(2026, 10, 17, 12, 0), false},
+		{"*/15 9-17 * * 1-5", date(2026, 10, 19, 17, 45), true},
+		{"*/15 9-17 * * 1-5", date(2026, 10, 19, 18, 0), false},
+		{"5/15 * * * *", date(2026, 10, 19, 0, 50), true},
+		{"5/15 * * * *", date(2026, 10, 19, 0, 0), false},
+		// 7 is Sunday too
+		{"0 0 * * 7", date(2026, 10, 18, 0, 0), true},
+		// Restricted day of month and day of week match if either does
+		{"0 0 13 * FRI", date(2026, 10, 13, 0, 0), true},
+		{"0 0 13 * FRI", date(2026, 10, 16, 0, 0), true},
+		{"0 0 13 * FRI", date(2026, 10, 14, 0, 0), false},
+		{"0 0 13 * *", date(2026, 10, 16, 0, 0), false},
+	}
+	for _, tt := range tests {
+		s := mustParse(t, tt.expr)
+		if got := s.Matches(tt.at); got != tt.want {
+			t.Errorf("%q matches %s = %v, want %v", tt.expr, tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	ny := location(t, "America/New_York")
+	tests := []struct {
+		name   string
+		expr   string
+		from   time.Time
+		within time.Duration
+		want   time.Time
+		ok     bool
+	}{
+		{
+			name: "same hour", expr: "*/15 * * * *",
+			from: date(2026, 10, 19, 9, 7), within: time.Hour,
+			want: date(2026, 10, 19, 9, 15), ok: true,
+		},
+		{
+			name: "strictly after", expr: "0 12 * * *",
+			from: date(2026, 10, 19, 12, 0), within: 48 * time.Hour,
+			want: date(2026, 10, 20, 12, 0), ok: true,
+		},
+		{
+			name: "skips short months", expr: "0 0 31 * *",
+			from: date(2026, 4, 15, 0, 0), within: 60 * 24 * time.Hour,
+			want: date(2026, 5, 31, 0, 0), ok: true,
+		},
+		{
+			name: "end of year", expr: "0 0 1 * *",
+			from: date(2026, 12, 31, 23, 59), within: time.Hour,
+			want: date(2027, 1, 1, 0, 0), ok: true,
+		},
+		{
+			name: "leap day", expr: "0 0 29 FEB *",
+			from: date(2026, 1, 1, 0, 0), within: 4 * 366 * 24 * time.Hour,
+			want: date(2028, 2, 29, 0, 0), ok: true,
+		},
+		{
+			name: "out of range", expr: "0 0 29 FEB *",
+			from: date(2026, 1, 1, 0, 0), within: 365 * 24 * time.Hour,
+		},
+		{
+			// 02:30 does not exist when clocks spring forward
+			name: "spring forward", expr: "30 2 * * *",
+			from: time.Date(2026, 3, 8, 1, 0, 0, 0, ny), within: 48 * time.Hour,
+			want: time.Date(2026, 3, 9, 2, 30, 0, 0, ny), ok: true,
+		},
+		{
+			name: "spring forward hour", expr: "0 3 * * *",
+			from: time.Date(2026, 3, 8, 1, 59, 0, 0, ny), within: time.Hour,
+			want: time.Date(2026, 3, 8, 3, 0, 0, 0, ny), ok: true,
+		},
+		{
+			// 01:30 happens twice when clocks fall back
+			name: "fall back", expr: "30 1 * * *",
+			from: time.Date(2026, 11, 1, 1, 30, 0, 0, ny), within: 2 * time.Hour,
+			want: time.Date(2026, 11, 1, 1, 30, 0, 0, ny).Add(time.Hour), ok: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := mustParse(t, tt.expr).Next(tt.from, tt.within)
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, %v, want %s, %v", tt.from, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestPrev(t *testing.T) {
+	ny := location(t, "America/New_York")
+	tests := []struct {
+		name   string
+		expr   string
+		from   time.Time
+		within time.Duration
+		want   time.Time
+		ok     bool
+	}{
+		{
+			name: "at the minute", expr: "0 12 * * *",
+			from: date(2026, 10, 19, 12, 0).Add(30 * time.Second), within: time.Minute,
+			want: date(2026, 10, 19, 12, 0), ok: true,
+		},
+		{
+			name: "earlier day", expr: "0 12 * * FRI",
+			from: date(2026, 10, 19, 9, 0), within: 7 * 24 * time.Hour,
+			want: date(2026, 10, 16, 12, 0), ok: true,
+		},
+		{
+			name: "too early", expr: "0 12 * * FRI",
+			from: date(2026, 10, 19, 9, 0), within: 24 * time.Hour,
+		},
+		{
+			name: "skips short months", expr: "0 0 31 * *",
+			from: date(2026, 5, 1, 12, 0), within: 60 * 24 * time.Hour,
+			want: date(2026, 3, 31, 0, 0), ok: true,
+		},
+		{
+			name: "start of year", expr: "59 23 31 12 *",
+			from: date(2027, 1, 1, 0, 0), within: time.Hour,
+			want: date(2026, 12, 31, 23, 59), ok: true,
+		},
+		{
+			name: "spring forward", expr: "30 2 * * *",
+			from: time.Date(2026, 3, 8, 12, 0, 0, 0, ny), within: 48 * time.Hour,
+			want: time.Date(2026, 3, 7, 2, 30, 0, 0, ny), ok: true,
+		},
+		{
+			name: "fall back", expr: "30 1 * * *",
+			from: time.Date(2026, 11, 1, 3, 0, 0, 0, ny), within: 6 * time.Hour,
+			want: time.Date(2026, 11, 1, 1, 30, 0, 0, ny).Add(time.Hour), ok: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := mustParse(t, tt.expr).Prev(tt.from, tt.within)
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Errorf("Prev(%s) = %s, %v, want %s, %v", tt.from, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func date(year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+}
+
+func mustParse(t *testing.T, expr string) *Schedule {
+	t.Helper()
+	s, err := Parse(expr)
+	if err != nil {
+		t.Fatalf("Parse(%q) error: %v", expr, err)
+	}
+	return s
+}
+
+func location(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s not available: %v", name, err)
+	}
+	return loc
+}
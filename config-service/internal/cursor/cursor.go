@@ -0,0 +1,45 @@
+// Package cursor implements the opaque keyset pagination cursors returned by
+// list endpoints. A cursor records the sort column and order of the listing
+// and the sort value and ID of the row a page starts after, or ends before
+// when paging backwards.
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalid is returned for cursors that cannot be decoded
+var ErrInvalid = errors.New("invalid cursor")
+
+// Cursor is the decoded form of an opaque cursor
+type Cursor struct {
+	SortBy    string `json:"s"`
+	SortOrder string `json:"o"`
+	Value     string `json:"v"`
+	ID        int64  `json:"i"`
+	Backward  bool   `json:"b,omitempty"`
+}
+
+// Encode returns the opaque string form of a cursor
+func Encode(c Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode parses an opaque cursor
+func Decode(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.SortBy == "" || c.ID <= 0 {
+		return nil, ErrInvalid
+	}
+	if c.SortOrder != "asc" && c.SortOrder != "desc" {
+		return nil, ErrInvalid
+	}
+	return &c, nil
+}
@@ -66,6 +66,27 @@ func (c *Connection) HealthCheck() error {
 	return nil
 }
 
+// Transaction runs fn inside a database transaction. The transaction is
+// committed when fn returns nil and rolled back otherwise.
+func (c *Connection) Transaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			c.logger.Error().Err(rbErr).Msg("Failed to rollback transaction")
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
 // Stats returns database connection statistics
 func (c *Connection) Stats() sql.DBStats {
 	return c.DB.Stats()
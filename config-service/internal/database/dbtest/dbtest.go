@@ -0,0 +1,86 @@
+// Package dbtest provides migrated PostgreSQL databases to tests.
+//
+// Tests using it are skipped unless TEST_DATABASE_HOST names a PostgreSQL
+// server; the remaining TEST_DATABASE_* variables follow the DATABASE_*
+// settings of the service:
+//
+//	TEST_DATABASE_HOST=localhost TEST_DATABASE_PORT=5431 go test ./...
+package dbtest
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/company/config-service/internal/config"
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/logger"
+	"github.com/kelseyhightower/envconfig"
+	"github.com/rs/zerolog"
+)
+
+// New creates an empty database with every migration applied and returns a
+// connection to it. The database is dropped when the test ends.
+func New(t testing.TB) *database.Connection {
+	t.Helper()
+	if os.Getenv("TEST_DATABASE_HOST") == "" {
+		t.Skip("TEST_DATABASE_HOST is not set")
+	}
+
+	var cfg config.DatabaseConfig
+	if err := envconfig.Process("TEST_DATABASE", &cfg); err != nil {
+		t.Fatalf("failed to read test database config: %v", err)
+	}
+	nop := zerolog.Nop()
+	log := &logger.Logger{Logger: &nop}
+
+	admin, err := sql.Open("postgres", cfg.GetDSN())
+	if err != nil {
+		t.Fatalf("failed to open test database server: %v", err)
+	}
+	defer admin.Close()
+
+	name := fmt.Sprintf("%s_test_%d", cfg.Name, time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE DATABASE ` + name); err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	t.Cleanup(func() {
+		admin, err := sql.Open("postgres", cfg.GetDSN())
+		if err != nil {
+			t.Errorf("failed to open test database server: %v", err)
+			return
+		}
+		defer admin.Close()
+		if _, err := admin.Exec(`DROP DATABASE IF EXISTS ` + name + ` WITH (FORCE)`); err != nil {
+			t.Errorf("failed to drop test database: %v", err)
+		}
+	})
+
+	cfg.Name = name
+	cfg.MigrationsPath = "file://" + migrationsDir()
+	conn, err := database.New(cfg, log)
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	t.Cleanup(func() { conn.DB.Close() })
+
+	// The runner is not closed, since closing it closes the shared connection
+	runner, err := database.NewMigrationRunner(conn, cfg, log)
+	if err != nil {
+		t.Fatalf("failed to create migration runner: %v", err)
+	}
+	if err := runner.Up(); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	return conn
+}
+
+// migrationsDir returns the absolute path of the migrations directory
+func migrationsDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "..", "migrations")
+}
@@ -0,0 +1,322 @@
+// Package flags validates feature flags and evaluates them for a context.
+//
+// A disabled flag serves its off variation. An enabled flag serves the first
+// rule whose conditions all match the context, or its fallthrough. A rule or
+// fallthrough serves a fixed variation or a percentage rollout; rollouts place
+// a context in one of 100 buckets by a hash of the flag key and the bucket-by
+// attribute, so a context keeps its variation as weights shift towards it.
+package flags
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/company/config-service/internal/model"
+)
+
+// KeyAttribute is the attribute naming the key of an evaluation context
+const KeyAttribute = "key"
+
+// Error reports an invalid part of a flag
+type Error struct {
+	Field string
+	Msg   string
+}
+
+func (e *Error) Error() string {
+	return e.Field + ": " + e.Msg
+}
+
+// Validate checks that the variations of a flag are unique, that everything
+// served refers to one of them and that conditions can be evaluated
+func Validate(f *model.Flag) error {
+	if f.Type == model.FlagBoolean {
+		if len(f.Variations) != 2 {
+			return &Error{Field: "variations", Msg: "boolean flags have the variations true and false"}
+		}
+		for _, v := range f.Variations {
+			if b, ok := v.Value.(bool); !ok || v.Name != strconv.FormatBool(b) {
+				return &Error{Field: "variations", Msg: "boolean flags have the variations true and false"}
+			}
+		}
+	} else if len(f.Variations) < 2 {
+		return &Error{Field: "variations", Msg: "multivariate flags need at least 2 variations"}
+	}
+
+	names := make(map[string]bool, len(f.Variations))
+	for i, v := range f.Variations {
+		if v.Name == "" {
+			return &Error{Field: fmt.Sprintf("variations[%d].name", i), Msg: "is required"}
+		}
+		if names[v.Name] {
+			return &Error{Field: fmt.Sprintf("variations[%d].name", i), Msg: fmt.Sprintf("variation %q is defined twice", v.Name)}
+		}
+		names[v.Name] = true
+	}
+
+	if !names[f.OffVariation] {
+		return &Error{Field: "off_variation", Msg: fmt.Sprintf("unknown variation %q", f.OffVariation)}
+	}
+	if err := validateServe("fallthrough", f.Fallthrough, names); err != nil {
+		return err
+	}
+	for i, rule := range f.Rules {
+		field := fmt.Sprintf("rules[%d]", i)
+		if len(rule.Conditions) == 0 {
+			return &Error{Field: field + ".conditions", Msg: "at least one condition is required"}
+		}
+		for j, cond := range rule.Conditions {
+			if err := validateCondition(fmt.Sprintf("%s.conditions[%d]", field, j), cond); err != nil {
+				return err
+			}
+		}
+		if err := validateServe(field+".serve", rule.Serve, names); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateServe(field string, s model.FlagServe, names map[string]bool) error {
+	switch {
+	case s.Variation != "" && len(s.Rollout) > 0:
+		return &Error{Field: field, Msg: "serves either a variation or a rollout"}
+	case s.Variation != "":
+		if !names[s.Variation] {
+			return &Error{Field: field + ".variation", Msg: fmt.Sprintf("unknown variation %q", s.Variation)}
+		}
+		return nil
+	case len(s.Rollout) == 0:
+		return &Error{Field: field, Msg: "a variation or a rollout is required"}
+	}
+
+	total := 0
+	for i, w := range s.Rollout {
+		if !names[w.Variation] {
+			return &Error{Field: fmt.Sprintf("%s.rollout[%d].variation", field, i), Msg: fmt.Sprintf("unknown variation %q", w.Variation)}
+		}
+		if w.Weight < 0 {
+			return &Error{Field: fmt.Sprintf("%s.rollout[%d].weight", field, i), Msg: "must not be negative"}
+		}
+		total += w.Weight
+	}
+	if total != 100 {
+		return &Error{Field: field + ".rollout", Msg: fmt.Sprintf("weights add up to %d instead of 100", total)}
+	}
+	return nil
+}
+
+func validateCondition(field string, c model.FlagCondition) error {
+	if c.Operator == model.OpExists {
+		return nil
+	}
+	if len(c.Values) == 0 {
+		return &Error{Field: field + ".values", Msg: "at least one value is required"}
+	}
+	for i, v := range c.Values {
+		vf := fmt.Sprintf("%s.values[%d]", field, i)
+		switch c.Operator {
+		case model.OpContains, model.OpStartsWith, model.OpEndsWith:
+			if _, ok := v.(string); !ok {
+				return &Error{Field: vf, Msg: "must be a string"}
+			}
+		case model.OpMatches:
+			s, ok := v.(string)
+			if !ok {
+				return &Error{Field: vf, Msg: "must be a regular expression"}
+			}
+			if _, err := regexp.Compile(s); err != nil {
+				return &Error{Field: vf, Msg: err.Error()}
+			}
+		case model.OpLessThan, model.OpLessEqual, model.OpGreater, model.OpGreaterEq:
+			if _, ok := v.(float64); !ok {
+				return &Error{Field: vf, Msg: "must be a number"}
+			}
+		}
+	}
+	return nil
+}
+
+// Evaluate returns the variation a valid flag serves a context
+func Evaluate(f *model.Flag, ctx model.EvaluationContext) model.FlagEvaluation {
+	if !f.Enabled {
+		return result(f, f.OffVariation, model.ReasonOff, nil)
+	}
+	for i, rule := range f.Rules {
+		if matchesAll(rule.Conditions, ctx) {
+			index := i
+			return result(f, serve(f, rule.Serve, ctx), model.ReasonRuleMatch, &index)
+		}
+	}
+	return result(f, serve(f, f.Fallthrough, ctx), model.ReasonFallthrough, nil)
+}
+
+func result(f *model.Flag, variation, reason string, rule *int) model.FlagEvaluation {
+	e := model.FlagEvaluation{Variation: variation, Reason: reason, RuleIndex: rule}
+	for _, v := range f.Variations {
+		if v.Name == variation {
+			e.Value = v.Value
+			break
+		}
+	}
+	return e
+}
+
+// serve picks the variation of a fixed serve or of the rollout bucket the
+// context falls in. Contexts without the bucket-by attribute fall in bucket 0.
+func serve(f *model.Flag, s model.FlagServe, ctx model.EvaluationContext) string {
+	if s.Variation != "" {
+		return s.Variation
+	}
+
+	bucket := 0
+	if v, ok := attribute(ctx, bucketBy(f)); ok {
+		bucket = Bucket(f.Key, fmt.Sprint(v))
+	}
+	sum := 0
+	for _, w := range s.Rollout {
+		sum += w.Weight
+		if bucket < sum {
+			return w.Variation
+		}
+	}
+	return s.Rollout[len(s.Rollout)-1].Variation
+}
+
+func bucketBy(f *model.Flag) string {
+	if f.BucketBy == "" {
+		return KeyAttribute
+	}
+	return f.BucketBy
+}
+
+// Bucket places a value in one of 100 buckets for a flag
+func Bucket(flagKey, value string) int {
+	sum := sha256.Sum256([]byte(flagKey + "/" + value))
+	return int(binary.BigEndian.Uint64(sum[:8]) % 100)
+}
+
+func attribute(ctx model.EvaluationContext, name string) (interface{}, bool) {
+	if name == KeyAttribute {
+		return ctx.Key, ctx.Key != ""
+	}
+	v, ok := ctx.Attributes[name]
+	return v, ok && v != nil
+}
+
+func matchesAll(conditions []model.FlagCondition, ctx model.EvaluationContext) bool {
+	for _, c := range conditions {
+		if !matches(c, ctx) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches evaluates a condition. A list attribute matches when one of its
+// elements does; not_in requires that none does.
+func matches(c model.FlagCondition, ctx model.EvaluationContext) bool {
+	v, ok := attribute(ctx, c.Attribute)
+	if c.Operator == model.OpExists {
+		return ok
+	}
+	if !ok {
+		return false
+	}
+
+	items, isList := v.([]interface{})
+	if !isList {
+		items = []interface{}{v}
+	}
+	if c.Operator == model.OpNotIn {
+		for _, item := range items {
+			if matchesAny(model.OpIn, item, c.Values) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, item := range items {
+		if matchesAny(c.Operator, item, c.Values) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(op model.FlagOperator, v interface{}, values []interface{}) bool {
+	for _, want := range values {
+		if compare(op, v, want) {
+			return true
+		}
+	}
+	return false
+}
+
+func compare(op model.FlagOperator, v, want interface{}) bool {
+	switch op {
+	case model.OpIn:
+		return equal(v, want)
+	case model.OpContains, model.OpStartsWith, model.OpEndsWith, model.OpMatches:
+		s, ok := v.(string)
+		w, _ := want.(string)
+		if !ok {
+			return false
+		}
+		switch op {
+		case model.OpContains:
+			return strings.Contains(s, w)
+		case model.OpStartsWith:
+			return strings.HasPrefix(s, w)
+		case model.OpEndsWith:
+			return strings.HasSuffix(s, w)
+		}
+		re, err := regexp.Compile(w)
+		return err == nil && re.MatchString(s)
+	case model.OpLessThan, model.OpLessEqual, model.OpGreater, model.OpGreaterEq:
+		n, ok := number(v)
+		w, _ := want.(float64)
+		if !ok {
+			return false
+		}
+		switch op {
+		case model.OpLessThan:
+			return n < w
+		case model.OpLessEqual:
+			return n <= w
+		case model.OpGreater:
+			return n > w
+		}
+		return n >= w
+	}
+	return false
+}
+
+// equal compares decoded JSON values; numbers also equal their string form
+// so that attributes passed as strings match numeric values
+func equal(v, want interface{}) bool {
+	switch w := want.(type) {
+	case float64:
+		n, ok := number(v)
+		return ok && n == w
+	case string, bool:
+		return v == w
+	}
+	return false
+}
+
+// number reads a JSON number, or a string holding one
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
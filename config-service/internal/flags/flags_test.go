@@ -0,0 +1,271 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/company/config-service/internal/model"
+)
+
+func boolFlag() *model.Flag {
+	return &model.Flag{
+		Key:          "new-checkout",
+		Type:         model.FlagBoolean,
+		Variations:   []model.FlagVariation{{Name: "true", Value: true}, {Name: "false", Value: false}},
+		Enabled:      true,
+		OffVariation: "false",
+		Fallthrough:  model.FlagServe{Variation: "false"},
+	}
+}
+
+func rollout(on int) model.FlagServe {
+	return model.FlagServe{Rollout: []model.FlagWeight{{Variation: "true", Weight: on}, {Variation: "false", Weight: 100 - on}}}
+}
+
+func TestBucket(t *testing.T) {
+	// Buckets must not change between releases, or contexts would switch
+	// variations on upgrade
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"user-1", 37},
+		{"user-2", 20},
+		{"alice", 29},
+		{"bob", 80},
+	}
+	for _, tt := range tests {
+		if got := Bucket("new-checkout", tt.value); got != tt.want {
+			t.Errorf("Bucket(new-checkout, %s) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+
+	counts := make([]int, 100)
+	differ := 0
+	for i := 0; i < 10000; i++ {
+		value := fmt.Sprintf("user-%d", i)
+		b := Bucket("new-checkout", value)
+		if b < 0 || b >= 100 {
+			t.Fatalf("Bucket(%s) = %d, out of range", value, b)
+		}
+		counts[b]++
+		if Bucket("other-flag", value) != b {
+			differ++
+		}
+	}
+	for b, n := range counts {
+		if n < 50 || n > 150 {
+			t.Errorf("bucket %d holds %d of 10000 values", b, n)
+		}
+	}
+	if differ < 9000 {
+		t.Errorf("only %d of 10000 values fall in another bucket for another flag", differ)
+	}
+}
+
+func TestRolloutStability(t *testing.T) {
+	f := boolFlag()
+	served := make(map[string]bool)
+	for _, on := range []int{0, 10, 25, 50, 90, 100} {
+		f.Fallthrough = rollout(on)
+		count := 0
+		for i := 0; i < 1000; i++ {
+			key := fmt.Sprintf("user-%d", i)
+			got := Evaluate(f, model.EvaluationContext{Key: key}).Value == true
+			if served[key] && !got {
+				t.Fatalf("%s lost the true variation as its weight grew to %d", key, on)
+			}
+			// The same context always gets the same variation
+			if again := Evaluate(f, model.EvaluationContext{Key: key}).Value == true; again != got {
+				t.Fatalf("%s got different variations at %d", key, on)
+			}
+			served[key] = got
+			if got {
+				count++
+			}
+		}
+		if count < on*10-60 || count > on*10+60 {
+			t.Errorf("%d of 1000 contexts served true at %d%%", count, on)
+		}
+	}
+}
+
+func TestBucketBy(t *testing.T) {
+	f := boolFlag()
+	f.Fallthrough = rollout(50)
+	f.BucketBy = "org"
+
+	// Contexts of an organization share a variation
+	for _, org := range []string{"acme", "globex", "initech"} {
+		first := Evaluate(f, model.EvaluationContext{Key: "a", Attributes: map[string]interface{}{"org": org}})
+		for i := 0; i < 20; i++ {
+			ctx := model.EvaluationContext{Key: fmt.Sprintf("user-%d", i), Attributes: map[string]interface{}{"org": org}}
+			if got := Evaluate(f, ctx); got.Variation != first.Variation {
+				t.Errorf("%s of %s got %s, want %s", ctx.Key, org, got.Variation, first.Variation)
+			}
+		}
+	}
+
+	// Without the attribute a context falls in bucket 0
+	if got := Evaluate(f, model.EvaluationContext{Key: "user-1"}); got.Variation != "true" {
+		t.Errorf("context without bucket-by attribute got %s", got.Variation)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	rules := []model.FlagRule{
+		{
+			Conditions: []model.FlagCondition{{Attribute: "email", Operator: model.OpEndsWith, Values: []interface{}{"@company.com"}}},
+			Serve:      model.FlagServe{Variation: "true"},
+		},
+		{
+			Conditions: []model.FlagCondition{
+				{Attribute: "country", Operator: model.OpIn, Values: []interface{}{"NL", "BE"}},
+				{Attribute: "age", Operator: model.OpGreaterEq, Values: []interface{}{18.0}},
+			},
+			Serve: model.FlagServe{Variation: "true"},
+		},
+		{
+			Conditions: []model.FlagCondition{{Attribute: "groups", Operator: model.OpNotIn, Values: []interface{}{"beta"}}},
+			Serve:      model.FlagServe{Variation: "false"},
+		},
+		{
+			Conditions: []model.FlagCondition{{Attribute: "plan", Operator: model.OpExists}},
+			Serve:      model.FlagServe{Variation: "true"},
+		},
+	}
+	index := func(i int) *int { return &i }
+
+	tests := []struct {
+		name    string
+		enabled bool
+		attrs   map[string]interface{}
+		want    string
+		reason  string
+		rule    *int
+	}{
+		{name: "disabled", attrs: map[string]interface{}{"email": "a@company.com"}, want: "false", reason: model.ReasonOff},
+		{name: "first rule", enabled: true, attrs: map[string]interface{}{"email": "a@company.com", "groups": []interface{}{"staff"}}, want: "true", reason: model.ReasonRuleMatch, rule: index(0)},
+		{name: "all conditions", enabled: true, attrs: map[string]interface{}{"country": "NL", "age": 21.0}, want: "true", reason: model.ReasonRuleMatch, rule: index(1)},
+		{name: "number as string", enabled: true, attrs: map[string]interface{}{"country": "BE", "age": "30"}, want: "true", reason: model.ReasonRuleMatch, rule: index(1)},
+		{name: "one condition fails", enabled: true, attrs: map[string]interface{}{"country": "NL", "age": 16.0, "groups": []interface{}{"beta"}}, want: "false", reason: model.ReasonFallthrough},
+		{name: "not in list", enabled: true, attrs: map[string]interface{}{"groups": []interface{}{"staff", "ops"}}, want: "false", reason: model.ReasonRuleMatch, rule: index(2)},
+		{name: "in list", enabled: true, attrs: map[string]interface{}{"groups": []interface{}{"staff", "beta"}, "plan": "pro"}, want: "true", reason: model.ReasonRuleMatch, rule: index(3)},
+		{name: "missing attribute", enabled: true, attrs: nil, want: "false", reason: model.ReasonFallthrough},
+		{name: "null attribute", enabled: true, attrs: map[string]interface{}{"plan": nil}, want: "false", reason: model.ReasonFallthrough},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := boolFlag()
+			f.Enabled = tt.enabled
+			f.Rules = rules
+			got := Evaluate(f, model.EvaluationContext{Key: "user-1", Attributes: tt.attrs})
+			if got.Variation != tt.want || got.Reason != tt.reason {
+				t.Fatalf("Evaluate = %s (%s), want %s (%s)", got.Variation, got.Reason, tt.want, tt.reason)
+			}
+			if (got.RuleIndex == nil) != (tt.rule == nil) || (got.RuleIndex != nil && *got.RuleIndex != *tt.rule) {
+				t.Errorf("rule index = %v, want %v", got.RuleIndex, tt.rule)
+			}
+			if got.Value != (tt.want == "true") {
+				t.Errorf("value = %v, want %s", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		op    model.FlagOperator
+		v     interface{}
+		want  interface{}
+		match bool
+	}{
+		{model.OpIn, "a", "a", true},
+		{model.OpIn, 1.0, 1.0, true},
+		{model.OpIn, "1", 1.0, true},
+		{model.OpIn, true, true, true},
+		{model.OpIn, "true", true, false},
+		{model.OpContains, "checkout-v2", "out", true},
+		{model.OpStartsWith, "checkout-v2", "check", true},
+		{model.OpEndsWith, "checkout-v2", "v1", false},
+		{model.OpMatches, "v2.3.1", `^v2\.`, true},
+		{model.OpMatches, 2.0, `2`, false},
+		{model.OpLessThan, 1.0, 2.0, true},
+		{model.OpLessEqual, 2.0, 2.0, true},
+		{model.OpGreater, "3.5", 3.0, true},
+		{model.OpGreaterEq, "abc", 1.0, false},
+	}
+	for _, tt := range tests {
+		if got := compare(tt.op, tt.v, tt.want); got != tt.match {
+			t.Errorf("compare(%s, %v, %v) = %v, want %v", tt.op, tt.v, tt.want, got, tt.match)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	multi := func() *model.Flag {
+		return &model.Flag{
+			Key:          "theme",
+			Type:         model.FlagMultivariate,
+			Variations:   []model.FlagVariation{{Name: "light", Value: "light"}, {Name: "dark", Value: "dark"}},
+			OffVariation: "light",
+			Fallthrough:  model.FlagServe{Variation: "light"},
+		}
+	}
+	tests := []struct {
+		name   string
+		change func(f *model.Flag)
+		field  string
+	}{
+		{name: "valid"},
+		{name: "one variation", change: func(f *model.Flag) { f.Variations = f.Variations[:1] }, field: "variations"},
+		{name: "duplicate variation", change: func(f *model.Flag) { f.Variations[1].Name = "light" }, field: "variations[1].name"},
+		{name: "unknown off variation", change: func(f *model.Flag) { f.OffVariation = "blue" }, field: "off_variation"},
+		{name: "variation and rollout", change: func(f *model.Flag) {
+			f.Fallthrough.Rollout = []model.FlagWeight{{Variation: "dark", Weight: 100}}
+		}, field: "fallthrough"},
+		{name: "weights", change: func(f *model.Flag) {
+			f.Fallthrough = model.FlagServe{Rollout: []model.FlagWeight{{Variation: "dark", Weight: 60}, {Variation: "light", Weight: 30}}}
+		}, field: "fallthrough.rollout"},
+		{name: "rule without conditions", change: func(f *model.Flag) {
+			f.Rules = []model.FlagRule{{Serve: model.FlagServe{Variation: "dark"}}}
+		}, field: "rules[0].conditions"},
+		{name: "bad regular expression", change: func(f *model.Flag) {
+			f.Rules = []model.FlagRule{{
+				Conditions: []model.FlagCondition{{Attribute: "v", Operator: model.OpMatches, Values: []interface{}{"("}}},
+				Serve:      model.FlagServe{Variation: "dark"},
+			}}
+		}, field: "rules[0].conditions[0].values[0]"},
+		{name: "number expected", change: func(f *model.Flag) {
+			f.Rules = []model.FlagRule{{
+				Conditions: []model.FlagCondition{{Attribute: "age", Operator: model.OpGreater, Values: []interface{}{"18"}}},
+				Serve:      model.FlagServe{Variation: "dark"},
+			}}
+		}, field: "rules[0].conditions[0].values[0]"},
+		{name: "boolean variations", change: func(f *model.Flag) { f.Type = model.FlagBoolean }, field: "variations"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := multi()
+			if tt.change != nil {
+				tt.change(f)
+			}
+			err := Validate(f)
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("Validate error: %v", err)
+				}
+				return
+			}
+			var flagErr *Error
+			if !errors.As(err, &flagErr) || flagErr.Field != tt.field {
+				t.Errorf("Validate error = %v, want one for %s", err, tt.field)
+			}
+		})
+	}
+
+	if err := Validate(boolFlag()); err != nil {
+		t.Errorf("Validate boolean flag: %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package kubernetes
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/secrets"
+	"github.com/company/config-service/internal/service"
+	"gopkg.in/yaml.v3"
+)
+
+// Label and annotation keys set on generated objects
+const (
+	LabelManagedBy         = "app.kubernetes.io/managed-by"
+	LabelEnvironment       = "config-service/environment"
+	LabelTagPrefix         = "tag.config-service/"
+	AnnotationTemplateID   = "config-service/template-id"
+	AnnotationTemplateName = "config-service/template-name"
+	AnnotationVersion      = "config-service/template-version"
+	AnnotationChecksum     = "config-service/checksum"
+	managedByValue         = "config-service"
+	maxLabelValueLength    = 63
+	maxResourceNameLength  = 253
+	defaultSecretTag       = "sensitive"
+)
+
+var (
+	invalidNameChars  = regexp.MustCompile(`[^a-z0-9.-]+`)
+	invalidLabelChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// Options controls how manifests are generated
+type Options struct {
+	Namespace  string
+	NamePrefix string
+	// SecretTags lists tag names that mark a template as sensitive. Sensitive
+	// templates, and those holding encrypted values, are exported as Secrets
+	// instead of ConfigMaps.
+	SecretTags []string
+}
+
+type objectMeta struct {
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type configMap struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   objectMeta        `yaml:"metadata"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type secret struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   objectMeta        `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	Data       map[string]string `yaml:"data"`
+}
+
+// NameConflictError reports two templates that would be exported as objects
+// of the same kind and name, or under the same data key. Names are
+// normalized lossily, so distinct template names can collide.
+type NameConflictError struct {
+	// Kind is ConfigMap, Secret or key
+	Kind      string
+	Name      string
+	Templates [2]string
+}
+
+func (e *NameConflictError) Error() string {
+	return fmt.Sprintf("templates %q and %q map to the same %s %q", e.Templates[0], e.Templates[1], e.Kind, e.Name)
+}
+
+// Manifests renders one ConfigMap or Secret per rendered template as a
+// multi-document YAML stream. A *NameConflictError is returned when two
+// templates map to the same object or data key, rather than letting one
+// overwrite the other when applied or mounted together.
+func Manifests(env *model.Environment, items []service.RenderedTemplate, opts Options) ([]byte, error) {
+	secretTags := opts.SecretTags
+	if len(secretTags) == 0 {
+		secretTags = []string{defaultSecretTag}
+	}
+
+	// taken maps kind and name of every object and data key to its template
+	taken := make(map[[2]string]string, 2*len(items))
+	claim := func(kind, name, template string) error {
+		if other, ok := taken[[2]string{kind, name}]; ok {
+			return &NameConflictError{Kind: kind, Name: name, Templates: [2]string{other, template}}
+		}
+		taken[[2]string{kind, name}] = template
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for i, item := range items {
+		meta := objectMeta{
+			Name:        ResourceName(opts.NamePrefix, item.Template.Name),
+			Namespace:   opts.Namespace,
+			Labels:      labels(env, item.Template.Tags),
+			Annotations: annotations(item),
+		}
+		sensitive := isSensitive(&item.Template, secretTags)
+		kind := "ConfigMap"
+		if sensitive {
+			kind = "Secret"
+		}
+		if err := claim(kind, meta.Name, item.Template.Name); err != nil {
+			return nil, err
+		}
+		if err := claim("key", item.FileName, item.Template.Name); err != nil {
+			return nil, err
+		}
+
+		var obj interface{}
+		if sensitive {
+			obj = secret{
+				APIVersion: "v1",
+				Kind:       kind,
+				Metadata:   meta,
+				Type:       "Opaque",
+				Data:       map[string]string{item.FileName: base64.StdEncoding.EncodeToString(item.Content)},
+			}
+		} else {
+			obj = configMap{
+				APIVersion: "v1",
+				Kind:       kind,
+				Metadata:   meta,
+				Data:       map[string]string{item.FileName: string(item.Content)},
+			}
+		}
+
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(obj); err != nil {
+			return nil, fmt.Errorf("failed to encode manifest for template %q: %w", item.Template.Name, err)
+		}
+		if err := enc.Close(); err != nil {
+			return nil, fmt.Errorf("failed to encode manifest for template %q: %w", item.Template.Name, err)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ResourceName converts a template name into a DNS-1123 subdomain
+func ResourceName(prefix, name string) string {
+	n := strings.ToLower(prefix + name)
+	n = invalidNameChars.ReplaceAllString(n, "-")
+	if len(n) > maxResourceNameLength {
+		n = n[:maxResourceNameLength]
+	}
+	n = strings.Trim(n, "-.")
+	if n == "" {
+		return "template"
+	}
+	return n
+}
+
+func labels(env *model.Environment, tags []model.Tag) map[string]string {
+	l := map[string]string{
+		LabelManagedBy:   managedByValue,
+		LabelEnvironment: labelValue(env.Slug),
+	}
+	for _, tag := range tags {
+		if v := labelValue(tag.Name); v != "" {
+			l[LabelTagPrefix+v] = "true"
+		}
+	}
+	return l
+}
+
+func annotations(item service.RenderedTemplate) map[string]string {
+	return map[string]string{
+		AnnotationTemplateID:   strconv.FormatInt(item.Template.ID, 10),
+		AnnotationTemplateName: item.Template.Name,
+		AnnotationVersion:      item.Template.Version,
+		AnnotationChecksum:     item.Checksum,
+	}
+}
+
+// labelValue sanitizes s into a valid label value or label name segment
+func labelValue(s string) string {
+	v := invalidLabelChars.ReplaceAllString(s, "-")
+	if len(v) > maxLabelValueLength {
+		v = v[:maxLabelValueLength]
+	}
+	return strings.Trim(v, "-._")
+}
+
+// isSensitive reports whether a template holds encrypted values or carries
+// one of the secret tags
+func isSensitive(tpl *model.Template, secretTags []string) bool {
+	if secrets.Contains(tpl.DefaultValues) {
+		return true
+	}
+	for _, tag := range tpl.Tags {
+		for _, name := range secretTags {
+			if strings.EqualFold(tag.Name, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package kubernetes
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/secrets"
+	"github.com/company/config-service/internal/service"
+	"gopkg.in/yaml.v3"
+)
+
+func TestResourceName(t *testing.T) {
+	tests := []struct {
+		prefix, name string
+		want         string
+	}{
+		{"", "app", "app"},
+		{"", "App Config_v2", "app-config-v2"},
+		{"prod-", "db.yaml", "prod-db.yaml"},
+		{"", "--.x.--", "x"},
+		{"", "ü", "template"},
+		{"", strings.Repeat("a", 300), strings.Repeat("a", maxResourceNameLength)},
+	}
+	for _, tt := range tests {
+		if got := ResourceName(tt.prefix, tt.name); got != tt.want {
+			t.Errorf("ResourceName(%q, %q) = %q, want %q", tt.prefix, tt.name, got, tt.want)
+		}
+	}
+}
+
+func rendered(id int64, name, fileName string, tags ...string) service.RenderedTemplate {
+	tpl := model.Template{ID: id, Name: name, Version: "1.0.0"}
+	for _, tag := range tags {
+		tpl.Tags = append(tpl.Tags, model.Tag{Name: tag})
+	}
+	return service.RenderedTemplate{Template: tpl, FileName: fileName, Content: []byte("a: 1\n"), Checksum: "sum"}
+}
+
+func TestManifests(t *testing.T) {
+	env := &model.Environment{Slug: "prod"}
+	envelope, err := newCipher(t).Encrypt("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed := rendered(3, "db", "db.yaml")
+	sealed.Template.DefaultValues = model.JSONMap{"password": envelope}
+
+	out, err := Manifests(env, []service.RenderedTemplate{
+		rendered(1, "App", "App.yaml", "api"),
+		rendered(2, "keys", "keys.yaml", "sensitive"),
+		sealed,
+	}, Options{Namespace: "payments", NamePrefix: "cfg-"})
+	if err != nil {
+		t.Fatalf("Manifests error: %v", err)
+	}
+
+	dec := yaml.NewDecoder(strings.NewReader(string(out)))
+	var kinds, names []string
+	for {
+		var obj struct {
+			Kind     string
+			Metadata objectMeta
+			Data     map[string]string
+		}
+		if err := dec.Decode(&obj); err != nil {
+			break
+		}
+		kinds = append(kinds, obj.Kind)
+		names = append(names, obj.Metadata.Name)
+		if obj.Metadata.Namespace != "payments" || obj.Metadata.Labels[LabelEnvironment] != "prod" {
+			t.Errorf("metadata of %s = %+v", obj.Metadata.Name, obj.Metadata)
+		}
+		if len(obj.Data) != 1 {
+			t.Errorf("data of %s = %v, want one key", obj.Metadata.Name, obj.Data)
+		}
+	}
+	if got, want := strings.Join(kinds, ","), "ConfigMap,Secret,Secret"; got != want {
+		t.Errorf("kinds = %s, want %s", got, want)
+	}
+	if got, want := strings.Join(names, ","), "cfg-app,cfg-keys,cfg-db"; got != want {
+		t.Errorf("names = %s, want %s", got, want)
+	}
+}
+
+func TestManifestsNameConflicts(t *testing.T) {
+	env := &model.Environment{Slug: "prod"}
+	tests := []struct {
+		name  string
+		items []service.RenderedTemplate
+		want  *NameConflictError
+	}{
+		{
+			name:  "same object name",
+			items: []service.RenderedTemplate{rendered(1, "app config", "a.yaml"), rendered(2, "App_Config", "b.yaml")},
+			want:  &NameConflictError{Kind: "ConfigMap", Name: "app-config", Templates: [2]string{"app config", "App_Config"}},
+		},
+		{
+			name:  "same data key",
+			items: []service.RenderedTemplate{rendered(1, "app", "app.yaml"), rendered(2, "app.yaml", "app.yaml")},
+			want:  &NameConflictError{Kind: "key", Name: "app.yaml", Templates: [2]string{"app", "app.yaml"}},
+		},
+		{
+			name:  "same name of different kinds",
+			items: []service.RenderedTemplate{rendered(1, "app", "a.yaml"), rendered(2, "APP", "b.yaml", "sensitive")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Manifests(env, tt.items, Options{})
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Manifests error: %v", err)
+				}
+				return
+			}
+			var conflictErr *NameConflictError
+			if !errors.As(err, &conflictErr) || *conflictErr != *tt.want {
+				t.Fatalf("Manifests error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func newCipher(t *testing.T) *secrets.Cipher {
+	t.Helper()
+	c, err := secrets.NewCipher([]secrets.MasterKey{{Version: 1, Key: make([]byte, secrets.KeySize)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"time"
+)
+
+// ArchiveFormatVersion is the current version of the configuration archive layout
+const ArchiveFormatVersion = 1
+
+// ConflictStrategy decides what an import does with records that already exist
+type ConflictStrategy string
+
+const (
+	ConflictSkip      ConflictStrategy = "skip"
+	ConflictOverwrite ConflictStrategy = "overwrite"
+	ConflictFail      ConflictStrategy = "fail"
+)
+
+// Archive is a portable snapshot of the whole configuration store. Records
+// reference each other by environment slug, tag name and template name rather
+// than by database ID, so that archives can be imported into another cluster.
+// SealedSecrets reports whether secret values carry their envelopes, which
+// only a store sharing the master keyring can open, rather than being masked.
+type Archive struct {
+	FormatVersion int                  `json:"format_version"`
+	ExportedAt    time.Time            `json:"exported_at"`
+	SealedSecrets bool                 `json:"sealed_secrets"`
+	Environments  []ArchiveEnvironment `json:"environments"`
+	Tags          []ArchiveTag         `json:"tags"`
+	Templates     []ArchiveTemplate    `json:"templates"`
+	TemplateTags  []ArchiveTemplateTag `json:"template_tags"`
+}
+
+// ArchiveEnvironment is an environment within an archive
+type ArchiveEnvironment struct {
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+	Active      bool   `json:"active"`
+	Priority    int    `json:"priority"`
+}
+
+// ArchiveTag is a tag within an archive
+type ArchiveTag struct {
+	Name        string `json:"name"`
+	Key         string `json:"key,omitempty"`
+	Value       string `json:"value,omitempty"`
+	Parent      string `json:"parent,omitempty"`
+	Description string `json:"description"`
+	Color       string `json:"color"`
+}
+
+// ArchiveTemplate is a template within an archive
+type ArchiveTemplate struct {
+	Environment   string       `json:"environment"`
+	Name          string       `json:"name"`
+	Description   string       `json:"description"`
+	Format        ConfigFormat `json:"format"`
+	Content       string       `json:"content"`
+	Schema        JSONMap      `json:"schema"`
+	DefaultValues JSONMap      `json:"default_values"`
+	Version       string       `json:"version"`
+	Active        bool         `json:"active"`
+	CreatedBy     string       `json:"created_by"`
+	UpdatedBy     string       `json:"updated_by"`
+}
+
+// ArchiveTemplateTag links a template to a tag within an archive
+type ArchiveTemplateTag struct {
+	Environment string `json:"environment"`
+	Template    string `json:"template"`
+	Tag         string `json:"tag"`
+}
+
+// ImportCounts summarizes what an import did with one kind of record
+type ImportCounts struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+}
+
+// ImportSummary represents the result of an archive import
+type ImportSummary struct {
+	Strategy     ConflictStrategy `json:"strategy"`
+	DryRun       bool             `json:"dry_run"`
+	Environments ImportCounts     `json:"environments"`
+	Tags         ImportCounts     `json:"tags"`
+	Templates    ImportCounts     `json:"templates"`
+	TemplateTags ImportCounts     `json:"template_tags"`
+	Conflicts    []string         `json:"conflicts,omitempty"`
+}
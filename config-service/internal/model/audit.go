@@ -0,0 +1,67 @@
+package model
+
+import (
+	"time"
+)
+
+// Audit actions
+const (
+	AuditTagMerge             = "tag.merge"
+	AuditTagRename            = "tag.rename"
+	AuditChangeRequestCreate  = "change_request.create"
+	AuditChangeRequestApprove = "change_request.approve"
+	AuditChangeRequestApply   = "change_request.apply"
+	AuditChangeRequestReject  = "change_request.reject"
+	AuditEnvironmentProtect   = "environment.protect"
+	AuditFreezeWindowCreate   = "freeze_window.create"
+	AuditFreezeWindowDelete   = "freeze_window.delete"
+	AuditDeploymentSchedule   = "deployment.schedule"
+	AuditDeploymentCancel     = "deployment.cancel"
+	AuditDeploymentApply      = "deployment.apply"
+	AuditCandidateSet         = "candidate.set"
+	AuditCandidateRollout     = "candidate.rollout"
+	AuditCandidatePromote     = "candidate.promote"
+	AuditCandidateAbort       = "candidate.abort"
+	AuditFlagCreate           = "flag.create"
+	AuditFlagUpdate           = "flag.update"
+	AuditFlagDelete           = "flag.delete"
+	AuditEnvironmentClone     = "environment.clone"
+	AuditSnapshotCreate       = "snapshot.create"
+	AuditSnapshotDelete       = "snapshot.delete"
+	AuditWebhookCreate        = "webhook.create"
+	AuditWebhookUpdate        = "webhook.update"
+	AuditWebhookDelete        = "webhook.delete"
+	AuditWebhookDisable       = "webhook.disable"
+	AuditWebhookRedeliver     = "webhook.redeliver"
+)
+
+// AuditEntry records a change made to the configuration store
+type AuditEntry struct {
+	ID         int64     `json:"id" db:"id"`
+	Action     string    `json:"action" db:"action"`
+	EntityType string    `json:"entity_type" db:"entity_type"`
+	EntityID   *int64    `json:"entity_id,omitempty" db:"entity_id"`
+	Actor      string    `json:"actor" db:"actor"`
+	Details    JSONMap   `json:"details" db:"details"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// AuditFilter selects audit entries. All set criteria must match.
+type AuditFilter struct {
+	Action     string `form:"action"`
+	EntityType string `form:"entity_type"`
+	EntityID   *int64 `form:"entity_id"`
+	Actor      string `form:"actor"`
+}
+
+// AuditListResponse represents paginated audit log response. Page is 0 for
+// pages addressed by cursor.
+type AuditListResponse struct {
+	Entries    []AuditEntry `json:"entries"`
+	Total      int64        `json:"total"`
+	Page       int          `json:"page"`
+	PageSize   int          `json:"page_size"`
+	HasNext    bool         `json:"has_next"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	PrevCursor string       `json:"prev_cursor,omitempty"`
+}
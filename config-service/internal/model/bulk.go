@@ -0,0 +1,74 @@
+package model
+
+// BulkOperation names a single operation within a bulk template request
+type BulkOperation string
+
+const (
+	BulkCreate     BulkOperation = "create"
+	BulkUpdate     BulkOperation = "update"
+	BulkDelete     BulkOperation = "delete"
+	BulkAttachTags BulkOperation = "attach_tags"
+	BulkDetachTags BulkOperation = "detach_tags"
+	BulkActivate   BulkOperation = "activate"
+	BulkDeactivate BulkOperation = "deactivate"
+)
+
+// BulkItemStatus describes the outcome of a single bulk item
+type BulkItemStatus string
+
+const (
+	BulkItemSucceeded  BulkItemStatus = "succeeded"
+	BulkItemFailed     BulkItemStatus = "failed"
+	BulkItemRolledBack BulkItemStatus = "rolled_back"
+)
+
+// BulkTemplateItem is one create, update or delete within a bulk request.
+// Create is required for create, ID and Update for update and ID for delete.
+// Their created_by and updated_by are set to the caller.
+type BulkTemplateItem struct {
+	Op     BulkOperation          `json:"op" validate:"required,oneof=create update delete"`
+	ID     int64                  `json:"id,omitempty"`
+	Create *CreateTemplateRequest `json:"create,omitempty"`
+	Update *UpdateTemplateRequest `json:"update,omitempty"`
+}
+
+// BulkTemplateRequest represents a batch of template writes
+type BulkTemplateRequest struct {
+	// Atomic rolls back every item when any item fails
+	Atomic bool               `json:"atomic"`
+	Items  []BulkTemplateItem `json:"items" validate:"required,min=1,max=1000,dive"`
+}
+
+// BulkTagRequest attaches and detaches tags on all templates matching a filter
+type BulkTagRequest struct {
+	Atomic bool           `json:"atomic"`
+	Filter TemplateFilter `json:"filter"`
+	Attach []string       `json:"attach,omitempty"`
+	Detach []string       `json:"detach,omitempty"`
+}
+
+// BulkStatusRequest activates or deactivates all templates matching a filter
+type BulkStatusRequest struct {
+	Atomic bool           `json:"atomic"`
+	Filter TemplateFilter `json:"filter"`
+	Active *bool          `json:"active" validate:"required"`
+}
+
+// BulkItemResult reports the outcome of one bulk item
+type BulkItemResult struct {
+	Index      int            `json:"index"`
+	Op         BulkOperation  `json:"op"`
+	TemplateID int64          `json:"template_id,omitempty"`
+	Name       string         `json:"name,omitempty"`
+	Status     BulkItemStatus `json:"status"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// BulkResponse represents the result of a bulk request
+type BulkResponse struct {
+	Atomic    bool             `json:"atomic"`
+	Committed bool             `json:"committed"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []BulkItemResult `json:"results"`
+}
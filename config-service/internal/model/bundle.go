@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+)
+
+// BundleFile represents a single rendered template within a bundle. Variant
+// is stable or candidate for templates under rollout.
+type BundleFile struct {
+	TemplateID int64        `json:"template_id"`
+	Name       string       `json:"name"`
+	FileName   string       `json:"file_name"`
+	Format     ConfigFormat `json:"format"`
+	Version    string       `json:"version"`
+	Variant    string       `json:"variant,omitempty"`
+	Content    string       `json:"content"`
+	Checksum   string       `json:"checksum"`
+}
+
+// BundleResponse represents all rendered templates of an environment
+// matching a tag selector. AsOf is set for bundles rendered from the state
+// at a past moment.
+type BundleResponse struct {
+	Environment string       `json:"environment"`
+	Tags        []string     `json:"tags"`
+	Selector    string       `json:"selector,omitempty"`
+	Checksum    string       `json:"checksum"`
+	GeneratedAt time.Time    `json:"generated_at"`
+	AsOf        *time.Time   `json:"as_of,omitempty"`
+	Files       []BundleFile `json:"files"`
+}
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"time"
+)
+
+// ChangeRequestStatus is the state of a change request
+type ChangeRequestStatus string
+
+// Change request states
+const (
+	ChangeOpen     ChangeRequestStatus = "open"
+	ChangeApplied  ChangeRequestStatus = "applied"
+	ChangeRejected ChangeRequestStatus = "rejected"
+)
+
+// FieldChange is one difference between two versions of a record. Path is
+// the field name, followed by the dotted key path inside schema and
+// default_values. Old is omitted for added and New for removed values;
+// secret values are masked.
+type FieldChange struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// ChangeRequest proposes creating, updating or deleting a template. It is
+// applied once RequiredApprovals users other than the author approved it.
+type ChangeRequest struct {
+	ID                int64               `json:"id"`
+	EnvironmentID     int64               `json:"environment_id"`
+	TemplateID        *int64              `json:"template_id,omitempty"`
+	Op                BulkOperation       `json:"op"`
+	Title             string              `json:"title"`
+	Description       string              `json:"description"`
+	Diff              []FieldChange       `json:"diff"`
+	Status            ChangeRequestStatus `json:"status"`
+	RequiredApprovals int                 `json:"required_approvals"`
+	Author            string              `json:"author"`
+	ResolvedBy        string              `json:"resolved_by,omitempty"`
+	Reason            string              `json:"reason,omitempty"`
+	ResolvedAt        *time.Time          `json:"resolved_at,omitempty"`
+	CreatedAt         time.Time           `json:"created_at"`
+	UpdatedAt         time.Time           `json:"updated_at"`
+	Approvals         []ChangeApproval    `json:"approvals"`
+	Comments          []ChangeComment     `json:"comments,omitempty"`
+
+	// Proposed is the template to write, with secret values encrypted; nil
+	// for deletions
+	Proposed *Template `json:"-"`
+	// BaseUpdatedAt is the updated_at of the template when the change was
+	// proposed, or nil for creations
+	BaseUpdatedAt *time.Time `json:"-"`
+}
+
+// ChangeApproval records the approval of a change request
+type ChangeApproval struct {
+	Approver  string    `json:"approver"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ChangeComment is a comment on a change request. Replies to a comment are
+// nested under it.
+type ChangeComment struct {
+	ID        int64           `json:"id"`
+	ParentID  *int64          `json:"parent_id,omitempty"`
+	Author    string          `json:"author"`
+	Body      string          `json:"body"`
+	CreatedAt time.Time       `json:"created_at"`
+	Replies   []ChangeComment `json:"replies,omitempty"`
+}
+
+// CreateChangeRequest proposes a template change. Op selects which of
+// create, or template_id with update, or template_id alone for delete, is
+// used; the author is the authenticated caller.
+type CreateChangeRequest struct {
+	Title       string                 `json:"title" validate:"required,min=1,max=200"`
+	Description string                 `json:"description" validate:"max=5000"`
+	Op          BulkOperation          `json:"op" validate:"required,oneof=create update delete"`
+	TemplateID  int64                  `json:"template_id,omitempty"`
+	Create      *CreateTemplateRequest `json:"create,omitempty"`
+	Update      *UpdateTemplateRequest `json:"update,omitempty"`
+}
+
+// RejectChangeRequest rejects a change request
+type RejectChangeRequest struct {
+	Reason string `json:"reason" validate:"required,min=1,max=2000"`
+}
+
+// CreateChangeCommentRequest comments on a change request, or replies to the
+// comment ParentID
+type CreateChangeCommentRequest struct {
+	Body     string `json:"body" validate:"required,min=1,max=5000"`
+	ParentID *int64 `json:"parent_id,omitempty"`
+}
+
+// ChangeRequestFilter selects change requests. All set criteria must match.
+type ChangeRequestFilter struct {
+	Status      string `form:"status" validate:"omitempty,oneof=open applied rejected"`
+	Environment string `form:"environment"`
+	TemplateID  *int64 `form:"template_id"`
+	Author      string `form:"author"`
+}
+
+// ChangeRequestListResponse represents a page of change requests, newest
+// first, without comments. Page is 0 for pages addressed by cursor.
+type ChangeRequestListResponse struct {
+	ChangeRequests []ChangeRequest `json:"change_requests"`
+	Total          int64           `json:"total"`
+	Page           int             `json:"page"`
+	PageSize       int             `json:"page_size"`
+	HasNext        bool            `json:"has_next"`
+	NextCursor     string          `json:"next_cursor,omitempty"`
+	PrevCursor     string          `json:"prev_cursor,omitempty"`
+}
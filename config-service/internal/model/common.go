@@ -28,10 +28,12 @@ type ServiceHealthInfo struct {
 	LastCheck string `json:"last_check"`
 }
 
-// PaginationParams represents pagination parameters
+// PaginationParams represents pagination parameters. A Cursor returned by a
+// previous page takes precedence over Page and selects keyset pagination.
 type PaginationParams struct {
-	Page     int `form:"page,default=1" validate:"min=1"`
-	PageSize int `form:"page_size,default=20" validate:"min=1,max=100"`
+	Page     int    `form:"page,default=1" validate:"min=1"`
+	PageSize int    `form:"page_size,default=20" validate:"min=1,max=100"`
+	Cursor   string `form:"cursor" validate:"max=1024"`
 }
 
 // FilterParams represents common filter parameters
@@ -45,3 +47,20 @@ type SortParams struct {
 	SortBy    string `form:"sort_by,default=created_at"`
 	SortOrder string `form:"sort_order,default=desc" validate:"oneof=asc desc"`
 }
+
+// FieldParams selects the fields and embedded relations of a response, each
+// as a comma separated list. When neither is given the full response is
+// returned.
+type FieldParams struct {
+	Fields  string `form:"fields" validate:"max=500"`
+	Include string `form:"include" validate:"max=200"`
+}
+
+// Fieldset is a set of selected response fields and relations. A nil
+// Fieldset selects everything.
+type Fieldset map[string]bool
+
+// Has reports whether a field is selected
+func (f Fieldset) Has(name string) bool {
+	return f == nil || f[name]
+}
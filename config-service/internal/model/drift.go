@@ -0,0 +1,81 @@
+package model
+
+import (
+	"time"
+)
+
+// Reasons an applied template has drifted
+const (
+	// DriftBehind marks a template applied in another version than the one
+	// currently served to the instance
+	DriftBehind = "behind"
+	// DriftUnexpectedChecksum marks a template applied in the current
+	// version whose content differs from what is currently served
+	DriftUnexpectedChecksum = "unexpected_checksum"
+	// DriftUnknownTemplate marks a template that is no longer served by the
+	// environment, because it was deleted, deactivated or moved
+	DriftUnknownTemplate = "unknown_template"
+)
+
+// AppliedTemplate is a template a client has applied. Checksum is the hex
+// encoded SHA-256 of its content, as in bundle files.
+type AppliedTemplate struct {
+	TemplateID int64  `json:"template_id" validate:"required"`
+	Version    string `json:"version" validate:"required,max=100"`
+	Checksum   string `json:"checksum" validate:"required,len=64,hexadecimal"`
+}
+
+// CheckInRequest reports the templates a client instance has applied.
+// InstanceID defaults to the X-Instance-ID header.
+type CheckInRequest struct {
+	InstanceID     string            `json:"instance_id" validate:"required,max=255"`
+	AgentVersion   string            `json:"agent_version,omitempty" validate:"max=100"`
+	BundleChecksum string            `json:"bundle_checksum,omitempty" validate:"omitempty,len=64,hexadecimal"`
+	Templates      []AppliedTemplate `json:"templates" validate:"max=1000,dive"`
+}
+
+// CheckIn is the latest check-in of a client instance
+type CheckIn struct {
+	EnvironmentID  int64             `json:"environment_id" db:"environment_id"`
+	InstanceID     string            `json:"instance_id" db:"instance_id"`
+	AgentVersion   string            `json:"agent_version" db:"agent_version"`
+	BundleChecksum string            `json:"bundle_checksum" db:"bundle_checksum"`
+	Templates      []AppliedTemplate `json:"templates" db:"templates"`
+	CheckedInAt    time.Time         `json:"checked_in_at" db:"checked_in_at"`
+}
+
+// TemplateDrift describes an applied template that differs from what is
+// currently served to the instance. ExpectedVersion is empty for unknown
+// templates.
+type TemplateDrift struct {
+	TemplateID      int64  `json:"template_id"`
+	Name            string `json:"name,omitempty"`
+	Reason          string `json:"reason"`
+	AppliedVersion  string `json:"applied_version"`
+	ExpectedVersion string `json:"expected_version,omitempty"`
+	AppliedChecksum string `json:"applied_checksum"`
+}
+
+// InstanceDrift represents the state of a client instance at its latest
+// check-in
+type InstanceDrift struct {
+	InstanceID   string          `json:"instance_id"`
+	AgentVersion string          `json:"agent_version,omitempty"`
+	CheckedInAt  time.Time       `json:"checked_in_at"`
+	Applied      int             `json:"applied"`
+	Drifted      bool            `json:"drifted"`
+	Templates    []TemplateDrift `json:"templates"`
+}
+
+// DriftReport lists the instances of an environment whose applied templates
+// differ from what is currently served to them. Instances that have not
+// checked in within the staleness threshold are only counted as stale.
+type DriftReport struct {
+	Environment      string          `json:"environment"`
+	GeneratedAt      time.Time       `json:"generated_at"`
+	Instances        int             `json:"instances"`
+	DriftedInstances int             `json:"drifted_instances"`
+	StaleInstances   int             `json:"stale_instances"`
+	Drifted          []InstanceDrift `json:"drifted"`
+	InSync           []InstanceDrift `json:"in_sync,omitempty"`
+}
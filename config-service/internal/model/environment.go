@@ -4,16 +4,20 @@ import (
 	"time"
 )
 
-// Environment represents a deployment environment
+// Environment represents a deployment environment. The templates of a
+// protected environment only change through change requests approved by
+// RequiredApprovals users.
 type Environment struct {
-	ID          int64     `json:"id" db:"id"`
-	Name        string    `json:"name" db:"name" validate:"required,min=1,max=100"`
-	Slug        string    `json:"slug" db:"slug" validate:"required,min=1,max=100,alphanum"`
-	Description string    `json:"description" db:"description" validate:"max=500"`
-	Active      bool      `json:"active" db:"active"`
-	Priority    int       `json:"priority" db:"priority" validate:"min=0,max=100"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID                int64     `json:"id" db:"id"`
+	Name              string    `json:"name" db:"name" validate:"required,min=1,max=100"`
+	Slug              string    `json:"slug" db:"slug" validate:"required,min=1,max=100,alphanum"`
+	Description       string    `json:"description" db:"description" validate:"max=500"`
+	Active            bool      `json:"active" db:"active"`
+	Priority          int       `json:"priority" db:"priority" validate:"min=0,max=100"`
+	Protected         bool      `json:"protected" db:"protected"`
+	RequiredApprovals int       `json:"required_approvals" db:"required_approvals" validate:"min=1,max=10"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
 }
 
 // CreateEnvironmentRequest represents request for creating an environment
@@ -34,14 +38,53 @@ type UpdateEnvironmentRequest struct {
 	Priority    *int    `json:"priority,omitempty" validate:"omitempty,min=0,max=100"`
 }
 
+// EnvironmentProtectionRequest marks an environment protected or lifts the
+// protection. RequiredApprovals applies to change requests opened afterwards
+// and keeps its value when omitted.
+type EnvironmentProtectionRequest struct {
+	Protected         *bool `json:"protected" validate:"required"`
+	RequiredApprovals *int  `json:"required_approvals,omitempty" validate:"omitempty,min=1,max=10"`
+}
+
 // EnvironmentResponse represents environment response
 type EnvironmentResponse struct {
-	ID          int64     `json:"id"`
-	Name        string    `json:"name"`
-	Slug        string    `json:"slug"`
-	Description string    `json:"description"`
-	Active      bool      `json:"active"`
-	Priority    int       `json:"priority"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID                int64     `json:"id"`
+	Name              string    `json:"name"`
+	Slug              string    `json:"slug"`
+	Description       string    `json:"description"`
+	Active            bool      `json:"active"`
+	Priority          int       `json:"priority"`
+	Protected         bool      `json:"protected"`
+	RequiredApprovals int       `json:"required_approvals"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// EnvironmentListResponse represents environment list response. Without
+// pagination parameters all environments are returned and the page fields
+// are omitted.
+type EnvironmentListResponse struct {
+	Environments []EnvironmentResponse `json:"environments"`
+	Total        int64                 `json:"total"`
+	Page         int                   `json:"page,omitempty"`
+	PageSize     int                   `json:"page_size,omitempty"`
+	HasNext      bool                  `json:"has_next"`
+	NextCursor   string                `json:"next_cursor,omitempty"`
+	PrevCursor   string                `json:"prev_cursor,omitempty"`
+}
+
+// NewEnvironmentResponse converts an environment into its API representation
+func NewEnvironmentResponse(e Environment) EnvironmentResponse {
+	return EnvironmentResponse{
+		ID:                e.ID,
+		Name:              e.Name,
+		Slug:              e.Slug,
+		Description:       e.Description,
+		Active:            e.Active,
+		Priority:          e.Priority,
+		Protected:         e.Protected,
+		RequiredApprovals: e.RequiredApprovals,
+		CreatedAt:         e.CreatedAt,
+		UpdatedAt:         e.UpdatedAt,
+	}
 }
@@ -0,0 +1,168 @@
+package model
+
+import "time"
+
+// FlagType is the kind of values a feature flag serves
+type FlagType string
+
+// Feature flag types
+const (
+	FlagBoolean      FlagType = "boolean"
+	FlagMultivariate FlagType = "multivariate"
+)
+
+// FlagOperator compares a context attribute with the values of a condition
+type FlagOperator string
+
+// Condition operators
+const (
+	OpIn         FlagOperator = "in"
+	OpNotIn      FlagOperator = "not_in"
+	OpContains   FlagOperator = "contains"
+	OpStartsWith FlagOperator = "starts_with"
+	OpEndsWith   FlagOperator = "ends_with"
+	OpMatches    FlagOperator = "matches"
+	OpLessThan   FlagOperator = "lt"
+	OpLessEqual  FlagOperator = "lte"
+	OpGreater    FlagOperator = "gt"
+	OpGreaterEq  FlagOperator = "gte"
+	OpExists     FlagOperator = "exists"
+)
+
+// Evaluation reasons
+const (
+	ReasonOff         = "off"
+	ReasonRuleMatch   = "rule_match"
+	ReasonFallthrough = "fallthrough"
+)
+
+// FlagVariation is one of the values a flag can serve
+type FlagVariation struct {
+	Name  string      `json:"name" validate:"required,max=100"`
+	Value interface{} `json:"value"`
+}
+
+// FlagCondition matches a context attribute against values. Conditions on an
+// attribute the context lacks do not match, except exists which tests for it.
+type FlagCondition struct {
+	Attribute string        `json:"attribute" validate:"required,max=100"`
+	Operator  FlagOperator  `json:"operator" validate:"required,oneof=in not_in contains starts_with ends_with matches lt lte gt gte exists"`
+	Values    []interface{} `json:"values,omitempty"`
+}
+
+// FlagWeight is the percentage of contexts of a rollout served a variation
+type FlagWeight struct {
+	Variation string `json:"variation" validate:"required"`
+	Weight    int    `json:"weight" validate:"min=0,max=100"`
+}
+
+// FlagServe serves either a fixed variation or a percentage rollout over
+// variations whose weights add up to 100
+type FlagServe struct {
+	Variation string       `json:"variation,omitempty"`
+	Rollout   []FlagWeight `json:"rollout,omitempty" validate:"dive"`
+}
+
+// FlagRule serves a variation to contexts matching all its conditions
+type FlagRule struct {
+	Description string          `json:"description,omitempty" validate:"max=200"`
+	Conditions  []FlagCondition `json:"conditions" validate:"required,min=1,dive"`
+	Serve       FlagServe       `json:"serve"`
+}
+
+// Flag is a feature flag of an environment. Disabled flags serve
+// OffVariation; enabled ones serve the first matching rule, or Fallthrough.
+// Rollouts place contexts by a hash of the flag key and the BucketBy
+// attribute.
+type Flag struct {
+	ID            int64           `json:"id"`
+	Key           string          `json:"key"`
+	Description   string          `json:"description"`
+	EnvironmentID int64           `json:"environment_id"`
+	Type          FlagType        `json:"type"`
+	Variations    []FlagVariation `json:"variations"`
+	Enabled       bool            `json:"enabled"`
+	OffVariation  string          `json:"off_variation"`
+	Rules         []FlagRule      `json:"rules"`
+	Fallthrough   FlagServe       `json:"fallthrough"`
+	BucketBy      string          `json:"bucket_by"`
+	TagIDs        []int64         `json:"-"`
+	Tags          []Tag           `json:"-"`
+	CreatedBy     string          `json:"created_by"`
+	UpdatedBy     string          `json:"updated_by"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// FlagResponse is a feature flag as returned by the API
+type FlagResponse struct {
+	Flag
+	Environment string        `json:"environment"`
+	Tags        []TagResponse `json:"tags"`
+}
+
+// CreateFlagRequest creates a feature flag. Boolean flags have the
+// variations true and false and leave variations out. OffVariation and
+// Fallthrough default to the false variation, or the first one.
+type CreateFlagRequest struct {
+	Key          string          `json:"key" validate:"required,min=1,max=100"`
+	Description  string          `json:"description" validate:"max=1000"`
+	Type         FlagType        `json:"type" validate:"required,oneof=boolean multivariate"`
+	Variations   []FlagVariation `json:"variations,omitempty" validate:"dive"`
+	Enabled      bool            `json:"enabled"`
+	OffVariation string          `json:"off_variation,omitempty"`
+	Rules        []FlagRule      `json:"rules,omitempty" validate:"dive"`
+	Fallthrough  *FlagServe      `json:"fallthrough,omitempty"`
+	BucketBy     string          `json:"bucket_by,omitempty" validate:"max=100"`
+	Tags         []string        `json:"tags,omitempty"`
+}
+
+// UpdateFlagRequest changes the fields of a feature flag that are set;
+// an empty rules list removes all rules
+type UpdateFlagRequest struct {
+	Description  *string         `json:"description,omitempty" validate:"omitempty,max=1000"`
+	Variations   []FlagVariation `json:"variations,omitempty" validate:"dive"`
+	Enabled      *bool           `json:"enabled,omitempty"`
+	OffVariation *string         `json:"off_variation,omitempty"`
+	Rules        []FlagRule      `json:"rules" validate:"dive"`
+	Fallthrough  *FlagServe      `json:"fallthrough,omitempty"`
+	BucketBy     *string         `json:"bucket_by,omitempty" validate:"omitempty,max=100"`
+	Tags         []string        `json:"tags,omitempty"`
+}
+
+// FlagListResponse lists the feature flags of an environment
+type FlagListResponse struct {
+	Environment string         `json:"environment"`
+	Selector    string         `json:"selector,omitempty"`
+	Flags       []FlagResponse `json:"flags"`
+}
+
+// EvaluationContext describes who a flag is evaluated for. The key
+// attribute refers to Key; others to Attributes.
+type EvaluationContext struct {
+	Key        string                 `json:"key"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// EvaluateFlagsRequest evaluates the flags of an environment, optionally
+// restricted by key or tag selector, for a context
+type EvaluateFlagsRequest struct {
+	Context  EvaluationContext `json:"context"`
+	Flags    []string          `json:"flags,omitempty"`
+	Selector string            `json:"selector,omitempty"`
+}
+
+// FlagEvaluation is the variation a flag serves a context and why.
+// RuleIndex is set when a rule matched.
+type FlagEvaluation struct {
+	Value     interface{} `json:"value"`
+	Variation string      `json:"variation"`
+	Reason    string      `json:"reason"`
+	RuleIndex *int        `json:"rule_index,omitempty"`
+}
+
+// EvaluateFlagsResponse holds the evaluation of every requested flag by key
+type EvaluateFlagsResponse struct {
+	Environment string                    `json:"environment"`
+	Flags       map[string]FlagEvaluation `json:"flags"`
+}
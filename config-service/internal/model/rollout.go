@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// Rollout variants a client can be assigned for a template
+const (
+	VariantStable    = "stable"
+	VariantCandidate = "candidate"
+)
+
+// TemplateCandidate is a new version of a template served to a percentage
+// of clients before it is promoted to replace the stable version
+type TemplateCandidate struct {
+	TemplateID        int64     `json:"template_id"`
+	Version           string    `json:"version"`
+	Content           string    `json:"content"`
+	DefaultValues     JSONMap   `json:"default_values"`
+	RolloutPercentage int       `json:"rollout_percentage"`
+	StableVersion     string    `json:"stable_version"`
+	CreatedBy         string    `json:"created_by"`
+	UpdatedBy         string    `json:"updated_by"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// SetCandidateRequest creates or replaces the candidate of a template.
+// Content and default values left out are taken from the stable version.
+type SetCandidateRequest struct {
+	Version           string  `json:"version" validate:"required,semver"`
+	Content           *string `json:"content,omitempty" validate:"omitempty,min=1"`
+	DefaultValues     JSONMap `json:"default_values,omitempty"`
+	RolloutPercentage int     `json:"rollout_percentage" validate:"min=0,max=100"`
+}
+
+// RolloutRequest changes the share of clients served the candidate
+type RolloutRequest struct {
+	RolloutPercentage *int `json:"rollout_percentage" validate:"required,min=0,max=100"`
+}
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"time"
+)
+
+// FreezeWindow blocks template writes in an environment. A window either
+// recurs, opening at every minute matching Cron in Timezone and staying open
+// for Duration, or spans the fixed range from StartsAt to EndsAt.
+type FreezeWindow struct {
+	ID            int64      `json:"id" db:"id"`
+	EnvironmentID int64      `json:"environment_id" db:"environment_id"`
+	Name          string     `json:"name" db:"name"`
+	Reason        string     `json:"reason" db:"reason"`
+	Cron          string     `json:"cron,omitempty" db:"cron"`
+	Duration      string     `json:"duration,omitempty" db:"-"`
+	Timezone      string     `json:"timezone" db:"timezone"`
+	StartsAt      *time.Time `json:"starts_at,omitempty" db:"starts_at"`
+	EndsAt        *time.Time `json:"ends_at,omitempty" db:"ends_at"`
+	CreatedBy     string     `json:"created_by" db:"created_by"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	// DurationSeconds is how long a recurring window stays open
+	DurationSeconds int `json:"-" db:"duration_seconds"`
+}
+
+// CreateFreezeWindowRequest creates a freeze window. Either cron with
+// duration, e.g. "0 12 * * FRI" and "12h", or starts_at with ends_at is
+// required; timezone defaults to UTC.
+type CreateFreezeWindowRequest struct {
+	Name     string     `json:"name" validate:"required,min=1,max=100"`
+	Reason   string     `json:"reason" validate:"max=1000"`
+	Cron     string     `json:"cron,omitempty" validate:"max=100"`
+	Duration string     `json:"duration,omitempty"`
+	Timezone string     `json:"timezone,omitempty" validate:"max=64"`
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+}
+
+// FreezeWindowResponse represents a freeze window and whether it is open.
+// ActiveUntil is when the open window closes, NextStart when a closed window
+// opens next within the coming week.
+type FreezeWindowResponse struct {
+	FreezeWindow
+	Active      bool       `json:"active"`
+	ActiveUntil *time.Time `json:"active_until,omitempty"`
+	NextStart   *time.Time `json:"next_start,omitempty"`
+}
+
+// FreezeWindowListResponse lists the freeze windows of an environment
+type FreezeWindowListResponse struct {
+	Environment string                 `json:"environment"`
+	Frozen      bool                   `json:"frozen"`
+	Windows     []FreezeWindowResponse `json:"windows"`
+}
+
+// ScheduledDeploymentStatus is the state of a scheduled deployment
+type ScheduledDeploymentStatus string
+
+// Scheduled deployment states
+const (
+	DeploymentPending  ScheduledDeploymentStatus = "pending"
+	DeploymentApplied  ScheduledDeploymentStatus = "applied"
+	DeploymentFailed   ScheduledDeploymentStatus = "failed"
+	DeploymentCanceled ScheduledDeploymentStatus = "canceled"
+)
+
+// ScheduledDeployment applies an update bringing a template to Version once
+// RunAt has passed. While the environment is frozen it stays pending and
+// Error says why.
+type ScheduledDeployment struct {
+	ID            int64                     `json:"id"`
+	TemplateID    *int64                    `json:"template_id,omitempty"`
+	EnvironmentID int64                     `json:"environment_id"`
+	Version       string                    `json:"version"`
+	Diff          []FieldChange             `json:"diff"`
+	RunAt         time.Time                 `json:"run_at"`
+	Status        ScheduledDeploymentStatus `json:"status"`
+	Error         string                    `json:"error,omitempty"`
+	CreatedBy     string                    `json:"created_by"`
+	CanceledBy    string                    `json:"canceled_by,omitempty"`
+	AppliedAt     *time.Time                `json:"applied_at,omitempty"`
+	CreatedAt     time.Time                 `json:"created_at"`
+	UpdatedAt     time.Time                 `json:"updated_at"`
+
+	// Changes is the update to apply, with secret values encrypted
+	Changes *UpdateTemplateRequest `json:"-"`
+}
+
+// CreateScheduledDeploymentRequest schedules an update of a template that
+// sets its version. The author is the authenticated caller.
+type CreateScheduledDeploymentRequest struct {
+	TemplateID int64                 `json:"template_id" validate:"required"`
+	RunAt      time.Time             `json:"run_at" validate:"required"`
+	Update     UpdateTemplateRequest `json:"update"`
+}
+
+// ScheduledDeploymentFilter selects scheduled deployments. All set criteria
+// must match.
+type ScheduledDeploymentFilter struct {
+	Status      string `form:"status" validate:"omitempty,oneof=pending applied failed canceled"`
+	Environment string `form:"environment"`
+	TemplateID  *int64 `form:"template_id"`
+}
+
+// ScheduledDeploymentListResponse represents a page of scheduled
+// deployments, earliest run first. Page is 0 for pages addressed by cursor.
+type ScheduledDeploymentListResponse struct {
+	Deployments []ScheduledDeployment `json:"deployments"`
+	Total       int64                 `json:"total"`
+	Page        int                   `json:"page"`
+	PageSize    int                   `json:"page_size"`
+	HasNext     bool                  `json:"has_next"`
+	NextCursor  string                `json:"next_cursor,omitempty"`
+	PrevCursor  string                `json:"prev_cursor,omitempty"`
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"time"
+)
+
+// SecretRotationStatus is the state of a secret rotation job
+type SecretRotationStatus string
+
+// Secret rotation states
+const (
+	RotationRunning   SecretRotationStatus = "running"
+	RotationPaused    SecretRotationStatus = "paused"
+	RotationCompleted SecretRotationStatus = "completed"
+	RotationFailed    SecretRotationStatus = "failed"
+)
+
+// SecretRotation is a job rewrapping the data keys of secret values with the
+// primary master key. Templates are processed in ID order, then the versions
+// recorded in the configuration history; LastTemplateID and LastHistoryID are
+// the last of each done.
+type SecretRotation struct {
+	ID             int64                `json:"id" db:"id"`
+	KeyID          string               `json:"key_id" db:"key_id"`
+	KeyVersion     int                  `json:"key_version" db:"key_version"`
+	Status         SecretRotationStatus `json:"status" db:"status"`
+	Total          int                  `json:"total" db:"total"`
+	Processed      int                  `json:"processed" db:"processed"`
+	Rewrapped      int                  `json:"rewrapped" db:"rewrapped"`
+	LastTemplateID int64                `json:"last_template_id" db:"last_template_id"`
+	LastHistoryID  int64                `json:"last_history_id" db:"last_history_id"`
+	Error          string               `json:"error,omitempty" db:"error"`
+	StartedBy      string               `json:"started_by" db:"started_by"`
+	StartedAt      time.Time            `json:"started_at" db:"started_at"`
+	UpdatedAt      time.Time            `json:"updated_at" db:"updated_at"`
+	CompletedAt    *time.Time           `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// Progress returns the share of templates and recorded versions processed,
+// between 0 and 1
+func (r *SecretRotation) Progress() float64 {
+	if r.Status == RotationCompleted || r.Total == 0 {
+		return 1
+	}
+	return min(float64(r.Processed)/float64(r.Total), 1)
+}
+
+// SecretKey describes a master key and the templates holding secret values
+// wrapped by it. Version is 0 for keys missing from the keyring.
+type SecretKey struct {
+	Version   int    `json:"version,omitempty"`
+	ID        string `json:"id"`
+	Primary   bool   `json:"primary"`
+	Templates int64  `json:"templates"`
+}
+
+// SecretRotationResponse reports the keyring and the latest rotation job
+type SecretRotationResponse struct {
+	Keys     []SecretKey     `json:"keys"`
+	Rotation *SecretRotation `json:"rotation,omitempty"`
+	Progress float64         `json:"progress"`
+}
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"time"
+)
+
+// CloneEnvironmentRequest creates an environment holding a copy of every
+// template of another one. Description, active and priority default to
+// those of the source.
+type CloneEnvironmentRequest struct {
+	Name        string  `json:"name" validate:"required,min=1,max=100"`
+	Slug        string  `json:"slug" validate:"required,min=1,max=100,alphanum"`
+	Description *string `json:"description,omitempty" validate:"omitempty,max=500"`
+	Active      *bool   `json:"active,omitempty"`
+	Priority    *int    `json:"priority,omitempty" validate:"omitempty,min=0,max=100"`
+}
+
+// CloneEnvironmentResponse represents the environment created by a clone
+// and how much was copied into it
+type CloneEnvironmentResponse struct {
+	Environment EnvironmentResponse `json:"environment"`
+	Source      string              `json:"source"`
+	Templates   int                 `json:"templates"`
+	TagLinks    int                 `json:"tag_links"`
+}
+
+// SnapshotTemplate is the copy of a template kept in a snapshot. Default
+// values are kept as stored, so secret values stay encrypted, and tags are
+// kept by name.
+type SnapshotTemplate struct {
+	Name          string       `json:"name"`
+	Description   string       `json:"description"`
+	Format        ConfigFormat `json:"format"`
+	Content       string       `json:"content"`
+	Schema        JSONMap      `json:"schema"`
+	DefaultValues JSONMap      `json:"default_values"`
+	Version       string       `json:"version"`
+	Active        bool         `json:"active"`
+	Tags          []string     `json:"tags"`
+}
+
+// Snapshot is a named, immutable copy of the templates of an environment.
+// Templates is only loaded for a single snapshot.
+type Snapshot struct {
+	ID            int64              `json:"id" db:"id"`
+	EnvironmentID int64              `json:"environment_id" db:"environment_id"`
+	Name          string             `json:"name" db:"name"`
+	Description   string             `json:"description" db:"description"`
+	TemplateCount int                `json:"template_count" db:"-"`
+	Templates     []SnapshotTemplate `json:"templates,omitempty" db:"templates"`
+	CreatedBy     string             `json:"created_by" db:"created_by"`
+	CreatedAt     time.Time          `json:"created_at" db:"created_at"`
+}
+
+// CreateSnapshotRequest takes a snapshot of an environment. Names are
+// unique within the environment and cannot contain "@", which separates
+// environment and snapshot in comparisons.
+type CreateSnapshotRequest struct {
+	Name        string `json:"name" validate:"required,min=1,max=100,excludes=@"`
+	Description string `json:"description" validate:"max=1000"`
+}
+
+// SnapshotResponse represents a snapshot with secret values masked
+type SnapshotResponse struct {
+	Snapshot
+	Environment string `json:"environment"`
+}
+
+// SnapshotListResponse lists the snapshots of an environment, newest first
+type SnapshotListResponse struct {
+	Environment string             `json:"environment"`
+	Snapshots   []SnapshotResponse `json:"snapshots"`
+}
+
+// ComparedTemplate identifies a template present on one side of a
+// comparison only
+type ComparedTemplate struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// TemplateComparison lists the differences between the templates of the
+// same name on both sides of a comparison. Tags are compared by name.
+type TemplateComparison struct {
+	Name        string        `json:"name"`
+	FromVersion string        `json:"from_version"`
+	ToVersion   string        `json:"to_version"`
+	Changes     []FieldChange `json:"changes"`
+}
+
+// EnvironmentComparison represents the differences between the templates of
+// two environments or snapshots, matched by name. Added templates only exist
+// in To, removed ones only in From.
+type EnvironmentComparison struct {
+	From      string               `json:"from"`
+	To        string               `json:"to"`
+	Added     []ComparedTemplate   `json:"added"`
+	Removed   []ComparedTemplate   `json:"removed"`
+	Changed   []TemplateComparison `json:"changed"`
+	Unchanged int                  `json:"unchanged"`
+}
@@ -1,29 +1,65 @@
 package model
 
 import (
+	"strings"
 	"time"
 )
 
-// Tag represents a configuration tag
+// LabelSeparator separates key and value in the canonical name of a label tag
+const LabelSeparator = "="
+
+// Tag represents a configuration tag. A tag with a Key is a key/value label
+// such as team=payments; plain tags leave Key and Value empty. Tags form a
+// hierarchy through ParentID.
 type Tag struct {
 	ID          int64     `json:"id" db:"id"`
 	Name        string    `json:"name" db:"name" validate:"required,min=1,max=100"`
+	Key         string    `json:"key,omitempty" db:"key"`
+	Value       string    `json:"value,omitempty" db:"value"`
+	ParentID    *int64    `json:"parent_id,omitempty" db:"parent_id"`
 	Description string    `json:"description" db:"description" validate:"max=500"`
 	Color       string    `json:"color" db:"color" validate:"hexcolor"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
 }
 
-// CreateTagRequest represents request for creating a tag
+// IsLabel reports whether the tag is a key/value label
+func (t Tag) IsLabel() bool {
+	return t.Key != ""
+}
+
+// ParseLabel splits a name of the form key=value. It reports false when the
+// name contains no separator or either side is empty.
+func ParseLabel(name string) (key, value string, ok bool) {
+	key, value, ok = strings.Cut(name, LabelSeparator)
+	if !ok || key == "" || value == "" {
+		return "", "", false
+	}
+	return key, value, true
+}
+
+// CreateTagRequest represents request for creating a tag. Label tags set Key
+// and Value; their name defaults to key=value.
 type CreateTagRequest struct {
-	Name        string `json:"name" validate:"required,min=1,max=100"`
+	Name        string `json:"name" validate:"required_without=Key,max=100"`
+	Key         string `json:"key,omitempty" validate:"required_with=Value,max=63"`
+	Value       string `json:"value,omitempty" validate:"required_with=Key,max=100"`
+	ParentID    *int64 `json:"parent_id,omitempty" validate:"omitempty,min=1"`
 	Description string `json:"description" validate:"max=500"`
 	Color       string `json:"color" validate:"required,hexcolor"`
 }
 
-// UpdateTagRequest represents request for updating a tag
+// UpdateTagRequest represents request for updating a tag. Setting Key and
+// Value to empty strings turns a label back into a plain tag unless its name
+// has the form key=value. Renaming a tag derives its key and value from the
+// new name unless they are set too, and changing the key or value of a label
+// named key=value renames it. A ParentID of 0 detaches the tag from its
+// parent.
 type UpdateTagRequest struct {
 	Name        *string `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	Key         *string `json:"key,omitempty" validate:"omitempty,max=63"`
+	Value       *string `json:"value,omitempty" validate:"omitempty,max=100"`
+	ParentID    *int64  `json:"parent_id,omitempty" validate:"omitempty,min=0"`
 	Description *string `json:"description,omitempty" validate:"omitempty,max=500"`
 	Color       *string `json:"color,omitempty" validate:"omitempty,hexcolor"`
 }
@@ -32,8 +68,126 @@ type UpdateTagRequest struct {
 type TagResponse struct {
 	ID          int64     `json:"id"`
 	Name        string    `json:"name"`
+	Key         string    `json:"key,omitempty"`
+	Value       string    `json:"value,omitempty"`
+	ParentID    *int64    `json:"parent_id,omitempty"`
 	Description string    `json:"description"`
 	Color       string    `json:"color"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
+
+// NewTagResponse converts a tag into its API representation
+func NewTagResponse(t Tag) TagResponse {
+	return TagResponse{
+		ID:          t.ID,
+		Name:        t.Name,
+		Key:         t.Key,
+		Value:       t.Value,
+		ParentID:    t.ParentID,
+		Description: t.Description,
+		Color:       t.Color,
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+	}
+}
+
+// TagNode is a tag within the tag tree
+type TagNode struct {
+	TagResponse
+	Children []TagNode `json:"children"`
+}
+
+// TagTreeResponse represents the tag hierarchy, roots ordered by name
+type TagTreeResponse struct {
+	Tags []TagNode `json:"tags"`
+}
+
+// TagListResponse represents tag list response. Without pagination
+// parameters all tags are returned and the page fields are omitted.
+type TagListResponse struct {
+	Tags       []TagResponse `json:"tags"`
+	Total      int64         `json:"total"`
+	Page       int           `json:"page,omitempty"`
+	PageSize   int           `json:"page_size,omitempty"`
+	HasNext    bool          `json:"has_next"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+	PrevCursor string        `json:"prev_cursor,omitempty"`
+}
+
+// MigrateLabelsRequest converts plain tags named <key><separator><value> into
+// key/value labels
+type MigrateLabelsRequest struct {
+	Separator string   `json:"separator" validate:"required,max=3"`
+	Keys      []string `json:"keys,omitempty"`
+	DryRun    bool     `json:"dry_run"`
+}
+
+// MigratedLabel reports the conversion of one tag
+type MigratedLabel struct {
+	TagID  int64  `json:"tag_id"`
+	Name   string `json:"name"`
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// MigrateLabelsResponse summarizes a label migration
+type MigrateLabelsResponse struct {
+	DryRun   bool            `json:"dry_run"`
+	Migrated []MigratedLabel `json:"migrated"`
+	Skipped  []MigratedLabel `json:"skipped"`
+}
+
+// TagUsage reports how many templates a tag is linked to. Counts cover direct
+// links only; LastUsedAt is when the tag was last linked to a template.
+type TagUsage struct {
+	TagID         int64          `json:"tag_id"`
+	Name          string         `json:"name"`
+	Templates     int            `json:"templates"`
+	ByEnvironment map[string]int `json:"by_environment"`
+	ByFormat      map[string]int `json:"by_format"`
+	LastUsedAt    *time.Time     `json:"last_used_at,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// TagUsageResponse represents usage statistics of all tags
+type TagUsageResponse struct {
+	Tags   []TagUsage `json:"tags"`
+	Total  int        `json:"total"`
+	Unused int        `json:"unused"`
+}
+
+// TagCleanupRequest selects unused tags, without template links or child
+// tags, created more than OlderThanDays days ago
+type TagCleanupRequest struct {
+	OlderThanDays int  `json:"older_than_days" validate:"min=0"`
+	DryRun        bool `json:"dry_run"`
+}
+
+// TagCleanupResponse lists the unused tags that were, or with DryRun would
+// be, deleted
+type TagCleanupResponse struct {
+	DryRun bool          `json:"dry_run"`
+	Tags   []TagResponse `json:"tags"`
+	Total  int           `json:"total"`
+}
+
+// TagMergeRequest merges the source tags into the target tag. When the target
+// does not exist and there is a single source, the source is renamed instead.
+type TagMergeRequest struct {
+	Sources []string `json:"sources" validate:"required,min=1,max=100,dive,required"`
+	Target  string   `json:"target" validate:"required,max=100"`
+}
+
+// TagMergeResponse summarizes a merge or rename
+type TagMergeResponse struct {
+	Target            TagResponse `json:"target"`
+	Sources           []string    `json:"sources"`
+	Renamed           bool        `json:"renamed"`
+	LinksMoved        int64       `json:"links_moved"`
+	DuplicatesRemoved int64       `json:"duplicates_removed"`
+	ChildrenMoved     int         `json:"children_moved"`
+	SelectorsUpdated  int         `json:"selectors_updated"`
+	AuditID           int64       `json:"audit_id"`
+}
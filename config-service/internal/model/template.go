@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -23,8 +24,12 @@ func (cf *ConfigFormat) Scan(value interface{}) error {
 		*cf = ConfigFormatJSON
 		return nil
 	}
-	if str, ok := value.(string); ok {
-		*cf = ConfigFormat(str)
+	switch v := value.(type) {
+	case string:
+		*cf = ConfigFormat(v)
+		return nil
+	case []byte:
+		*cf = ConfigFormat(v)
 		return nil
 	}
 	return fmt.Errorf("cannot scan %T into ConfigFormat", value)
@@ -82,6 +87,15 @@ type Template struct {
 	UpdatedBy     string       `json:"updated_by" db:"updated_by"`
 }
 
+// PartialPrefix starts the names of partials: templates that are rendered
+// only where other templates include them and are left out of bundles
+const PartialPrefix = "_"
+
+// IsPartial reports whether the template is a partial
+func (t *Template) IsPartial() bool {
+	return strings.HasPrefix(t.Name, PartialPrefix)
+}
+
 // CreateTemplateRequest represents request for creating a template
 type CreateTemplateRequest struct {
 	Name          string       `json:"name" validate:"required,min=1,max=200"`
@@ -122,6 +136,7 @@ type TemplateResponse struct {
 	Schema        JSONMap             `json:"schema"`
 	DefaultValues JSONMap             `json:"default_values"`
 	Version       string              `json:"version"`
+	EnvironmentID int64               `json:"environment_id"`
 	Environment   EnvironmentResponse `json:"environment"`
 	Tags          []TagResponse       `json:"tags"`
 	Active        bool                `json:"active"`
@@ -129,13 +144,185 @@ type TemplateResponse struct {
 	UpdatedAt     time.Time           `json:"updated_at"`
 	CreatedBy     string              `json:"created_by"`
 	UpdatedBy     string              `json:"updated_by"`
+
+	fields Fieldset
+}
+
+// Relations of a template embedded in responses on request with ?include=
+const (
+	TemplateIncludeEnvironment = "environment"
+	TemplateIncludeTags        = "tags"
+)
+
+// TemplateResponseFields lists the fields of TemplateResponse that can be
+// selected with ?fields=, in response order
+var TemplateResponseFields = []string{
+	"id", "name", "description", "format", "content", "schema", "default_values", "version",
+	"environment_id", "active", "created_at", "updated_at", "created_by", "updated_by",
+}
+
+// WithFields returns a copy of the response that serializes only the given
+// fields and relations. A nil Fieldset serializes the full response.
+func (r TemplateResponse) WithFields(fields Fieldset) TemplateResponse {
+	r.fields = fields
+	return r
+}
+
+// MarshalJSON implements json.Marshaler, honouring the selected fields
+func (r TemplateResponse) MarshalJSON() ([]byte, error) {
+	type plain TemplateResponse
+	if r.fields == nil {
+		return json.Marshal(plain(r))
+	}
+
+	members := []struct {
+		name  string
+		value interface{}
+	}{
+		{"id", r.ID},
+		{"name", r.Name},
+		{"description", r.Description},
+		{"format", r.Format},
+		{"content", r.Content},
+		{"schema", r.Schema},
+		{"default_values", r.DefaultValues},
+		{"version", r.Version},
+		{"environment_id", r.EnvironmentID},
+		{TemplateIncludeEnvironment, r.Environment},
+		{TemplateIncludeTags, r.Tags},
+		{"active", r.Active},
+		{"created_at", r.CreatedAt},
+		{"updated_at", r.UpdatedAt},
+		{"created_by", r.CreatedBy},
+		{"updated_by", r.UpdatedBy},
+	}
+
+	buf := []byte{'{'}
+	for _, m := range members {
+		if !r.fields[m.name] {
+			continue
+		}
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, '"')
+		buf = append(buf, m.name...)
+		buf = append(buf, '"', ':')
+		buf = append(buf, value...)
+	}
+	return append(buf, '}'), nil
 }
 
-// TemplateListResponse represents paginated template list response
+// TemplateListResponse represents paginated template list response. Page is
+// 0 for pages addressed by cursor.
 type TemplateListResponse struct {
-	Templates []TemplateResponse `json:"templates"`
-	Total     int64              `json:"total"`
-	Page      int                `json:"page"`
-	PageSize  int                `json:"page_size"`
-	HasNext   bool               `json:"has_next"`
+	Templates  []TemplateResponse `json:"templates"`
+	Total      int64              `json:"total"`
+	Page       int                `json:"page"`
+	PageSize   int                `json:"page_size"`
+	HasNext    bool               `json:"has_next"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+	PrevCursor string             `json:"prev_cursor,omitempty"`
+}
+
+// TemplateFilter selects templates for listing and bulk operations. All set
+// criteria must match; Selector is a tag selector expression and Search a
+// full-text query over name, description and content.
+type TemplateFilter struct {
+	IDs         []int64      `json:"ids,omitempty"`
+	Environment string       `json:"environment,omitempty"`
+	Tags        []string     `json:"tags,omitempty"`
+	Selector    string       `json:"selector,omitempty"`
+	Search      string       `json:"search,omitempty" validate:"max=500"`
+	Format      ConfigFormat `json:"format,omitempty" validate:"omitempty,oneof=json yaml toml env"`
+	Active      *bool        `json:"active,omitempty"`
+}
+
+// IsEmpty reports whether the filter has no criteria and would match every template
+func (f TemplateFilter) IsEmpty() bool {
+	return len(f.IDs) == 0 && f.Environment == "" && len(f.Tags) == 0 && f.Selector == "" && f.Search == "" && f.Format == "" && f.Active == nil
+}
+
+// TemplateSearchHit is a template matching a full-text search. Snippets
+// holds highlighted excerpts of the description and content as HTML: the
+// text is escaped and matches are wrapped in <mark> tags.
+type TemplateSearchHit struct {
+	ID          int64             `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Format      ConfigFormat      `json:"format"`
+	Version     string            `json:"version"`
+	Environment string            `json:"environment"`
+	Tags        []string          `json:"tags"`
+	Active      bool              `json:"active"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	Rank        float64           `json:"rank"`
+	Snippets    map[string]string `json:"snippets"`
+}
+
+// TemplateSearchResponse represents paginated full-text search results,
+// best match first
+type TemplateSearchResponse struct {
+	Query    string              `json:"query"`
+	Results  []TemplateSearchHit `json:"results"`
+	Total    int64               `json:"total"`
+	Page     int                 `json:"page"`
+	PageSize int                 `json:"page_size"`
+	HasNext  bool                `json:"has_next"`
+}
+
+// JSON document fields of a template that can be queried by JSON path
+const (
+	TemplateFieldDefaultValues = "default_values"
+	TemplateFieldSchema        = "schema"
+)
+
+// TemplatePathMatch is a template whose default values or schema match a JSON
+// path, with the matched values per field
+type TemplatePathMatch struct {
+	ID          int64                    `json:"id"`
+	Name        string                   `json:"name"`
+	Environment string                   `json:"environment"`
+	Version     string                   `json:"version"`
+	Active      bool                     `json:"active"`
+	Matches     map[string][]interface{} `json:"matches"`
+}
+
+// TemplatePathQueryResponse represents paginated JSON path query results
+type TemplatePathQueryResponse struct {
+	Path     string              `json:"path"`
+	Fields   []string            `json:"fields"`
+	Results  []TemplatePathMatch `json:"results"`
+	Total    int64               `json:"total"`
+	Page     int                 `json:"page"`
+	PageSize int                 `json:"page_size"`
+	HasNext  bool                `json:"has_next"`
+}
+
+// TemplateRef identifies a template in a dependency graph
+type TemplateRef struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Partial bool   `json:"partial"`
+}
+
+// TemplateDependenciesResponse describes the include and lookup relations of
+// a template within its environment. Affected lists every template that
+// renders differently when it changes.
+type TemplateDependenciesResponse struct {
+	Template    TemplateRef   `json:"template"`
+	Environment string        `json:"environment"`
+	Includes    []TemplateRef `json:"includes"`
+	Lookups     []TemplateRef `json:"lookups"`
+	Missing     []string      `json:"missing,omitempty"`
+	IncludedBy  []TemplateRef `json:"included_by"`
+	LookedUpBy  []TemplateRef `json:"looked_up_by"`
+	Affected    []TemplateRef `json:"affected"`
+	Depth       int           `json:"depth"`
+	Cycle       []string      `json:"cycle,omitempty"`
+	Error       string        `json:"error,omitempty"`
 }
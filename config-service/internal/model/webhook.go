@@ -0,0 +1,138 @@
+package model
+
+import "time"
+
+// Webhook event types
+const (
+	WebhookTemplateCreated = "template.created"
+	WebhookTemplateUpdated = "template.updated"
+	WebhookTemplateDeleted = "template.deleted"
+	// WebhookPing is sent to a single subscription on request to test it;
+	// subscriptions cannot filter it out
+	WebhookPing = "ping"
+)
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookSubscription receives the events of its environment, or of all
+// environments, whose template matches its tag selector and whose type is
+// one of its event types; empty filters match every event. Subscriptions
+// failing for too long are disabled with a reason. The signing secret is
+// stored sealed in SecretEnvelope when a master key is configured; Secret
+// holds it in plaintext otherwise, or once revealed.
+type WebhookSubscription struct {
+	ID                  int64      `json:"id"`
+	Name                string     `json:"name"`
+	URL                 string     `json:"url"`
+	Secret              string     `json:"-"`
+	SecretEnvelope      JSONMap    `json:"-"`
+	EnvironmentID       *int64     `json:"environment_id,omitempty"`
+	Environment         string     `json:"environment,omitempty"`
+	Selector            string     `json:"selector"`
+	EventTypes          []string   `json:"event_types"`
+	Active              bool       `json:"active"`
+	DisabledReason      string     `json:"disabled_reason,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	FailingSince        *time.Time `json:"failing_since,omitempty"`
+	CreatedBy           string     `json:"created_by"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// WebhookResponse is a subscription as returned by the API. Secret is only
+// returned when it was generated or changed.
+type WebhookResponse struct {
+	WebhookSubscription
+	Secret string `json:"secret,omitempty"`
+}
+
+// CreateWebhookRequest subscribes a URL to events. A secret is generated
+// when none is given; Active defaults to true.
+type CreateWebhookRequest struct {
+	Name        string   `json:"name" validate:"required,min=1,max=100"`
+	URL         string   `json:"url" validate:"required,url,max=2000"`
+	Secret      string   `json:"secret,omitempty" validate:"omitempty,min=16,max=128"`
+	Environment string   `json:"environment,omitempty"`
+	Selector    string   `json:"selector,omitempty" validate:"max=1000"`
+	EventTypes  []string `json:"event_types,omitempty" validate:"dive,oneof=template.created template.updated template.deleted"`
+	Active      *bool    `json:"active,omitempty"`
+}
+
+// UpdateWebhookRequest changes the fields of a subscription that are set. An
+// empty environment subscribes to all environments and an empty event types
+// list to all types. Activating a subscription clears its failures.
+type UpdateWebhookRequest struct {
+	Name        *string  `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	URL         *string  `json:"url,omitempty" validate:"omitempty,url,max=2000"`
+	Secret      *string  `json:"secret,omitempty" validate:"omitempty,min=16,max=128"`
+	Environment *string  `json:"environment,omitempty"`
+	Selector    *string  `json:"selector,omitempty" validate:"omitempty,max=1000"`
+	EventTypes  []string `json:"event_types" validate:"dive,oneof=template.created template.updated template.deleted"`
+	Active      *bool    `json:"active,omitempty"`
+}
+
+// WebhookListResponse lists webhook subscriptions
+type WebhookListResponse struct {
+	Webhooks []WebhookSubscription `json:"webhooks"`
+}
+
+// WebhookEvent is a change queued for delivery. Tags are those of the
+// template, for selector matching.
+type WebhookEvent struct {
+	ID            int64     `json:"id"`
+	Type          string    `json:"type"`
+	EnvironmentID *int64    `json:"environment_id,omitempty"`
+	TemplateID    *int64    `json:"template_id,omitempty"`
+	Tags          []Tag     `json:"-"`
+	Payload       JSONMap   `json:"data"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// WebhookPayload is the body POSTed to subscribers. ID identifies the event
+// and stays the same across retries and redeliveries.
+type WebhookPayload struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      JSONMap   `json:"data"`
+}
+
+// WebhookDelivery sends an event to a subscription. Pending deliveries are
+// attempted at NextAttemptAt; Log lists the attempts made.
+type WebhookDelivery struct {
+	ID               int64                    `json:"id"`
+	SubscriptionID   int64                    `json:"subscription_id"`
+	EventID          int64                    `json:"event_id"`
+	EventType        string                   `json:"event_type"`
+	Status           string                   `json:"status"`
+	Attempts         int                      `json:"attempts"`
+	NextAttemptAt    *time.Time               `json:"next_attempt_at,omitempty"`
+	LastResponseCode *int                     `json:"last_response_code,omitempty"`
+	LastError        string                   `json:"last_error,omitempty"`
+	RedeliveryOf     *int64                   `json:"redelivery_of,omitempty"`
+	CreatedAt        time.Time                `json:"created_at"`
+	UpdatedAt        time.Time                `json:"updated_at"`
+	Log              []WebhookDeliveryAttempt `json:"log,omitempty"`
+}
+
+// WebhookDeliveryAttempt records one attempt of a delivery. ResponseCode is
+// unset when no response was received.
+type WebhookDeliveryAttempt struct {
+	Attempt      int       `json:"attempt"`
+	ResponseCode *int      `json:"response_code,omitempty"`
+	ResponseBody string    `json:"response_body,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	DurationMS   int       `json:"duration_ms"`
+	AttemptedAt  time.Time `json:"attempted_at"`
+}
+
+// WebhookDeliveryListResponse lists the latest deliveries of a subscription
+type WebhookDeliveryListResponse struct {
+	Webhook    string            `json:"webhook"`
+	Deliveries []WebhookDelivery `json:"deliveries"`
+}
@@ -0,0 +1,203 @@
+package render
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// maxIndent bounds the width passed to indent and nindent
+const maxIndent = 256
+
+// funcs returns the functions available to template content. None of them
+// touches the file system or network; env reads process variables only when
+// Options.AllowEnv is set.
+func (r *renderer) funcs() template.FuncMap {
+	return template.FuncMap{
+		"include":  r.include,
+		"lookup":   r.lookup,
+		"env":      r.env,
+		"default":  defaultValue,
+		"required": required,
+		"empty":    empty,
+		"toJson":   toJSON,
+		"toYaml":   toYAML,
+		"toToml":   toTOML,
+		"indent":   indent,
+		"nindent":  nindent,
+		"quote":    quote,
+		"upper":    strings.ToUpper,
+		"lower":    strings.ToLower,
+		"trim":     strings.TrimSpace,
+		"join":     join,
+		"b64enc":   b64enc,
+		"b64dec":   b64dec,
+		"sha256":   sha256Hex,
+	}
+}
+
+// lookup returns a value from the default values of another template of the
+// environment. path is a dot separated key path; an empty path returns all
+// default values.
+func (r *renderer) lookup(name, path string) (interface{}, error) {
+	tpl, ok := r.partials[name]
+	if !ok {
+		return nil, fmt.Errorf("looked up template %q does not exist in the environment", name)
+	}
+
+	values, err := r.values(tpl)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{} = map[string]interface{}(values)
+	if path == "" {
+		return value, nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("lookup %q %q: %s is not an object", name, path, key)
+		}
+		if value, ok = m[key]; !ok {
+			return nil, fmt.Errorf("lookup %q %q: no value for %s", name, path, key)
+		}
+	}
+	return value, nil
+}
+
+func (r *renderer) env(name string) (string, error) {
+	if !r.opts.AllowEnv {
+		return "", errors.New("env lookups are disabled")
+	}
+	if len(r.opts.EnvAllowlist) > 0 && !slices.Contains(r.opts.EnvAllowlist, name) {
+		return "", fmt.Errorf("environment variable %s is not allowed", name)
+	}
+	return os.Getenv(name), nil
+}
+
+// defaultValue returns value unless it is empty, and def otherwise. Missing
+// map keys fail before reaching it; use index, as in
+// `default 8080 (index . "port")`, for optional keys.
+func defaultValue(def interface{}, value ...interface{}) interface{} {
+	if len(value) == 0 || empty(value[0]) {
+		return def
+	}
+	return value[0]
+}
+
+func required(msg string, value interface{}) (interface{}, error) {
+	if empty(value) {
+		return nil, errors.New(msg)
+	}
+	return value, nil
+}
+
+// empty reports whether a value is nil, false, zero or has no elements
+func empty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
+
+func toJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	return string(data), err
+}
+
+func toYAML(value interface{}) (string, error) {
+	data, err := yaml.Marshal(value)
+	return strings.TrimSuffix(string(data), "\n"), err
+}
+
+func toTOML(value interface{}) (string, error) {
+	data, err := toml.Marshal(integers(value))
+	return strings.TrimSuffix(string(data), "\n"), err
+}
+
+// integers converts whole float64 numbers, as decoded from JSON, to int64 so
+// that TOML writes them as integers
+func integers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = integers(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = integers(item)
+		}
+		return out
+	}
+	return value
+}
+
+func indent(width int, s string) (string, error) {
+	if width < 0 || width > maxIndent {
+		return "", fmt.Errorf("indent width must be between 0 and %d", maxIndent)
+	}
+	pad := strings.Repeat(" ", width)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad), nil
+}
+
+func nindent(width int, s string) (string, error) {
+	out, err := indent(width, s)
+	return "\n" + out, err
+}
+
+func quote(value interface{}) string {
+	return fmt.Sprintf("%q", fmt.Sprint(value))
+}
+
+// join concatenates the elements of a list with sep
+func join(sep string, list interface{}) (string, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("join expects a list, got %T", list)
+	}
+	parts := make([]string, v.Len())
+	for i := range parts {
+		parts[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+	return strings.Join(parts, sep), nil
+}
+
+func b64enc(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func b64dec(s string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	return string(data), err
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
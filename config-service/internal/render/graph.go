@@ -0,0 +1,188 @@
+package render
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/company/config-service/internal/model"
+)
+
+// Graph is the include and lookup graph of the templates of one environment
+type Graph struct {
+	templates  map[string]*model.Template
+	includes   map[string][]string
+	includedBy map[string][]string
+	lookups    map[string][]string
+	lookedUpBy map[string][]string
+	errs       map[string]error
+}
+
+// NewGraph builds the include graph of templates. Templates whose content
+// does not parse have no edges; their errors are reported by Err.
+func NewGraph(templates []model.Template) *Graph {
+	g := &Graph{
+		templates:  make(map[string]*model.Template, len(templates)),
+		includes:   map[string][]string{},
+		includedBy: map[string][]string{},
+		lookups:    map[string][]string{},
+		lookedUpBy: map[string][]string{},
+		errs:       map[string]error{},
+	}
+	for i := range templates {
+		tpl := &templates[i]
+		g.templates[tpl.Name] = tpl
+		refs, err := Refs(tpl.Content)
+		if err != nil {
+			g.errs[tpl.Name] = err
+			continue
+		}
+		g.includes[tpl.Name] = refs.Includes
+		for _, name := range refs.Includes {
+			g.includedBy[name] = append(g.includedBy[name], tpl.Name)
+		}
+		g.lookups[tpl.Name] = refs.Lookups
+		for _, name := range refs.Lookups {
+			g.lookedUpBy[name] = append(g.lookedUpBy[name], tpl.Name)
+		}
+	}
+	for _, reverse := range []map[string][]string{g.includedBy, g.lookedUpBy} {
+		for name := range reverse {
+			sort.Strings(reverse[name])
+		}
+	}
+	return g
+}
+
+// Template returns the named template, or nil
+func (g *Graph) Template(name string) *model.Template {
+	return g.templates[name]
+}
+
+// Err returns the error parsing the includes of the named template
+func (g *Graph) Err(name string) error {
+	return g.errs[name]
+}
+
+// Includes returns the names the template includes directly
+func (g *Graph) Includes(name string) []string {
+	return g.includes[name]
+}
+
+// IncludedBy returns the names of the templates including the template
+// directly
+func (g *Graph) IncludedBy(name string) []string {
+	return g.includedBy[name]
+}
+
+// Lookups returns the names of the templates whose values the template looks
+// up
+func (g *Graph) Lookups(name string) []string {
+	return g.lookups[name]
+}
+
+// LookedUpBy returns the names of the templates looking up values of the
+// template
+func (g *Graph) LookedUpBy(name string) []string {
+	return g.lookedUpBy[name]
+}
+
+// Missing returns the names the template includes or looks up that do not
+// exist
+func (g *Graph) Missing(name string) []string {
+	var missing []string
+	for _, ref := range append(append([]string{}, g.includes[name]...), g.lookups[name]...) {
+		if g.templates[ref] == nil && !slices.Contains(missing, ref) {
+			missing = append(missing, ref)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// Affected returns the sorted names of every template that renders
+// differently when the template changes: those looking up its values, and
+// those including it or any affected template
+func (g *Graph) Affected(name string) []string {
+	seen := map[string]bool{name: true}
+	queue := []string{name}
+	var affected []string
+	for _, parent := range g.lookedUpBy[name] {
+		if !seen[parent] {
+			seen[parent] = true
+			affected = append(affected, parent)
+			queue = append(queue, parent)
+		}
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, parent := range g.includedBy[current] {
+			if !seen[parent] {
+				seen[parent] = true
+				affected = append(affected, parent)
+				queue = append(queue, parent)
+			}
+		}
+	}
+	sort.Strings(affected)
+	return affected
+}
+
+// Cycle returns an include chain leading from the template back to itself,
+// starting and ending with its name, or nil when there is none
+func (g *Graph) Cycle(name string) []string {
+	visited := map[string]bool{}
+	var path []string
+	var visit func(string) bool
+	visit = func(current string) bool {
+		path = append(path, current)
+		for _, next := range g.includes[current] {
+			if next == name {
+				path = append(path, next)
+				return true
+			}
+			if !visited[next] {
+				visited[next] = true
+				if visit(next) {
+					return true
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+	if visit(name) {
+		return path
+	}
+	return nil
+}
+
+// Depth returns the length of the longest include chain below the template.
+// Templates on a cycle count once.
+func (g *Graph) Depth(name string) int {
+	onPath := map[string]bool{}
+	memo := map[string]int{}
+	var depth func(string) int
+	depth = func(current string) int {
+		if d, ok := memo[current]; ok {
+			return d
+		}
+		onPath[current] = true
+		longest := 0
+		for _, next := range g.includes[current] {
+			if onPath[next] || g.templates[next] == nil {
+				if longest < 1 {
+					longest = 1
+				}
+				continue
+			}
+			if d := depth(next) + 1; d > longest {
+				longest = d
+			}
+		}
+		onPath[current] = false
+		memo[current] = longest
+		return longest
+	}
+	return depth(name)
+}
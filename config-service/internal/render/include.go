@@ -0,0 +1,312 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/company/config-service/internal/model"
+)
+
+// MaxIncludeDepth bounds how deeply includes may nest below the rendered
+// template
+const MaxIncludeDepth = 8
+
+var (
+	// ErrIncludeCycle is returned when a template includes itself, directly
+	// or through other templates
+	ErrIncludeCycle = errors.New("include cycle")
+	// ErrIncludeDepth is returned when includes nest deeper than MaxIncludeDepth
+	ErrIncludeDepth = errors.New("include depth exceeded")
+	// ErrIncludeName is returned for includes and lookups whose template name
+	// is not a string constant
+	ErrIncludeName = errors.New("include and lookup require a constant template name")
+	// ErrTimeout is returned when rendering exceeds Options.Timeout
+	ErrTimeout = errors.New("rendering timed out")
+	// ErrOutputTooLarge is returned when output exceeds Options.MaxOutputBytes
+	ErrOutputTooLarge = errors.New("rendered output too large")
+)
+
+// Partials maps template names to the templates of an environment that can be
+// included or looked up. A nil Partials resolves neither.
+type Partials map[string]*model.Template
+
+// NewPartials indexes templates by name
+func NewPartials(templates []model.Template) Partials {
+	p := make(Partials, len(templates))
+	for i := range templates {
+		p[templates[i].Name] = &templates[i]
+	}
+	return p
+}
+
+// renderer executes a template and the templates it includes, tracking the
+// include chain to detect cycles and excessive nesting
+type renderer struct {
+	partials Partials
+	opts     Options
+	deadline time.Time
+	parsed   map[string]*template.Template
+	stack    []string
+}
+
+func (r *renderer) render(tpl *model.Template, data interface{}) ([]byte, error) {
+	for _, name := range r.stack {
+		if name == tpl.Name {
+			return nil, fmt.Errorf("%w: %s", ErrIncludeCycle, strings.Join(append(r.stack, tpl.Name), " -> "))
+		}
+	}
+	if len(r.stack) > MaxIncludeDepth {
+		return nil, fmt.Errorf("%w: %s", ErrIncludeDepth, strings.Join(append(r.stack, tpl.Name), " -> "))
+	}
+
+	t, ok := r.parsed[tpl.Name]
+	if !ok {
+		var err error
+		t, err = template.New(tpl.Name).Option("missingkey=error").Funcs(r.funcs()).Parse(tpl.Content)
+		if err != nil {
+			return nil, err
+		}
+		if !r.deadline.IsZero() {
+			t.Funcs(template.FuncMap{"checkDeadline": r.checkDeadline})
+			guardLoops(t)
+		}
+		if r.parsed == nil {
+			r.parsed = map[string]*template.Template{}
+		}
+		r.parsed[tpl.Name] = t
+	}
+
+	r.stack = append(r.stack, tpl.Name)
+	defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+
+	w := &limitWriter{r: r}
+	if err := t.Execute(w, data); err != nil {
+		return nil, err
+	}
+	return w.buf.Bytes(), nil
+}
+
+// limitWriter buffers output, failing once the deadline has passed or the
+// output grows beyond Options.MaxOutputBytes. Failing writes stop execution.
+type limitWriter struct {
+	buf bytes.Buffer
+	r   *renderer
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if !w.r.deadline.IsZero() && time.Now().After(w.r.deadline) {
+		return 0, ErrTimeout
+	}
+	if limit := w.r.opts.MaxOutputBytes; limit > 0 && w.buf.Len()+len(p) > limit {
+		return 0, fmt.Errorf("%w: more than %d bytes", ErrOutputTooLarge, limit)
+	}
+	return w.buf.Write(p)
+}
+
+// deadlineCheck is an action calling checkDeadline, inserted into loops and
+// defined templates by guardLoops
+var deadlineCheck = template.Must(template.New("").
+	Funcs(template.FuncMap{"checkDeadline": func() string { return "" }}).
+	Parse("{{checkDeadline}}")).Tree.Root.Nodes[0]
+
+// guardLoops makes every range iteration and every call of a defined
+// template check the deadline first, so that loops and recursion producing
+// no output still stop when rendering times out
+func guardLoops(t *template.Template) {
+	for _, associated := range t.Templates() {
+		if associated.Tree == nil {
+			continue
+		}
+		root := associated.Tree.Root
+		guardList(root)
+		root.Nodes = append([]parse.Node{deadlineCheck}, root.Nodes...)
+	}
+}
+
+func guardList(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.IfNode:
+			guardBranch(&n.BranchNode)
+		case *parse.WithNode:
+			guardBranch(&n.BranchNode)
+		case *parse.RangeNode:
+			guardBranch(&n.BranchNode)
+			n.List.Nodes = append([]parse.Node{deadlineCheck}, n.List.Nodes...)
+		}
+	}
+}
+
+func guardBranch(n *parse.BranchNode) {
+	guardList(n.List)
+	guardList(n.ElseList)
+}
+
+// checkDeadline fails once the deadline has passed
+func (r *renderer) checkDeadline() (string, error) {
+	if !r.deadline.IsZero() && time.Now().After(r.deadline) {
+		return "", ErrTimeout
+	}
+	return "", nil
+}
+
+// include renders the named template of the environment. The partial's
+// default values fill keys missing from map data; without data the partial
+// renders with its own default values.
+func (r *renderer) include(name string, data ...interface{}) (string, error) {
+	if len(data) > 1 {
+		return "", fmt.Errorf("include %q takes at most one data argument", name)
+	}
+	partial, ok := r.partials[name]
+	if !ok {
+		return "", fmt.Errorf("included template %q does not exist in the environment", name)
+	}
+
+	values, err := r.values(partial)
+	if err != nil {
+		return "", err
+	}
+	out, err := r.render(partial, includeData(values, data))
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func includeData(values model.JSONMap, data []interface{}) interface{} {
+	merged := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		merged[k] = v
+	}
+	if len(data) == 0 {
+		return merged
+	}
+	values, ok := data[0].(map[string]interface{})
+	if !ok {
+		return data[0]
+	}
+	for k, v := range values {
+		merged[k] = v
+	}
+	return merged
+}
+
+// values returns the default values of a template passed through
+// opts.Reveal
+func (r *renderer) values(tpl *model.Template) (model.JSONMap, error) {
+	if r.opts.Reveal == nil {
+		return tpl.DefaultValues, nil
+	}
+	return r.opts.Reveal(tpl.DefaultValues)
+}
+
+// References lists the templates a template refers to by name
+type References struct {
+	// Includes are rendered into the template
+	Includes []string
+	// Lookups provide default values to the template
+	Lookups []string
+}
+
+// Refs parses template content and returns the sorted, distinct names of the
+// templates it includes and looks up
+func Refs(content string) (References, error) {
+	t, err := template.New("").Funcs((&renderer{}).funcs()).Parse(content)
+	if err != nil {
+		return References{}, err
+	}
+
+	found := map[string]map[string]bool{"include": {}, "lookup": {}}
+	for _, associated := range t.Templates() {
+		if associated.Tree == nil {
+			continue
+		}
+		if err := collectRefs(associated.Tree.Root, found); err != nil {
+			return References{}, err
+		}
+	}
+	return References{Includes: sortedKeys(found["include"]), Lookups: sortedKeys(found["lookup"])}, nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// collectRefs walks a parse tree recording the names passed to include and
+// lookup, keyed by function
+func collectRefs(node parse.Node, found map[string]map[string]bool) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := collectRefs(child, found); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return collectRefs(n.Pipe, found)
+	case *parse.IfNode:
+		return collectBranch(&n.BranchNode, found)
+	case *parse.RangeNode:
+		return collectBranch(&n.BranchNode, found)
+	case *parse.WithNode:
+		return collectBranch(&n.BranchNode, found)
+	case *parse.TemplateNode:
+		return collectRefs(n.Pipe, found)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			if err := collectRefs(cmd, found); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		for i, arg := range n.Args {
+			if ident, ok := arg.(*parse.IdentifierNode); ok && found[ident.Ident] != nil {
+				if i != 0 || len(n.Args) < 2 {
+					return ErrIncludeName
+				}
+				name, ok := n.Args[1].(*parse.StringNode)
+				if !ok {
+					return ErrIncludeName
+				}
+				found[ident.Ident][name.Text] = true
+				continue
+			}
+			if err := collectRefs(arg, found); err != nil {
+				return err
+			}
+		}
+	case *parse.ChainNode:
+		return collectRefs(n.Node, found)
+	}
+	return nil
+}
+
+func collectBranch(n *parse.BranchNode, found map[string]map[string]bool) error {
+	if err := collectRefs(n.Pipe, found); err != nil {
+		return err
+	}
+	if err := collectRefs(n.List, found); err != nil {
+		return err
+	}
+	return collectRefs(n.ElseList, found)
+}
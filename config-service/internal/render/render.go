@@ -0,0 +1,128 @@
+package render
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/company/config-service/internal/model"
+)
+
+// Error describes a template that could not be parsed or executed
+type Error struct {
+	Template string
+	Err      error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("failed to render template %q: %v", e.Template, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Options bounds and configures rendering
+type Options struct {
+	// Timeout bounds rendering a template including its includes; zero
+	// disables the limit
+	Timeout time.Duration
+	// MaxOutputBytes bounds the output of a template and of each template it
+	// includes; zero disables the limit
+	MaxOutputBytes int
+	// AllowEnv enables the env function, which reads variables of the server
+	// process. A non-empty EnvAllowlist restricts it to the listed variables.
+	AllowEnv     bool
+	EnvAllowlist []string
+	// Reveal, when set, is applied to the default values of every template
+	// before they are used as data, to decrypt secret values or refuse them
+	Reveal func(model.JSONMap) (model.JSONMap, error)
+}
+
+// Render executes the template content with its default values as data.
+// Includes and lookups are resolved against partials, normally the templates
+// of the same environment. Rendering runs in its own goroutine so that it
+// can be abandoned when opts.Timeout elapses; the goroutine stops at its next
+// write, loop iteration or defined template call.
+func Render(tpl *model.Template, partials Partials, opts Options) ([]byte, error) {
+	r := &renderer{partials: partials, opts: opts}
+	values, err := r.values(tpl)
+	if err != nil {
+		return nil, &Error{Template: tpl.Name, Err: err}
+	}
+	data := map[string]interface{}(values)
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	if opts.Timeout > 0 {
+		r.deadline = time.Now().Add(opts.Timeout)
+	}
+
+	type result struct {
+		out []byte
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- result{err: fmt.Errorf("template panicked: %v", p)}
+			}
+		}()
+		out, err := r.render(tpl, data)
+		done <- result{out: out, err: err}
+	}()
+
+	var res result
+	if opts.Timeout > 0 {
+		timer := time.NewTimer(opts.Timeout)
+		defer timer.Stop()
+		select {
+		case res = <-done:
+		case <-timer.C:
+			res = result{err: ErrTimeout}
+		}
+	} else {
+		res = <-done
+	}
+	if res.err != nil {
+		return nil, &Error{Template: tpl.Name, Err: res.err}
+	}
+	return res.out, nil
+}
+
+// Checksum returns the hex encoded SHA-256 of rendered content
+func Checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// FileName returns the file name a rendered template is materialized under.
+// The template name is sanitized and the format extension is appended unless
+// the name already ends with it.
+func FileName(tpl *model.Template) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, strings.TrimSpace(tpl.Name))
+	name = strings.TrimLeft(name, ".")
+	if name == "" {
+		name = fmt.Sprintf("template-%d", tpl.ID)
+	}
+
+	ext := "." + string(tpl.Format)
+	if strings.EqualFold(filepath.Ext(name), ext) {
+		return name
+	}
+	return name + ext
+}
@@ -0,0 +1,69 @@
+package render
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/company/config-service/internal/model"
+)
+
+func TestRenderTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"loop without output", `{{range 2000000000}}{{end}}`},
+		{"nested loops", `{{range 100000}}{{range 100000}}{{end}}{{end}}`},
+		{"loop in else branch", `{{if false}}{{else}}{{range 2000000000}}{{end}}{{end}}`},
+		{"loop in defined template", `{{define "spin"}}{{range 2000000000}}{{end}}{{end}}{{template "spin"}}`},
+		{"loop with output", `{{range 2000000000}}x{{end}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+			tpl := &model.Template{Name: "slow", Content: tt.content}
+
+			start := time.Now()
+			_, err := Render(tpl, nil, Options{Timeout: 50 * time.Millisecond})
+			if !errors.Is(err, ErrTimeout) {
+				t.Fatalf("Render error = %v, want %v", err, ErrTimeout)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("Render returned after %s", elapsed)
+			}
+
+			// The rendering goroutine stops rather than running the loop out
+			deadline := time.Now().Add(2 * time.Second)
+			for runtime.NumGoroutine() > before {
+				if time.Now().After(deadline) {
+					t.Fatalf("%d goroutines still running, %d before rendering", runtime.NumGoroutine(), before)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestRenderWithinTimeout(t *testing.T) {
+	tpl := &model.Template{
+		Name:          "hosts",
+		Content:       `{{define "host"}}{{.}};{{end}}{{range .hosts}}{{template "host" .}}{{end}}{{range 3}}{{.}}{{end}}`,
+		DefaultValues: model.JSONMap{"hosts": []interface{}{"a", "b"}},
+	}
+	out, err := Render(tpl, nil, Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+	if string(out) != "a;b;012" {
+		t.Errorf("Render = %q, want %q", out, "a;b;012")
+	}
+}
+
+func TestRenderOutputLimit(t *testing.T) {
+	tpl := &model.Template{Name: "big", Content: `{{range 1000}}0123456789{{end}}`}
+	if _, err := Render(tpl, nil, Options{MaxOutputBytes: 100}); !errors.Is(err, ErrOutputTooLarge) {
+		t.Errorf("Render error = %v, want %v", err, ErrOutputTooLarge)
+	}
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+)
+
+const auditColumns = `id, action, entity_type, entity_id, actor, details, created_at`
+
+// AuditRepository provides access to the audit log
+type AuditRepository struct {
+	db DBTX
+}
+
+// NewAuditRepository creates a new audit repository
+func NewAuditRepository(db *database.Connection) *AuditRepository {
+	return &AuditRepository{db: db.DB}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *AuditRepository) WithTx(tx *sql.Tx) *AuditRepository {
+	return &AuditRepository{db: tx}
+}
+
+// Create appends an entry to the audit log and fills its ID and timestamp
+func (r *AuditRepository) Create(ctx context.Context, entry *model.AuditEntry) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO audit_log (action, entity_type, entity_id, actor, details)
+		VALUES ($1, $2, $3, $4, COALESCE($5::jsonb, '{}'))
+		RETURNING id, created_at`,
+		entry.Action, entry.EntityType, entry.EntityID, entry.Actor, entry.Details,
+	).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry %s: %w", entry.Action, err)
+	}
+	return nil
+}
+
+// auditSortColumns are the columns the audit log can be sorted by; entries
+// are always listed newest first
+var auditSortColumns = map[string]sortColumn{
+	"created_at": {expr: "created_at", cast: "timestamptz"},
+}
+
+// ListPage returns one page of audit entries matching the filter, newest
+// first, addressed by page number or by the cursor in page, together with
+// its position
+func (r *AuditRepository) ListPage(ctx context.Context, filter model.AuditFilter, page model.PaginationParams) ([]model.AuditEntry, PageInfo, error) {
+	var conditions []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	k, err := newKeyset(page, model.SortParams{SortBy: "created_at", SortOrder: "desc"}, auditSortColumns, "id")
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	if filter.Action != "" {
+		conditions = append(conditions, "action = "+arg(filter.Action))
+	}
+	if filter.EntityType != "" {
+		conditions = append(conditions, "entity_type = "+arg(filter.EntityType))
+	}
+	if filter.EntityID != nil {
+		conditions = append(conditions, "entity_id = "+arg(*filter.EntityID))
+	}
+	if filter.Actor != "" {
+		conditions = append(conditions, "actor = "+arg(filter.Actor))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM audit_log`+where, args...).Scan(&total); err != nil {
+		return nil, PageInfo{}, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+
+	where = k.where(where, arg)
+	rows, err := r.db.QueryContext(ctx, `SELECT `+auditColumns+` FROM audit_log`+where+k.clauses(arg), args...)
+	if err != nil {
+		return nil, PageInfo{}, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []model.AuditEntry
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, PageInfo{}, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entries = append(entries, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, PageInfo{}, fmt.Errorf("failed to iterate audit entries: %w", err)
+	}
+
+	entries, info := keysetPage(k, entries, total, func(e model.AuditEntry, _ string) (string, int64) {
+		return cursorTime(e.CreatedAt), e.ID
+	})
+	return entries, info, nil
+}
+
+func scanAuditEntry(row rowScanner) (*model.AuditEntry, error) {
+	var entry model.AuditEntry
+	if err := row.Scan(
+		&entry.ID, &entry.Action, &entry.EntityType, &entry.EntityID, &entry.Actor,
+		&entry.Details, &entry.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
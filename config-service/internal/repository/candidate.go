@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+)
+
+const candidateColumns = `c.template_id, c.version, c.content, c.default_values, c.rollout_percentage,
+	c.created_by, c.updated_by, c.created_at, c.updated_at`
+
+// CandidateRepository provides access to the candidate versions of templates
+type CandidateRepository struct {
+	db DBTX
+}
+
+// NewCandidateRepository creates a new candidate repository
+func NewCandidateRepository(db *database.Connection) *CandidateRepository {
+	return &CandidateRepository{db: db.DB}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *CandidateRepository) WithTx(tx *sql.Tx) *CandidateRepository {
+	return &CandidateRepository{db: tx}
+}
+
+// AsOf returns a read-only copy of the repository reading the state recorded
+// in the history at the given moment
+func (r *CandidateRepository) AsOf(at time.Time) *CandidateRepository {
+	return &CandidateRepository{db: asOf(r.db, at)}
+}
+
+// Get returns the candidate of a template
+func (r *CandidateRepository) Get(ctx context.Context, templateID int64) (*model.TemplateCandidate, error) {
+	c, err := scanCandidate(r.db.QueryRowContext(ctx,
+		`SELECT `+candidateColumns+` FROM template_candidates c WHERE c.template_id = $1`, templateID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get candidate of template %d: %w", templateID, err)
+	}
+	return c, nil
+}
+
+// ListByEnvironment returns the candidates of the templates of an
+// environment keyed by template ID
+func (r *CandidateRepository) ListByEnvironment(ctx context.Context, environmentID int64) (map[int64]*model.TemplateCandidate, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+candidateColumns+`
+		FROM template_candidates c
+		JOIN templates t ON t.id = c.template_id
+		WHERE t.environment_id = $1`, environmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list candidates: %w", err)
+	}
+	defer rows.Close()
+
+	candidates := make(map[int64]*model.TemplateCandidate)
+	for rows.Next() {
+		c, err := scanCandidate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan candidate: %w", err)
+		}
+		candidates[c.TemplateID] = c
+	}
+	return candidates, rows.Err()
+}
+
+// Upsert creates or replaces the candidate of a template and fills its
+// timestamps
+func (r *CandidateRepository) Upsert(ctx context.Context, c *model.TemplateCandidate) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO template_candidates (template_id, version, content, default_values,
+			rollout_percentage, created_by, updated_by)
+		VALUES ($1, $2, $3, COALESCE($4::jsonb, '{}'), $5, $6, $6)
+		ON CONFLICT (template_id) DO UPDATE
+		SET version = EXCLUDED.version, content = EXCLUDED.content,
+			default_values = EXCLUDED.default_values,
+			rollout_percentage = EXCLUDED.rollout_percentage, updated_by = EXCLUDED.updated_by
+		RETURNING created_by, created_at, updated_at`,
+		c.TemplateID, c.Version, c.Content, c.DefaultValues, c.RolloutPercentage, c.UpdatedBy,
+	).Scan(&c.CreatedBy, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save candidate of template %d: %w", c.TemplateID, err)
+	}
+	return nil
+}
+
+// SetRollout changes the rollout percentage of a candidate
+func (r *CandidateRepository) SetRollout(ctx context.Context, c *model.TemplateCandidate) error {
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE template_candidates SET rollout_percentage = $2, updated_by = $3
+		WHERE template_id = $1
+		RETURNING updated_at`,
+		c.TemplateID, c.RolloutPercentage, c.UpdatedBy,
+	).Scan(&c.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to update rollout of template %d: %w", c.TemplateID, err)
+	}
+	return nil
+}
+
+// Delete removes the candidate of a template
+func (r *CandidateRepository) Delete(ctx context.Context, templateID int64) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM template_candidates WHERE template_id = $1`, templateID)
+	if err != nil {
+		return fmt.Errorf("failed to delete candidate of template %d: %w", templateID, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanCandidate(row rowScanner) (*model.TemplateCandidate, error) {
+	var c model.TemplateCandidate
+	if err := row.Scan(
+		&c.TemplateID, &c.Version, &c.Content, &c.DefaultValues, &c.RolloutPercentage,
+		&c.CreatedBy, &c.UpdatedBy, &c.CreatedAt, &c.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
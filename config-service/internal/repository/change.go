@@ -0,0 +1,302 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+	"github.com/lib/pq"
+)
+
+const changeRequestColumns = `c.id, c.environment_id, c.template_id, c.operation, c.title, c.description,
+	c.proposed, c.base_updated_at, c.diff, c.status, c.required_approvals, c.author, c.resolved_by,
+	c.reason, c.resolved_at, c.created_at, c.updated_at`
+
+// ChangeRequestRepository provides access to change requests with their
+// approvals and comments
+type ChangeRequestRepository struct {
+	db DBTX
+}
+
+// NewChangeRequestRepository creates a new change request repository
+func NewChangeRequestRepository(db *database.Connection) *ChangeRequestRepository {
+	return &ChangeRequestRepository{db: db.DB}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *ChangeRequestRepository) WithTx(tx *sql.Tx) *ChangeRequestRepository {
+	return &ChangeRequestRepository{db: tx}
+}
+
+// Create inserts a change request and fills its ID and timestamps
+func (r *ChangeRequestRepository) Create(ctx context.Context, cr *model.ChangeRequest) error {
+	var proposed []byte
+	if cr.Proposed != nil {
+		var err error
+		if proposed, err = json.Marshal(cr.Proposed); err != nil {
+			return fmt.Errorf("failed to encode proposed template: %w", err)
+		}
+	}
+	diff, err := json.Marshal(cr.Diff)
+	if err != nil {
+		return fmt.Errorf("failed to encode change request diff: %w", err)
+	}
+
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO change_requests (environment_id, template_id, operation, title, description,
+			proposed, base_updated_at, diff, status, required_approvals, author)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at, updated_at`,
+		cr.EnvironmentID, cr.TemplateID, cr.Op, cr.Title, cr.Description, proposed,
+		cr.BaseUpdatedAt, diff, cr.Status, cr.RequiredApprovals, cr.Author,
+	).Scan(&cr.ID, &cr.CreatedAt, &cr.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create change request: %w", err)
+	}
+	return nil
+}
+
+// GetByID returns a change request with its approvals
+func (r *ChangeRequestRepository) GetByID(ctx context.Context, id int64) (*model.ChangeRequest, error) {
+	return r.get(ctx, `SELECT `+changeRequestColumns+` FROM change_requests c WHERE c.id = $1`, id)
+}
+
+// Lock returns a change request with its approvals and locks it until the
+// transaction ends
+func (r *ChangeRequestRepository) Lock(ctx context.Context, id int64) (*model.ChangeRequest, error) {
+	return r.get(ctx, `SELECT `+changeRequestColumns+` FROM change_requests c WHERE c.id = $1 FOR UPDATE`, id)
+}
+
+func (r *ChangeRequestRepository) get(ctx context.Context, query string, id int64) (*model.ChangeRequest, error) {
+	cr, err := scanChangeRequest(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get change request %d: %w", id, err)
+	}
+	if err := r.loadApprovals(ctx, []*model.ChangeRequest{cr}); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// changeRequestSortColumns are the columns change requests can be sorted by;
+// they are always listed newest first
+var changeRequestSortColumns = map[string]sortColumn{
+	"created_at": {expr: "c.created_at", cast: "timestamptz"},
+}
+
+// ListPage returns one page of change requests matching the filter with
+// their approvals, newest first, addressed by page number or by the cursor in
+// page, together with its position
+func (r *ChangeRequestRepository) ListPage(ctx context.Context, filter model.ChangeRequestFilter, page model.PaginationParams) ([]model.ChangeRequest, PageInfo, error) {
+	var conditions []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	k, err := newKeyset(page, model.SortParams{SortBy: "created_at", SortOrder: "desc"}, changeRequestSortColumns, "c.id")
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	if filter.Status != "" {
+		conditions = append(conditions, "c.status = "+arg(filter.Status))
+	}
+	if filter.Environment != "" {
+		conditions = append(conditions, "c.environment_id = (SELECT id FROM environments WHERE slug = "+arg(filter.Environment)+")")
+	}
+	if filter.TemplateID != nil {
+		conditions = append(conditions, "c.template_id = "+arg(*filter.TemplateID))
+	}
+	if filter.Author != "" {
+		conditions = append(conditions, "c.author = "+arg(filter.Author))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM change_requests c`+where, args...).Scan(&total); err != nil {
+		return nil, PageInfo{}, fmt.Errorf("failed to count change requests: %w", err)
+	}
+
+	where = k.where(where, arg)
+	rows, err := r.db.QueryContext(ctx, `SELECT `+changeRequestColumns+` FROM change_requests c`+where+k.clauses(arg), args...)
+	if err != nil {
+		return nil, PageInfo{}, fmt.Errorf("failed to list change requests: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []model.ChangeRequest
+	for rows.Next() {
+		cr, err := scanChangeRequest(rows)
+		if err != nil {
+			return nil, PageInfo{}, fmt.Errorf("failed to scan change request: %w", err)
+		}
+		changes = append(changes, *cr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, PageInfo{}, fmt.Errorf("failed to iterate change requests: %w", err)
+	}
+
+	changes, info := keysetPage(k, changes, total, func(c model.ChangeRequest, _ string) (string, int64) {
+		return cursorTime(c.CreatedAt), c.ID
+	})
+
+	refs := make([]*model.ChangeRequest, len(changes))
+	for i := range changes {
+		refs[i] = &changes[i]
+	}
+	if err := r.loadApprovals(ctx, refs); err != nil {
+		return nil, PageInfo{}, err
+	}
+	return changes, info, nil
+}
+
+// Resolve records the final status of a change request
+func (r *ChangeRequestRepository) Resolve(ctx context.Context, cr *model.ChangeRequest) error {
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE change_requests
+		SET status = $2, resolved_by = $3, reason = $4, template_id = $5, resolved_at = NOW()
+		WHERE id = $1
+		RETURNING resolved_at, updated_at`,
+		cr.ID, cr.Status, cr.ResolvedBy, cr.Reason, cr.TemplateID,
+	).Scan(&cr.ResolvedAt, &cr.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to resolve change request %d: %w", cr.ID, err)
+	}
+	return nil
+}
+
+// AddApproval records an approval and appends it to the change request
+func (r *ChangeRequestRepository) AddApproval(ctx context.Context, cr *model.ChangeRequest, approver string) error {
+	approval := model.ChangeApproval{Approver: approver}
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO change_request_approvals (change_request_id, approver)
+		VALUES ($1, $2)
+		RETURNING created_at`, cr.ID, approver,
+	).Scan(&approval.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to approve change request %d: %w", cr.ID, err)
+	}
+	cr.Approvals = append(cr.Approvals, approval)
+	return nil
+}
+
+// loadApprovals fills the approvals of change requests, oldest first
+func (r *ChangeRequestRepository) loadApprovals(ctx context.Context, changes []*model.ChangeRequest) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(changes))
+	index := make(map[int64]*model.ChangeRequest, len(changes))
+	for i, cr := range changes {
+		ids[i] = cr.ID
+		index[cr.ID] = cr
+		cr.Approvals = []model.ChangeApproval{}
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT change_request_id, approver, created_at
+		FROM change_request_approvals
+		WHERE change_request_id = ANY($1)
+		ORDER BY created_at, approver`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to load change request approvals: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var approval model.ChangeApproval
+		if err := rows.Scan(&id, &approval.Approver, &approval.CreatedAt); err != nil {
+			return fmt.Errorf("failed to scan change request approval: %w", err)
+		}
+		index[id].Approvals = append(index[id].Approvals, approval)
+	}
+	return rows.Err()
+}
+
+// AddComment inserts a comment and fills its ID and timestamp
+func (r *ChangeRequestRepository) AddComment(ctx context.Context, changeRequestID int64, comment *model.ChangeComment) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO change_request_comments (change_request_id, parent_id, author, body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`,
+		changeRequestID, comment.ParentID, comment.Author, comment.Body,
+	).Scan(&comment.ID, &comment.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to comment on change request %d: %w", changeRequestID, err)
+	}
+	return nil
+}
+
+// ListComments returns the comments of a change request, oldest first
+func (r *ChangeRequestRepository) ListComments(ctx context.Context, changeRequestID int64) ([]model.ChangeComment, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, parent_id, author, body, created_at
+		FROM change_request_comments
+		WHERE change_request_id = $1
+		ORDER BY created_at, id`, changeRequestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments of change request %d: %w", changeRequestID, err)
+	}
+	defer rows.Close()
+
+	var comments []model.ChangeComment
+	for rows.Next() {
+		var c model.ChangeComment
+		if err := rows.Scan(&c.ID, &c.ParentID, &c.Author, &c.Body, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+// CommentBelongs reports whether a comment was made on the change request
+func (r *ChangeRequestRepository) CommentBelongs(ctx context.Context, changeRequestID, commentID int64) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM change_request_comments WHERE id = $1 AND change_request_id = $2)`,
+		commentID, changeRequestID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check comment %d: %w", commentID, err)
+	}
+	return exists, nil
+}
+
+func scanChangeRequest(row rowScanner) (*model.ChangeRequest, error) {
+	var cr model.ChangeRequest
+	var proposed, diff []byte
+	if err := row.Scan(
+		&cr.ID, &cr.EnvironmentID, &cr.TemplateID, &cr.Op, &cr.Title, &cr.Description,
+		&proposed, &cr.BaseUpdatedAt, &diff, &cr.Status, &cr.RequiredApprovals, &cr.Author,
+		&cr.ResolvedBy, &cr.Reason, &cr.ResolvedAt, &cr.CreatedAt, &cr.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if proposed != nil {
+		cr.Proposed = &model.Template{}
+		if err := json.Unmarshal(proposed, cr.Proposed); err != nil {
+			return nil, fmt.Errorf("failed to decode proposed template: %w", err)
+		}
+	}
+	if err := json.Unmarshal(diff, &cr.Diff); err != nil {
+		return nil, fmt.Errorf("failed to decode change request diff: %w", err)
+	}
+	return &cr, nil
+}
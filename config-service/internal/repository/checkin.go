@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+)
+
+// CheckInRepository provides access to the latest check-ins of client
+// instances
+type CheckInRepository struct {
+	db DBTX
+}
+
+// NewCheckInRepository creates a new check-in repository
+func NewCheckInRepository(db *database.Connection) *CheckInRepository {
+	return &CheckInRepository{db: db.DB}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *CheckInRepository) WithTx(tx *sql.Tx) *CheckInRepository {
+	return &CheckInRepository{db: tx}
+}
+
+// Upsert records a check-in, replacing the previous one of the instance,
+// and fills its time
+func (r *CheckInRepository) Upsert(ctx context.Context, c *model.CheckIn) error {
+	templates := c.Templates
+	if templates == nil {
+		templates = []model.AppliedTemplate{}
+	}
+	data, err := json.Marshal(templates)
+	if err != nil {
+		return fmt.Errorf("failed to encode applied templates: %w", err)
+	}
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO instance_check_ins (environment_id, instance_id, agent_version, bundle_checksum, templates)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (environment_id, instance_id) DO UPDATE
+		SET agent_version = EXCLUDED.agent_version, bundle_checksum = EXCLUDED.bundle_checksum,
+			templates = EXCLUDED.templates, checked_in_at = NOW()
+		RETURNING checked_in_at`,
+		c.EnvironmentID, c.InstanceID, c.AgentVersion, c.BundleChecksum, data,
+	).Scan(&c.CheckedInAt)
+	if err != nil {
+		return fmt.Errorf("failed to record check-in of %s: %w", c.InstanceID, err)
+	}
+	return nil
+}
+
+// ListByEnvironment returns the latest check-in of every instance of an
+// environment ordered by instance ID
+func (r *CheckInRepository) ListByEnvironment(ctx context.Context, environmentID int64) ([]model.CheckIn, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT environment_id, instance_id, agent_version, bundle_checksum, templates, checked_in_at
+		FROM instance_check_ins
+		WHERE environment_id = $1
+		ORDER BY instance_id`, environmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list check-ins: %w", err)
+	}
+	defer rows.Close()
+
+	var checkIns []model.CheckIn
+	for rows.Next() {
+		var c model.CheckIn
+		var data []byte
+		if err := rows.Scan(&c.EnvironmentID, &c.InstanceID, &c.AgentVersion, &c.BundleChecksum,
+			&data, &c.CheckedInAt); err != nil {
+			return nil, fmt.Errorf("failed to scan check-in: %w", err)
+		}
+		if err := json.Unmarshal(data, &c.Templates); err != nil {
+			return nil, fmt.Errorf("failed to decode applied templates: %w", err)
+		}
+		checkIns = append(checkIns, c)
+	}
+	return checkIns, rows.Err()
+}
+
+// DeleteBefore removes the check-ins of instances that have not checked in
+// since before and returns how many were removed
+func (r *CheckInRepository) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM instance_check_ins WHERE checked_in_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete check-ins: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+)
+
+const deploymentColumns = `d.id, d.template_id, d.environment_id, d.version, d.changes, d.diff, d.run_at,
+	d.status, d.error, d.created_by, d.canceled_by, d.applied_at, d.created_at, d.updated_at`
+
+// ScheduledDeploymentRepository provides access to scheduled deployments
+type ScheduledDeploymentRepository struct {
+	db DBTX
+}
+
+// NewScheduledDeploymentRepository creates a new scheduled deployment
+// repository
+func NewScheduledDeploymentRepository(db *database.Connection) *ScheduledDeploymentRepository {
+	return &ScheduledDeploymentRepository{db: db.DB}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *ScheduledDeploymentRepository) WithTx(tx *sql.Tx) *ScheduledDeploymentRepository {
+	return &ScheduledDeploymentRepository{db: tx}
+}
+
+// Create inserts a scheduled deployment and fills its ID and timestamps
+func (r *ScheduledDeploymentRepository) Create(ctx context.Context, d *model.ScheduledDeployment) error {
+	changes, err := json.Marshal(d.Changes)
+	if err != nil {
+		return fmt.Errorf("failed to encode deployment changes: %w", err)
+	}
+	diff, err := json.Marshal(d.Diff)
+	if err != nil {
+		return fmt.Errorf("failed to encode deployment diff: %w", err)
+	}
+
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO scheduled_deployments (template_id, environment_id, version, changes, diff,
+			run_at, status, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at`,
+		d.TemplateID, d.EnvironmentID, d.Version, changes, diff, d.RunAt, d.Status, d.CreatedBy,
+	).Scan(&d.ID, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create scheduled deployment: %w", err)
+	}
+	return nil
+}
+
+// GetByID returns a scheduled deployment
+func (r *ScheduledDeploymentRepository) GetByID(ctx context.Context, id int64) (*model.ScheduledDeployment, error) {
+	return r.get(ctx, `SELECT `+deploymentColumns+` FROM scheduled_deployments d WHERE d.id = $1`, id)
+}
+
+// Lock returns a scheduled deployment and locks it until the transaction
+// ends
+func (r *ScheduledDeploymentRepository) Lock(ctx context.Context, id int64) (*model.ScheduledDeployment, error) {
+	return r.get(ctx, `SELECT `+deploymentColumns+` FROM scheduled_deployments d WHERE d.id = $1 FOR UPDATE`, id)
+}
+
+func (r *ScheduledDeploymentRepository) get(ctx context.Context, query string, id int64) (*model.ScheduledDeployment, error) {
+	d, err := scanDeployment(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get scheduled deployment %d: %w", id, err)
+	}
+	return d, nil
+}
+
+// Due returns the IDs of up to limit pending deployments whose run time has
+// passed, earliest first
+func (r *ScheduledDeploymentRepository) Due(ctx context.Context, limit int) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id FROM scheduled_deployments
+		WHERE status = 'pending' AND run_at <= NOW()
+		ORDER BY run_at, id
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due deployments: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan deployment id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// deploymentSortColumns are the columns scheduled deployments can be sorted
+// by; they are always listed earliest run first
+var deploymentSortColumns = map[string]sortColumn{
+	"run_at": {expr: "d.run_at", cast: "timestamptz"},
+}
+
+// ListPage returns one page of scheduled deployments matching the filter,
+// earliest run first, addressed by page number or by the cursor in page,
+// together with its position
+func (r *ScheduledDeploymentRepository) ListPage(ctx context.Context, filter model.ScheduledDeploymentFilter, page model.PaginationParams) ([]model.ScheduledDeployment, PageInfo, error) {
+	var conditions []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	k, err := newKeyset(page, model.SortParams{SortBy: "run_at", SortOrder: "asc"}, deploymentSortColumns, "d.id")
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	if filter.Status != "" {
+		conditions = append(conditions, "d.status = "+arg(filter.Status))
+	}
+	if filter.Environment != "" {
+		conditions = append(conditions, "d.environment_id = (SELECT id FROM environments WHERE slug = "+arg(filter.Environment)+")")
+	}
+	if filter.TemplateID != nil {
+		conditions = append(conditions, "d.template_id = "+arg(*filter.TemplateID))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM scheduled_deployments d`+where, args...).Scan(&total); err != nil {
+		return nil, PageInfo{}, fmt.Errorf("failed to count scheduled deployments: %w", err)
+	}
+
+	where = k.where(where, arg)
+	rows, err := r.db.QueryContext(ctx, `SELECT `+deploymentColumns+` FROM scheduled_deployments d`+where+k.clauses(arg), args...)
+	if err != nil {
+		return nil, PageInfo{}, fmt.Errorf("failed to list scheduled deployments: %w", err)
+	}
+	defer rows.Close()
+
+	var deployments []model.ScheduledDeployment
+	for rows.Next() {
+		d, err := scanDeployment(rows)
+		if err != nil {
+			return nil, PageInfo{}, fmt.Errorf("failed to scan scheduled deployment: %w", err)
+		}
+		deployments = append(deployments, *d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, PageInfo{}, fmt.Errorf("failed to iterate scheduled deployments: %w", err)
+	}
+
+	deployments, info := keysetPage(k, deployments, total, func(d model.ScheduledDeployment, _ string) (string, int64) {
+		return cursorTime(d.RunAt), d.ID
+	})
+	return deployments, info, nil
+}
+
+// SetStatus records the status and error of a deployment. Applied
+// deployments are stamped with their application time.
+func (r *ScheduledDeploymentRepository) SetStatus(ctx context.Context, d *model.ScheduledDeployment) error {
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE scheduled_deployments
+		SET status = $2, error = $3, canceled_by = $4,
+			applied_at = CASE WHEN $2 = 'applied' THEN NOW() END
+		WHERE id = $1
+		RETURNING applied_at, updated_at`,
+		d.ID, d.Status, d.Error, d.CanceledBy,
+	).Scan(&d.AppliedAt, &d.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to update scheduled deployment %d: %w", d.ID, err)
+	}
+	return nil
+}
+
+func scanDeployment(row rowScanner) (*model.ScheduledDeployment, error) {
+	var d model.ScheduledDeployment
+	var changes, diff []byte
+	if err := row.Scan(
+		&d.ID, &d.TemplateID, &d.EnvironmentID, &d.Version, &changes, &diff, &d.RunAt,
+		&d.Status, &d.Error, &d.CreatedBy, &d.CanceledBy, &d.AppliedAt, &d.CreatedAt, &d.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	d.Changes = &model.UpdateTemplateRequest{}
+	if err := json.Unmarshal(changes, d.Changes); err != nil {
+		return nil, fmt.Errorf("failed to decode deployment changes: %w", err)
+	}
+	if err := json.Unmarshal(diff, &d.Diff); err != nil {
+		return nil, fmt.Errorf("failed to decode deployment diff: %w", err)
+	}
+	return &d, nil
+}
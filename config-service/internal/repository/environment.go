@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+)
+
+const environmentColumns = `id, name, slug, COALESCE(description, ''), COALESCE(active, true),
+	COALESCE(priority, 50), protected, required_approvals, created_at, updated_at`
+
+// EnvironmentRepository provides access to environments
+type EnvironmentRepository struct {
+	db DBTX
+}
+
+// NewEnvironmentRepository creates a new environment repository
+func NewEnvironmentRepository(db *database.Connection) *EnvironmentRepository {
+	return &EnvironmentRepository{db: db.DB}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *EnvironmentRepository) WithTx(tx *sql.Tx) *EnvironmentRepository {
+	return &EnvironmentRepository{db: tx}
+}
+
+// AsOf returns a read-only copy of the repository reading the state recorded
+// in the history at the given moment
+func (r *EnvironmentRepository) AsOf(at time.Time) *EnvironmentRepository {
+	return &EnvironmentRepository{db: asOf(r.db, at)}
+}
+
+// GetBySlug returns the environment with the given slug
+func (r *EnvironmentRepository) GetBySlug(ctx context.Context, slug string) (*model.Environment, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+environmentColumns+` FROM environments WHERE slug = $1`, slug)
+
+	env, err := scanEnvironment(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get environment %q: %w", slug, err)
+	}
+	return env, nil
+}
+
+func scanEnvironment(row rowScanner) (*model.Environment, error) {
+	var env model.Environment
+	if err := row.Scan(
+		&env.ID, &env.Name, &env.Slug, &env.Description, &env.Active,
+		&env.Priority, &env.Protected, &env.RequiredApprovals, &env.CreatedAt, &env.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &env, nil
+}
+
+// GetByID returns the environment with the given ID
+func (r *EnvironmentRepository) GetByID(ctx context.Context, id int64) (*model.Environment, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+environmentColumns+` FROM environments WHERE id = $1`, id)
+
+	env, err := scanEnvironment(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get environment %d: %w", id, err)
+	}
+	return env, nil
+}
+
+// List returns all environments ordered by priority
+func (r *EnvironmentRepository) List(ctx context.Context) ([]model.Environment, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+environmentColumns+` FROM environments ORDER BY priority DESC, name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list environments: %w", err)
+	}
+	defer rows.Close()
+
+	var environments []model.Environment
+	for rows.Next() {
+		env, err := scanEnvironment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan environment: %w", err)
+		}
+		environments = append(environments, *env)
+	}
+	return environments, rows.Err()
+}
+
+// environmentSortColumns are the columns environments can be sorted by
+var environmentSortColumns = map[string]sortColumn{
+	"id":         {expr: "id", cast: "bigint"},
+	"priority":   {expr: "priority", cast: "integer"},
+	"name":       {expr: "name", cast: "text"},
+	"slug":       {expr: "slug", cast: "text"},
+	"created_at": {expr: "created_at", cast: "timestamptz"},
+}
+
+// ListPage returns one page of environments, addressed by page number or by
+// the cursor in page, together with its position
+func (r *EnvironmentRepository) ListPage(ctx context.Context, page model.PaginationParams, sort model.SortParams) ([]model.Environment, PageInfo, error) {
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	k, err := newKeyset(page, sort, environmentSortColumns, "id")
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM environments`).Scan(&total); err != nil {
+		return nil, PageInfo{}, fmt.Errorf("failed to count environments: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+environmentColumns+` FROM environments`+k.where("", arg)+k.clauses(arg), args...)
+	if err != nil {
+		return nil, PageInfo{}, fmt.Errorf("failed to list environments: %w", err)
+	}
+	defer rows.Close()
+
+	var environments []model.Environment
+	for rows.Next() {
+		env, err := scanEnvironment(rows)
+		if err != nil {
+			return nil, PageInfo{}, fmt.Errorf("failed to scan environment: %w", err)
+		}
+		environments = append(environments, *env)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, PageInfo{}, fmt.Errorf("failed to iterate environments: %w", err)
+	}
+
+	environments, info := keysetPage(k, environments, total, environmentSortValue)
+	return environments, info, nil
+}
+
+// environmentSortValue returns the cursor value of an environment for a sort
+// column
+func environmentSortValue(e model.Environment, sortBy string) (string, int64) {
+	switch sortBy {
+	case "priority":
+		return strconv.Itoa(e.Priority), e.ID
+	case "name":
+		return e.Name, e.ID
+	case "slug":
+		return e.Slug, e.ID
+	case "created_at":
+		return cursorTime(e.CreatedAt), e.ID
+	}
+	return strconv.FormatInt(e.ID, 10), e.ID
+}
+
+// Create inserts a new environment and fills its ID and timestamps
+func (r *EnvironmentRepository) Create(ctx context.Context, env *model.Environment) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO environments (name, slug, description, active, priority)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at`,
+		env.Name, env.Slug, env.Description, env.Active, env.Priority,
+	).Scan(&env.ID, &env.CreatedAt, &env.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create environment %q: %w", env.Slug, err)
+	}
+	return nil
+}
+
+// Update overwrites all mutable fields of an environment
+func (r *EnvironmentRepository) Update(ctx context.Context, env *model.Environment) error {
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE environments
+		SET name = $2, slug = $3, description = $4, active = $5, priority = $6
+		WHERE id = $1
+		RETURNING updated_at`,
+		env.ID, env.Name, env.Slug, env.Description, env.Active, env.Priority,
+	).Scan(&env.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to update environment %q: %w", env.Slug, err)
+	}
+	return nil
+}
+
+// SetProtection updates whether an environment is protected and how many
+// approvals its change requests need. Update leaves both untouched so that
+// archive imports cannot lift a protection.
+func (r *EnvironmentRepository) SetProtection(ctx context.Context, env *model.Environment) error {
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE environments
+		SET protected = $2, required_approvals = $3
+		WHERE id = $1
+		RETURNING updated_at`,
+		env.ID, env.Protected, env.RequiredApprovals,
+	).Scan(&env.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to update protection of environment %q: %w", env.Slug, err)
+	}
+	return nil
+}
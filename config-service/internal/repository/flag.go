@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+	"github.com/lib/pq"
+)
+
+const flagColumns = `f.id, f.environment_id, f.key, f.description, f.type, f.variations, f.enabled,
+	f.off_variation, f.rules, f.fallthrough_serve, f.bucket_by, f.created_by, f.updated_by,
+	f.created_at, f.updated_at`
+
+// FlagRepository provides access to feature flags and their tag links
+type FlagRepository struct {
+	db DBTX
+}
+
+// NewFlagRepository creates a new feature flag repository
+func NewFlagRepository(db *database.Connection) *FlagRepository {
+	return &FlagRepository{db: db.DB}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *FlagRepository) WithTx(tx *sql.Tx) *FlagRepository {
+	return &FlagRepository{db: tx}
+}
+
+// GetByKey returns the flag of an environment with the given key and its tags
+func (r *FlagRepository) GetByKey(ctx context.Context, environmentID int64, key string) (*model.Flag, error) {
+	f, err := scanFlag(r.db.QueryRowContext(ctx,
+		`SELECT `+flagColumns+` FROM feature_flags f WHERE f.environment_id = $1 AND f.key = $2`,
+		environmentID, key))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get flag %q: %w", key, err)
+	}
+	flags := []model.Flag{*f}
+	if err := r.attachTags(ctx, flags); err != nil {
+		return nil, err
+	}
+	return &flags[0], nil
+}
+
+// ListByEnvironment returns the flags of an environment ordered by key with
+// their tags
+func (r *FlagRepository) ListByEnvironment(ctx context.Context, environmentID int64) ([]model.Flag, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+flagColumns+` FROM feature_flags f WHERE f.environment_id = $1 ORDER BY f.key`,
+		environmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list flags: %w", err)
+	}
+	defer rows.Close()
+
+	var flags []model.Flag
+	for rows.Next() {
+		f, err := scanFlag(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan flag: %w", err)
+		}
+		flags = append(flags, *f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate flags: %w", err)
+	}
+
+	if err := r.attachTags(ctx, flags); err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
+
+// Create inserts a flag and fills its ID and timestamps. Tag links are not
+// written; use SetTags.
+func (r *FlagRepository) Create(ctx context.Context, f *model.Flag) error {
+	variations, rules, serve, err := encodeFlag(f)
+	if err != nil {
+		return err
+	}
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO feature_flags (environment_id, key, description, type, variations, enabled,
+			off_variation, rules, fallthrough_serve, bucket_by, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $11)
+		RETURNING id, created_at, updated_at`,
+		f.EnvironmentID, f.Key, f.Description, f.Type, variations, f.Enabled,
+		f.OffVariation, rules, serve, f.BucketBy, f.CreatedBy,
+	).Scan(&f.ID, &f.CreatedAt, &f.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create flag %q: %w", f.Key, err)
+	}
+	return nil
+}
+
+// Update overwrites the mutable fields of a flag. Tag links are not written;
+// use SetTags.
+func (r *FlagRepository) Update(ctx context.Context, f *model.Flag) error {
+	variations, rules, serve, err := encodeFlag(f)
+	if err != nil {
+		return err
+	}
+	err = r.db.QueryRowContext(ctx, `
+		UPDATE feature_flags
+		SET description = $2, variations = $3, enabled = $4, off_variation = $5,
+			rules = $6, fallthrough_serve = $7, bucket_by = $8, updated_by = $9
+		WHERE id = $1
+		RETURNING updated_at`,
+		f.ID, f.Description, variations, f.Enabled, f.OffVariation, rules, serve,
+		f.BucketBy, f.UpdatedBy,
+	).Scan(&f.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to update flag %q: %w", f.Key, err)
+	}
+	return nil
+}
+
+// Delete removes a flag; its tag links are removed by cascade
+func (r *FlagRepository) Delete(ctx context.Context, id int64) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM feature_flags WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete flag %d: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetTags replaces all tag links of a flag
+func (r *FlagRepository) SetTags(ctx context.Context, flagID int64, tagIDs []int64) error {
+	if _, err := r.db.ExecContext(ctx,
+		`DELETE FROM feature_flag_tags WHERE flag_id = $1`, flagID); err != nil {
+		return fmt.Errorf("failed to clear flag tags: %w", err)
+	}
+	if len(tagIDs) == 0 {
+		return nil
+	}
+	if _, err := r.db.ExecContext(ctx, `
+		INSERT INTO feature_flag_tags (flag_id, tag_id)
+		SELECT $1, unnest($2::bigint[])
+		ON CONFLICT DO NOTHING`, flagID, pq.Array(tagIDs)); err != nil {
+		return fmt.Errorf("failed to link flag tags: %w", err)
+	}
+	return nil
+}
+
+func (r *FlagRepository) attachTags(ctx context.Context, flags []model.Flag) error {
+	if len(flags) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(flags))
+	index := make(map[int64]int, len(flags))
+	for i, f := range flags {
+		ids[i] = f.ID
+		index[f.ID] = i
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT ft.flag_id, g.id, g.name, COALESCE(g.key, ''), COALESCE(g.value, ''),
+			g.parent_id, COALESCE(g.description, ''), g.color, g.created_at, g.updated_at
+		FROM feature_flag_tags ft
+		JOIN tags g ON g.id = ft.tag_id
+		WHERE ft.flag_id = ANY($1)
+		ORDER BY g.name`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to load flag tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var flagID int64
+		var tag model.Tag
+		if err := rows.Scan(&flagID, &tag.ID, &tag.Name, &tag.Key, &tag.Value,
+			&tag.ParentID, &tag.Description, &tag.Color, &tag.CreatedAt, &tag.UpdatedAt); err != nil {
+			return fmt.Errorf("failed to scan flag tag: %w", err)
+		}
+		f := &flags[index[flagID]]
+		f.Tags = append(f.Tags, tag)
+		f.TagIDs = append(f.TagIDs, tag.ID)
+	}
+	return rows.Err()
+}
+
+// encodeFlag encodes the targeting configuration of a flag for its JSONB
+// columns
+func encodeFlag(f *model.Flag) (variations, rules, serve []byte, err error) {
+	if variations, err = json.Marshal(f.Variations); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to encode flag variations: %w", err)
+	}
+	flagRules := f.Rules
+	if flagRules == nil {
+		flagRules = []model.FlagRule{}
+	}
+	if rules, err = json.Marshal(flagRules); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to encode flag rules: %w", err)
+	}
+	if serve, err = json.Marshal(f.Fallthrough); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to encode flag fallthrough: %w", err)
+	}
+	return variations, rules, serve, nil
+}
+
+func scanFlag(row rowScanner) (*model.Flag, error) {
+	var f model.Flag
+	var variations, rules, serve []byte
+	if err := row.Scan(
+		&f.ID, &f.EnvironmentID, &f.Key, &f.Description, &f.Type, &variations, &f.Enabled,
+		&f.OffVariation, &rules, &serve, &f.BucketBy, &f.CreatedBy, &f.UpdatedBy,
+		&f.CreatedAt, &f.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(variations, &f.Variations); err != nil {
+		return nil, fmt.Errorf("failed to decode flag variations: %w", err)
+	}
+	if err := json.Unmarshal(rules, &f.Rules); err != nil {
+		return nil, fmt.Errorf("failed to decode flag rules: %w", err)
+	}
+	if err := json.Unmarshal(serve, &f.Fallthrough); err != nil {
+		return nil, fmt.Errorf("failed to decode flag fallthrough: %w", err)
+	}
+	return &f, nil
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+)
+
+const freezeWindowColumns = `id, environment_id, name, reason, COALESCE(cron, ''), COALESCE(duration_seconds, 0),
+	timezone, starts_at, ends_at, created_by, created_at`
+
+// FreezeWindowRepository provides access to the freeze windows of
+// environments
+type FreezeWindowRepository struct {
+	db DBTX
+}
+
+// NewFreezeWindowRepository creates a new freeze window repository
+func NewFreezeWindowRepository(db *database.Connection) *FreezeWindowRepository {
+	return &FreezeWindowRepository{db: db.DB}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *FreezeWindowRepository) WithTx(tx *sql.Tx) *FreezeWindowRepository {
+	return &FreezeWindowRepository{db: tx}
+}
+
+// Create inserts a freeze window and fills its ID and creation time
+func (r *FreezeWindowRepository) Create(ctx context.Context, w *model.FreezeWindow) error {
+	var cron sql.NullString
+	var duration sql.NullInt64
+	if w.Cron != "" {
+		cron = sql.NullString{String: w.Cron, Valid: true}
+		duration = sql.NullInt64{Int64: int64(w.DurationSeconds), Valid: true}
+	}
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO freeze_windows (environment_id, name, reason, cron, duration_seconds,
+			timezone, starts_at, ends_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at`,
+		w.EnvironmentID, w.Name, w.Reason, cron, duration, w.Timezone, w.StartsAt, w.EndsAt, w.CreatedBy,
+	).Scan(&w.ID, &w.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create freeze window %q: %w", w.Name, err)
+	}
+	return nil
+}
+
+// GetByName returns the freeze window of an environment with the given name
+func (r *FreezeWindowRepository) GetByName(ctx context.Context, environmentID int64, name string) (*model.FreezeWindow, error) {
+	w, err := scanFreezeWindow(r.db.QueryRowContext(ctx,
+		`SELECT `+freezeWindowColumns+` FROM freeze_windows WHERE environment_id = $1 AND name = $2`,
+		environmentID, name))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get freeze window %q: %w", name, err)
+	}
+	return w, nil
+}
+
+// ListByEnvironment returns the freeze windows of an environment by name
+func (r *FreezeWindowRepository) ListByEnvironment(ctx context.Context, environmentID int64) ([]model.FreezeWindow, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+freezeWindowColumns+` FROM freeze_windows WHERE environment_id = $1 ORDER BY name`,
+		environmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list freeze windows: %w", err)
+	}
+	defer rows.Close()
+
+	var windows []model.FreezeWindow
+	for rows.Next() {
+		w, err := scanFreezeWindow(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan freeze window: %w", err)
+		}
+		windows = append(windows, *w)
+	}
+	return windows, rows.Err()
+}
+
+// Delete removes a freeze window of an environment
+func (r *FreezeWindowRepository) Delete(ctx context.Context, environmentID, id int64) error {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM freeze_windows WHERE id = $1 AND environment_id = $2`, id, environmentID)
+	if err != nil {
+		return fmt.Errorf("failed to delete freeze window %d: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanFreezeWindow(row rowScanner) (*model.FreezeWindow, error) {
+	var w model.FreezeWindow
+	if err := row.Scan(
+		&w.ID, &w.EnvironmentID, &w.Name, &w.Reason, &w.Cron, &w.DurationSeconds,
+		&w.Timezone, &w.StartsAt, &w.EndsAt, &w.CreatedBy, &w.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+)
+
+// ErrReadOnly is returned for writes through a repository reading history
+var ErrReadOnly = errors.New("history is read-only")
+
+// historyTables are the tables whose row versions config_history records
+var historyTables = []string{"environments", "tags", "templates", "template_tags", "template_candidates"}
+
+// historyDB runs the queries of a repository against the state recorded in
+// config_history at a moment. Each history table is shadowed by a common
+// table expression of the same name holding its rows as of that moment, so
+// repository queries, including selector conditions, run unchanged.
+type historyDB struct {
+	db DBTX
+	at time.Time
+}
+
+// asOf binds db to the state at the given moment
+func asOf(db DBTX, at time.Time) DBTX {
+	if h, ok := db.(historyDB); ok {
+		db = h.db
+	}
+	return historyDB{db: db, at: at}
+}
+
+// ExecContext implements DBTX; history cannot be written
+func (h historyDB) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, ErrReadOnly
+}
+
+// QueryContext implements DBTX
+func (h historyDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	query, args = h.rewrite(query, args)
+	return h.db.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext implements DBTX
+func (h historyDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	query, args = h.rewrite(query, args)
+	return h.db.QueryRowContext(ctx, query, args...)
+}
+
+// rewrite prepends the shadowing expressions to query, merging them into
+// its own WITH clause if it has one, and appends the moment as the last
+// argument
+func (h historyDB) rewrite(query string, args []interface{}) (string, []interface{}) {
+	at := fmt.Sprintf("$%d::timestamptz", len(args)+1)
+	ctes := make([]string, len(historyTables))
+	for i, table := range historyTables {
+		ctes[i] = table + ` AS (
+			SELECT (jsonb_populate_record(NULL::` + table + `, h.data)).*
+			FROM config_history h
+			WHERE h.table_name = '` + table + `' AND h.valid_from <= ` + at + `
+			  AND (h.valid_to IS NULL OR h.valid_to > ` + at + `))`
+	}
+	with := strings.Join(ctes, ",\n")
+
+	query = strings.TrimSpace(query)
+	upper := strings.ToUpper(query)
+	switch {
+	case strings.HasPrefix(upper, "WITH RECURSIVE "):
+		query = "WITH RECURSIVE " + with + ",\n" + query[len("WITH RECURSIVE "):]
+	case strings.HasPrefix(upper, "WITH "):
+		query = "WITH " + with + ",\n" + query[len("WITH "):]
+	default:
+		query = "WITH " + with + "\n" + query
+	}
+	return query, append(args, h.at)
+}
+
+// HistoryVersion is a row version recorded in config_history
+type HistoryVersion struct {
+	ID    int64
+	Table string
+	Data  model.JSONMap
+}
+
+// HistoryRepository provides maintenance access to the recorded row
+// versions. Reading the state at a moment goes through the AsOf methods of
+// the other repositories instead.
+type HistoryRepository struct {
+	db DBTX
+}
+
+// NewHistoryRepository creates a new history repository
+func NewHistoryRepository(db *database.Connection) *HistoryRepository {
+	return &HistoryRepository{db: db.DB}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *HistoryRepository) WithTx(tx *sql.Tx) *HistoryRepository {
+	return &HistoryRepository{db: tx}
+}
+
+// historyHasSecretValues matches versions whose data holds an encrypted
+// secret envelope at any depth
+const historyHasSecretValues = `jsonb_path_exists(h.data, 'lax $.**."$secret"')`
+
+// CountWithSecretValues counts the recorded versions holding encrypted
+// secret values
+func (r *HistoryRepository) CountWithSecretValues(ctx context.Context) (int, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM config_history h WHERE `+historyHasSecretValues).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count history versions with secret values: %w", err)
+	}
+	return count, nil
+}
+
+// ListSecretValuesAfter locks and returns up to limit recorded versions
+// holding encrypted secret values with IDs above afterID, in ID order
+func (r *HistoryRepository) ListSecretValuesAfter(ctx context.Context, afterID int64, limit int) ([]HistoryVersion, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT h.id, h.table_name, h.data
+		FROM config_history h
+		WHERE h.id > $1 AND `+historyHasSecretValues+`
+		ORDER BY h.id
+		LIMIT $2
+		FOR UPDATE`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list history versions with secret values: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []HistoryVersion
+	for rows.Next() {
+		var v HistoryVersion
+		if err := rows.Scan(&v.ID, &v.Table, &v.Data); err != nil {
+			return nil, fmt.Errorf("failed to scan history version: %w", err)
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// UpdateData replaces the recorded data of a version. It is meant for
+// re-encrypting secret values; the version keeps its validity period.
+func (r *HistoryRepository) UpdateData(ctx context.Context, id int64, data model.JSONMap) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE config_history SET data = $2 WHERE id = $1`, id, data)
+	if err != nil {
+		return fmt.Errorf("failed to update history version %d: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/company/config-service/internal/database/dbtest"
+	"github.com/company/config-service/internal/model"
+)
+
+func TestHistoryRewrite(t *testing.T) {
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		query  string
+		args   []interface{}
+		prefix string
+		suffix string
+	}{
+		{
+			name:   "plain",
+			query:  "  SELECT id FROM tags WHERE id = $1",
+			args:   []interface{}{int64(7)},
+			prefix: "WITH environments AS (",
+			suffix: "))\nSELECT id FROM tags WHERE id = $1",
+		},
+		{
+			name:   "with",
+			query:  "with linked AS (SELECT tag_id FROM template_tags) SELECT * FROM linked",
+			prefix: "WITH environments AS (",
+			suffix: ")),\nlinked AS (SELECT tag_id FROM template_tags) SELECT * FROM linked",
+		},
+		{
+			name:   "with recursive",
+			query:  "WITH RECURSIVE up(id) AS (SELECT $1::bigint) SELECT id FROM up",
+			args:   []interface{}{int64(7), "x"},
+			prefix: "WITH RECURSIVE environments AS (",
+			suffix: ")),\nup(id) AS (SELECT $1::bigint) SELECT id FROM up",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := historyDB{at: at}.rewrite(tt.query, tt.args)
+			if !strings.HasPrefix(query, tt.prefix) || !strings.HasSuffix(query, tt.suffix) {
+				t.Errorf("rewrite(%q) = %q", tt.query, query)
+			}
+			if strings.Count(query, "WITH") != 1 {
+				t.Errorf("rewrite(%q) has more than one WITH: %q", tt.query, query)
+			}
+			for _, table := range historyTables {
+				if !strings.Contains(query, table+" AS (") || !strings.Contains(query, "NULL::"+table+",") {
+					t.Errorf("rewrite(%q) does not shadow %s", tt.query, table)
+				}
+			}
+			if len(args) != len(tt.args)+1 || args[len(args)-1] != at {
+				t.Fatalf("args = %v, want %v followed by the moment", args, tt.args)
+			}
+			placeholder := fmt.Sprintf("$%d::timestamptz", len(args))
+			if strings.Count(query, placeholder) != 2*len(historyTables) {
+				t.Errorf("rewrite(%q) does not bind the moment as %s: %q", tt.query, placeholder, query)
+			}
+		})
+	}
+}
+
+func TestHistoryAsOf(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t)
+	tags := NewTagRepository(db)
+	now := func() time.Time {
+		t.Helper()
+		var at time.Time
+		if err := db.DB.QueryRow(`SELECT clock_timestamp()`).Scan(&at); err != nil {
+			t.Fatal(err)
+		}
+		return at
+	}
+
+	tag := &model.Tag{Name: "api", Color: "#111111"}
+	if err := tags.Create(ctx, tag); err != nil {
+		t.Fatal(err)
+	}
+	created := now()
+
+	// A transaction that waits before writing, and writes twice
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec(`SELECT pg_sleep(0.2)`); err != nil {
+		t.Fatal(err)
+	}
+	waiting := now()
+	for _, color := range []string{"#222222", "#333333"} {
+		tag.Color = color
+		if err := tags.WithTx(tx).Update(ctx, tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	updated := now()
+
+	if err := tags.Delete(ctx, []int64{tag.ID}); err != nil {
+		t.Fatal(err)
+	}
+	deleted := now()
+
+	if err := tags.AsOf(deleted).Delete(ctx, []int64{tag.ID}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Delete through history error = %v, want ErrReadOnly", err)
+	}
+
+	tests := []struct {
+		name  string
+		at    time.Time
+		color string
+	}{
+		{name: "before creation", at: created.Add(-time.Hour)},
+		{name: "after creation", at: created, color: "#111111"},
+		{name: "while the update waited", at: waiting, color: "#111111"},
+		{name: "after the update", at: updated, color: "#333333"},
+		{name: "after deletion", at: deleted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tags.AsOf(tt.at).GetByID(ctx, tag.ID)
+			if tt.color == "" {
+				if !errors.Is(err, ErrNotFound) {
+					t.Errorf("GetByID = %+v, %v, want ErrNotFound", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetByID error: %v", err)
+			}
+			if got.Color != tt.color {
+				t.Errorf("color = %s, want %s", got.Color, tt.color)
+			}
+		})
+	}
+
+	var versions int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM config_history WHERE table_name = 'tags'`).Scan(&versions); err != nil {
+		t.Fatal(err)
+	}
+	if versions != 2 {
+		t.Errorf("recorded %d tag versions, want one per transaction", versions)
+	}
+}
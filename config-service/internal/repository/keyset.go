@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/company/config-service/internal/cursor"
+	"github.com/company/config-service/internal/model"
+)
+
+// ErrInvalidCursor is returned for cursors that cannot be decoded or do not
+// belong to the listing they are used with
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrUnsupportedSort is returned when a listing cannot be sorted by the
+// requested column
+var ErrUnsupportedSort = errors.New("unsupported sort column")
+
+// PageInfo describes the position of a page within a listing
+type PageInfo struct {
+	Total      int64
+	HasNext    bool
+	NextCursor string
+	PrevCursor string
+}
+
+// sortColumn is a column a listing can be sorted by: its SQL expression and
+// the type cursor values are cast to
+type sortColumn struct {
+	expr string
+	cast string
+}
+
+// keyset resolves the ordering and position of a page. Without a cursor the
+// page is addressed by page number and offset; with a cursor it starts after,
+// or when paging backwards ends before, the row the cursor records.
+type keyset struct {
+	sortBy string
+	order  string
+	column sortColumn
+	idExpr string
+	after  *cursor.Cursor
+	page   model.PaginationParams
+}
+
+// newKeyset resolves a page request against the sortable columns of a
+// listing. The sort of a cursor takes precedence over sort.
+func newKeyset(page model.PaginationParams, sort model.SortParams, columns map[string]sortColumn, idExpr string) (*keyset, error) {
+	k := &keyset{sortBy: sort.SortBy, order: sort.SortOrder, idExpr: idExpr, page: page}
+	if page.Cursor != "" {
+		c, err := cursor.Decode(page.Cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		k.sortBy, k.order, k.after = c.SortBy, c.SortOrder, c
+	}
+	if k.order != "asc" {
+		k.order = "desc"
+	}
+
+	column, ok := columns[k.sortBy]
+	if !ok {
+		if k.after != nil {
+			return nil, ErrInvalidCursor
+		}
+		return nil, fmt.Errorf("%w %s", ErrUnsupportedSort, k.sortBy)
+	}
+	k.column = column
+	return k, nil
+}
+
+// descending reports whether rows are fetched in descending order
+func (k *keyset) descending() bool {
+	backward := k.after != nil && k.after.Backward
+	return (k.order == "desc") != backward
+}
+
+// condition returns the keyset condition for a cursor, or "" without one
+func (k *keyset) condition(arg func(interface{}) string) string {
+	if k.after == nil {
+		return ""
+	}
+	cmp := ">"
+	if k.descending() {
+		cmp = "<"
+	}
+	return "(" + k.column.expr + ", " + k.idExpr + ") " + cmp +
+		" (" + arg(k.after.Value) + "::" + k.column.cast + ", " + arg(k.after.ID) + ")"
+}
+
+// where extends a WHERE clause, possibly empty, with the keyset condition
+func (k *keyset) where(where string, arg func(interface{}) string) string {
+	cond := k.condition(arg)
+	switch {
+	case cond == "":
+		return where
+	case where == "":
+		return " WHERE " + cond
+	}
+	return where + " AND " + cond
+}
+
+// clauses returns the ORDER BY, LIMIT and, without a cursor, OFFSET clauses.
+// One row more than the page size is fetched to detect a following page.
+func (k *keyset) clauses(arg func(interface{}) string) string {
+	direction := "ASC"
+	if k.descending() {
+		direction = "DESC"
+	}
+	s := " ORDER BY " + k.column.expr + " " + direction + ", " + k.idExpr + " " + direction +
+		" LIMIT " + arg(k.page.PageSize+1)
+	if k.after == nil {
+		s += " OFFSET " + arg((k.page.Page-1)*k.page.PageSize)
+	}
+	return s
+}
+
+// keysetPage trims rows fetched with keyset.clauses to the page size, restores
+// the requested order and computes the page cursors. value returns the sort
+// value and ID of a row.
+func keysetPage[T any](k *keyset, rows []T, total int64, value func(T, string) (string, int64)) ([]T, PageInfo) {
+	info := PageInfo{Total: total}
+	more := len(rows) > k.page.PageSize
+	if more {
+		rows = rows[:k.page.PageSize]
+	}
+	backward := k.after != nil && k.after.Backward
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+	if len(rows) == 0 {
+		return rows, info
+	}
+
+	var hasNext, hasPrev bool
+	switch {
+	case k.after == nil:
+		hasNext, hasPrev = more, k.page.Page > 1
+	case backward:
+		hasNext, hasPrev = true, more
+	default:
+		hasNext, hasPrev = more, true
+	}
+
+	encode := func(row T, backward bool) string {
+		v, id := value(row, k.sortBy)
+		return cursor.Encode(cursor.Cursor{SortBy: k.sortBy, SortOrder: k.order, Value: v, ID: id, Backward: backward})
+	}
+	info.HasNext = hasNext
+	if hasNext {
+		info.NextCursor = encode(rows[len(rows)-1], false)
+	}
+	if hasPrev {
+		info.PrevCursor = encode(rows[0], true)
+	}
+	return rows, info
+}
+
+// cursorTime formats a timestamp sort value
+func cursorTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
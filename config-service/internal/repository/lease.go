@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/company/config-service/internal/database"
+)
+
+// LeaseRepository elects the replica running a background job through
+// expiring leases
+type LeaseRepository struct {
+	db DBTX
+}
+
+// NewLeaseRepository creates a new lease repository
+func NewLeaseRepository(db *database.Connection) *LeaseRepository {
+	return &LeaseRepository{db: db.DB}
+}
+
+// Acquire takes or renews the lease name for holder until ttl from now. It
+// reports false while another holder's lease has not expired.
+func (r *LeaseRepository) Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	var got string
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO leader_leases (name, holder, expires_at)
+		VALUES ($1, $2, NOW() + $3 * INTERVAL '1 millisecond')
+		ON CONFLICT (name) DO UPDATE
+		SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+		WHERE leader_leases.holder = EXCLUDED.holder OR leader_leases.expires_at < NOW()
+		RETURNING holder`, name, holder, ttl.Milliseconds()).Scan(&got)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to acquire lease %q: %w", name, err)
+	}
+	return true, nil
+}
+
+// Release gives up the lease name if holder holds it, so that another
+// replica can take over without waiting for it to expire
+func (r *LeaseRepository) Release(ctx context.Context, name, holder string) error {
+	if _, err := r.db.ExecContext(ctx,
+		`DELETE FROM leader_leases WHERE name = $1 AND holder = $2`, name, holder); err != nil {
+		return fmt.Errorf("failed to release lease %q: %w", name, err)
+	}
+	return nil
+}
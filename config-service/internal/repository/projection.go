@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/company/config-service/internal/model"
+)
+
+// TemplateLoad selects what a template read loads: the columns of the
+// response fields in Fields, or every column when nil, and optionally the
+// environment and tags. The environment is joined only when requested.
+type TemplateLoad struct {
+	Fields      model.Fieldset
+	Environment bool
+	Tags        bool
+}
+
+// templateColumn maps a template response field to its column and scan
+// destination
+type templateColumn struct {
+	field string
+	expr  string
+	dest  func(*model.Template) interface{}
+}
+
+// templateFieldColumns are the selectable template columns in scan order
+var templateFieldColumns = []templateColumn{
+	{"id", "t.id", func(t *model.Template) interface{} { return &t.ID }},
+	{"name", "t.name", func(t *model.Template) interface{} { return &t.Name }},
+	{"description", "COALESCE(t.description, '')", func(t *model.Template) interface{} { return &t.Description }},
+	{"format", "t.format", func(t *model.Template) interface{} { return &t.Format }},
+	{"content", "t.content", func(t *model.Template) interface{} { return &t.Content }},
+	{"schema", "t.schema", func(t *model.Template) interface{} { return &t.Schema }},
+	{"default_values", "t.default_values", func(t *model.Template) interface{} { return &t.DefaultValues }},
+	{"version", "t.version", func(t *model.Template) interface{} { return &t.Version }},
+	{"environment_id", "t.environment_id", func(t *model.Template) interface{} { return &t.EnvironmentID }},
+	{"active", "COALESCE(t.active, true)", func(t *model.Template) interface{} { return &t.Active }},
+	{"created_at", "t.created_at", func(t *model.Template) interface{} { return &t.CreatedAt }},
+	{"updated_at", "t.updated_at", func(t *model.Template) interface{} { return &t.UpdatedAt }},
+	{"created_by", "t.created_by", func(t *model.Template) interface{} { return &t.CreatedBy }},
+	{"updated_by", "t.updated_by", func(t *model.Template) interface{} { return &t.UpdatedBy }},
+}
+
+const environmentJoinColumns = `e.id, e.name, e.slug, COALESCE(e.description, ''), COALESCE(e.active, true),
+	COALESCE(e.priority, 50), e.created_at, e.updated_at`
+
+// templateProjection is the select list and FROM clause of a template read
+type templateProjection struct {
+	columns     []templateColumn
+	environment bool
+}
+
+// newTemplateProjection selects the columns for load. The ID and the fields
+// in required, such as the sort column of a keyset page, are always loaded.
+func newTemplateProjection(load TemplateLoad, required ...string) templateProjection {
+	p := templateProjection{environment: load.Environment}
+	for _, c := range templateFieldColumns {
+		if c.field == "id" || load.Fields.Has(c.field) || slices.Contains(required, c.field) {
+			p.columns = append(p.columns, c)
+		}
+	}
+	return p
+}
+
+// query returns the SELECT and FROM clauses, joining environments when the
+// environment is loaded
+func (p templateProjection) query() string {
+	exprs := make([]string, 0, len(p.columns)+1)
+	for _, c := range p.columns {
+		exprs = append(exprs, c.expr)
+	}
+	if !p.environment {
+		return `SELECT ` + strings.Join(exprs, ", ") + ` FROM templates t`
+	}
+	exprs = append(exprs, environmentJoinColumns)
+	return `SELECT ` + strings.Join(exprs, ", ") + ` FROM templates t JOIN environments e ON e.id = t.environment_id`
+}
+
+func (p templateProjection) scan(row rowScanner) (*model.Template, error) {
+	var tpl model.Template
+	dest := make([]interface{}, 0, len(p.columns)+8)
+	for _, c := range p.columns {
+		dest = append(dest, c.dest(&tpl))
+	}
+	if p.environment {
+		env := &tpl.Environment
+		dest = append(dest, &env.ID, &env.Name, &env.Slug, &env.Description, &env.Active,
+			&env.Priority, &env.CreatedAt, &env.UpdatedAt)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if p.environment {
+		tpl.EnvironmentID = tpl.Environment.ID
+	}
+	return &tpl, nil
+}
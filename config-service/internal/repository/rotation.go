@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+)
+
+const rotationColumns = `id, key_id, key_version, status, total, processed, rewrapped,
+	last_template_id, last_history_id, error, started_by, started_at, updated_at, completed_at`
+
+// SecretRotationRepository provides access to secret rotation jobs
+type SecretRotationRepository struct {
+	db DBTX
+}
+
+// NewSecretRotationRepository creates a new secret rotation repository
+func NewSecretRotationRepository(db *database.Connection) *SecretRotationRepository {
+	return &SecretRotationRepository{db: db.DB}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *SecretRotationRepository) WithTx(tx *sql.Tx) *SecretRotationRepository {
+	return &SecretRotationRepository{db: tx}
+}
+
+// Create inserts a rotation and fills its ID and timestamps
+func (r *SecretRotationRepository) Create(ctx context.Context, rotation *model.SecretRotation) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO secret_rotations (key_id, key_version, status, total, started_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, started_at, updated_at`,
+		rotation.KeyID, rotation.KeyVersion, rotation.Status, rotation.Total, rotation.StartedBy,
+	).Scan(&rotation.ID, &rotation.StartedAt, &rotation.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create secret rotation: %w", err)
+	}
+	return nil
+}
+
+// Latest returns the most recently started rotation
+func (r *SecretRotationRepository) Latest(ctx context.Context) (*model.SecretRotation, error) {
+	return r.get(ctx, `SELECT `+rotationColumns+` FROM secret_rotations ORDER BY id DESC LIMIT 1`)
+}
+
+// Unfinished locks and returns the running or paused rotation
+func (r *SecretRotationRepository) Unfinished(ctx context.Context) (*model.SecretRotation, error) {
+	return r.get(ctx, `SELECT `+rotationColumns+` FROM secret_rotations
+		WHERE status IN ('running', 'paused') FOR UPDATE`)
+}
+
+// Claim locks and returns the running rotation for owner, provided owner
+// already holds it or the heartbeat of its holder is older than lease. The
+// heartbeat is renewed. It returns ErrNotFound when no rotation is running
+// or another owner holds it.
+func (r *SecretRotationRepository) Claim(ctx context.Context, owner string, lease time.Duration) (*model.SecretRotation, error) {
+	return r.get(ctx, `
+		UPDATE secret_rotations
+		SET owner = $1, heartbeat_at = NOW()
+		WHERE status = 'running'
+			AND (owner = $1 OR heartbeat_at IS NULL OR heartbeat_at < NOW() - $2 * INTERVAL '1 millisecond')
+		RETURNING `+rotationColumns, owner, lease.Milliseconds())
+}
+
+// SetStatus moves a rotation to status, recording a failure message.
+// Finished rotations are stamped with their completion time.
+func (r *SecretRotationRepository) SetStatus(ctx context.Context, rotation *model.SecretRotation) error {
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE secret_rotations
+		SET status = $2, error = $3, key_id = $4, key_version = $5,
+			completed_at = CASE WHEN $2 IN ('completed', 'failed') THEN NOW() END
+		WHERE id = $1
+		RETURNING updated_at, completed_at`,
+		rotation.ID, rotation.Status, rotation.Error, rotation.KeyID, rotation.KeyVersion,
+	).Scan(&rotation.UpdatedAt, &rotation.CompletedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to update secret rotation %d: %w", rotation.ID, err)
+	}
+	return nil
+}
+
+// Advance records the progress of a rotation
+func (r *SecretRotationRepository) Advance(ctx context.Context, rotation *model.SecretRotation) error {
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE secret_rotations
+		SET processed = $2, rewrapped = $3, last_template_id = $4, last_history_id = $5, heartbeat_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at`,
+		rotation.ID, rotation.Processed, rotation.Rewrapped, rotation.LastTemplateID, rotation.LastHistoryID,
+	).Scan(&rotation.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to record progress of secret rotation %d: %w", rotation.ID, err)
+	}
+	return nil
+}
+
+func (r *SecretRotationRepository) get(ctx context.Context, query string, args ...interface{}) (*model.SecretRotation, error) {
+	var rotation model.SecretRotation
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&rotation.ID, &rotation.KeyID, &rotation.KeyVersion, &rotation.Status, &rotation.Total,
+		&rotation.Processed, &rotation.Rewrapped, &rotation.LastTemplateID, &rotation.LastHistoryID, &rotation.Error,
+		&rotation.StartedBy, &rotation.StartedAt, &rotation.UpdatedAt, &rotation.CompletedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get secret rotation: %w", err)
+	}
+	return &rotation, nil
+}
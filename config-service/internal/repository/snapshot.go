@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+)
+
+const snapshotColumns = `id, environment_id, name, description, jsonb_array_length(templates),
+	created_by, created_at`
+
+// SnapshotRepository provides access to the snapshots of environments
+type SnapshotRepository struct {
+	db DBTX
+}
+
+// NewSnapshotRepository creates a new snapshot repository
+func NewSnapshotRepository(db *database.Connection) *SnapshotRepository {
+	return &SnapshotRepository{db: db.DB}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *SnapshotRepository) WithTx(tx *sql.Tx) *SnapshotRepository {
+	return &SnapshotRepository{db: tx}
+}
+
+// Create inserts a snapshot with its templates and fills its ID and
+// creation time
+func (r *SnapshotRepository) Create(ctx context.Context, s *model.Snapshot) error {
+	templates := s.Templates
+	if templates == nil {
+		templates = []model.SnapshotTemplate{}
+	}
+	data, err := json.Marshal(templates)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot templates: %w", err)
+	}
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO environment_snapshots (environment_id, name, description, templates, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		s.EnvironmentID, s.Name, s.Description, data, s.CreatedBy,
+	).Scan(&s.ID, &s.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot %q: %w", s.Name, err)
+	}
+	s.TemplateCount = len(templates)
+	return nil
+}
+
+// GetByName returns the snapshot of an environment with the given name and
+// its templates
+func (r *SnapshotRepository) GetByName(ctx context.Context, environmentID int64, name string) (*model.Snapshot, error) {
+	var data []byte
+	var s model.Snapshot
+	err := r.db.QueryRowContext(ctx,
+		`SELECT `+snapshotColumns+`, templates FROM environment_snapshots
+		WHERE environment_id = $1 AND name = $2`, environmentID, name,
+	).Scan(&s.ID, &s.EnvironmentID, &s.Name, &s.Description, &s.TemplateCount,
+		&s.CreatedBy, &s.CreatedAt, &data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get snapshot %q: %w", name, err)
+	}
+	if err := json.Unmarshal(data, &s.Templates); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot templates: %w", err)
+	}
+	return &s, nil
+}
+
+// ListByEnvironment returns the snapshots of an environment, newest first,
+// without their templates
+func (r *SnapshotRepository) ListByEnvironment(ctx context.Context, environmentID int64) ([]model.Snapshot, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+snapshotColumns+` FROM environment_snapshots
+		WHERE environment_id = $1 ORDER BY created_at DESC, id DESC`, environmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []model.Snapshot
+	for rows.Next() {
+		var s model.Snapshot
+		if err := rows.Scan(&s.ID, &s.EnvironmentID, &s.Name, &s.Description, &s.TemplateCount,
+			&s.CreatedBy, &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot: %w", err)
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
+
+// Delete removes a snapshot of an environment
+func (r *SnapshotRepository) Delete(ctx context.Context, environmentID, id int64) error {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM environment_snapshots WHERE id = $1 AND environment_id = $2`, id, environmentID)
+	if err != nil {
+		return fmt.Errorf("failed to delete snapshot %d: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
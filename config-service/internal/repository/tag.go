@@ -0,0 +1,397 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+	"github.com/lib/pq"
+)
+
+const tagColumns = `id, name, COALESCE(key, ''), COALESCE(value, ''), parent_id, COALESCE(description, ''),
+	color, created_at, updated_at`
+
+// TagRepository provides access to tags
+type TagRepository struct {
+	db DBTX
+}
+
+// NewTagRepository creates a new tag repository
+func NewTagRepository(db *database.Connection) *TagRepository {
+	return &TagRepository{db: db.DB}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *TagRepository) WithTx(tx *sql.Tx) *TagRepository {
+	return &TagRepository{db: tx}
+}
+
+// AsOf returns a read-only copy of the repository reading the state recorded
+// in the history at the given moment
+func (r *TagRepository) AsOf(at time.Time) *TagRepository {
+	return &TagRepository{db: asOf(r.db, at)}
+}
+
+// List returns all tags ordered by name
+func (r *TagRepository) List(ctx context.Context) ([]model.Tag, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+tagColumns+` FROM tags ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []model.Tag
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, *tag)
+	}
+	return tags, rows.Err()
+}
+
+// tagSortColumns are the columns tags can be sorted by
+var tagSortColumns = map[string]sortColumn{
+	"id":         {expr: "id", cast: "bigint"},
+	"name":       {expr: "name", cast: "text"},
+	"created_at": {expr: "created_at", cast: "timestamptz"},
+	"updated_at": {expr: "updated_at", cast: "timestamptz"},
+}
+
+// ListPage returns one page of tags, addressed by page number or by the
+// cursor in page, together with its position
+func (r *TagRepository) ListPage(ctx context.Context, page model.PaginationParams, sort model.SortParams) ([]model.Tag, PageInfo, error) {
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	k, err := newKeyset(page, sort, tagSortColumns, "id")
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM tags`).Scan(&total); err != nil {
+		return nil, PageInfo{}, fmt.Errorf("failed to count tags: %w", err)
+	}
+
+	tags, err := r.queryTags(ctx, `SELECT `+tagColumns+` FROM tags`+k.where("", arg)+k.clauses(arg), args...)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	tags, info := keysetPage(k, tags, total, tagSortValue)
+	return tags, info, nil
+}
+
+// tagSortValue returns the cursor value of a tag for a sort column
+func tagSortValue(t model.Tag, sortBy string) (string, int64) {
+	switch sortBy {
+	case "name":
+		return t.Name, t.ID
+	case "created_at":
+		return cursorTime(t.CreatedAt), t.ID
+	case "updated_at":
+		return cursorTime(t.UpdatedAt), t.ID
+	}
+	return strconv.FormatInt(t.ID, 10), t.ID
+}
+
+// ListByNames returns the tags with the given names. Unknown names are ignored.
+func (r *TagRepository) ListByNames(ctx context.Context, names []string) ([]model.Tag, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+tagColumns+` FROM tags WHERE name = ANY($1) ORDER BY name`, pq.Array(names))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []model.Tag
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, *tag)
+	}
+	return tags, rows.Err()
+}
+
+// GetByID returns the tag with the given ID
+func (r *TagRepository) GetByID(ctx context.Context, id int64) (*model.Tag, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+tagColumns+` FROM tags WHERE id = $1`, id)
+
+	tag, err := scanTag(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get tag %d: %w", id, err)
+	}
+	return tag, nil
+}
+
+// GetByName returns the tag with the given name
+func (r *TagRepository) GetByName(ctx context.Context, name string) (*model.Tag, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+tagColumns+` FROM tags WHERE name = $1`, name)
+
+	tag, err := scanTag(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get tag %q: %w", name, err)
+	}
+	return tag, nil
+}
+
+// Create inserts a new tag and fills its ID and timestamps
+func (r *TagRepository) Create(ctx context.Context, tag *model.Tag) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO tags (name, key, value, parent_id, description, color)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4, $5, $6)
+		RETURNING id, created_at, updated_at`,
+		tag.Name, tag.Key, tag.Value, tag.ParentID, tag.Description, tag.Color,
+	).Scan(&tag.ID, &tag.CreatedAt, &tag.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create tag %q: %w", tag.Name, err)
+	}
+	return nil
+}
+
+// Update overwrites all mutable fields of a tag
+func (r *TagRepository) Update(ctx context.Context, tag *model.Tag) error {
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE tags SET name = $2, key = NULLIF($3, ''), value = NULLIF($4, ''),
+			parent_id = $5, description = $6, color = $7
+		WHERE id = $1
+		RETURNING updated_at`,
+		tag.ID, tag.Name, tag.Key, tag.Value, tag.ParentID, tag.Description, tag.Color,
+	).Scan(&tag.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to update tag %q: %w", tag.Name, err)
+	}
+	return nil
+}
+
+// GetByLabel returns the label tag with the given key and value
+func (r *TagRepository) GetByLabel(ctx context.Context, key, value string) (*model.Tag, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+tagColumns+` FROM tags WHERE key = $1 AND value = $2`, key, value)
+
+	tag, err := scanTag(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get label %s=%s: %w", key, value, err)
+	}
+	return tag, nil
+}
+
+// Ancestors returns the IDs of the parent chain of a tag, nearest first. The
+// walk stops at a repeated tag so that an existing cycle cannot loop forever.
+func (r *TagRepository) Ancestors(ctx context.Context, id int64) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		WITH RECURSIVE chain(id, parent_id, depth) AS (
+			SELECT id, parent_id, 0 FROM tags WHERE id = $1
+			UNION
+			SELECT g.id, g.parent_id, c.depth + 1
+			FROM tags g JOIN chain c ON g.id = c.parent_id
+			WHERE c.depth < 1000
+		)
+		SELECT id FROM chain WHERE depth > 0 ORDER BY depth`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ancestors of tag %d: %w", id, err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var ancestor int64
+		if err := rows.Scan(&ancestor); err != nil {
+			return nil, fmt.Errorf("failed to scan ancestor: %w", err)
+		}
+		ids = append(ids, ancestor)
+	}
+	return ids, rows.Err()
+}
+
+// LockHierarchy serializes changes to tag parents until the end of the
+// current transaction
+func (r *TagRepository) LockHierarchy(ctx context.Context) error {
+	if _, err := r.db.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('tags.parent_id'))`); err != nil {
+		return fmt.Errorf("failed to lock tag hierarchy: %w", err)
+	}
+	return nil
+}
+
+// MoveLinks relinks every template linked to one of the source tags to the
+// target tag, keeping the time of its earliest source link, and removes the
+// source links. It returns the number of links moved and the number dropped
+// because the template already had the target.
+// Feature flag links are moved the same way but not counted.
+func (r *TagRepository) MoveLinks(ctx context.Context, sourceIDs []int64, targetID int64) (moved, duplicates int64, err error) {
+	var total int64
+	if err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM template_tags WHERE tag_id = ANY($1::bigint[])`,
+		pq.Array(sourceIDs)).Scan(&total); err != nil {
+		return 0, 0, fmt.Errorf("failed to count tag links: %w", err)
+	}
+
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO template_tags (template_id, tag_id, created_at)
+		SELECT template_id, $2::bigint, MIN(created_at)
+		FROM template_tags
+		WHERE tag_id = ANY($1::bigint[])
+		GROUP BY template_id
+		ON CONFLICT DO NOTHING`, pq.Array(sourceIDs), targetID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to relink templates to tag %d: %w", targetID, err)
+	}
+	if moved, err = res.RowsAffected(); err != nil {
+		return 0, 0, fmt.Errorf("failed to relink templates to tag %d: %w", targetID, err)
+	}
+
+	if _, err := r.db.ExecContext(ctx,
+		`DELETE FROM template_tags WHERE tag_id = ANY($1::bigint[])`, pq.Array(sourceIDs)); err != nil {
+		return 0, 0, fmt.Errorf("failed to remove source tag links: %w", err)
+	}
+
+	if _, err := r.db.ExecContext(ctx, `
+		INSERT INTO feature_flag_tags (flag_id, tag_id)
+		SELECT DISTINCT flag_id, $2::bigint
+		FROM feature_flag_tags
+		WHERE tag_id = ANY($1::bigint[])
+		ON CONFLICT DO NOTHING`, pq.Array(sourceIDs), targetID); err != nil {
+		return 0, 0, fmt.Errorf("failed to relink flags to tag %d: %w", targetID, err)
+	}
+	if _, err := r.db.ExecContext(ctx,
+		`DELETE FROM feature_flag_tags WHERE tag_id = ANY($1::bigint[])`, pq.Array(sourceIDs)); err != nil {
+		return 0, 0, fmt.Errorf("failed to remove source flag tag links: %w", err)
+	}
+	return moved, total - moved, nil
+}
+
+// SetParent changes the parent of a tag
+func (r *TagRepository) SetParent(ctx context.Context, id int64, parentID *int64) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE tags SET parent_id = $2 WHERE id = $1`, id, parentID)
+	if err != nil {
+		return fmt.Errorf("failed to set parent of tag %d: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListChildren returns the direct children of the given tags
+func (r *TagRepository) ListChildren(ctx context.Context, parentIDs []int64) ([]model.Tag, error) {
+	return r.queryTags(ctx, `SELECT `+tagColumns+` FROM tags WHERE parent_id = ANY($1::bigint[]) ORDER BY name`,
+		pq.Array(parentIDs))
+}
+
+// Delete removes tags; their template links are removed by cascade and their
+// children are detached
+func (r *TagRepository) Delete(ctx context.Context, ids []int64) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM tags WHERE id = ANY($1::bigint[])`, pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to delete tags: %w", err)
+	}
+	return nil
+}
+
+// TagTemplateCount is the number of templates linked to a tag within one
+// environment and format. LastUsedAt is when the latest of those links was
+// made.
+type TagTemplateCount struct {
+	TagID       int64
+	Environment string
+	Format      model.ConfigFormat
+	Templates   int
+	LastUsedAt  time.Time
+}
+
+// TemplateCounts returns template link counts grouped by tag, environment
+// and format. Tags without links are omitted.
+func (r *TagRepository) TemplateCounts(ctx context.Context) ([]TagTemplateCount, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT tt.tag_id, e.slug, t.format, COUNT(*), MAX(COALESCE(tt.created_at, t.created_at))
+		FROM template_tags tt
+		JOIN templates t ON t.id = tt.template_id
+		JOIN environments e ON e.id = t.environment_id
+		GROUP BY tt.tag_id, e.slug, t.format`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tag usage: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []TagTemplateCount
+	for rows.Next() {
+		var c TagTemplateCount
+		if err := rows.Scan(&c.TagID, &c.Environment, &c.Format, &c.Templates, &c.LastUsedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tag usage: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// unusedTagCondition matches tags without template links, flag links and
+// child tags created before $1, other than the tags listed in $2
+const unusedTagCondition = `g.created_at < $1
+	AND g.id <> ALL(COALESCE($2::bigint[], '{}'))
+	AND NOT EXISTS (SELECT 1 FROM template_tags tt WHERE tt.tag_id = g.id)
+	AND NOT EXISTS (SELECT 1 FROM feature_flag_tags ft WHERE ft.tag_id = g.id)
+	AND NOT EXISTS (SELECT 1 FROM tags c WHERE c.parent_id = g.id)`
+
+// ListUnused returns tags created before the given time that have no
+// template links, flag links or child tags, except the tags in keep
+func (r *TagRepository) ListUnused(ctx context.Context, before time.Time, keep []int64) ([]model.Tag, error) {
+	return r.queryTags(ctx, `SELECT `+tagColumns+` FROM tags g WHERE `+unusedTagCondition+` ORDER BY name`,
+		before, pq.Array(keep))
+}
+
+// DeleteUnused deletes the tags ListUnused would return and returns them
+func (r *TagRepository) DeleteUnused(ctx context.Context, before time.Time, keep []int64) ([]model.Tag, error) {
+	return r.queryTags(ctx, `DELETE FROM tags g WHERE `+unusedTagCondition+` RETURNING `+tagColumns,
+		before, pq.Array(keep))
+}
+
+func (r *TagRepository) queryTags(ctx context.Context, query string, args ...interface{}) ([]model.Tag, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []model.Tag
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, *tag)
+	}
+	return tags, rows.Err()
+}
+
+func scanTag(row rowScanner) (*model.Tag, error) {
+	var tag model.Tag
+	if err := row.Scan(
+		&tag.ID, &tag.Name, &tag.Key, &tag.Value, &tag.ParentID, &tag.Description,
+		&tag.Color, &tag.CreatedAt, &tag.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
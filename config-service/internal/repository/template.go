@@ -0,0 +1,862 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/selector"
+	"github.com/lib/pq"
+)
+
+const templateColumns = `t.id, t.name, COALESCE(t.description, ''), t.format, t.content, t.schema,
+	t.default_values, t.version, t.environment_id, COALESCE(t.active, true), t.created_at,
+	t.updated_at, t.created_by, t.updated_by`
+
+// TemplateRepository provides access to templates and their tag links
+type TemplateRepository struct {
+	db DBTX
+}
+
+// NewTemplateRepository creates a new template repository
+func NewTemplateRepository(db *database.Connection) *TemplateRepository {
+	return &TemplateRepository{db: db.DB}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *TemplateRepository) WithTx(tx *sql.Tx) *TemplateRepository {
+	return &TemplateRepository{db: tx}
+}
+
+// AsOf returns a read-only copy of the repository reading the state recorded
+// in the history at the given moment
+func (r *TemplateRepository) AsOf(at time.Time) *TemplateRepository {
+	return &TemplateRepository{db: asOf(r.db, at)}
+}
+
+// ListActiveByEnvironment returns active templates of an environment matched
+// by a tag selector. A nil selector matches all templates.
+func (r *TemplateRepository) ListActiveByEnvironment(ctx context.Context, environmentID int64, expr selector.Expr) ([]model.Template, error) {
+	args := []interface{}{environmentID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	query := `SELECT ` + templateColumns + `
+		FROM templates t
+		WHERE t.environment_id = $1
+		  AND COALESCE(t.active, true)`
+	if expr != nil {
+		query += ` AND ` + selector.SQL(expr, "t.id", arg)
+	}
+	query += ` ORDER BY t.name`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []model.Template
+	for rows.Next() {
+		tpl, err := scanTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan template: %w", err)
+		}
+		templates = append(templates, *tpl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate templates: %w", err)
+	}
+
+	if err := r.attachTags(ctx, templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// ListByEnvironment returns every template of an environment ordered by
+// name, loading what load selects
+func (r *TemplateRepository) ListByEnvironment(ctx context.Context, environmentID int64, load TemplateLoad) ([]model.Template, error) {
+	p := newTemplateProjection(load, "name")
+	rows, err := r.db.QueryContext(ctx, p.query()+` WHERE t.environment_id = $1 ORDER BY t.name`, environmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates of environment %d: %w", environmentID, err)
+	}
+	defer rows.Close()
+
+	var templates []model.Template
+	for rows.Next() {
+		tpl, err := p.scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan template: %w", err)
+		}
+		templates = append(templates, *tpl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate templates: %w", err)
+	}
+
+	if load.Tags {
+		if err := r.attachTags(ctx, templates); err != nil {
+			return nil, err
+		}
+	}
+	return templates, nil
+}
+
+// List returns all templates across environments with their tags
+func (r *TemplateRepository) List(ctx context.Context) ([]model.Template, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+templateColumns+`
+		FROM templates t
+		ORDER BY t.environment_id, t.name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []model.Template
+	for rows.Next() {
+		tpl, err := scanTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan template: %w", err)
+		}
+		templates = append(templates, *tpl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate templates: %w", err)
+	}
+
+	if err := r.attachTags(ctx, templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// GetByID returns the template with the given ID and its tags
+func (r *TemplateRepository) GetByID(ctx context.Context, id int64) (*model.Template, error) {
+	return r.Load(ctx, id, TemplateLoad{Tags: true})
+}
+
+// Load returns the template with the given ID, loading what load selects
+func (r *TemplateRepository) Load(ctx context.Context, id int64, load TemplateLoad) (*model.Template, error) {
+	p := newTemplateProjection(load)
+	tpl, err := p.scan(r.db.QueryRowContext(ctx, p.query()+` WHERE t.id = $1`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get template %d: %w", id, err)
+	}
+
+	if !load.Tags {
+		return tpl, nil
+	}
+	templates := []model.Template{*tpl}
+	if err := r.attachTags(ctx, templates); err != nil {
+		return nil, err
+	}
+	return &templates[0], nil
+}
+
+// Find returns all templates matching the filter, ordered by ID
+func (r *TemplateRepository) Find(ctx context.Context, filter model.TemplateFilter) ([]model.Template, error) {
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	where, err := filterConditions(filter, arg)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + templateColumns + ` FROM templates t` + where + ` ORDER BY t.id`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find templates: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []model.Template
+	for rows.Next() {
+		tpl, err := scanTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan template: %w", err)
+		}
+		templates = append(templates, *tpl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate templates: %w", err)
+	}
+
+	if err := r.attachTags(ctx, templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// ListPage returns one page of templates matching the filter, addressed by
+// page number or by the cursor in page, together with its position. load
+// selects the columns and relations read.
+func (r *TemplateRepository) ListPage(ctx context.Context, filter model.TemplateFilter, page model.PaginationParams, sort model.SortParams, load TemplateLoad) ([]model.Template, PageInfo, error) {
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	k, err := newKeyset(page, sort, templateSortColumns, "t.id")
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	where, err := filterConditions(filter, arg)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM templates t`+where, args...).Scan(&total); err != nil {
+		return nil, PageInfo{}, fmt.Errorf("failed to count templates: %w", err)
+	}
+
+	p := newTemplateProjection(load, k.sortBy)
+	where = k.where(where, arg)
+	rows, err := r.db.QueryContext(ctx, p.query()+where+k.clauses(arg), args...)
+	if err != nil {
+		return nil, PageInfo{}, fmt.Errorf("failed to list templates: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []model.Template
+	for rows.Next() {
+		tpl, err := p.scan(rows)
+		if err != nil {
+			return nil, PageInfo{}, fmt.Errorf("failed to scan template: %w", err)
+		}
+		templates = append(templates, *tpl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, PageInfo{}, fmt.Errorf("failed to iterate templates: %w", err)
+	}
+
+	templates, info := keysetPage(k, templates, total, templateSortValue)
+	if load.Tags {
+		if err := r.attachTags(ctx, templates); err != nil {
+			return nil, PageInfo{}, err
+		}
+	}
+	return templates, info, nil
+}
+
+// templateSortColumns are the columns templates can be sorted by
+var templateSortColumns = map[string]sortColumn{
+	"id":         {expr: "t.id", cast: "bigint"},
+	"name":       {expr: "t.name", cast: "text"},
+	"version":    {expr: "t.version", cast: "text"},
+	"created_at": {expr: "t.created_at", cast: "timestamptz"},
+	"updated_at": {expr: "t.updated_at", cast: "timestamptz"},
+}
+
+// templateSortValue returns the cursor value of a template for a sort column
+func templateSortValue(t model.Template, sortBy string) (string, int64) {
+	switch sortBy {
+	case "name":
+		return t.Name, t.ID
+	case "version":
+		return t.Version, t.ID
+	case "created_at":
+		return cursorTime(t.CreatedAt), t.ID
+	case "updated_at":
+		return cursorTime(t.UpdatedAt), t.ID
+	}
+	return strconv.FormatInt(t.ID, 10), t.ID
+}
+
+// IsTemplateSortColumn reports whether templates can be sorted by the given column
+func IsTemplateSortColumn(name string) bool {
+	_, ok := templateSortColumns[name]
+	return ok
+}
+
+// filterConditions builds the WHERE clause for a template filter, registering
+// query arguments through arg. It returns an empty string for an empty filter.
+func filterConditions(filter model.TemplateFilter, arg func(interface{}) string) (string, error) {
+	var conditions []string
+
+	if len(filter.IDs) > 0 {
+		conditions = append(conditions, "t.id = ANY("+arg(pq.Array(filter.IDs))+"::bigint[])")
+	}
+	if filter.Environment != "" {
+		conditions = append(conditions,
+			"t.environment_id = (SELECT id FROM environments WHERE slug = "+arg(filter.Environment)+")")
+	}
+	if filter.Format != "" {
+		conditions = append(conditions, "t.format = "+arg(string(filter.Format)))
+	}
+	if filter.Active != nil {
+		conditions = append(conditions, "COALESCE(t.active, true) = "+arg(*filter.Active))
+	}
+	if len(filter.Tags) > 0 {
+		conditions = append(conditions, selector.SQL(selector.All(filter.Tags...), "t.id", arg))
+	}
+	if filter.Search != "" {
+		conditions = append(conditions,
+			"t.search_vector @@ websearch_to_tsquery('"+searchConfig+"', "+arg(filter.Search)+")")
+	}
+	if filter.Selector != "" {
+		expr, err := selector.Parse(filter.Selector)
+		if err != nil {
+			return "", err
+		}
+		if expr != nil {
+			conditions = append(conditions, selector.SQL(expr, "t.id", arg))
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), nil
+}
+
+// searchConfig is the text search configuration of templates.search_vector
+const searchConfig = "english"
+
+// Matches are delimited in ts_headline output by private use characters, which
+// highlight turns into <mark> tags once the text around them is escaped
+const (
+	snippetStart = "\uE000"
+	snippetStop  = "\uE001"
+)
+
+// searchSnippetOptions configures ts_headline for search snippets
+const searchSnippetOptions = `StartSel="` + snippetStart + `", StopSel="` + snippetStop + `", ` +
+	"MaxWords=25, MinWords=8, MaxFragments=2, FragmentDelimiter= … "
+
+// TemplateMatch is a template matching a full-text search with its rank and
+// description and content snippets. Snippets are HTML escaped, with matches
+// wrapped in <mark> tags; a snippet without a match is empty.
+type TemplateMatch struct {
+	Template           model.Template
+	Rank               float64
+	DescriptionSnippet string
+	ContentSnippet     string
+}
+
+// Search returns one page of templates matching filter.Search and the other
+// filter criteria, best match first, together with the total number of
+// matches. Snippets are only computed for the returned page.
+func (r *TemplateRepository) Search(ctx context.Context, filter model.TemplateFilter, page model.PaginationParams) ([]TemplateMatch, int64, error) {
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.Search == "" {
+		return nil, 0, fmt.Errorf("search query is required")
+	}
+	where, err := filterConditions(filter, arg)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM templates t`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count search results: %w", err)
+	}
+
+	query := `websearch_to_tsquery('` + searchConfig + `', ` + arg(filter.Search) + `)`
+	options := arg(searchSnippetOptions)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+templateColumns+`, p.rank,
+			ts_headline('`+searchConfig+`', COALESCE(t.description, ''), `+query+`, `+options+`),
+			ts_headline('`+searchConfig+`', t.content, `+query+`, `+options+`)
+		FROM (
+			SELECT t.id, ts_rank_cd(t.search_vector, `+query+`) AS rank
+			FROM templates t`+where+`
+			ORDER BY rank DESC, t.id
+			LIMIT `+arg(page.PageSize)+` OFFSET `+arg((page.Page-1)*page.PageSize)+`
+		) p
+		JOIN templates t ON t.id = p.id
+		ORDER BY p.rank DESC, t.id`, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search templates: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []TemplateMatch
+	var templates []model.Template
+	for rows.Next() {
+		var m TemplateMatch
+		tpl := &m.Template
+		if err := rows.Scan(
+			&tpl.ID, &tpl.Name, &tpl.Description, &tpl.Format, &tpl.Content, &tpl.Schema,
+			&tpl.DefaultValues, &tpl.Version, &tpl.EnvironmentID, &tpl.Active, &tpl.CreatedAt,
+			&tpl.UpdatedAt, &tpl.CreatedBy, &tpl.UpdatedBy,
+			&m.Rank, &m.DescriptionSnippet, &m.ContentSnippet,
+		); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		m.DescriptionSnippet = highlight(m.DescriptionSnippet)
+		m.ContentSnippet = highlight(m.ContentSnippet)
+		matches = append(matches, m)
+		templates = append(templates, m.Template)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to iterate search results: %w", err)
+	}
+
+	if err := r.attachTags(ctx, templates); err != nil {
+		return nil, 0, err
+	}
+	for i := range matches {
+		matches[i].Template.Tags = templates[i].Tags
+		matches[i].Template.TagIDs = templates[i].TagIDs
+	}
+	return matches, total, nil
+}
+
+// highlight HTML escapes a ts_headline snippet and wraps its matches in
+// <mark> tags, returning "" when nothing matched. Delimiters occurring in the
+// template text itself cannot unbalance the tags.
+func highlight(snippet string) string {
+	if !strings.Contains(snippet, snippetStart) {
+		return ""
+	}
+	var b strings.Builder
+	open := false
+	for snippet != "" {
+		i := strings.IndexAny(snippet, snippetStart+snippetStop)
+		if i < 0 {
+			b.WriteString(html.EscapeString(snippet))
+			break
+		}
+		b.WriteString(html.EscapeString(snippet[:i]))
+		start := strings.HasPrefix(snippet[i:], snippetStart)
+		switch {
+		case start && !open:
+			b.WriteString("<mark>")
+		case !start && open:
+			b.WriteString("</mark>")
+		}
+		open = start
+		snippet = snippet[i+len(snippetStart):]
+	}
+	if open {
+		b.WriteString("</mark>")
+	}
+	return b.String()
+}
+
+// jsonPathColumns maps queryable JSON fields to their columns; each has a GIN index
+var jsonPathColumns = map[string]string{
+	model.TemplateFieldDefaultValues: "t.default_values",
+	model.TemplateFieldSchema:        "t.schema",
+}
+
+// jsonPathDocuments maps queryable JSON fields to the documents paths are
+// evaluated on. Secret envelopes in default values are masked so that no
+// path can select them or their fields.
+var jsonPathDocuments = map[string]string{
+	model.TemplateFieldDefaultValues: "mask_secrets(t.default_values)",
+	model.TemplateFieldSchema:        "t.schema",
+}
+
+// PathMatch is a template matching a JSON path with the values the path
+// selected in each queried field
+type PathMatch struct {
+	Template model.Template
+	Values   map[string][]interface{}
+}
+
+// QueryPath returns one page of templates, ordered by ID, where the JSON path
+// selects at least one item in any of the given fields, together with the
+// total number of matches. The @? operator lets Postgres use the GIN indexes
+// on default_values and schema. A path Postgres rejects yields
+// ErrInvalidJSONPath.
+func (r *TemplateRepository) QueryPath(ctx context.Context, filter model.TemplateFilter, path string, fields []string, page model.PaginationParams) ([]PathMatch, int64, error) {
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	where, err := filterConditions(filter, arg)
+	if err != nil {
+		return nil, 0, err
+	}
+	jsonPath := arg(path) + "::jsonpath"
+
+	var exists, values []string
+	for _, field := range fields {
+		column, ok := jsonPathColumns[field]
+		if !ok {
+			return nil, 0, fmt.Errorf("unsupported JSON path field %q", field)
+		}
+		document := jsonPathDocuments[field]
+		if document == column {
+			exists = append(exists, column+" @? "+jsonPath)
+		} else {
+			// The column condition can use the index
+			exists = append(exists, "("+column+" @? "+jsonPath+" AND "+document+" @? "+jsonPath+")")
+		}
+		values = append(values, "jsonb_path_query_array("+document+", "+jsonPath+", '{}', true)")
+	}
+	condition := "(" + strings.Join(exists, " OR ") + ")"
+	if where == "" {
+		where = " WHERE " + condition
+	} else {
+		where += " AND " + condition
+	}
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM templates t`+where, args...).Scan(&total); err != nil {
+		return nil, 0, jsonPathError(err, "failed to count JSON path matches")
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+templateColumns+`, `+strings.Join(values, ", ")+`
+		FROM templates t`+where+`
+		ORDER BY t.id
+		LIMIT `+arg(page.PageSize)+` OFFSET `+arg((page.Page-1)*page.PageSize), args...)
+	if err != nil {
+		return nil, 0, jsonPathError(err, "failed to query templates by JSON path")
+	}
+	defer rows.Close()
+
+	var matches []PathMatch
+	for rows.Next() {
+		var m PathMatch
+		tpl := &m.Template
+		raw := make([][]byte, len(fields))
+		dest := []interface{}{
+			&tpl.ID, &tpl.Name, &tpl.Description, &tpl.Format, &tpl.Content, &tpl.Schema,
+			&tpl.DefaultValues, &tpl.Version, &tpl.EnvironmentID, &tpl.Active, &tpl.CreatedAt,
+			&tpl.UpdatedAt, &tpl.CreatedBy, &tpl.UpdatedBy,
+		}
+		for i := range raw {
+			dest = append(dest, &raw[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan JSON path match: %w", err)
+		}
+
+		m.Values = make(map[string][]interface{}, len(fields))
+		for i, field := range fields {
+			var items []interface{}
+			if err := json.Unmarshal(raw[i], &items); err != nil {
+				return nil, 0, fmt.Errorf("failed to decode matched values: %w", err)
+			}
+			if len(items) > 0 {
+				m.Values[field] = items
+			}
+		}
+		matches = append(matches, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, jsonPathError(err, "failed to iterate JSON path matches")
+	}
+	return matches, total, nil
+}
+
+// jsonPathError maps Postgres syntax and SQL/JSON errors to ErrInvalidJSONPath
+func jsonPathError(err error, msg string) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && (pqErr.Code == "42601" || pqErr.Code.Class() == "22") {
+		return fmt.Errorf("%w: %s", ErrInvalidJSONPath, pqErr.Message)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// GetByName returns the template with the given name within an environment
+func (r *TemplateRepository) GetByName(ctx context.Context, environmentID int64, name string) (*model.Template, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+templateColumns+`
+		FROM templates t
+		WHERE t.environment_id = $1 AND t.name = $2`, environmentID, name)
+
+	tpl, err := scanTemplate(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get template %q: %w", name, err)
+	}
+
+	templates := []model.Template{*tpl}
+	if err := r.attachTags(ctx, templates); err != nil {
+		return nil, err
+	}
+	return &templates[0], nil
+}
+
+// Create inserts a new template and fills its ID and timestamps. Tag links
+// are not written; use SetTags.
+func (r *TemplateRepository) Create(ctx context.Context, tpl *model.Template) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO templates (name, description, format, content, schema, default_values,
+			version, environment_id, active, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, COALESCE($5::jsonb, '{}'), COALESCE($6::jsonb, '{}'),
+			$7, $8, $9, $10, $11)
+		RETURNING id, created_at, updated_at`,
+		tpl.Name, tpl.Description, tpl.Format, tpl.Content, tpl.Schema, tpl.DefaultValues,
+		tpl.Version, tpl.EnvironmentID, tpl.Active, tpl.CreatedBy, tpl.UpdatedBy,
+	).Scan(&tpl.ID, &tpl.CreatedAt, &tpl.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create template %q: %w", tpl.Name, err)
+	}
+	return nil
+}
+
+// Update overwrites all mutable fields of a template. Tag links are not
+// written; use SetTags.
+func (r *TemplateRepository) Update(ctx context.Context, tpl *model.Template) error {
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE templates
+		SET name = $2, description = $3, format = $4, content = $5,
+			schema = COALESCE($6::jsonb, '{}'), default_values = COALESCE($7::jsonb, '{}'),
+			version = $8, environment_id = $9, active = $10, updated_by = $11
+		WHERE id = $1
+		RETURNING updated_at`,
+		tpl.ID, tpl.Name, tpl.Description, tpl.Format, tpl.Content, tpl.Schema,
+		tpl.DefaultValues, tpl.Version, tpl.EnvironmentID, tpl.Active, tpl.UpdatedBy,
+	).Scan(&tpl.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to update template %q: %w", tpl.Name, err)
+	}
+	return nil
+}
+
+// LockUpdatedAt locks a template until the transaction ends and returns
+// when it was last updated
+func (r *TemplateRepository) LockUpdatedAt(ctx context.Context, id int64) (time.Time, error) {
+	var updatedAt time.Time
+	err := r.db.QueryRowContext(ctx,
+		`SELECT updated_at FROM templates WHERE id = $1 FOR UPDATE`, id).Scan(&updatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, ErrNotFound
+		}
+		return time.Time{}, fmt.Errorf("failed to lock template %d: %w", id, err)
+	}
+	return updatedAt, nil
+}
+
+// SetTags replaces all tag links of a template. Links it already has are
+// kept as they are, so that they keep the time they were made.
+func (r *TemplateRepository) SetTags(ctx context.Context, templateID int64, tagIDs []int64) error {
+	if _, err := r.db.ExecContext(ctx,
+		`DELETE FROM template_tags WHERE template_id = $1 AND tag_id <> ALL($2::bigint[])`,
+		templateID, pq.Array(append([]int64{}, tagIDs...))); err != nil {
+		return fmt.Errorf("failed to clear template tags: %w", err)
+	}
+
+	if len(tagIDs) == 0 {
+		return nil
+	}
+	return r.AttachTags(ctx, templateID, tagIDs)
+}
+
+// Delete removes a template; its tag links are removed by cascade
+func (r *TemplateRepository) Delete(ctx context.Context, id int64) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM templates WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete template %d: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetActive activates or deactivates a template
+func (r *TemplateRepository) SetActive(ctx context.Context, id int64, active bool, updatedBy string) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE templates SET active = $2, updated_by = $3 WHERE id = $1`, id, active, updatedBy)
+	if err != nil {
+		return fmt.Errorf("failed to set active on template %d: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Touch attributes a change made outside the template row, such as to its
+// tag links, to updatedBy
+func (r *TemplateRepository) Touch(ctx context.Context, id int64, updatedBy string) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE templates SET updated_by = $2 WHERE id = $1`, id, updatedBy)
+	if err != nil {
+		return fmt.Errorf("failed to touch template %d: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AttachTags links tags to a template, ignoring links that already exist
+func (r *TemplateRepository) AttachTags(ctx context.Context, templateID int64, tagIDs []int64) error {
+	if _, err := r.db.ExecContext(ctx, `
+		INSERT INTO template_tags (template_id, tag_id)
+		SELECT $1, unnest($2::bigint[])
+		ON CONFLICT DO NOTHING`, templateID, pq.Array(tagIDs)); err != nil {
+		return fmt.Errorf("failed to attach tags to template %d: %w", templateID, err)
+	}
+	return nil
+}
+
+// DetachTags removes tag links from a template
+func (r *TemplateRepository) DetachTags(ctx context.Context, templateID int64, tagIDs []int64) error {
+	if _, err := r.db.ExecContext(ctx,
+		`DELETE FROM template_tags WHERE template_id = $1 AND tag_id = ANY($2::bigint[])`,
+		templateID, pq.Array(tagIDs)); err != nil {
+		return fmt.Errorf("failed to detach tags from template %d: %w", templateID, err)
+	}
+	return nil
+}
+
+// attachTags loads tags for the given templates in a single query
+func (r *TemplateRepository) attachTags(ctx context.Context, templates []model.Template) error {
+	if len(templates) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(templates))
+	index := make(map[int64]int, len(templates))
+	for i, tpl := range templates {
+		ids[i] = tpl.ID
+		index[tpl.ID] = i
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT tt.template_id, g.id, g.name, COALESCE(g.key, ''), COALESCE(g.value, ''),
+			g.parent_id, COALESCE(g.description, ''), g.color, g.created_at, g.updated_at
+		FROM template_tags tt
+		JOIN tags g ON g.id = tt.tag_id
+		WHERE tt.template_id = ANY($1)
+		ORDER BY g.name`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to load template tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var templateID int64
+		var tag model.Tag
+		if err := rows.Scan(&templateID, &tag.ID, &tag.Name, &tag.Key, &tag.Value,
+			&tag.ParentID, &tag.Description, &tag.Color, &tag.CreatedAt, &tag.UpdatedAt); err != nil {
+			return fmt.Errorf("failed to scan template tag: %w", err)
+		}
+		tpl := &templates[index[templateID]]
+		tpl.Tags = append(tpl.Tags, tag)
+		tpl.TagIDs = append(tpl.TagIDs, tag.ID)
+	}
+	return rows.Err()
+}
+
+func scanTemplate(row rowScanner) (*model.Template, error) {
+	var tpl model.Template
+	if err := row.Scan(
+		&tpl.ID, &tpl.Name, &tpl.Description, &tpl.Format, &tpl.Content, &tpl.Schema,
+		&tpl.DefaultValues, &tpl.Version, &tpl.EnvironmentID, &tpl.Active, &tpl.CreatedAt,
+		&tpl.UpdatedAt, &tpl.CreatedBy, &tpl.UpdatedBy,
+	); err != nil {
+		return nil, err
+	}
+	return &tpl, nil
+}
+
+// hasSecretValues matches templates whose default values hold an encrypted
+// secret envelope at any depth
+const hasSecretValues = `jsonb_path_exists(t.default_values, 'lax $.**."$secret"')`
+
+// CountWithSecretValues counts the templates holding encrypted secret values
+func (r *TemplateRepository) CountWithSecretValues(ctx context.Context) (int, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM templates t WHERE `+hasSecretValues).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count templates with secret values: %w", err)
+	}
+	return count, nil
+}
+
+// CountSecretValuesByKey counts the templates holding secret values wrapped
+// by each master key ID
+func (r *TemplateRepository) CountSecretValuesByKey(ctx context.Context) (map[string]int64, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT kid #>> '{}', COUNT(DISTINCT t.id)
+		FROM templates t, jsonb_path_query(t.default_values, 'lax $.**."$secret".kid') AS kid
+		GROUP BY 1`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count secret values by key: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int64{}
+	for rows.Next() {
+		var kid string
+		var count int64
+		if err := rows.Scan(&kid, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan secret key count: %w", err)
+		}
+		counts[kid] = count
+	}
+	return counts, rows.Err()
+}
+
+// ListSecretValuesAfter locks and returns up to limit templates holding
+// encrypted secret values with IDs above afterID, in ID order. Only the ID,
+// name and default values are loaded.
+func (r *TemplateRepository) ListSecretValuesAfter(ctx context.Context, afterID int64, limit int) ([]model.Template, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t.id, t.name, t.default_values
+		FROM templates t
+		WHERE t.id > $1 AND `+hasSecretValues+`
+		ORDER BY t.id
+		LIMIT $2
+		FOR UPDATE`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates with secret values: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []model.Template
+	for rows.Next() {
+		var tpl model.Template
+		if err := rows.Scan(&tpl.ID, &tpl.Name, &tpl.DefaultValues); err != nil {
+			return nil, fmt.Errorf("failed to scan template: %w", err)
+		}
+		templates = append(templates, tpl)
+	}
+	return templates, rows.Err()
+}
+
+// UpdateDefaultValues replaces the default values of a template without
+// touching its version or author. It is meant for re-encrypting secret
+// values, so the transaction queues no webhook events.
+func (r *TemplateRepository) UpdateDefaultValues(ctx context.Context, id int64, values model.JSONMap) error {
+	res, err := r.db.ExecContext(ctx, `
+		WITH quiet AS (SELECT set_config('config_service.suppress_events', 'on', true))
+		UPDATE templates SET default_values = COALESCE($2::jsonb, '{}') FROM quiet WHERE id = $1`, id, values)
+	if err != nil {
+		return fmt.Errorf("failed to update default values of template %d: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/company/config-service/internal/database/dbtest"
+	"github.com/company/config-service/internal/model"
+)
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet string
+		want    string
+	}{
+		{"no match", "plain <b>text</b>", ""},
+		{"match", "max \uE000pool\uE001 size", "max <mark>pool</mark> size"},
+		{"escaped", "<script>\uE000pool\uE001</script> & \"q\"", "&lt;script&gt;<mark>pool</mark>&lt;/script&gt; &amp; &#34;q&#34;"},
+		{"fragments", "\uE000a\uE001 … \uE000b\uE001", "<mark>a</mark> … <mark>b</mark>"},
+		{"stray stop", "x\uE001 \uE000a\uE001", "x <mark>a</mark>"},
+		{"nested start", "\uE000a\uE000b\uE001", "<mark>ab</mark>"},
+		{"unclosed", "\uE000a", "<mark>a</mark>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlight(tt.snippet); got != tt.want {
+				t.Errorf("highlight(%q) = %q, want %q", tt.snippet, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t)
+	env := &model.Environment{Name: "prod", Slug: "prod", Active: true}
+	if err := NewEnvironmentRepository(db).Create(ctx, env); err != nil {
+		t.Fatal(err)
+	}
+	templates := NewTemplateRepository(db)
+	for _, tpl := range []model.Template{
+		{Name: "queue", Content: "<script>alert(1)</script> pool: 10"},
+		{Name: "cache", Description: "shared pool settings", Content: "size: 1"},
+		{Name: "pool", Content: "size: 1"},
+		{Name: "other", Content: "size: 1"},
+	} {
+		tpl.Format, tpl.Version, tpl.EnvironmentID, tpl.Active = model.ConfigFormatYAML, "1.0.0", env.ID, true
+		if err := templates.Create(ctx, &tpl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	matches, total, err := templates.Search(ctx, model.TemplateFilter{Search: "pool"}, model.PaginationParams{Page: 1, PageSize: 10})
+	if err != nil {
+		t.Fatalf("Search error: %v", err)
+	}
+	if total != 3 || len(matches) != 3 {
+		t.Fatalf("Search = %d matches of %d, want 3", len(matches), total)
+	}
+
+	// Name matches rank above description matches, which rank above content
+	want := []struct {
+		name, description, content string
+	}{
+		{name: "pool"},
+		{name: "cache", description: "shared <mark>pool</mark> settings"},
+		{name: "queue", content: "&lt;script&gt;alert(1)&lt;/script&gt; <mark>pool</mark>: 10"},
+	}
+	for i, m := range matches {
+		if m.Template.Name != want[i].name {
+			t.Errorf("match %d = %s, want %s", i, m.Template.Name, want[i].name)
+			continue
+		}
+		if i > 0 && m.Rank >= matches[i-1].Rank {
+			t.Errorf("rank of %s = %v, not below %v", m.Template.Name, m.Rank, matches[i-1].Rank)
+		}
+		if m.DescriptionSnippet != want[i].description || m.ContentSnippet != want[i].content {
+			t.Errorf("snippets of %s = %q, %q, want %q, %q", m.Template.Name,
+				m.DescriptionSnippet, m.ContentSnippet, want[i].description, want[i].content)
+		}
+	}
+
+	page, total, err := templates.Search(ctx, model.TemplateFilter{Search: "pool"}, model.PaginationParams{Page: 2, PageSize: 2})
+	if err != nil {
+		t.Fatalf("Search page 2 error: %v", err)
+	}
+	if total != 3 || len(page) != 1 || page[0].Template.Name != "queue" {
+		t.Errorf("page 2 = %d matches of %d", len(page), total)
+	}
+}
@@ -0,0 +1,523 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+	"github.com/lib/pq"
+)
+
+const webhookColumns = `w.id, w.name, w.url, w.secret, w.environment_id, COALESCE(e.slug, ''), w.selector,
+	w.event_types, w.active, w.disabled_reason, w.consecutive_failures, w.failing_since, w.created_by,
+	w.created_at, w.updated_at`
+
+const webhookFrom = `webhook_subscriptions w LEFT JOIN environments e ON e.id = w.environment_id`
+
+const deliveryColumns = `d.id, d.subscription_id, d.event_id, ev.type, d.status, d.attempts, d.next_attempt_at,
+	d.last_response_code, d.last_error, d.redelivery_of, d.created_at, d.updated_at`
+
+// DueDelivery is a pending delivery with what is needed to send it. The
+// secret of the subscription is in Secret or sealed in SecretEnvelope.
+type DueDelivery struct {
+	model.WebhookDelivery
+	URL            string
+	Secret         string
+	SecretEnvelope model.JSONMap
+	Payload        model.WebhookPayload
+}
+
+// WebhookRepository provides access to webhook subscriptions, the event
+// outbox and deliveries
+type WebhookRepository struct {
+	db DBTX
+}
+
+// NewWebhookRepository creates a new webhook repository
+func NewWebhookRepository(db *database.Connection) *WebhookRepository {
+	return &WebhookRepository{db: db.DB}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *WebhookRepository) WithTx(tx *sql.Tx) *WebhookRepository {
+	return &WebhookRepository{db: tx}
+}
+
+// GetByID returns a webhook subscription
+func (r *WebhookRepository) GetByID(ctx context.Context, id int64) (*model.WebhookSubscription, error) {
+	w, err := scanWebhook(r.db.QueryRowContext(ctx,
+		`SELECT `+webhookColumns+` FROM `+webhookFrom+` WHERE w.id = $1`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get webhook %d: %w", id, err)
+	}
+	return w, nil
+}
+
+// List returns all webhook subscriptions ordered by name
+func (r *WebhookRepository) List(ctx context.Context) ([]model.WebhookSubscription, error) {
+	return r.list(ctx, `SELECT `+webhookColumns+` FROM `+webhookFrom+` ORDER BY w.name`)
+}
+
+// ListActive returns the active webhook subscriptions ordered by ID
+func (r *WebhookRepository) ListActive(ctx context.Context) ([]model.WebhookSubscription, error) {
+	return r.list(ctx, `SELECT `+webhookColumns+` FROM `+webhookFrom+` WHERE w.active ORDER BY w.id`)
+}
+
+// ListSealed locks and returns the webhook subscriptions whose secret is
+// sealed, ordered by ID
+func (r *WebhookRepository) ListSealed(ctx context.Context) ([]model.WebhookSubscription, error) {
+	return r.list(ctx, `SELECT `+webhookColumns+` FROM `+webhookFrom+`
+		WHERE jsonb_typeof(w.secret) = 'object' ORDER BY w.id FOR UPDATE OF w`)
+}
+
+// ListSelecting locks and returns the webhook subscriptions with a tag
+// selector, ordered by ID
+func (r *WebhookRepository) ListSelecting(ctx context.Context) ([]model.WebhookSubscription, error) {
+	return r.list(ctx, `SELECT `+webhookColumns+` FROM `+webhookFrom+`
+		WHERE w.selector <> '' ORDER BY w.id FOR UPDATE OF w`)
+}
+
+func (r *WebhookRepository) list(ctx context.Context, query string) ([]model.WebhookSubscription, error) {
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var webhooks []model.WebhookSubscription
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, *w)
+	}
+	return webhooks, rows.Err()
+}
+
+// Create inserts a webhook subscription and fills its ID and timestamps
+func (r *WebhookRepository) Create(ctx context.Context, w *model.WebhookSubscription) error {
+	secret, err := storedSecret(w.Secret, w.SecretEnvelope)
+	if err != nil {
+		return err
+	}
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO webhook_subscriptions (name, url, secret, environment_id, selector, event_types, active, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at`,
+		w.Name, w.URL, secret, w.EnvironmentID, w.Selector, pq.Array(eventTypes(w)), w.Active, w.CreatedBy,
+	).Scan(&w.ID, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook %q: %w", w.Name, err)
+	}
+	return nil
+}
+
+// Update overwrites the mutable fields of a webhook subscription, including
+// its failure state
+func (r *WebhookRepository) Update(ctx context.Context, w *model.WebhookSubscription) error {
+	secret, err := storedSecret(w.Secret, w.SecretEnvelope)
+	if err != nil {
+		return err
+	}
+	err = r.db.QueryRowContext(ctx, `
+		UPDATE webhook_subscriptions
+		SET name = $2, url = $3, secret = $4, environment_id = $5, selector = $6, event_types = $7,
+			active = $8, disabled_reason = $9, consecutive_failures = $10, failing_since = $11
+		WHERE id = $1
+		RETURNING updated_at`,
+		w.ID, w.Name, w.URL, secret, w.EnvironmentID, w.Selector, pq.Array(eventTypes(w)),
+		w.Active, w.DisabledReason, w.ConsecutiveFailures, w.FailingSince,
+	).Scan(&w.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to update webhook %d: %w", w.ID, err)
+	}
+	return nil
+}
+
+// UpdateSelector replaces the tag selector of a webhook subscription. It is
+// meant for following tags that are renamed or merged.
+func (r *WebhookRepository) UpdateSelector(ctx context.Context, id int64, selector string) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE webhook_subscriptions SET selector = $2 WHERE id = $1`, id, selector)
+	if err != nil {
+		return fmt.Errorf("failed to update selector of webhook %d: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UpdateSecretEnvelope replaces the sealed secret of a webhook subscription.
+// It is meant for rewrapping the secret after a master key rotation.
+func (r *WebhookRepository) UpdateSecretEnvelope(ctx context.Context, id int64, envelope model.JSONMap) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE webhook_subscriptions SET secret = $2 WHERE id = $1`, id, envelope)
+	if err != nil {
+		return fmt.Errorf("failed to update secret of webhook %d: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Delete removes a webhook subscription; its deliveries are removed by
+// cascade
+func (r *WebhookRepository) Delete(ctx context.Context, id int64) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook %d: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordSuccess ends the failure streak of a subscription
+func (r *WebhookRepository) RecordSuccess(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_subscriptions SET consecutive_failures = 0, failing_since = NULL
+		WHERE id = $1 AND (consecutive_failures > 0 OR failing_since IS NOT NULL)`, id)
+	if err != nil {
+		return fmt.Errorf("failed to record success of webhook %d: %w", id, err)
+	}
+	return nil
+}
+
+// RecordFailure extends the failure streak of a subscription and disables it
+// with reason once the streak started disableAfter ago. It reports whether
+// this call disabled the subscription.
+func (r *WebhookRepository) RecordFailure(ctx context.Context, id int64, disableAfter time.Duration, reason string) (bool, error) {
+	var disabled bool
+	err := r.db.QueryRowContext(ctx, `
+		WITH prev AS (
+			SELECT active, $2::float8 > 0
+				AND COALESCE(failing_since, NOW()) <= NOW() - $2::float8 * INTERVAL '1 second' AS expired
+			FROM webhook_subscriptions WHERE id = $1 FOR UPDATE)
+		UPDATE webhook_subscriptions w
+		SET consecutive_failures = w.consecutive_failures + 1,
+			failing_since = COALESCE(w.failing_since, NOW()),
+			active = w.active AND NOT prev.expired,
+			disabled_reason = CASE WHEN w.active AND prev.expired THEN $3 ELSE w.disabled_reason END
+		FROM prev
+		WHERE w.id = $1
+		RETURNING prev.active AND NOT w.active`,
+		id, disableAfter.Seconds(), reason,
+	).Scan(&disabled)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, ErrNotFound
+		}
+		return false, fmt.Errorf("failed to record failure of webhook %d: %w", id, err)
+	}
+	return disabled, nil
+}
+
+// CreateEvent queues an event and fills its ID and time. Events created
+// already dispatched are only delivered as written by CreateDelivery.
+func (r *WebhookRepository) CreateEvent(ctx context.Context, ev *model.WebhookEvent, dispatched bool) error {
+	tags := ev.Tags
+	if tags == nil {
+		tags = []model.Tag{}
+	}
+	tagData, err := json.Marshal(tags)
+	if err != nil {
+		return fmt.Errorf("failed to encode event tags: %w", err)
+	}
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO webhook_events (type, environment_id, template_id, tags, payload, dispatched_at)
+		VALUES ($1, $2, $3, $4, $5, CASE WHEN $6 THEN NOW() END)
+		RETURNING id, created_at`,
+		ev.Type, ev.EnvironmentID, ev.TemplateID, tagData, ev.Payload, dispatched,
+	).Scan(&ev.ID, &ev.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create %s event: %w", ev.Type, err)
+	}
+	return nil
+}
+
+// LockPending returns up to limit events not dispatched yet, oldest first,
+// locking them for the transaction
+func (r *WebhookRepository) LockPending(ctx context.Context, limit int) ([]model.WebhookEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, type, environment_id, template_id, tags, payload, created_at
+		FROM webhook_events
+		WHERE dispatched_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []model.WebhookEvent
+	for rows.Next() {
+		var ev model.WebhookEvent
+		var tags []byte
+		if err := rows.Scan(&ev.ID, &ev.Type, &ev.EnvironmentID, &ev.TemplateID, &tags, &ev.Payload,
+			&ev.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		if err := json.Unmarshal(tags, &ev.Tags); err != nil {
+			return nil, fmt.Errorf("failed to decode event tags: %w", err)
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
+
+// MarkDispatched records that events were matched against subscriptions
+func (r *WebhookRepository) MarkDispatched(ctx context.Context, ids []int64) error {
+	if _, err := r.db.ExecContext(ctx,
+		`UPDATE webhook_events SET dispatched_at = NOW() WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to mark events dispatched: %w", err)
+	}
+	return nil
+}
+
+// CreateDelivery queues a pending delivery due now and fills its ID, status
+// and timestamps
+func (r *WebhookRepository) CreateDelivery(ctx context.Context, d *model.WebhookDelivery) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, redelivery_of)
+		VALUES ($1, $2, $3)
+		RETURNING status, next_attempt_at, created_at, updated_at`,
+		d.SubscriptionID, d.EventID, d.RedeliveryOf,
+	).Scan(&d.Status, &d.NextAttemptAt, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create delivery of event %d: %w", d.EventID, err)
+	}
+	return nil
+}
+
+// GetDelivery returns a delivery of a subscription with its attempts
+func (r *WebhookRepository) GetDelivery(ctx context.Context, subscriptionID, id int64) (*model.WebhookDelivery, error) {
+	d, err := scanDelivery(r.db.QueryRowContext(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries d JOIN webhook_events ev ON ev.id = d.event_id
+		WHERE d.subscription_id = $1 AND d.id = $2`, subscriptionID, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get delivery %d: %w", id, err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT attempt, response_code, response_body, error, duration_ms, attempted_at
+		FROM webhook_delivery_attempts
+		WHERE delivery_id = $1
+		ORDER BY attempt`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attempts of delivery %d: %w", id, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a model.WebhookDeliveryAttempt
+		if err := rows.Scan(&a.Attempt, &a.ResponseCode, &a.ResponseBody, &a.Error, &a.DurationMS,
+			&a.AttemptedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan delivery attempt: %w", err)
+		}
+		d.Log = append(d.Log, a)
+	}
+	return d, rows.Err()
+}
+
+// ListDeliveries returns the latest deliveries of a subscription, newest
+// first, optionally restricted to a status
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, subscriptionID int64, status string, limit int) ([]model.WebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries d JOIN webhook_events ev ON ev.id = d.event_id
+		WHERE d.subscription_id = $1 AND ($2 = '' OR d.status = $2)
+		ORDER BY d.id DESC
+		LIMIT $3`, subscriptionID, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan delivery: %w", err)
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+// Due claims and returns up to limit pending deliveries of active
+// subscriptions whose next attempt is due, earliest first. Claimed
+// deliveries are skipped by other callers for the claim duration or until
+// an attempt is recorded.
+func (r *WebhookRepository) Due(ctx context.Context, limit int, claim time.Duration) ([]DueDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		WITH d AS (
+			UPDATE webhook_deliveries
+			SET locked_until = NOW() + $2 * INTERVAL '1 millisecond'
+			WHERE id IN (
+				SELECT d.id
+				FROM webhook_deliveries d
+				JOIN webhook_subscriptions w ON w.id = d.subscription_id
+				WHERE d.status = 'pending' AND d.next_attempt_at <= NOW() AND w.active
+					AND (d.locked_until IS NULL OR d.locked_until <= NOW())
+				ORDER BY d.next_attempt_at
+				LIMIT $1
+				FOR UPDATE OF d SKIP LOCKED)
+			RETURNING *)
+		SELECT `+deliveryColumns+`, w.url, w.secret, ev.created_at, ev.payload
+		FROM d
+		JOIN webhook_events ev ON ev.id = d.event_id
+		JOIN webhook_subscriptions w ON w.id = d.subscription_id
+		ORDER BY d.next_attempt_at`, limit, claim.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var due []DueDelivery
+	for rows.Next() {
+		var d DueDelivery
+		var secret []byte
+		if err := rows.Scan(
+			&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastResponseCode, &d.LastError, &d.RedeliveryOf, &d.CreatedAt, &d.UpdatedAt,
+			&d.URL, &secret, &d.Payload.CreatedAt, &d.Payload.Data,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan due delivery: %w", err)
+		}
+		if err := loadSecret(secret, &d.Secret, &d.SecretEnvelope); err != nil {
+			return nil, fmt.Errorf("failed to scan secret of delivery %d: %w", d.ID, err)
+		}
+		d.Payload.ID, d.Payload.Type = d.EventID, d.EventType
+		due = append(due, d)
+	}
+	return due, rows.Err()
+}
+
+// RecordAttempt logs an attempt of a delivery and stores the resulting
+// state of the delivery: its status, attempt count, next attempt and last
+// response. The claim on the delivery is released.
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, d *model.WebhookDelivery, a *model.WebhookDeliveryAttempt) error {
+	if err := r.db.QueryRowContext(ctx, `
+		INSERT INTO webhook_delivery_attempts (delivery_id, attempt, response_code, response_body, error, duration_ms)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING attempted_at`,
+		d.ID, a.Attempt, a.ResponseCode, a.ResponseBody, a.Error, a.DurationMS,
+	).Scan(&a.AttemptedAt); err != nil {
+		return fmt.Errorf("failed to log attempt of delivery %d: %w", d.ID, err)
+	}
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, next_attempt_at = COALESCE($4, next_attempt_at),
+			last_response_code = $5, last_error = $6, locked_until = NULL
+		WHERE id = $1
+		RETURNING updated_at`,
+		d.ID, d.Status, d.Attempts, d.NextAttemptAt, d.LastResponseCode, d.LastError,
+	).Scan(&d.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to update delivery %d: %w", d.ID, err)
+	}
+	return nil
+}
+
+// DeleteBefore removes the events created before a time that have no
+// pending delivery left, with their deliveries, and returns how many events
+// were removed
+func (r *WebhookRepository) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM webhook_events ev
+		WHERE ev.created_at < $1 AND ev.dispatched_at IS NOT NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM webhook_deliveries d WHERE d.event_id = ev.id AND d.status = 'pending')`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete webhook events: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// eventTypes returns the event types of a subscription for its array column
+func eventTypes(w *model.WebhookSubscription) []string {
+	if w.EventTypes == nil {
+		return []string{}
+	}
+	return w.EventTypes
+}
+
+// storedSecret encodes a signing secret for the secret column: the envelope
+// sealing it when set, or else the plaintext as a JSON string
+func storedSecret(secret string, envelope model.JSONMap) ([]byte, error) {
+	var data []byte
+	var err error
+	if envelope != nil {
+		data, err = json.Marshal(envelope)
+	} else {
+		data, err = json.Marshal(secret)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode webhook secret: %w", err)
+	}
+	return data, nil
+}
+
+// loadSecret decodes the secret column into the plaintext secret or the
+// envelope sealing it
+func loadSecret(data []byte, secret *string, envelope *model.JSONMap) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, secret)
+	}
+	return json.Unmarshal(data, envelope)
+}
+
+func scanWebhook(row rowScanner) (*model.WebhookSubscription, error) {
+	var w model.WebhookSubscription
+	var types pq.StringArray
+	var secret []byte
+	if err := row.Scan(
+		&w.ID, &w.Name, &w.URL, &secret, &w.EnvironmentID, &w.Environment, &w.Selector,
+		&types, &w.Active, &w.DisabledReason, &w.ConsecutiveFailures, &w.FailingSince, &w.CreatedBy,
+		&w.CreatedAt, &w.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if err := loadSecret(secret, &w.Secret, &w.SecretEnvelope); err != nil {
+		return nil, err
+	}
+	w.EventTypes = []string(types)
+	if w.EventTypes == nil {
+		w.EventTypes = []string{}
+	}
+	return &w, nil
+}
+
+func scanDelivery(row rowScanner) (*model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	if err := row.Scan(
+		&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.NextAttemptAt,
+		&d.LastResponseCode, &d.LastError, &d.RedeliveryOf, &d.CreatedAt, &d.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if d.Status != model.DeliveryPending {
+		d.NextAttemptAt = nil
+	}
+	return &d, nil
+}
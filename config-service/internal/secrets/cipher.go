@@ -0,0 +1,276 @@
+// Package secrets envelope-encrypts secret template values.
+//
+// Every secret value is encrypted with AES-256-GCM under a fresh random data
+// key, and the data key is encrypted (wrapped) with the master key. The
+// result replaces the plaintext value in the template's default values:
+//
+//	{"$secret": {"v": 1, "kid": "3f1a9c0e52d4b7a8", "dk": "...", "ct": "..."}}
+//
+// kid identifies the version of the master key that wrapped the data key, dk
+// is the wrapped data key and ct the encrypted JSON encoding of the value,
+// each prefixed with its nonce. Rotating the master key only rewraps dk.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// KeySize is the length of the master key and of data keys in bytes
+const KeySize = 32
+
+// envelopeVersion is the format version of the envelopes written
+const envelopeVersion = 1
+
+var (
+	// ErrNotConfigured is returned when secrets are written or read without
+	// a master key
+	ErrNotConfigured = errors.New("no master key is configured for secret values")
+	// ErrUnknownKey is returned for an envelope wrapped by a master key
+	// missing from the keyring
+	ErrUnknownKey = errors.New("secret value is encrypted with an unknown master key")
+	// ErrInvalidEnvelope is returned for a malformed envelope
+	ErrInvalidEnvelope = errors.New("invalid secret envelope")
+)
+
+// MasterKey is one version of the master key
+type MasterKey struct {
+	Version int
+	Key     []byte
+}
+
+// KeyInfo describes a master key of the keyring
+type KeyInfo struct {
+	Version int
+	ID      string
+	Primary bool
+}
+
+// Cipher seals and opens secret values with a keyring of master keys. The
+// key with the highest version is primary and wraps the data keys of new
+// values; older versions only unwrap data keys sealed before a rotation.
+type Cipher struct {
+	keys    map[string]cipher.AEAD
+	infos   []KeyInfo
+	primary string
+}
+
+// NewCipher creates a cipher from the versions of the 32 byte master key
+func NewCipher(keys []MasterKey) (*Cipher, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one master key is required")
+	}
+	sorted := slices.Clone(keys)
+	slices.SortFunc(sorted, func(a, b MasterKey) int { return a.Version - b.Version })
+
+	c := &Cipher{keys: make(map[string]cipher.AEAD, len(sorted))}
+	for i, key := range sorted {
+		if key.Version < 1 {
+			return nil, fmt.Errorf("master key version must be positive, got %d", key.Version)
+		}
+		if i > 0 && key.Version == sorted[i-1].Version {
+			return nil, fmt.Errorf("duplicate master key version %d", key.Version)
+		}
+		if len(key.Key) != KeySize {
+			return nil, fmt.Errorf("master key version %d must be %d bytes, got %d", key.Version, KeySize, len(key.Key))
+		}
+		id := KeyID(key.Key)
+		if _, dup := c.keys[id]; dup {
+			return nil, fmt.Errorf("master key version %d repeats an earlier version", key.Version)
+		}
+		aead, err := newAEAD(key.Key)
+		if err != nil {
+			return nil, err
+		}
+		c.keys[id] = aead
+		c.infos = append(c.infos, KeyInfo{Version: key.Version, ID: id})
+	}
+
+	last := len(c.infos) - 1
+	c.infos[last].Primary = true
+	c.primary = c.infos[last].ID
+	return c, nil
+}
+
+// KeyID derives the public identifier of a master key
+func KeyID(masterKey []byte) string {
+	sum := sha256.Sum256(append([]byte("config-service master key:"), masterKey...))
+	return hex.EncodeToString(sum[:8])
+}
+
+// KeyID returns the identifier of the primary master key
+func (c *Cipher) KeyID() string {
+	return c.primary
+}
+
+// Keys describes the master keys of the keyring by ascending version
+func (c *Cipher) Keys() []KeyInfo {
+	return slices.Clone(c.infos)
+}
+
+// Encrypt seals a value into an envelope under the primary master key
+func (c *Cipher) Encrypt(value interface{}) (map[string]interface{}, error) {
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode secret value: %w", err)
+	}
+
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := c.wrap(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := seal(data, plaintext, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		Marker: map[string]interface{}{
+			"v":   envelopeVersion,
+			"kid": c.primary,
+			"dk":  wrapped,
+			"ct":  base64.StdEncoding.EncodeToString(ciphertext),
+		},
+	}, nil
+}
+
+// Decrypt opens an envelope and returns the plaintext value
+func (c *Cipher) Decrypt(envelope map[string]interface{}) (interface{}, error) {
+	body, ok := envelope[Marker].(map[string]interface{})
+	if !ok {
+		return nil, ErrInvalidEnvelope
+	}
+	dataKey, err := c.unwrap(body)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := decodeField(body, "ct")
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(data, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret value: %w", err)
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(plaintext, &value); err != nil {
+		return nil, fmt.Errorf("failed to decode secret value: %w", err)
+	}
+	return value, nil
+}
+
+// Rewrap wraps the data key of an envelope sealed under an older master key
+// with the primary key; the value itself is not re-encrypted. It reports
+// whether the envelope changed.
+func (c *Cipher) Rewrap(envelope map[string]interface{}) (map[string]interface{}, bool, error) {
+	body, ok := envelope[Marker].(map[string]interface{})
+	if !ok {
+		return nil, false, ErrInvalidEnvelope
+	}
+	if kid, _ := body["kid"].(string); kid == c.primary {
+		return envelope, false, nil
+	}
+
+	dataKey, err := c.unwrap(body)
+	if err != nil {
+		return nil, false, err
+	}
+	wrapped, err := c.wrap(dataKey)
+	if err != nil {
+		return nil, false, err
+	}
+
+	rewrapped := make(map[string]interface{}, len(body))
+	for k, v := range body {
+		rewrapped[k] = v
+	}
+	rewrapped["kid"] = c.primary
+	rewrapped["dk"] = wrapped
+	return map[string]interface{}{Marker: rewrapped}, true, nil
+}
+
+// wrap encrypts a data key with the primary master key, bound to its ID
+func (c *Cipher) wrap(dataKey []byte) (string, error) {
+	wrapped, err := seal(c.keys[c.primary], dataKey, []byte(c.primary))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(wrapped), nil
+}
+
+// unwrap decrypts the data key of an envelope body with the master key it
+// names
+func (c *Cipher) unwrap(body map[string]interface{}) ([]byte, error) {
+	kid, _ := body["kid"].(string)
+	master, ok := c.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, kid)
+	}
+	wrapped, err := decodeField(body, "dk")
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := open(master, wrapped, []byte(kid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+func decodeField(body map[string]interface{}, name string) ([]byte, error) {
+	encoded, _ := body[name].(string)
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || encoded == "" {
+		return nil, fmt.Errorf("%w: bad %s", ErrInvalidEnvelope, name)
+	}
+	return data, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext, prefixing the result with a random nonce
+func seal(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// open decrypts the output of seal
+func open(aead cipher.AEAD, ciphertext, additional []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrInvalidEnvelope
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, additional)
+}
@@ -0,0 +1,102 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// keyringFile lists the versions of the master key, each given base64
+// encoded or as a file holding it:
+//
+//	keys:
+//	  - version: 1
+//	    key: q1zK...
+//	  - version: 2
+//	    key_file: /run/secrets/master-key-v2
+type keyringFile struct {
+	Keys []struct {
+		Version int    `yaml:"version"`
+		Key     string `yaml:"key"`
+		KeyFile string `yaml:"key_file"`
+	} `yaml:"keys"`
+}
+
+// LoadKeys returns the master keys configured by a single key, given base64
+// encoded in value or stored in file, or by a keyring file listing
+// versioned keys. A single key is version 1. It returns nil when nothing is
+// configured.
+func LoadKeys(value, file, keyring string) ([]MasterKey, error) {
+	set := 0
+	for _, s := range []string{value, file, keyring} {
+		if s != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, errors.New("master key, master key file and keyring file are mutually exclusive")
+	}
+	if keyring != "" {
+		return loadKeyring(keyring)
+	}
+	if value == "" && file == "" {
+		return nil, nil
+	}
+
+	key, err := readKey(value, file)
+	if err != nil {
+		return nil, err
+	}
+	return []MasterKey{{Version: 1, Key: key}}, nil
+}
+
+func loadKeyring(path string) ([]MasterKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring file: %w", err)
+	}
+	var ring keyringFile
+	if err := yaml.Unmarshal(data, &ring); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring file: %w", err)
+	}
+	if len(ring.Keys) == 0 {
+		return nil, errors.New("keyring file lists no keys")
+	}
+
+	keys := make([]MasterKey, 0, len(ring.Keys))
+	for _, entry := range ring.Keys {
+		if (entry.Key == "") == (entry.KeyFile == "") {
+			return nil, fmt.Errorf("master key version %d needs exactly one of key and key_file", entry.Version)
+		}
+		key, err := readKey(entry.Key, entry.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("master key version %d: %w", entry.Version, err)
+		}
+		keys = append(keys, MasterKey{Version: entry.Version, Key: key})
+	}
+	return keys, nil
+}
+
+// readKey decodes a base64 key, or reads it raw or base64 encoded from file
+func readKey(value, file string) ([]byte, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read master key file: %w", err)
+		}
+		if len(data) == KeySize {
+			return data, nil
+		}
+		value = strings.TrimSpace(string(data))
+	}
+
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("master key is not valid base64: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,456 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/company/config-service/internal/model"
+)
+
+func newTestCipher(t *testing.T, versions ...int) *Cipher {
+	t.Helper()
+	keys := make([]MasterKey, len(versions))
+	for i, v := range versions {
+		keys[i] = MasterKey{Version: v, Key: bytes.Repeat([]byte{byte(v)}, KeySize)}
+	}
+	c, err := NewCipher(keys)
+	if err != nil {
+		t.Fatalf("NewCipher error: %v", err)
+	}
+	return c
+}
+
+func TestNewCipher(t *testing.T) {
+	key := func(b byte) []byte { return bytes.Repeat([]byte{b}, KeySize) }
+	tests := []struct {
+		name string
+		keys []MasterKey
+		err  string
+	}{
+		{name: "none", err: "at least one master key is required"},
+		{name: "short", keys: []MasterKey{{Version: 1, Key: []byte("short")}}, err: "must be 32 bytes, got 5"},
+		{name: "version zero", keys: []MasterKey{{Version: 0, Key: key(1)}}, err: "version must be positive"},
+		{name: "duplicate version", keys: []MasterKey{{Version: 1, Key: key(1)}, {Version: 1, Key: key(2)}}, err: "duplicate master key version 1"},
+		{name: "repeated key", keys: []MasterKey{{Version: 1, Key: key(1)}, {Version: 2, Key: key(1)}}, err: "repeats an earlier version"},
+		{name: "keyring", keys: []MasterKey{{Version: 2, Key: key(2)}, {Version: 1, Key: key(1)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewCipher(tt.keys)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("NewCipher error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewCipher error: %v", err)
+			}
+			// The highest version is primary whatever the order given
+			if c.KeyID() != KeyID(key(2)) {
+				t.Errorf("primary key = %s, want version 2", c.KeyID())
+			}
+			infos := c.Keys()
+			if len(infos) != 2 || infos[0].Version != 1 || infos[0].Primary || !infos[1].Primary {
+				t.Errorf("Keys = %+v", infos)
+			}
+		})
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	c := newTestCipher(t, 1)
+	values := []interface{}{"hunter2", 42.0, true, nil, map[string]interface{}{"user": "app"}, []interface{}{"a", "b"}}
+	for _, value := range values {
+		envelope, err := c.Encrypt(value)
+		if err != nil {
+			t.Fatalf("Encrypt(%v) error: %v", value, err)
+		}
+		if !IsEnvelope(envelope) {
+			t.Fatalf("Encrypt(%v) = %v, not an envelope", value, envelope)
+		}
+		got, err := c.Decrypt(envelope)
+		if err != nil {
+			t.Fatalf("Decrypt error: %v", err)
+		}
+		if !reflect.DeepEqual(got, value) {
+			t.Errorf("Decrypt = %v, want %v", got, value)
+		}
+	}
+
+	a, _ := c.Encrypt("same")
+	b, _ := c.Encrypt("same")
+	if reflect.DeepEqual(a, b) {
+		t.Error("encrypting a value twice gave the same envelope")
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	c := newTestCipher(t, 1)
+	envelope, err := c.Encrypt("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := envelope[Marker].(map[string]interface{})
+	with := func(field string, value interface{}) map[string]interface{} {
+		changed := make(map[string]interface{}, len(body))
+		for k, v := range body {
+			changed[k] = v
+		}
+		changed[field] = value
+		return map[string]interface{}{Marker: changed}
+	}
+	other, _ := newTestCipher(t, 2).Encrypt("hunter2")
+
+	tests := []struct {
+		name     string
+		envelope map[string]interface{}
+		want     error
+	}{
+		{"not an envelope", map[string]interface{}{"value": "x"}, ErrInvalidEnvelope},
+		{"unknown key", other, ErrUnknownKey},
+		{"bad data key", with("dk", "!!"), ErrInvalidEnvelope},
+		{"bad ciphertext", with("ct", ""), ErrInvalidEnvelope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c.Decrypt(tt.envelope); !errors.Is(err, tt.want) {
+				t.Errorf("Decrypt error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	tampered := with("ct", body["dk"])
+	if _, err := c.Decrypt(tampered); err == nil {
+		t.Error("Decrypt accepted a tampered ciphertext")
+	}
+
+	// The data key is bound to the ID of the master key that wrapped it
+	rotated := newTestCipher(t, 1, 2)
+	if _, err := rotated.Decrypt(with("kid", rotated.KeyID())); err == nil {
+		t.Error("Decrypt accepted a data key under another key ID")
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	old := newTestCipher(t, 1)
+	rotated := newTestCipher(t, 1, 2)
+
+	values := model.JSONMap{"plain": "x"}
+	for _, name := range []string{"password", "token"} {
+		envelope, err := old.Encrypt(name + "-value")
+		if err != nil {
+			t.Fatal(err)
+		}
+		values[name] = envelope
+	}
+	values["nested"] = map[string]interface{}{"list": []interface{}{values["token"]}}
+
+	rewrapped, n, err := rotated.RewrapValues(values)
+	if err != nil {
+		t.Fatalf("RewrapValues error: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("rewrapped %d envelopes, want 3", n)
+	}
+	if _, n, _ := rotated.RewrapValues(rewrapped); n != 0 {
+		t.Errorf("rewrapping again rewrapped %d envelopes", n)
+	}
+
+	// The ciphertext is kept and only opens with the new primary key
+	before := values["password"].(map[string]interface{})[Marker].(map[string]interface{})
+	after := rewrapped["password"].(map[string]interface{})[Marker].(map[string]interface{})
+	if after["ct"] != before["ct"] || after["kid"] != rotated.KeyID() {
+		t.Errorf("rewrapped envelope = %v", after)
+	}
+	if _, err := newTestCipher(t, 2).Reveal(rewrapped); err != nil {
+		t.Errorf("Reveal with the new key only: %v", err)
+	}
+
+	revealed, err := rotated.Reveal(rewrapped)
+	if err != nil {
+		t.Fatalf("Reveal error: %v", err)
+	}
+	want := model.JSONMap{
+		"plain":    "x",
+		"password": "password-value",
+		"token":    "token-value",
+		"nested":   map[string]interface{}{"list": []interface{}{"token-value"}},
+	}
+	if !reflect.DeepEqual(revealed, want) {
+		t.Errorf("Reveal = %v, want %v", revealed, want)
+	}
+}
+
+func TestRevealWithoutCipher(t *testing.T) {
+	var c *Cipher
+	plain := model.JSONMap{"a": "b"}
+	if got, err := c.Reveal(plain); err != nil || !reflect.DeepEqual(got, plain) {
+		t.Errorf("Reveal = %v, %v", got, err)
+	}
+	envelope, _ := newTestCipher(t, 1).Encrypt("x")
+	if _, err := c.Reveal(model.JSONMap{"a": envelope}); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("Reveal error = %v, want %v", err, ErrNotConfigured)
+	}
+}
+
+func TestMasked(t *testing.T) {
+	envelope, _ := newTestCipher(t, 1).Encrypt("x")
+	values := model.JSONMap{"a": "b", "db": map[string]interface{}{"password": envelope}}
+	want := model.JSONMap{"a": "b", "db": map[string]interface{}{"password": Mask}}
+	if got := Masked(values); !reflect.DeepEqual(got, want) {
+		t.Errorf("Masked = %v, want %v", got, want)
+	}
+	if !IsEnvelope(values["db"].(map[string]interface{})["password"]) {
+		t.Error("Masked changed its argument")
+	}
+}
+
+func TestPaths(t *testing.T) {
+	schema := model.JSONMap{
+		"properties": map[string]interface{}{
+			"name":  map[string]interface{}{"type": "string"},
+			"token": map[string]interface{}{"type": "string", "secret": true},
+			"db": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"host":     map[string]interface{}{"type": "string"},
+					"password": map[string]interface{}{"type": "string", "secret": true},
+				},
+			},
+		},
+	}
+	got := Paths(schema)
+	want := [][]string{{"db", "password"}, {"token"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Paths = %v, want %v", got, want)
+	}
+}
+
+func TestSeal(t *testing.T) {
+	c := newTestCipher(t, 1)
+	schema := model.JSONMap{
+		"properties": map[string]interface{}{
+			"token": map[string]interface{}{"secret": true},
+			"db": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"password": map[string]interface{}{"secret": true},
+				},
+			},
+		},
+	}
+	stored, err := c.Encrypt("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stray, _ := c.Encrypt("stray")
+	previous := model.JSONMap{"token": stored, "name": "app"}
+
+	tests := []struct {
+		name     string
+		noCipher bool
+		schema   model.JSONMap
+		values   model.JSONMap
+		previous model.JSONMap
+		// want maps paths to the plaintext expected, or to Mask for a
+		// missing value
+		want map[string]interface{}
+		err  string
+	}{
+		{
+			name:   "plaintext",
+			values: model.JSONMap{"token": "t", "db": map[string]interface{}{"password": "p"}, "name": "app"},
+			want:   map[string]interface{}{"token": "t", "db.password": "p", "name": "app"},
+		},
+		{
+			name:     "mask keeps previous",
+			values:   model.JSONMap{"token": Mask},
+			previous: previous,
+			want:     map[string]interface{}{"token": "old"},
+		},
+		{
+			name:   "mask without previous",
+			values: model.JSONMap{"token": Mask},
+			want:   map[string]interface{}{"token": Mask},
+		},
+		{
+			name:     "unchanged envelope",
+			values:   model.JSONMap{"token": stored},
+			previous: previous,
+			want:     map[string]interface{}{"token": "old"},
+		},
+		{
+			name:   "new envelope",
+			values: model.JSONMap{"token": stored},
+			err:    "secret token: encrypted values cannot be written directly",
+		},
+		{
+			name:     "envelope outside secret fields",
+			values:   model.JSONMap{"token": "t", "db": map[string]interface{}{"host": stray}},
+			previous: previous,
+			err:      "secret db.host: encrypted values are only allowed in fields marked secret",
+		},
+		{
+			name:   "envelope in a list",
+			values: model.JSONMap{"hosts": []interface{}{"a", stray}},
+			err:    "secret hosts.1: encrypted values are only allowed in fields marked secret",
+		},
+		{
+			name:   "envelope without secret fields",
+			schema: model.JSONMap{"type": "object"},
+			values: model.JSONMap{"token": stray},
+			err:    "secret token: encrypted values are only allowed in fields marked secret",
+		},
+		{
+			name:     "no cipher",
+			noCipher: true,
+			values:   model.JSONMap{"token": "t"},
+			err:      "secret token: " + ErrNotConfigured.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cipher := c
+			if tt.noCipher {
+				cipher = nil
+			}
+			s := schema
+			if tt.schema != nil {
+				s = tt.schema
+			}
+			sealed, err := cipher.Seal(tt.values, s, tt.previous)
+			if tt.err != "" {
+				var secretErr *Error
+				if !errors.As(err, &secretErr) || err.Error() != tt.err {
+					t.Fatalf("Seal error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Seal error: %v", err)
+			}
+			for _, p := range Paths(schema) {
+				if v, ok := get(sealed, p); ok && !IsEnvelope(v) {
+					t.Errorf("%s was not sealed: %v", strings.Join(p, "."), v)
+				}
+			}
+			revealed, err := c.Reveal(sealed)
+			if err != nil {
+				t.Fatalf("Reveal error: %v", err)
+			}
+			for path, want := range tt.want {
+				got, ok := get(revealed, strings.Split(path, "."))
+				if want == Mask {
+					if ok {
+						t.Errorf("%s = %v, want it dropped", path, got)
+					}
+				} else if got != want {
+					t.Errorf("%s = %v, want %v", path, got, want)
+				}
+			}
+		})
+	}
+
+	values := model.JSONMap{"token": "t"}
+	if _, err := c.Seal(values, schema, nil); err != nil {
+		t.Fatal(err)
+	}
+	if values["token"] != "t" {
+		t.Error("Seal changed its argument")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	c := newTestCipher(t, 1)
+	schema := model.JSONMap{
+		"properties": map[string]interface{}{
+			"token": map[string]interface{}{"secret": true},
+		},
+	}
+	token, _ := c.Encrypt("t")
+	stray, _ := c.Encrypt("stray")
+	foreign, _ := newTestCipher(t, 2).Encrypt("t")
+
+	tests := []struct {
+		name     string
+		noCipher bool
+		values   model.JSONMap
+		want     model.JSONMap
+		err      string
+	}{
+		{
+			name:   "secret field",
+			values: model.JSONMap{"token": token, "name": "app"},
+			want:   model.JSONMap{"token": "t", "name": "app"},
+		},
+		{
+			name:   "envelope outside secret fields is kept",
+			values: model.JSONMap{"host": stray},
+			want:   model.JSONMap{"host": stray},
+		},
+		{
+			name:   "unknown master key",
+			values: model.JSONMap{"token": foreign},
+			err:    "secret token: " + ErrUnknownKey.Error(),
+		},
+		{
+			name:     "no cipher",
+			noCipher: true,
+			values:   model.JSONMap{"token": token},
+			err:      "secret token: " + ErrNotConfigured.Error(),
+		},
+		{
+			name:     "no envelopes without cipher",
+			noCipher: true,
+			values:   model.JSONMap{"token": "t"},
+			want:     model.JSONMap{"token": "t"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cipher := c
+			if tt.noCipher {
+				cipher = nil
+			}
+			got, err := cipher.Open(tt.values, schema)
+			if tt.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+					t.Fatalf("Open error = %v, want prefix %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Open error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Open = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnrestorable(t *testing.T) {
+	schema := model.JSONMap{
+		"properties": map[string]interface{}{
+			"token": map[string]interface{}{"secret": true},
+			"db": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"password": map[string]interface{}{"secret": true},
+				},
+			},
+		},
+	}
+	stored, _ := newTestCipher(t, 1).Encrypt("old")
+	values := model.JSONMap{"token": Mask, "db": map[string]interface{}{"password": Mask}, "name": Mask}
+
+	if got, want := Unrestorable(values, schema, nil), []string{"db.password", "token"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unrestorable = %v, want %v", got, want)
+	}
+	previous := model.JSONMap{"token": stored, "db": map[string]interface{}{"password": "plain"}}
+	if got, want := Unrestorable(values, schema, previous), []string{"db.password"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unrestorable with previous = %v, want %v", got, want)
+	}
+}
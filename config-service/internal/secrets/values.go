@@ -0,0 +1,355 @@
+package secrets
+
+import (
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/company/config-service/internal/model"
+)
+
+// Marker is the key of an envelope object
+const Marker = "$secret"
+
+// Mask replaces secret values in API responses. Writing it back as the value
+// of a secret field keeps the stored value.
+const Mask = "******"
+
+// Error describes a secret field that cannot be sealed
+type Error struct {
+	Path string
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return "secret " + e.Path + ": " + e.Msg
+}
+
+// Paths returns the paths of the properties marked "secret": true in a JSON
+// schema, descending into nested object properties
+func Paths(schema model.JSONMap) [][]string {
+	var paths [][]string
+	var walk func(node map[string]interface{}, prefix []string)
+	walk = func(node map[string]interface{}, prefix []string) {
+		props, _ := node["properties"].(map[string]interface{})
+		for name, p := range props {
+			prop, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			path := append(append([]string(nil), prefix...), name)
+			if secret, _ := prop["secret"].(bool); secret {
+				paths = append(paths, path)
+				continue
+			}
+			walk(prop, path)
+		}
+	}
+	walk(schema, nil)
+
+	sort.Slice(paths, func(i, j int) bool {
+		return strings.Join(paths[i], ".") < strings.Join(paths[j], ".")
+	})
+	return paths
+}
+
+// IsEnvelope reports whether a value is an encrypted secret
+func IsEnvelope(v interface{}) bool {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return false
+	}
+	_, ok = m[Marker].(map[string]interface{})
+	return ok
+}
+
+// Contains reports whether values hold any encrypted secret
+func Contains(values model.JSONMap) bool {
+	return contains(map[string]interface{}(values))
+}
+
+func contains(v interface{}) bool {
+	if IsEnvelope(v) {
+		return true
+	}
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for _, item := range x {
+			if contains(item) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range x {
+			if contains(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Masked returns values with every encrypted secret replaced by Mask. values
+// is returned as is when it holds no secret.
+func Masked(values model.JSONMap) model.JSONMap {
+	if !Contains(values) {
+		return values
+	}
+	return MaskValue(map[string]interface{}(values)).(map[string]interface{})
+}
+
+// MaskValue returns v with every encrypted secret replaced by Mask
+func MaskValue(v interface{}) interface{} {
+	out, _ := transform(v, func(map[string]interface{}) (interface{}, error) {
+		return Mask, nil
+	})
+	return out
+}
+
+// Reveal returns values with every encrypted secret decrypted
+func (c *Cipher) Reveal(values model.JSONMap) (model.JSONMap, error) {
+	if !Contains(values) {
+		return values, nil
+	}
+	if c == nil {
+		return nil, ErrNotConfigured
+	}
+	out, err := transform(map[string]interface{}(values), c.Decrypt)
+	if err != nil {
+		return nil, err
+	}
+	return out.(map[string]interface{}), nil
+}
+
+// RewrapValues rewraps the data keys of every envelope in values sealed
+// under an older master key, returning the values and the number of
+// envelopes rewrapped
+func (c *Cipher) RewrapValues(values model.JSONMap) (model.JSONMap, int, error) {
+	if !Contains(values) {
+		return values, 0, nil
+	}
+	rewrapped := 0
+	out, err := transform(map[string]interface{}(values), func(e map[string]interface{}) (interface{}, error) {
+		envelope, changed, err := c.Rewrap(e)
+		if changed {
+			rewrapped++
+		}
+		return envelope, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return out.(map[string]interface{}), rewrapped, nil
+}
+
+// transform copies v, replacing envelopes with the result of fn
+func transform(v interface{}, fn func(map[string]interface{}) (interface{}, error)) (interface{}, error) {
+	if IsEnvelope(v) {
+		return fn(v.(map[string]interface{}))
+	}
+	switch x := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(x))
+		for key, item := range x {
+			value, err := transform(item, fn)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = value
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, item := range x {
+			value, err := transform(item, fn)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = value
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// Seal encrypts the plaintext values of the secret fields declared by schema.
+// previous holds the stored values being replaced, if any: a field set to
+// Mask keeps its previous envelope, or is dropped when there is none, and an
+// envelope is only accepted unchanged from previous. Envelopes outside the
+// secret fields are rejected. The cipher may be nil, in which case only
+// values without plaintext secrets can be sealed.
+func (c *Cipher) Seal(values, schema, previous model.JSONMap) (model.JSONMap, error) {
+	if values == nil {
+		return values, nil
+	}
+	paths := Paths(schema)
+	if len(paths) == 0 {
+		if err := checkEnvelopes(values, nil, nil); err != nil {
+			return nil, err
+		}
+		return values, nil
+	}
+
+	// Copy values so that the caller's map is left untouched
+	out, _ := transform(map[string]interface{}(values), func(e map[string]interface{}) (interface{}, error) {
+		return e, nil
+	})
+	sealed := out.(map[string]interface{})
+
+	for _, path := range paths {
+		name := strings.Join(path, ".")
+		value, ok := get(sealed, path)
+		if !ok || value == nil {
+			continue
+		}
+		before, hadBefore := get(previous, path)
+
+		switch {
+		case value == Mask:
+			if hadBefore && IsEnvelope(before) {
+				set(sealed, path, before)
+			} else {
+				remove(sealed, path)
+			}
+		case IsEnvelope(value):
+			if !hadBefore || !reflect.DeepEqual(value, before) {
+				return nil, &Error{Path: name, Msg: "encrypted values cannot be written directly"}
+			}
+		default:
+			if c == nil {
+				return nil, &Error{Path: name, Msg: ErrNotConfigured.Error()}
+			}
+			envelope, err := c.Encrypt(value)
+			if err != nil {
+				return nil, err
+			}
+			set(sealed, path, envelope)
+		}
+	}
+	if err := checkEnvelopes(sealed, nil, paths); err != nil {
+		return nil, err
+	}
+	return sealed, nil
+}
+
+// Open decrypts the envelopes found at the secret fields declared by schema,
+// so that values exported with their envelopes from a store sharing the
+// keyring can be sealed again. Envelopes elsewhere are left in place for Seal
+// to reject.
+func (c *Cipher) Open(values, schema model.JSONMap) (model.JSONMap, error) {
+	if !Contains(values) {
+		return values, nil
+	}
+	out, _ := transform(map[string]interface{}(values), func(e map[string]interface{}) (interface{}, error) {
+		return e, nil
+	})
+	opened := out.(map[string]interface{})
+
+	for _, path := range Paths(schema) {
+		value, ok := get(opened, path)
+		if !ok || !IsEnvelope(value) {
+			continue
+		}
+		if c == nil {
+			return nil, &Error{Path: strings.Join(path, "."), Msg: ErrNotConfigured.Error()}
+		}
+		plaintext, err := c.Decrypt(value.(map[string]interface{}))
+		if err != nil {
+			return nil, &Error{Path: strings.Join(path, "."), Msg: err.Error()}
+		}
+		set(opened, path, plaintext)
+	}
+	return opened, nil
+}
+
+// Unrestorable returns the secret fields of values set to Mask that have no
+// envelope in previous to keep, which Seal drops
+func Unrestorable(values, schema, previous model.JSONMap) []string {
+	var names []string
+	for _, path := range Paths(schema) {
+		if value, ok := get(values, path); !ok || value != Mask {
+			continue
+		}
+		if before, ok := get(previous, path); ok && IsEnvelope(before) {
+			continue
+		}
+		names = append(names, strings.Join(path, "."))
+	}
+	return names
+}
+
+// checkEnvelopes returns an error for the first envelope in v found at a
+// path other than one of the secret paths
+func checkEnvelopes(v interface{}, path []string, secretPaths [][]string) error {
+	if IsEnvelope(v) {
+		for _, p := range secretPaths {
+			if slices.Equal(p, path) {
+				return nil
+			}
+		}
+		return &Error{Path: strings.Join(path, "."), Msg: "encrypted values are only allowed in fields marked secret"}
+	}
+	switch x := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for key := range x {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := checkEnvelopes(x[key], append(path[:len(path):len(path)], key), secretPaths); err != nil {
+				return err
+			}
+		}
+	case model.JSONMap:
+		return checkEnvelopes(map[string]interface{}(x), path, secretPaths)
+	case []interface{}:
+		for i, item := range x {
+			if err := checkEnvelopes(item, append(path[:len(path):len(path)], strconv.Itoa(i)), secretPaths); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func get(values map[string]interface{}, path []string) (interface{}, bool) {
+	var current interface{} = values
+	for _, key := range path {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// parent returns the object holding the last key of path
+func parent(values map[string]interface{}, path []string) (map[string]interface{}, bool) {
+	current := values
+	for _, key := range path[:len(path)-1] {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current = next
+	}
+	return current, true
+}
+
+func set(values map[string]interface{}, path []string, value interface{}) {
+	if m, ok := parent(values, path); ok {
+		m[path[len(path)-1]] = value
+	}
+}
+
+func remove(values map[string]interface{}, path []string) {
+	if m, ok := parent(values, path); ok {
+		delete(m, path[len(path)-1])
+	}
+}
@@ -0,0 +1,259 @@
+package selector
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/company/config-service/internal/model"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokTag
+	tokLabel
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value string
+	pos   int
+}
+
+// Parse parses a selector. An empty or blank selector yields a nil Expr,
+// which matches every template.
+func Parse(input string) (Expr, error) {
+	if len(input) > maxLength {
+		return nil, &Error{Pos: maxLength, Msg: "selector is too long"}
+	}
+
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 1 {
+		return nil, nil
+	}
+
+	p := &parser{tokens: tokens}
+	expr, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, &Error{Pos: tok.pos, Msg: "unexpected " + describe(tok)}
+	}
+	return expr, nil
+}
+
+// isBare reports whether a tag name can be written without quotes
+func isBare(name string) bool {
+	if name == "" || isKeyword(name) {
+		return false
+	}
+	for _, r := range name {
+		if !isBareRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isBareRune(r rune) bool {
+	return !unicode.IsSpace(r) && r != '(' && r != ')' && r != '"' && r != '\''
+}
+
+func isKeyword(word string) bool {
+	switch strings.ToUpper(word) {
+	case "AND", "OR", "NOT":
+		return true
+	}
+	return false
+}
+
+func lex(input string) ([]token, error) {
+	var tokens []token
+	terms := 0
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case r == '"' || r == '\'':
+			start := i
+			name, next, err := lexQuoted(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			i = next
+			tokens = append(tokens, token{kind: tokTag, text: name, pos: start})
+			terms++
+		default:
+			start := i
+			for i < len(runes) && isBareRune(runes[i]) {
+				i++
+			}
+			word := string(runes[start:i])
+			switch strings.ToUpper(word) {
+			case "AND":
+				tokens = append(tokens, token{kind: tokAnd, text: word, pos: start})
+			case "OR":
+				tokens = append(tokens, token{kind: tokOr, text: word, pos: start})
+			case "NOT":
+				tokens = append(tokens, token{kind: tokNot, text: word, pos: start})
+			default:
+				tok := token{kind: tokTag, text: word, pos: start}
+				if key, value, isLabel := strings.Cut(word, model.LabelSeparator); isLabel {
+					if value == "" && i < len(runes) && (runes[i] == '"' || runes[i] == '\'') {
+						quoted, next, err := lexQuoted(runes, i)
+						if err != nil {
+							return nil, err
+						}
+						value, i = quoted, next
+					}
+					if key == "" || value == "" {
+						return nil, &Error{Pos: start, Msg: "label " + word + " needs a key and a value"}
+					}
+					tok = token{kind: tokLabel, text: key, value: value, pos: start}
+				}
+				tokens = append(tokens, tok)
+				terms++
+			}
+		}
+
+		if terms > maxTerms {
+			return nil, &Error{Pos: i, Msg: "selector references too many tags"}
+		}
+	}
+
+	return append(tokens, token{kind: tokEOF, pos: len(runes)}), nil
+}
+
+// lexQuoted reads the quoted string starting at runes[start], returning its
+// unescaped content and the index following the closing quote
+func lexQuoted(runes []rune, start int) (string, int, error) {
+	quote := runes[start]
+	var b strings.Builder
+	i := start + 1
+	for ; i < len(runes) && runes[i] != quote; i++ {
+		if runes[i] == '\\' && i+1 < len(runes) {
+			i++
+		}
+		b.WriteRune(runes[i])
+	}
+	if i >= len(runes) {
+		return "", 0, &Error{Pos: start, Msg: "unterminated quoted string"}
+	}
+	if b.Len() == 0 {
+		return "", 0, &Error{Pos: start, Msg: "empty quoted string"}
+	}
+	return b.String(), i + 1, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// parseOr parses: and { OR and }
+func (p *parser) parseOr(depth int) (Expr, error) {
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		p.next()
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = Or{L: left, R: right}
+	}
+	return left, nil
+}
+
+// parseAnd parses: unary { AND unary }
+func (p *parser) parseAnd(depth int) (Expr, error) {
+	left, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokAnd {
+		p.next()
+		right, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = And{L: left, R: right}
+	}
+	return left, nil
+}
+
+// parseUnary parses: NOT unary | ( or ) | tag
+func (p *parser) parseUnary(depth int) (Expr, error) {
+	if depth > maxDepth {
+		return nil, &Error{Pos: p.peek().pos, Msg: "selector is nested too deeply"}
+	}
+
+	tok := p.next()
+	switch tok.kind {
+	case tokNot:
+		x, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return Not{X: x}, nil
+	case tokLParen:
+		x, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, &Error{Pos: closing.pos, Msg: "expected ) but found " + describe(closing)}
+		}
+		return x, nil
+	case tokTag:
+		return Tag{Name: tok.text}, nil
+	case tokLabel:
+		return Label{Key: tok.text, Value: tok.value}, nil
+	default:
+		return nil, &Error{Pos: tok.pos, Msg: "expected tag name but found " + describe(tok)}
+	}
+}
+
+func describe(tok token) string {
+	switch tok.kind {
+	case tokEOF:
+		return "end of selector"
+	case tokLabel:
+		return "'" + tok.text + model.LabelSeparator + tok.value + "'"
+	}
+	return "'" + tok.text + "'"
+}
@@ -0,0 +1,310 @@
+// Package selector implements the tag selector language used to filter
+// templates by their tags, for example `database AND NOT deprecated` or
+// `(api OR monitoring) AND team=payments`.
+//
+// Operators are AND, OR and NOT (case-insensitive) with NOT binding tightest
+// and OR loosest; parentheses group. Tag names containing whitespace,
+// parentheses or quotes are written in double or single quotes.
+//
+// A bare term of the form key=value matches label tags by key and value, and
+// key=* matches any value of the key. Label values may be quoted, as in
+// team="payments core". A quoted "key=value" is matched as a plain tag name.
+package selector
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/company/config-service/internal/model"
+)
+
+// Limits guarding against pathological selectors
+const (
+	maxLength = 2048
+	maxTerms  = 64
+	maxDepth  = 32
+)
+
+// Expr is a parsed selector expression
+type Expr interface {
+	// Matches reports whether a template carrying the given tags is selected.
+	// Only the tags themselves are compared; to honour the hierarchy as SQL
+	// does, include the ancestors of every tag.
+	Matches(tags []model.Tag) bool
+	// String returns the canonical form of the expression
+	String() string
+	// sql appends the expression as a boolean SQL condition on idColumn
+	sql(b *strings.Builder, idColumn string, arg func(interface{}) string)
+}
+
+// Tag matches templates carrying the named tag
+type Tag struct {
+	Name string
+}
+
+// AnyValue is the label value matching every value of a key
+const AnyValue = "*"
+
+// Label matches templates carrying a label tag with the given key and value,
+// or any value of the key when Value is AnyValue
+type Label struct {
+	Key   string
+	Value string
+}
+
+// Not negates an expression
+type Not struct {
+	X Expr
+}
+
+// And matches when both operands match
+type And struct {
+	L, R Expr
+}
+
+// Or matches when either operand matches
+type Or struct {
+	L, R Expr
+}
+
+// Matches implements Expr
+func (t Tag) Matches(tags []model.Tag) bool {
+	for _, tag := range tags {
+		if tag.Name == t.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches implements Expr
+func (l Label) Matches(tags []model.Tag) bool {
+	for _, tag := range tags {
+		if tag.IsLabel() && tag.Key == l.Key && (l.Value == AnyValue || tag.Value == l.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches implements Expr
+func (n Not) Matches(tags []model.Tag) bool { return !n.X.Matches(tags) }
+
+// Matches implements Expr
+func (a And) Matches(tags []model.Tag) bool { return a.L.Matches(tags) && a.R.Matches(tags) }
+
+// Matches implements Expr
+func (o Or) Matches(tags []model.Tag) bool { return o.L.Matches(tags) || o.R.Matches(tags) }
+
+func (t Tag) String() string {
+	if isBare(t.Name) && !strings.Contains(t.Name, model.LabelSeparator) {
+		return t.Name
+	}
+	return quote(t.Name)
+}
+
+func (l Label) String() string {
+	if l.Value == AnyValue || isBare(l.Value) {
+		return l.Key + model.LabelSeparator + l.Value
+	}
+	return l.Key + model.LabelSeparator + quote(l.Value)
+}
+
+// quoteEscaper escapes the characters lexQuoted unescapes within double quotes
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func quote(s string) string {
+	return `"` + quoteEscaper.Replace(s) + `"`
+}
+
+func (n Not) String() string { return "NOT " + group(n.X, false) }
+
+func (a And) String() string { return group(a.L, true) + " AND " + group(a.R, true) }
+
+func (o Or) String() string { return o.L.String() + " OR " + o.R.String() }
+
+// group parenthesizes operands that bind looser than the enclosing operator
+func group(e Expr, inAnd bool) string {
+	switch e.(type) {
+	case Or:
+		return "(" + e.String() + ")"
+	case And:
+		if !inAnd {
+			return "(" + e.String() + ")"
+		}
+	}
+	return e.String()
+}
+
+// Error describes a selector that cannot be parsed
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid selector at position %d: %s", e.Pos, e.Msg)
+}
+
+// All returns an expression matching templates carrying every given tag, or
+// nil when names is empty
+func All(names ...string) Expr {
+	var expr Expr
+	for _, name := range names {
+		if expr == nil {
+			expr = Tag{Name: name}
+		} else {
+			expr = And{L: expr, R: Tag{Name: name}}
+		}
+	}
+	return expr
+}
+
+// Tags returns the distinct tag names referenced by an expression; labels
+// are reported as key=value
+func Tags(e Expr) []string {
+	seen := map[string]bool{}
+	var names []string
+	var walk func(Expr)
+	walk = func(e Expr) {
+		switch x := e.(type) {
+		case Tag:
+			if !seen[x.Name] {
+				seen[x.Name] = true
+				names = append(names, x.Name)
+			}
+		case Label:
+			name := x.Key + model.LabelSeparator + x.Value
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		case Not:
+			walk(x.X)
+		case And:
+			walk(x.L)
+			walk(x.R)
+		case Or:
+			walk(x.L)
+			walk(x.R)
+		}
+	}
+	if e != nil {
+		walk(e)
+	}
+	return names
+}
+
+// References reports whether an expression has a term selecting the given
+// tag: by name, or for a label by its key and value or by key=*
+func References(e Expr, tag model.Tag) bool {
+	found := false
+	if e != nil {
+		rewrite(e, func(term Expr) Expr {
+			found = found || selects(term, tag, true)
+			return term
+		})
+	}
+	return found
+}
+
+// Rename returns the expression with every term selecting from, by name or
+// for a label by its key and value, replaced by a term selecting to, and
+// whether any term was replaced. key=* terms are kept.
+func Rename(e Expr, from, to model.Tag) (Expr, bool) {
+	if e == nil {
+		return nil, false
+	}
+	changed := false
+	out := rewrite(e, func(term Expr) Expr {
+		if !selects(term, from, false) {
+			return term
+		}
+		changed = true
+		if _, ok := term.(Label); ok && to.IsLabel() {
+			return Label{Key: to.Key, Value: to.Value}
+		}
+		return Tag{Name: to.Name}
+	})
+	return out, changed
+}
+
+// selects reports whether a term names the tag; anyValue includes key=*
+// terms of its key
+func selects(term Expr, tag model.Tag, anyValue bool) bool {
+	switch x := term.(type) {
+	case Tag:
+		return x.Name == tag.Name
+	case Label:
+		return tag.IsLabel() && x.Key == tag.Key && (x.Value == tag.Value || anyValue && x.Value == AnyValue)
+	}
+	return false
+}
+
+// rewrite returns e with every term replaced by the result of fn
+func rewrite(e Expr, fn func(Expr) Expr) Expr {
+	switch x := e.(type) {
+	case Not:
+		return Not{X: rewrite(x.X, fn)}
+	case And:
+		return And{L: rewrite(x.L, fn), R: rewrite(x.R, fn)}
+	case Or:
+		return Or{L: rewrite(x.L, fn), R: rewrite(x.R, fn)}
+	}
+	return fn(e)
+}
+
+// SQL compiles an expression into a boolean SQL condition on the template ID
+// column idColumn. Every term becomes a sub-select over template_tags keyed by
+// tag_id so that idx_template_tags_tag_id is used; a term matches templates
+// tagged with the named tag or any of its descendants. arg registers a query
+// argument and returns its placeholder.
+func SQL(e Expr, idColumn string, arg func(interface{}) string) string {
+	var b strings.Builder
+	e.sql(&b, idColumn, arg)
+	return b.String()
+}
+
+// taggedWith writes a condition matching templates linked to the tags
+// selected by where, or to any of their descendants
+func taggedWith(b *strings.Builder, idColumn, where string) {
+	b.WriteString(idColumn)
+	b.WriteString(" IN (SELECT tt.template_id FROM template_tags tt WHERE tt.tag_id IN (")
+	b.WriteString("WITH RECURSIVE sub(id) AS (SELECT id FROM tags WHERE ")
+	b.WriteString(where)
+	b.WriteString(" UNION SELECT c.id FROM tags c JOIN sub ON c.parent_id = sub.id) SELECT id FROM sub))")
+}
+
+func (t Tag) sql(b *strings.Builder, idColumn string, arg func(interface{}) string) {
+	taggedWith(b, idColumn, "name = "+arg(t.Name))
+}
+
+func (l Label) sql(b *strings.Builder, idColumn string, arg func(interface{}) string) {
+	where := "key = " + arg(l.Key)
+	if l.Value != AnyValue {
+		where += " AND value = " + arg(l.Value)
+	}
+	taggedWith(b, idColumn, where)
+}
+
+func (n Not) sql(b *strings.Builder, idColumn string, arg func(interface{}) string) {
+	b.WriteString("NOT (")
+	n.X.sql(b, idColumn, arg)
+	b.WriteString(")")
+}
+
+func (a And) sql(b *strings.Builder, idColumn string, arg func(interface{}) string) {
+	b.WriteString("(")
+	a.L.sql(b, idColumn, arg)
+	b.WriteString(" AND ")
+	a.R.sql(b, idColumn, arg)
+	b.WriteString(")")
+}
+
+func (o Or) sql(b *strings.Builder, idColumn string, arg func(interface{}) string) {
+	b.WriteString("(")
+	o.L.sql(b, idColumn, arg)
+	b.WriteString(" OR ")
+	o.R.sql(b, idColumn, arg)
+	b.WriteString(")")
+}
@@ -0,0 +1,323 @@
+package selector
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/company/config-service/internal/model"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"database", "database"},
+		{"  database  ", "database"},
+		{"a AND b", "a AND b"},
+		{"a and b or c", "a AND b OR c"},
+		{"a OR b AND c", "a OR b AND c"},
+		{"(a OR b) AND c", "(a OR b) AND c"},
+		{"NOT a AND b", "NOT a AND b"},
+		{"NOT (a AND b)", "NOT (a AND b)"},
+		{"not not a", "NOT NOT a"},
+		{"((a))", "a"},
+		{`"my tag" AND 'it\'s'`, `"my tag" AND "it's"`},
+		{`"with \"quote\""`, `"with \"quote\""`},
+		{`"back \\ slash"`, `"back \\ slash"`},
+		{`"ends with\\"`, `"ends with\\"`},
+		{`'\\"both\\'`, `"\\\"both\\"`},
+		{`a\b`, `a\b`},
+		{"team=payments", "team=payments"},
+		{"team=*", "team=*"},
+		{`team="payments core"`, `team="payments core"`},
+		{`team="C:\\Program Files"`, `team="C:\\Program Files"`},
+		{`"team=payments"`, `"team=payments"`},
+		{"tier:1", "tier:1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.input, err)
+			}
+			if got := expr.String(); got != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+			// The canonical form parses to the same expression
+			again, err := Parse(expr.String())
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", expr.String(), err)
+			}
+			if again.String() != expr.String() {
+				t.Errorf("canonical form %s reparses as %s", expr.String(), again.String())
+			}
+		})
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	for _, input := range []string{"", "   ", "\t\n"} {
+		expr, err := Parse(input)
+		if err != nil || expr != nil {
+			t.Errorf("Parse(%q) = %v, %v, want nil, nil", input, expr, err)
+		}
+	}
+}
+
+func TestParsePrecedence(t *testing.T) {
+	expr, err := Parse("a OR b AND NOT c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	or, ok := expr.(Or)
+	if !ok {
+		t.Fatalf("top level is %T, want Or", expr)
+	}
+	and, ok := or.R.(And)
+	if !ok {
+		t.Fatalf("right operand is %T, want And", or.R)
+	}
+	if _, ok := and.R.(Not); !ok {
+		t.Fatalf("innermost operand is %T, want Not", and.R)
+	}
+
+	expr, err = Parse("a AND b AND c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// AND is left-associative
+	if and, ok := expr.(And); !ok || and.R != (Tag{Name: "c"}) {
+		t.Errorf("a AND b AND c parsed as %#v", expr)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+		msg   string
+	}{
+		{"a AND", 5, "expected tag name but found end of selector"},
+		{"AND a", 0, "expected tag name but found 'AND'"},
+		{"(a OR b", 7, "expected ) but found end of selector"},
+		{"a b", 2, "unexpected 'b'"},
+		{"a)", 1, "unexpected ')'"},
+		{`"open`, 0, "unterminated quoted string"},
+		{`""`, 0, "empty quoted string"},
+		{"=value", 0, "label =value needs a key and a value"},
+		{"key=", 0, "label key= needs a key and a value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var selErr *Error
+			if !errors.As(err, &selErr) {
+				t.Fatalf("Parse(%q) error = %v, want *Error", tt.input, err)
+			}
+			if selErr.Pos != tt.pos || selErr.Msg != tt.msg {
+				t.Errorf("Parse(%q) error = %d %q, want %d %q", tt.input, selErr.Pos, selErr.Msg, tt.pos, tt.msg)
+			}
+		})
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		msg   string
+	}{
+		{"length", strings.Repeat("a", maxLength+1), "selector is too long"},
+		{"terms", terms(maxTerms + 1), "selector references too many tags"},
+		{"parentheses", strings.Repeat("(", maxDepth+1) + "a" + strings.Repeat(")", maxDepth+1), "selector is nested too deeply"},
+		{"negations", strings.Repeat("NOT ", maxDepth+1) + "a", "selector is nested too deeply"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var selErr *Error
+			if !errors.As(err, &selErr) || selErr.Msg != tt.msg {
+				t.Fatalf("Parse error = %v, want %q", err, tt.msg)
+			}
+		})
+	}
+
+	within := []string{
+		terms(maxTerms),
+		strings.Repeat("(", maxDepth) + "a" + strings.Repeat(")", maxDepth),
+		strings.Repeat("NOT ", maxDepth) + "a",
+	}
+	for _, input := range within {
+		if _, err := Parse(input); err != nil {
+			t.Errorf("Parse(%.40q...) error: %v", input, err)
+		}
+	}
+}
+
+// terms returns a selector OR-ing n distinct tags
+func terms(n int) string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("t%d", i)
+	}
+	return strings.Join(names, " OR ")
+}
+
+func TestMatches(t *testing.T) {
+	tags := []model.Tag{
+		{Name: "database"},
+		{Name: "my tag"},
+		{Name: "team=payments", Key: "team", Value: "payments"},
+	}
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"database", true},
+		{"cache", false},
+		{`"my tag"`, true},
+		{"database AND NOT deprecated", true},
+		{"database AND deprecated", false},
+		{"cache OR database", true},
+		{"NOT database", false},
+		{"team=payments", true},
+		{"team=core", false},
+		{"team=*", true},
+		{"owner=*", false},
+		{`"team=payments"`, true},
+		{"(cache OR team=payments) AND NOT (deprecated OR legacy)", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			expr, err := Parse(tt.selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := expr.Matches(tags); got != tt.want {
+				t.Errorf("%s matches = %v, want %v", tt.selector, got, tt.want)
+			}
+		})
+	}
+
+	// A plain tag whose name looks like a label is not a label
+	plain := []model.Tag{{Name: "team=payments"}}
+	if expr, _ := Parse("team=payments"); expr.Matches(plain) {
+		t.Error("label term matched a plain tag")
+	}
+}
+
+func TestTags(t *testing.T) {
+	expr, err := Parse("(a OR team=core) AND NOT a AND b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(Tags(expr), ",")
+	if want := "a,team=core,b"; got != want {
+		t.Errorf("Tags = %s, want %s", got, want)
+	}
+	if Tags(nil) != nil {
+		t.Error("Tags(nil) is not empty")
+	}
+}
+
+func TestReferences(t *testing.T) {
+	plain := model.Tag{Name: "db"}
+	label := model.Tag{Name: "payments", Key: "team", Value: "core"}
+	tests := []struct {
+		input string
+		tag   model.Tag
+		want  bool
+	}{
+		{"api OR NOT db", plain, true},
+		{"api OR dbs", plain, false},
+		{"team=core", label, true},
+		{"team=*", label, true},
+		{"team=edge", label, false},
+		{"payments AND api", label, true},
+		{`"team=core"`, label, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := References(expr, tt.tag); got != tt.want {
+				t.Errorf("References(%q, %s) = %v, want %v", tt.input, tt.tag.Name, got, tt.want)
+			}
+		})
+	}
+	if References(nil, plain) {
+		t.Error("References(nil) is true")
+	}
+}
+
+func TestRename(t *testing.T) {
+	plain := model.Tag{Name: "db"}
+	label := model.Tag{Name: "team=core", Key: "team", Value: "core"}
+	tests := []struct {
+		input    string
+		from, to model.Tag
+		want     string
+		changed  bool
+	}{
+		{"db AND NOT (db OR api)", plain, model.Tag{Name: "database"}, "database AND NOT (database OR api)", true},
+		{"db", plain, model.Tag{Name: "data base"}, `"data base"`, true},
+		{"dbs OR api", plain, model.Tag{Name: "database"}, "dbs OR api", false},
+		{"team=core OR team=*", label, model.Tag{Name: "team=platform", Key: "team", Value: "platform"}, "team=platform OR team=*", true},
+		{"team=core", label, model.Tag{Name: "core"}, "core", true},
+		{"db", plain, model.Tag{Name: "tier=db", Key: "tier", Value: "db"}, `"tier=db"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, changed := Rename(expr, tt.from, tt.to)
+			if got.String() != tt.want || changed != tt.changed {
+				t.Errorf("Rename = %s, %v, want %s, %v", got, changed, tt.want, tt.changed)
+			}
+			if _, err := Parse(got.String()); err != nil {
+				t.Errorf("renamed selector %s does not parse: %v", got, err)
+			}
+		})
+	}
+}
+
+func TestAll(t *testing.T) {
+	if All() != nil {
+		t.Error("All() is not nil")
+	}
+	if got := All("a", "b", "c").String(); got != "a AND b AND c" {
+		t.Errorf("All = %s", got)
+	}
+}
+
+func TestSQL(t *testing.T) {
+	expr, err := Parse("(a OR team=*) AND NOT env=prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var args []interface{}
+	got := SQL(expr, "t.id", func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	})
+
+	sub := func(where string) string {
+		return "t.id IN (SELECT tt.template_id FROM template_tags tt WHERE tt.tag_id IN (" +
+			"WITH RECURSIVE sub(id) AS (SELECT id FROM tags WHERE " + where +
+			" UNION SELECT c.id FROM tags c JOIN sub ON c.parent_id = sub.id) SELECT id FROM sub))"
+	}
+	want := "((" + sub("name = $1") + " OR " + sub("key = $2") + ") AND NOT (" + sub("key = $3 AND value = $4") + "))"
+	if got != want {
+		t.Errorf("SQL =\n%s\nwant\n%s", got, want)
+	}
+	if fmt.Sprint(args) != "[a team env prod]" {
+		t.Errorf("args = %v", args)
+	}
+}
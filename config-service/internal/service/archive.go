@@ -0,0 +1,550 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/secrets"
+)
+
+// errDryRun rolls back the import transaction after a dry run
+var errDryRun = errors.New("dry run")
+
+// ArchiveService exports and imports the whole configuration store
+type ArchiveService struct {
+	db           *database.Connection
+	environments *repository.EnvironmentRepository
+	tags         *repository.TagRepository
+	templates    *repository.TemplateRepository
+	freezes      *repository.FreezeWindowRepository
+	cipher       *secrets.Cipher
+}
+
+// NewArchiveService creates a new archive service. Secret values are
+// exported masked or as envelopes, and are encrypted with cipher on import;
+// cipher may be nil when no master key is configured.
+func NewArchiveService(db *database.Connection, environments *repository.EnvironmentRepository,
+	tags *repository.TagRepository, templates *repository.TemplateRepository,
+	freezes *repository.FreezeWindowRepository, cipher *secrets.Cipher) *ArchiveService {
+	return &ArchiveService{
+		db:           db,
+		environments: environments,
+		tags:         tags,
+		templates:    templates,
+		freezes:      freezes,
+		cipher:       cipher,
+	}
+}
+
+// Export reads all environments, tags, templates and tag links from a single
+// consistent snapshot. Secret values are masked unless sealed is set, in which
+// case their envelopes are exported so that a store sharing the keyring can
+// restore them.
+func (s *ArchiveService) Export(ctx context.Context, sealed bool) (*model.Archive, error) {
+	a := &model.Archive{
+		FormatVersion: model.ArchiveFormatVersion,
+		ExportedAt:    time.Now().UTC(),
+		SealedSecrets: sealed,
+		Environments:  []model.ArchiveEnvironment{},
+		Tags:          []model.ArchiveTag{},
+		Templates:     []model.ArchiveTemplate{},
+		TemplateTags:  []model.ArchiveTemplateTag{},
+	}
+
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `SET TRANSACTION ISOLATION LEVEL REPEATABLE READ, READ ONLY`); err != nil {
+			return fmt.Errorf("failed to start snapshot: %w", err)
+		}
+
+		environments, err := s.environments.WithTx(tx).List(ctx)
+		if err != nil {
+			return err
+		}
+		slugs := make(map[int64]string, len(environments))
+		for _, env := range environments {
+			slugs[env.ID] = env.Slug
+			a.Environments = append(a.Environments, model.ArchiveEnvironment{
+				Name:        env.Name,
+				Slug:        env.Slug,
+				Description: env.Description,
+				Active:      env.Active,
+				Priority:    env.Priority,
+			})
+		}
+
+		tags, err := s.tags.WithTx(tx).List(ctx)
+		if err != nil {
+			return err
+		}
+		names := make(map[int64]string, len(tags))
+		for _, tag := range tags {
+			names[tag.ID] = tag.Name
+		}
+		for _, tag := range tags {
+			var parent string
+			if tag.ParentID != nil {
+				parent = names[*tag.ParentID]
+			}
+			a.Tags = append(a.Tags, model.ArchiveTag{
+				Name:        tag.Name,
+				Key:         tag.Key,
+				Value:       tag.Value,
+				Parent:      parent,
+				Description: tag.Description,
+				Color:       tag.Color,
+			})
+		}
+
+		templates, err := s.templates.WithTx(tx).List(ctx)
+		if err != nil {
+			return err
+		}
+		for _, tpl := range templates {
+			slug := slugs[tpl.EnvironmentID]
+			values := tpl.DefaultValues
+			if !sealed {
+				values = secrets.Masked(values)
+			}
+			a.Templates = append(a.Templates, model.ArchiveTemplate{
+				Environment:   slug,
+				Name:          tpl.Name,
+				Description:   tpl.Description,
+				Format:        tpl.Format,
+				Content:       tpl.Content,
+				Schema:        tpl.Schema,
+				DefaultValues: values,
+				Version:       tpl.Version,
+				Active:        tpl.Active,
+				CreatedBy:     tpl.CreatedBy,
+				UpdatedBy:     tpl.UpdatedBy,
+			})
+			for _, tag := range tpl.Tags {
+				a.TemplateTags = append(a.TemplateTags, model.ArchiveTemplateTag{
+					Environment: slug,
+					Template:    tpl.Name,
+					Tag:         tag.Name,
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Import writes an archive into the store within a single transaction.
+// Records are matched by environment slug, tag name and template name within
+// its environment; strategy decides what happens to records that already
+// exist. Templates written are attributed to actor. With dryRun the
+// transaction is rolled back after computing the summary.
+func (s *ArchiveService) Import(ctx context.Context, a *model.Archive, strategy model.ConflictStrategy, dryRun bool, actor string) (*model.ImportSummary, error) {
+	switch strategy {
+	case model.ConflictSkip, model.ConflictOverwrite, model.ConflictFail:
+	default:
+		return nil, &ValidationError{Field: "strategy", Message: "must be one of skip, overwrite, fail"}
+	}
+	if err := validateArchive(a); err != nil {
+		return nil, err
+	}
+
+	summary := &model.ImportSummary{Strategy: strategy, DryRun: dryRun}
+
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		imp := &importer{
+			environments: s.environments.WithTx(tx),
+			tags:         s.tags.WithTx(tx),
+			templates:    s.templates.WithTx(tx),
+			freezes:      s.freezes.WithTx(tx),
+			cipher:       s.cipher,
+			strategy:     strategy,
+			actor:        actor,
+			summary:      summary,
+		}
+		if err := imp.run(ctx, a); err != nil {
+			return err
+		}
+
+		if strategy == model.ConflictFail && len(summary.Conflicts) > 0 {
+			return &ConflictError{Conflicts: summary.Conflicts}
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// importer holds the state of a single import transaction
+type importer struct {
+	environments *repository.EnvironmentRepository
+	tags         *repository.TagRepository
+	templates    *repository.TemplateRepository
+	freezes      *repository.FreezeWindowRepository
+	cipher       *secrets.Cipher
+	strategy     model.ConflictStrategy
+	actor        string
+	summary      *model.ImportSummary
+
+	environmentIDs map[string]int64
+	// protected lists the slugs of protected environments, whose templates
+	// an import may not change
+	protected map[string]bool
+	tagIDs    map[string]int64
+	// templateIDs maps environment slug and template name to the template ID
+	// for every template whose tag links are replaced by the import
+	templateIDs map[[2]string]int64
+	skipped     map[[2]string]bool
+}
+
+func (imp *importer) run(ctx context.Context, a *model.Archive) error {
+	if err := imp.importEnvironments(ctx, a.Environments); err != nil {
+		return err
+	}
+	if err := imp.importTags(ctx, a.Tags); err != nil {
+		return err
+	}
+	if err := imp.importTemplates(ctx, a.Templates); err != nil {
+		return err
+	}
+	return imp.importTemplateTags(ctx, a.TemplateTags)
+}
+
+func (imp *importer) importEnvironments(ctx context.Context, environments []model.ArchiveEnvironment) error {
+	existing, err := imp.environments.List(ctx)
+	if err != nil {
+		return err
+	}
+	bySlug := make(map[string]model.Environment, len(existing))
+	imp.environmentIDs = make(map[string]int64, len(existing)+len(environments))
+	imp.protected = make(map[string]bool)
+	for _, env := range existing {
+		bySlug[env.Slug] = env
+		imp.environmentIDs[env.Slug] = env.ID
+		imp.protected[env.Slug] = env.Protected
+	}
+
+	for _, in := range environments {
+		env, exists := bySlug[in.Slug]
+		env.Name, env.Slug, env.Description, env.Active, env.Priority =
+			in.Name, in.Slug, in.Description, in.Active, in.Priority
+
+		switch {
+		case !exists:
+			if err := imp.environments.Create(ctx, &env); err != nil {
+				return err
+			}
+			imp.environmentIDs[env.Slug] = env.ID
+			imp.summary.Environments.Created++
+		case imp.strategy == model.ConflictOverwrite:
+			if err := imp.environments.Update(ctx, &env); err != nil {
+				return err
+			}
+			imp.summary.Environments.Updated++
+		default:
+			imp.conflict("environment " + in.Slug)
+			imp.summary.Environments.Skipped++
+		}
+	}
+	return nil
+}
+
+func (imp *importer) importTags(ctx context.Context, tags []model.ArchiveTag) error {
+	existing, err := imp.tags.List(ctx)
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]model.Tag, len(existing))
+	imp.tagIDs = make(map[string]int64, len(existing)+len(tags))
+	for _, tag := range existing {
+		byName[tag.Name] = tag
+		imp.tagIDs[tag.Name] = tag.ID
+	}
+
+	var written []model.ArchiveTag
+	for _, in := range tags {
+		tag, exists := byName[in.Name]
+		tag.Name, tag.Description, tag.Color = in.Name, in.Description, in.Color
+		tag.Key, tag.Value = in.Key, in.Value
+
+		switch {
+		case !exists:
+			if err := imp.tags.Create(ctx, &tag); err != nil {
+				return err
+			}
+			imp.tagIDs[tag.Name] = tag.ID
+			imp.summary.Tags.Created++
+		case imp.strategy == model.ConflictOverwrite:
+			if err := imp.tags.Update(ctx, &tag); err != nil {
+				return err
+			}
+			imp.summary.Tags.Updated++
+		default:
+			imp.conflict("tag " + in.Name)
+			imp.summary.Tags.Skipped++
+			continue
+		}
+		byName[tag.Name] = tag
+		written = append(written, in)
+	}
+
+	// Parents are linked once every tag exists so that archive order does not matter
+	for _, in := range written {
+		tag := byName[in.Name]
+		var parentID *int64
+		if in.Parent != "" {
+			id, ok := imp.tagIDs[in.Parent]
+			if !ok {
+				return &ValidationError{Field: "tags", Message: "parent " + in.Parent + " of tag " + in.Name + " does not exist"}
+			}
+			parentID = &id
+		}
+		if equalIDs(tag.ParentID, parentID) {
+			continue
+		}
+		tag.ParentID = parentID
+		if err := checkParent(ctx, imp.tags, &tag); err != nil {
+			return err
+		}
+		if err := imp.tags.Update(ctx, &tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// equalIDs compares two optional IDs
+func equalIDs(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func (imp *importer) importTemplates(ctx context.Context, templates []model.ArchiveTemplate) error {
+	imp.templateIDs = make(map[[2]string]int64, len(templates))
+	imp.skipped = make(map[[2]string]bool)
+
+	for _, in := range templates {
+		envID, ok := imp.environmentIDs[in.Environment]
+		if !ok {
+			return &ValidationError{
+				Field:   "templates",
+				Message: fmt.Sprintf("template %q references unknown environment %q", in.Name, in.Environment),
+			}
+		}
+
+		key := [2]string{in.Environment, in.Name}
+		existing, err := imp.templates.GetByName(ctx, envID, in.Name)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+
+		tpl := model.Template{
+			Name:          in.Name,
+			Description:   in.Description,
+			Format:        in.Format,
+			Content:       in.Content,
+			Schema:        in.Schema,
+			DefaultValues: in.DefaultValues,
+			Version:       in.Version,
+			EnvironmentID: envID,
+			Active:        in.Active,
+			CreatedBy:     imp.actor,
+			UpdatedBy:     imp.actor,
+		}
+
+		if existing == nil || imp.strategy == model.ConflictOverwrite {
+			if imp.protected[in.Environment] {
+				return &ValidationError{
+					Field:   "templates",
+					Message: fmt.Sprintf("template %q: %s", in.Name, (&ProtectedError{Environment: in.Environment}).Error()),
+				}
+			}
+			if err := checkUnfrozen(ctx, imp.environments, imp.freezes, envID); err != nil {
+				var frozenErr *FrozenError
+				if errors.As(err, &frozenErr) {
+					return &ValidationError{Field: "templates", Message: fmt.Sprintf("template %q: %s", in.Name, frozenErr.Error())}
+				}
+				return err
+			}
+		}
+
+		switch {
+		case existing == nil:
+			if err := imp.sealSecrets(&tpl, nil); err != nil {
+				return err
+			}
+			if err := imp.templates.Create(ctx, &tpl); err != nil {
+				return err
+			}
+			imp.templateIDs[key] = tpl.ID
+			imp.summary.Templates.Created++
+		case imp.strategy == model.ConflictOverwrite:
+			tpl.ID = existing.ID
+			if err := imp.sealSecrets(&tpl, existing.DefaultValues); err != nil {
+				return err
+			}
+			if err := imp.templates.Update(ctx, &tpl); err != nil {
+				return err
+			}
+			imp.templateIDs[key] = tpl.ID
+			imp.summary.Templates.Updated++
+		default:
+			imp.conflict(fmt.Sprintf("template %s/%s", in.Environment, in.Name))
+			imp.skipped[key] = true
+			imp.summary.Templates.Skipped++
+		}
+	}
+	return nil
+}
+
+// sealSecrets encrypts the secret values of an imported template. Envelopes
+// of an archive exported with sealed secrets are opened with the keyring and
+// sealed again; masked values keep the values of the template they overwrite
+// and are rejected when there is none, since they cannot be restored.
+func (imp *importer) sealSecrets(tpl *model.Template, previous model.JSONMap) error {
+	invalid := func(msg string) error {
+		return &ValidationError{Field: "templates", Message: fmt.Sprintf("template %q: %s", tpl.Name, msg)}
+	}
+
+	values, err := imp.cipher.Open(tpl.DefaultValues, tpl.Schema)
+	if err != nil {
+		var secretErr *secrets.Error
+		if errors.As(err, &secretErr) {
+			return invalid(secretErr.Error())
+		}
+		return err
+	}
+	tpl.DefaultValues = values
+
+	if masked := secrets.Unrestorable(tpl.DefaultValues, tpl.Schema, previous); len(masked) > 0 {
+		return invalid("masked secret values cannot be restored: " + strings.Join(masked, ", ") +
+			"; export the archive with a token holding secrets:read")
+	}
+
+	err = sealSecrets(imp.cipher, tpl, previous)
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return invalid(validationErr.Error())
+	}
+	return err
+}
+
+func (imp *importer) importTemplateTags(ctx context.Context, links []model.ArchiveTemplateTag) error {
+	tagIDs := make(map[[2]string][]int64, len(imp.templateIDs))
+	for _, link := range links {
+		key := [2]string{link.Environment, link.Template}
+		if imp.skipped[key] {
+			imp.summary.TemplateTags.Skipped++
+			continue
+		}
+		if _, ok := imp.templateIDs[key]; !ok {
+			return &ValidationError{
+				Field:   "template_tags",
+				Message: fmt.Sprintf("link references unknown template %s/%s", link.Environment, link.Template),
+			}
+		}
+		tagID, ok := imp.tagIDs[link.Tag]
+		if !ok {
+			return &ValidationError{
+				Field:   "template_tags",
+				Message: fmt.Sprintf("link of template %s/%s references unknown tag %q", link.Environment, link.Template, link.Tag),
+			}
+		}
+		tagIDs[key] = append(tagIDs[key], tagID)
+	}
+
+	// Imported templates get exactly the links of the archive
+	keys := make([][2]string, 0, len(imp.templateIDs))
+	for key := range imp.templateIDs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i][0] < keys[j][0] || (keys[i][0] == keys[j][0] && keys[i][1] < keys[j][1])
+	})
+	for _, key := range keys {
+		if err := imp.templates.SetTags(ctx, imp.templateIDs[key], tagIDs[key]); err != nil {
+			return err
+		}
+		imp.summary.TemplateTags.Created += len(tagIDs[key])
+	}
+	return nil
+}
+
+func (imp *importer) conflict(record string) {
+	imp.summary.Conflicts = append(imp.summary.Conflicts, record)
+}
+
+// validateArchive checks that every record carries the fields needed to match
+// and insert it, and that records are unique within the archive
+func validateArchive(a *model.Archive) error {
+	seen := make(map[string]bool)
+	unique := func(field, key string) error {
+		if seen[field+"\x00"+key] {
+			return &ValidationError{Field: field, Message: fmt.Sprintf("duplicate entry %q", key)}
+		}
+		seen[field+"\x00"+key] = true
+		return nil
+	}
+
+	for _, env := range a.Environments {
+		if env.Slug == "" || env.Name == "" {
+			return &ValidationError{Field: "environments", Message: "name and slug are required"}
+		}
+		if env.Priority < 0 || env.Priority > 100 {
+			return &ValidationError{Field: "environments", Message: fmt.Sprintf("priority of %q must be between 0 and 100", env.Slug)}
+		}
+		if err := unique("environments", env.Slug); err != nil {
+			return err
+		}
+	}
+	for _, tag := range a.Tags {
+		if tag.Name == "" || tag.Color == "" {
+			return &ValidationError{Field: "tags", Message: "name and color are required"}
+		}
+		if (tag.Key == "") != (tag.Value == "") {
+			return &ValidationError{Field: "tags", Message: "label " + tag.Name + " needs both key and value"}
+		}
+		if err := unique("tags", tag.Name); err != nil {
+			return err
+		}
+	}
+	for _, tpl := range a.Templates {
+		if tpl.Environment == "" || tpl.Name == "" || tpl.Content == "" || tpl.Version == "" {
+			return &ValidationError{Field: "templates", Message: "environment, name, content and version are required"}
+		}
+		if validate.Var(tpl.Version, "semver") != nil {
+			return &ValidationError{Field: "templates", Message: fmt.Sprintf("template %q has invalid version %q", tpl.Name, tpl.Version)}
+		}
+		switch tpl.Format {
+		case model.ConfigFormatJSON, model.ConfigFormatYAML, model.ConfigFormatTOML, model.ConfigFormatEnv:
+		default:
+			return &ValidationError{Field: "templates", Message: fmt.Sprintf("template %q has unsupported format %q", tpl.Name, tpl.Format)}
+		}
+		if err := unique("templates", tpl.Environment+"/"+tpl.Name); err != nil {
+			return err
+		}
+	}
+	for _, link := range a.TemplateTags {
+		if err := unique("template_tags", link.Environment+"/"+link.Template+"#"+link.Tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
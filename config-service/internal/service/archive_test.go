@@ -0,0 +1,254 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/database/dbtest"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/secrets"
+)
+
+func newTestArchiveService(db *database.Connection, cipher *secrets.Cipher) *ArchiveService {
+	return NewArchiveService(db, repository.NewEnvironmentRepository(db), repository.NewTagRepository(db),
+		repository.NewTemplateRepository(db), repository.NewFreezeWindowRepository(db), cipher)
+}
+
+func newTestCipher(t *testing.T) *secrets.Cipher {
+	t.Helper()
+	c, err := secrets.NewCipher([]secrets.MasterKey{{Version: 1, Key: bytes.Repeat([]byte{1}, secrets.KeySize)}})
+	if err != nil {
+		t.Fatalf("NewCipher error: %v", err)
+	}
+	return c
+}
+
+// testArchive returns an archive of one environment holding a template with
+// a secret value, linked to a tag
+func testArchive(description string) *model.Archive {
+	return &model.Archive{
+		FormatVersion: model.ArchiveFormatVersion,
+		Environments:  []model.ArchiveEnvironment{{Name: "Production", Slug: "prod", Active: true, Priority: 10}},
+		Tags:          []model.ArchiveTag{{Name: "api", Color: "#112233"}},
+		Templates: []model.ArchiveTemplate{{
+			Environment: "prod",
+			Name:        "app",
+			Description: description,
+			Format:      model.ConfigFormatYAML,
+			Content:     "token: {{ .token }}",
+			Schema: model.JSONMap{"properties": map[string]interface{}{
+				"token": map[string]interface{}{"type": "string", "secret": true},
+			}},
+			DefaultValues: model.JSONMap{"token": "s3cret"},
+			Version:       "1.0.0",
+			Active:        true,
+		}},
+		TemplateTags: []model.ArchiveTemplateTag{{Environment: "prod", Template: "app", Tag: "api"}},
+	}
+}
+
+func TestValidateArchive(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(a *model.Archive)
+		err    string
+	}{
+		{name: "valid", modify: func(*model.Archive) {}},
+		{
+			name:   "invalid version",
+			modify: func(a *model.Archive) { a.Templates[0].Version = "latest" },
+			err:    `templates: template "app" has invalid version "latest"`,
+		},
+		{
+			name:   "unsupported format",
+			modify: func(a *model.Archive) { a.Templates[0].Format = "xml" },
+			err:    `templates: template "app" has unsupported format "xml"`,
+		},
+		{
+			name:   "duplicate template",
+			modify: func(a *model.Archive) { a.Templates = append(a.Templates, a.Templates[0]) },
+			err:    `templates: duplicate entry "prod/app"`,
+		},
+		{
+			name:   "label without value",
+			modify: func(a *model.Archive) { a.Tags[0].Key = "team" },
+			err:    "tags: label api needs both key and value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := testArchive("")
+			tt.modify(a)
+			err := validateArchive(a)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("validateArchive error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("validateArchive error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestArchiveImportStrategies(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		strategy    model.ConflictStrategy
+		want        model.ImportCounts
+		description string
+		conflict    bool
+	}{
+		{strategy: model.ConflictSkip, want: model.ImportCounts{Skipped: 1}, description: "original"},
+		{strategy: model.ConflictOverwrite, want: model.ImportCounts{Updated: 1}, description: "changed"},
+		{strategy: model.ConflictFail, conflict: true, description: "original"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			db := dbtest.New(t)
+			s := newTestArchiveService(db, newTestCipher(t))
+
+			summary, err := s.Import(ctx, testArchive("original"), model.ConflictFail, false, "alice")
+			if err != nil {
+				t.Fatalf("initial Import error: %v", err)
+			}
+			if want := (model.ImportCounts{Created: 1}); summary.Templates != want || summary.TemplateTags != want {
+				t.Fatalf("initial Import summary = %+v", summary)
+			}
+
+			summary, err = s.Import(ctx, testArchive("changed"), tt.strategy, false, "bob")
+			var conflictErr *ConflictError
+			switch {
+			case tt.conflict:
+				if !errors.As(err, &conflictErr) {
+					t.Fatalf("Import error = %v, want a conflict", err)
+				}
+				want := []string{"environment prod", "tag api", "template prod/app"}
+				if !reflect.DeepEqual(conflictErr.Conflicts, want) {
+					t.Errorf("conflicts = %v, want %v", conflictErr.Conflicts, want)
+				}
+			case err != nil:
+				t.Fatalf("Import error: %v", err)
+			case summary.Templates != tt.want:
+				t.Errorf("templates = %+v, want %+v", summary.Templates, tt.want)
+			}
+
+			a, err := s.Export(ctx, false)
+			if err != nil {
+				t.Fatalf("Export error: %v", err)
+			}
+			if len(a.Templates) != 1 || a.Templates[0].Description != tt.description {
+				t.Fatalf("exported templates = %+v, want description %q", a.Templates, tt.description)
+			}
+			if got := a.Templates[0].DefaultValues["token"]; got != secrets.Mask {
+				t.Errorf("exported token = %v, want it masked", got)
+			}
+			if len(a.TemplateTags) != 1 {
+				t.Errorf("exported links = %+v, want one", a.TemplateTags)
+			}
+		})
+	}
+}
+
+func TestArchiveImportDryRun(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t)
+	s := newTestArchiveService(db, newTestCipher(t))
+
+	summary, err := s.Import(ctx, testArchive(""), model.ConflictSkip, true, "alice")
+	if err != nil {
+		t.Fatalf("Import error: %v", err)
+	}
+	if !summary.DryRun || summary.Environments.Created != 1 || summary.Templates.Created != 1 {
+		t.Errorf("summary = %+v", summary)
+	}
+
+	a, err := s.Export(ctx, false)
+	if err != nil {
+		t.Fatalf("Export error: %v", err)
+	}
+	if len(a.Environments)+len(a.Tags)+len(a.Templates) != 0 {
+		t.Errorf("dry run wrote %+v", a)
+	}
+}
+
+func TestArchiveSecrets(t *testing.T) {
+	ctx := context.Background()
+	cipher := newTestCipher(t)
+	source := newTestArchiveService(dbtest.New(t), cipher)
+	if _, err := source.Import(ctx, testArchive(""), model.ConflictFail, false, "alice"); err != nil {
+		t.Fatalf("Import error: %v", err)
+	}
+
+	sealed, err := source.Export(ctx, true)
+	if err != nil {
+		t.Fatalf("Export error: %v", err)
+	}
+	if !sealed.SealedSecrets || !secrets.IsEnvelope(sealed.Templates[0].DefaultValues["token"]) {
+		t.Fatalf("sealed export = %+v", sealed.Templates[0].DefaultValues)
+	}
+	masked, err := source.Export(ctx, false)
+	if err != nil {
+		t.Fatalf("Export error: %v", err)
+	}
+
+	t.Run("sealed into a store sharing the keyring", func(t *testing.T) {
+		db := dbtest.New(t)
+		target := newTestArchiveService(db, cipher)
+		if _, err := target.Import(ctx, sealed, model.ConflictFail, false, "bob"); err != nil {
+			t.Fatalf("Import error: %v", err)
+		}
+		env, err := repository.NewEnvironmentRepository(db).GetBySlug(ctx, "prod")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tpl, err := repository.NewTemplateRepository(db).GetByName(ctx, env.ID, "app")
+		if err != nil {
+			t.Fatal(err)
+		}
+		values, err := cipher.Reveal(tpl.DefaultValues)
+		if err != nil || values["token"] != "s3cret" {
+			t.Errorf("restored token = %v, %v", values["token"], err)
+		}
+	})
+
+	t.Run("sealed into a store with another keyring", func(t *testing.T) {
+		other, _ := secrets.NewCipher([]secrets.MasterKey{{Version: 2, Key: bytes.Repeat([]byte{2}, secrets.KeySize)}})
+		target := newTestArchiveService(dbtest.New(t), other)
+		var validationErr *ValidationError
+		if _, err := target.Import(ctx, sealed, model.ConflictFail, false, "bob"); !errors.As(err, &validationErr) {
+			t.Fatalf("Import error = %v, want a validation error", err)
+		}
+	})
+
+	t.Run("masked into an empty store", func(t *testing.T) {
+		target := newTestArchiveService(dbtest.New(t), cipher)
+		_, err := target.Import(ctx, masked, model.ConflictFail, false, "bob")
+		if err == nil || !strings.Contains(err.Error(), "masked secret values cannot be restored: token") {
+			t.Fatalf("Import error = %v, want masked secrets rejected", err)
+		}
+	})
+
+	t.Run("masked over the source", func(t *testing.T) {
+		if _, err := source.Import(ctx, masked, model.ConflictOverwrite, false, "bob"); err != nil {
+			t.Fatalf("Import error: %v", err)
+		}
+		a, err := source.Export(ctx, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values, err := cipher.Reveal(a.Templates[0].DefaultValues)
+		if err != nil || values["token"] != "s3cret" {
+			t.Errorf("kept token = %v, %v", values["token"], err)
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+)
+
+// AuditService provides read access to the audit log
+type AuditService struct {
+	audit *repository.AuditRepository
+}
+
+// NewAuditService creates a new audit service
+func NewAuditService(audit *repository.AuditRepository) *AuditService {
+	return &AuditService{audit: audit}
+}
+
+// List returns one page of audit entries matching the filter, newest first
+func (s *AuditService) List(ctx context.Context, filter model.AuditFilter, page model.PaginationParams) (*model.AuditListResponse, error) {
+	if err := validateStruct(page); err != nil {
+		return nil, err
+	}
+
+	entries, info, err := s.audit.ListPage(ctx, filter, page)
+	if err != nil {
+		return nil, pageError(err)
+	}
+	if entries == nil {
+		entries = []model.AuditEntry{}
+	}
+
+	return &model.AuditListResponse{
+		Entries:    entries,
+		Total:      info.Total,
+		Page:       pageNumber(page),
+		PageSize:   page.PageSize,
+		HasNext:    info.HasNext,
+		NextCursor: info.NextCursor,
+		PrevCursor: info.PrevCursor,
+	}, nil
+}
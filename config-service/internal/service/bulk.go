@@ -0,0 +1,481 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/secrets"
+	"github.com/company/config-service/pkg/metrics"
+)
+
+// errBulkAborted rolls back the transaction of an atomic bulk request
+var errBulkAborted = errors.New("bulk request aborted")
+
+// maxBulkItems bounds the number of templates a single bulk request may touch
+const maxBulkItems = 1000
+
+// BulkService executes batches of template writes in a single transaction
+type BulkService struct {
+	db           *database.Connection
+	environments *repository.EnvironmentRepository
+	tags         *repository.TagRepository
+	templates    *repository.TemplateRepository
+	freezes      *repository.FreezeWindowRepository
+	cipher       *secrets.Cipher
+}
+
+// NewBulkService creates a new bulk service encrypting secret values with
+// cipher, which may be nil when no master key is configured
+func NewBulkService(db *database.Connection, environments *repository.EnvironmentRepository,
+	tags *repository.TagRepository, templates *repository.TemplateRepository,
+	freezes *repository.FreezeWindowRepository, cipher *secrets.Cipher) *BulkService {
+	return &BulkService{
+		db:           db,
+		environments: environments,
+		tags:         tags,
+		templates:    templates,
+		freezes:      freezes,
+		cipher:       cipher,
+	}
+}
+
+// bulkItem is executed for a single item of a batch. It fills in the
+// template ID, name and environment of the result and returns the item error.
+type bulkItem func(ctx context.Context, tx *sql.Tx, result *itemResult) error
+
+// itemResult is a per-item result plus the environment used as metric label
+type itemResult struct {
+	model.BulkItemResult
+	environmentID int64
+}
+
+// Templates creates, updates and deletes templates on behalf of actor
+func (s *BulkService) Templates(ctx context.Context, req model.BulkTemplateRequest, actor string) (*model.BulkResponse, error) {
+	if len(req.Items) == 0 || len(req.Items) > maxBulkItems {
+		return nil, &ValidationError{Field: "items", Message: fmt.Sprintf("must contain between 1 and %d items", maxBulkItems)}
+	}
+
+	items := make([]bulkItem, len(req.Items))
+	ops := make([]model.BulkOperation, len(req.Items))
+	for i := range req.Items {
+		item := req.Items[i]
+		ops[i] = item.Op
+		if item.Create != nil {
+			item.Create.CreatedBy = actor
+		}
+		if item.Update != nil {
+			item.Update.UpdatedBy = actor
+		}
+		switch item.Op {
+		case model.BulkCreate:
+			items[i] = s.createTemplate(item.Create)
+		case model.BulkUpdate:
+			items[i] = s.updateTemplate(item.ID, item.Update)
+		case model.BulkDelete:
+			items[i] = s.deleteTemplate(item.ID)
+		default:
+			items[i] = func(context.Context, *sql.Tx, *itemResult) error {
+				return fmt.Errorf("unsupported operation %q", item.Op)
+			}
+		}
+	}
+
+	return s.run(ctx, req.Atomic, ops, items)
+}
+
+// Tags attaches and detaches tags on every template matching the filter,
+// recording actor as the author of the change
+func (s *BulkService) Tags(ctx context.Context, req model.BulkTagRequest, actor string) (*model.BulkResponse, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+	if len(req.Attach) == 0 && len(req.Detach) == 0 {
+		return nil, &ValidationError{Message: "at least one of attach or detach is required"}
+	}
+
+	attachIDs, err := resolveTags(ctx, s.tags, "attach", req.Attach)
+	if err != nil {
+		return nil, err
+	}
+	detachIDs, err := resolveTags(ctx, s.tags, "detach", req.Detach)
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := s.findTemplates(ctx, req.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	op := model.BulkAttachTags
+	if len(attachIDs) == 0 {
+		op = model.BulkDetachTags
+	}
+
+	items := make([]bulkItem, len(templates))
+	ops := make([]model.BulkOperation, len(templates))
+	for i := range templates {
+		tpl := templates[i]
+		ops[i] = op
+		items[i] = func(ctx context.Context, tx *sql.Tx, result *itemResult) error {
+			result.TemplateID, result.Name, result.environmentID = tpl.ID, tpl.Name, tpl.EnvironmentID
+			if err := s.checkWritable(ctx, tx, tpl.EnvironmentID); err != nil {
+				return err
+			}
+			repo := s.templates.WithTx(tx)
+			if len(attachIDs) > 0 {
+				if err := repo.AttachTags(ctx, tpl.ID, attachIDs); err != nil {
+					return err
+				}
+			}
+			if len(detachIDs) > 0 {
+				if err := repo.DetachTags(ctx, tpl.ID, detachIDs); err != nil {
+					return err
+				}
+			}
+			return repo.Touch(ctx, tpl.ID, actor)
+		}
+	}
+
+	return s.run(ctx, req.Atomic, ops, items)
+}
+
+// Status activates or deactivates every template matching the filter on
+// behalf of actor
+func (s *BulkService) Status(ctx context.Context, req model.BulkStatusRequest, actor string) (*model.BulkResponse, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+
+	templates, err := s.findTemplates(ctx, req.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	active := *req.Active
+	op := model.BulkDeactivate
+	if active {
+		op = model.BulkActivate
+	}
+
+	items := make([]bulkItem, len(templates))
+	ops := make([]model.BulkOperation, len(templates))
+	for i := range templates {
+		tpl := templates[i]
+		ops[i] = op
+		items[i] = func(ctx context.Context, tx *sql.Tx, result *itemResult) error {
+			result.TemplateID, result.Name, result.environmentID = tpl.ID, tpl.Name, tpl.EnvironmentID
+			if err := s.checkWritable(ctx, tx, tpl.EnvironmentID); err != nil {
+				return err
+			}
+			return s.templates.WithTx(tx).SetActive(ctx, tpl.ID, active, actor)
+		}
+	}
+
+	return s.run(ctx, req.Atomic, ops, items)
+}
+
+// run executes items in order within one transaction. Every item runs in its
+// own savepoint so a failure only undoes that item; when atomic is set the
+// first failure rolls back the whole transaction instead.
+func (s *BulkService) run(ctx context.Context, atomic bool, ops []model.BulkOperation, items []bulkItem) (*model.BulkResponse, error) {
+	results := make([]itemResult, len(items))
+	for i := range results {
+		results[i].BulkItemResult = model.BulkItemResult{Index: i, Op: ops[i], Status: model.BulkItemRolledBack}
+	}
+	succeeded, failed := 0, 0
+
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		for i, item := range items {
+			result := &results[i]
+
+			if _, err := tx.ExecContext(ctx, `SAVEPOINT bulk_item`); err != nil {
+				return fmt.Errorf("failed to create savepoint: %w", err)
+			}
+
+			if err := item(ctx, tx, result); err != nil {
+				result.Status = model.BulkItemFailed
+				result.Error = err.Error()
+				failed++
+
+				if atomic {
+					return errBulkAborted
+				}
+				if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT bulk_item`); err != nil {
+					return fmt.Errorf("failed to rollback savepoint: %w", err)
+				}
+				continue
+			}
+
+			if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT bulk_item`); err != nil {
+				return fmt.Errorf("failed to release savepoint: %w", err)
+			}
+			result.Status = model.BulkItemSucceeded
+			succeeded++
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBulkAborted) {
+		return nil, err
+	}
+
+	resp := &model.BulkResponse{
+		Atomic:    atomic,
+		Committed: err == nil,
+		Succeeded: succeeded,
+		Failed:    failed,
+		Results:   make([]model.BulkItemResult, len(results)),
+	}
+	if !resp.Committed {
+		// Nothing was applied, earlier successes included
+		resp.Succeeded = 0
+	}
+
+	slugs := s.environmentSlugs(ctx)
+	for i, result := range results {
+		if !resp.Committed && result.Status == model.BulkItemSucceeded {
+			result.Status = model.BulkItemRolledBack
+		}
+		resp.Results[i] = result.BulkItemResult
+
+		if result.Status != model.BulkItemRolledBack {
+			metrics.RecordTemplateOperation("bulk_"+string(result.Op), slugs[result.environmentID], string(result.Status))
+		}
+	}
+
+	return resp, nil
+}
+
+func (s *BulkService) createTemplate(req *model.CreateTemplateRequest) bulkItem {
+	return func(ctx context.Context, tx *sql.Tx, result *itemResult) error {
+		if req == nil {
+			return errors.New("create is required")
+		}
+		result.Name, result.environmentID = req.Name, req.EnvironmentID
+		if err := validateStruct(req); err != nil {
+			return err
+		}
+		if err := s.checkWritable(ctx, tx, req.EnvironmentID); err != nil {
+			return err
+		}
+
+		tpl := model.Template{
+			Name:          req.Name,
+			Description:   req.Description,
+			Format:        req.Format,
+			Content:       req.Content,
+			Schema:        req.Schema,
+			DefaultValues: req.DefaultValues,
+			Version:       req.Version,
+			EnvironmentID: req.EnvironmentID,
+			Active:        true,
+			CreatedBy:     req.CreatedBy,
+			UpdatedBy:     req.CreatedBy,
+		}
+		if req.Active != nil {
+			tpl.Active = *req.Active
+		}
+		if err := sealSecrets(s.cipher, &tpl, nil); err != nil {
+			return err
+		}
+
+		repo := s.templates.WithTx(tx)
+		if err := repo.Create(ctx, &tpl); err != nil {
+			return err
+		}
+		result.TemplateID = tpl.ID
+		if err := checkIncludes(ctx, repo, &tpl); err != nil {
+			return err
+		}
+		return repo.SetTags(ctx, tpl.ID, req.TagIDs)
+	}
+}
+
+func (s *BulkService) updateTemplate(id int64, req *model.UpdateTemplateRequest) bulkItem {
+	return func(ctx context.Context, tx *sql.Tx, result *itemResult) error {
+		result.TemplateID = id
+		if id == 0 || req == nil {
+			return errors.New("id and update are required")
+		}
+		if err := validateStruct(req); err != nil {
+			return err
+		}
+
+		repo := s.templates.WithTx(tx)
+		tpl, err := repo.GetByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return fmt.Errorf("template %d does not exist", id)
+			}
+			return err
+		}
+		result.Name, result.environmentID = tpl.Name, tpl.EnvironmentID
+
+		previous := tpl.DefaultValues
+		applyTemplateUpdate(tpl, req)
+		if err := s.checkWritable(ctx, tx, result.environmentID, tpl.EnvironmentID); err != nil {
+			return err
+		}
+		if err := sealSecrets(s.cipher, tpl, previous); err != nil {
+			return err
+		}
+		if err := repo.Update(ctx, tpl); err != nil {
+			return err
+		}
+		result.Name = tpl.Name
+		if err := checkIncludes(ctx, repo, tpl); err != nil {
+			return err
+		}
+		if req.TagIDs != nil {
+			return repo.SetTags(ctx, id, req.TagIDs)
+		}
+		return nil
+	}
+}
+
+func (s *BulkService) deleteTemplate(id int64) bulkItem {
+	return func(ctx context.Context, tx *sql.Tx, result *itemResult) error {
+		result.TemplateID = id
+		if id == 0 {
+			return errors.New("id is required")
+		}
+		repo := s.templates.WithTx(tx)
+		tpl, err := repo.GetByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return fmt.Errorf("template %d does not exist", id)
+			}
+			return err
+		}
+		result.Name, result.environmentID = tpl.Name, tpl.EnvironmentID
+		if err := s.checkWritable(ctx, tx, tpl.EnvironmentID); err != nil {
+			return err
+		}
+		return repo.Delete(ctx, id)
+	}
+}
+
+// checkWritable fails when one of the environments is protected or frozen
+func (s *BulkService) checkWritable(ctx context.Context, tx *sql.Tx, ids ...int64) error {
+	environments := s.environments.WithTx(tx)
+	if err := checkUnprotected(ctx, environments, ids...); err != nil {
+		return err
+	}
+	return checkUnfrozen(ctx, environments, s.freezes.WithTx(tx), ids...)
+}
+
+// checkUnprotected fails with a *ProtectedError when one of the environments
+// is protected
+func checkUnprotected(ctx context.Context, environments *repository.EnvironmentRepository, ids ...int64) error {
+	for _, id := range ids {
+		env, err := environments.GetByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return fmt.Errorf("environment %d does not exist", id)
+			}
+			return err
+		}
+		if env.Protected {
+			return &ProtectedError{Environment: env.Slug}
+		}
+	}
+	return nil
+}
+
+// applyTemplateUpdate copies the set fields of an update request onto a template
+func applyTemplateUpdate(tpl *model.Template, req *model.UpdateTemplateRequest) {
+	if req.Name != nil {
+		tpl.Name = *req.Name
+	}
+	if req.Description != nil {
+		tpl.Description = *req.Description
+	}
+	if req.Format != nil {
+		tpl.Format = *req.Format
+	}
+	if req.Content != nil {
+		tpl.Content = *req.Content
+	}
+	if req.Schema != nil {
+		tpl.Schema = req.Schema
+	}
+	if req.DefaultValues != nil {
+		tpl.DefaultValues = req.DefaultValues
+	}
+	if req.Version != nil {
+		tpl.Version = *req.Version
+	}
+	if req.EnvironmentID != nil {
+		tpl.EnvironmentID = *req.EnvironmentID
+	}
+	if req.Active != nil {
+		tpl.Active = *req.Active
+	}
+	tpl.UpdatedBy = req.UpdatedBy
+}
+
+// findTemplates resolves a non-empty filter to at most maxBulkItems templates
+func (s *BulkService) findTemplates(ctx context.Context, filter model.TemplateFilter) ([]model.Template, error) {
+	if filter.IsEmpty() {
+		return nil, &ValidationError{Field: "filter", Message: "at least one criterion is required"}
+	}
+	if err := validateSelector("filter.selector", filter.Selector); err != nil {
+		return nil, err
+	}
+
+	templates, err := s.templates.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(templates) > maxBulkItems {
+		return nil, &ValidationError{
+			Field:   "filter",
+			Message: fmt.Sprintf("matches %d templates, at most %d are allowed", len(templates), maxBulkItems),
+		}
+	}
+	return templates, nil
+}
+
+// resolveTags maps tag names to IDs, failing on unknown names
+func resolveTags(ctx context.Context, repo *repository.TagRepository, field string, names []string) ([]int64, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	tags, err := repo.ListByNames(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]int64, len(tags))
+	for _, tag := range tags {
+		known[tag.Name] = tag.ID
+	}
+	ids := make([]int64, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, &ValidationError{Field: field, Message: fmt.Sprintf("tag %q does not exist", name)}
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// environmentSlugs maps environment IDs to slugs for metric labels
+func (s *BulkService) environmentSlugs(ctx context.Context) map[int64]string {
+	slugs := make(map[int64]string)
+	environments, err := s.environments.List(ctx)
+	if err != nil {
+		return slugs
+	}
+	for _, env := range environments {
+		slugs[env.ID] = env.Slug
+	}
+	return slugs
+}
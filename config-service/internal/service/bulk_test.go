@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/database/dbtest"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+)
+
+func newTestBulkService(db *database.Connection) *BulkService {
+	return NewBulkService(db, repository.NewEnvironmentRepository(db), repository.NewTagRepository(db),
+		repository.NewTemplateRepository(db), repository.NewFreezeWindowRepository(db), nil)
+}
+
+// templateTagNames returns the sorted tag names of a template
+func templateTagNames(t *testing.T, db *database.Connection, id int64) []string {
+	t.Helper()
+	tpl, err := repository.NewTemplateRepository(db).GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetByID template error: %v", err)
+	}
+	var names []string
+	for _, tag := range tpl.Tags {
+		names = append(names, tag.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// bulkStatuses returns the item statuses of a bulk response in order
+func bulkStatuses(resp *model.BulkResponse) []model.BulkItemStatus {
+	statuses := make([]model.BulkItemStatus, len(resp.Results))
+	for i, result := range resp.Results {
+		statuses[i] = result.Status
+	}
+	return statuses
+}
+
+func TestBulkTags(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t)
+	s := newTestBulkService(db)
+	tags := createTestTags(t, newTestTagService(db), "api", "db", "legacy")
+	prod := createTestEnvironment(t, db, "prod")
+	staging := createTestEnvironment(t, db, "staging")
+	a := createTestTemplate(t, db, prod.ID, "a", model.ConfigFormatYAML, nil, tags["api"].ID, tags["legacy"].ID)
+	b := createTestTemplate(t, db, prod.ID, "b", model.ConfigFormatYAML, nil, tags["api"].ID)
+	c := createTestTemplate(t, db, staging.ID, "c", model.ConfigFormatYAML, nil, tags["api"].ID)
+	d := createTestTemplate(t, db, prod.ID, "d", model.ConfigFormatYAML, nil, tags["legacy"].ID)
+
+	resp, err := s.Tags(ctx, model.BulkTagRequest{
+		Filter: model.TemplateFilter{Environment: "prod", Tags: []string{"api"}},
+		Attach: []string{"db"},
+		Detach: []string{"legacy"},
+	}, "alice")
+	if err != nil {
+		t.Fatalf("Tags error: %v", err)
+	}
+	if !resp.Committed || resp.Succeeded != 2 || resp.Failed != 0 {
+		t.Errorf("response = %+v, want two committed items", resp)
+	}
+	var selected []int64
+	for _, result := range resp.Results {
+		selected = append(selected, result.TemplateID)
+	}
+	if !reflect.DeepEqual(selected, []int64{a.ID, b.ID}) {
+		t.Errorf("selected templates = %v, want a and b", selected)
+	}
+
+	want := map[*model.Template][]string{
+		a: {"api", "db"},
+		b: {"api", "db"},
+		c: {"api"},
+		d: {"legacy"},
+	}
+	for tpl, names := range want {
+		if got := templateTagNames(t, db, tpl.ID); !reflect.DeepEqual(got, names) {
+			t.Errorf("tags of %s = %v, want %v", tpl.Name, got, names)
+		}
+	}
+
+	invalid := []struct {
+		name string
+		req  model.BulkTagRequest
+		err  string
+	}{
+		{
+			name: "empty filter",
+			req:  model.BulkTagRequest{Attach: []string{"db"}},
+			err:  "filter: at least one criterion is required",
+		},
+		{
+			name: "no tags",
+			req:  model.BulkTagRequest{Filter: model.TemplateFilter{Environment: "prod"}},
+			err:  "at least one of attach or detach is required",
+		},
+		{
+			name: "unknown tag",
+			req:  model.BulkTagRequest{Filter: model.TemplateFilter{Environment: "prod"}, Detach: []string{"missing"}},
+			err:  `detach: tag "missing" does not exist`,
+		},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Tags(ctx, tt.req, "alice")
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || !strings.HasSuffix(err.Error(), tt.err) {
+				t.Errorf("Tags error = %v, want a validation error %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestBulkTemplatesAtomic(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		atomic    bool
+		committed bool
+		succeeded int
+		statuses  []model.BulkItemStatus
+		created   bool
+	}{
+		{
+			name:      "partial",
+			atomic:    false,
+			committed: true,
+			succeeded: 2,
+			statuses:  []model.BulkItemStatus{model.BulkItemSucceeded, model.BulkItemFailed, model.BulkItemSucceeded},
+			created:   true,
+		},
+		{
+			// The failure undoes the create and stops the batch before the delete
+			name:     "atomic",
+			atomic:   true,
+			statuses: []model.BulkItemStatus{model.BulkItemRolledBack, model.BulkItemFailed, model.BulkItemRolledBack},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dbtest.New(t)
+			s := newTestBulkService(db)
+			env := createTestEnvironment(t, db, "prod")
+			existing := createTestTemplate(t, db, env.ID, "existing", model.ConfigFormatYAML, nil)
+
+			resp, err := s.Templates(ctx, model.BulkTemplateRequest{
+				Atomic: tt.atomic,
+				Items: []model.BulkTemplateItem{
+					{Op: model.BulkCreate, Create: &model.CreateTemplateRequest{
+						Name: "new", Format: model.ConfigFormatYAML, Content: "a: 1", Version: "1.0.0", EnvironmentID: env.ID,
+					}},
+					{Op: model.BulkDelete, ID: existing.ID + 1000},
+					{Op: model.BulkDelete, ID: existing.ID},
+				},
+			}, "alice")
+			if err != nil {
+				t.Fatalf("Templates error: %v", err)
+			}
+			if resp.Committed != tt.committed || resp.Succeeded != tt.succeeded || resp.Failed != 1 {
+				t.Errorf("response = %+v", resp)
+			}
+			if got := bulkStatuses(resp); !reflect.DeepEqual(got, tt.statuses) {
+				t.Errorf("statuses = %v, want %v", got, tt.statuses)
+			}
+
+			templates := repository.NewTemplateRepository(db)
+			if _, err := templates.GetByName(ctx, env.ID, "new"); (err == nil) != tt.created {
+				t.Errorf("GetByName(new) error = %v, want created %v", err, tt.created)
+			}
+			if _, err := templates.GetByID(ctx, existing.ID); (err == nil) != tt.atomic {
+				t.Errorf("GetByID(existing) error = %v, want kept %v", err, tt.atomic)
+			}
+		})
+	}
+}
+
+func TestBulkCheckWritable(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t)
+	s := newTestBulkService(db)
+	open := createTestEnvironment(t, db, "open")
+	protected := createTestEnvironment(t, db, "protected")
+	frozen := createTestEnvironment(t, db, "frozen")
+	protectTestEnvironment(t, db, protected, 1)
+	freezeTestEnvironment(t, db, frozen.ID, "release")
+
+	var ids []int64
+	for _, env := range []*model.Environment{open, protected, frozen} {
+		ids = append(ids, createTestTemplate(t, db, env.ID, env.Slug, model.ConfigFormatYAML, nil).ID)
+	}
+
+	active := false
+	resp, err := s.Status(ctx, model.BulkStatusRequest{Filter: model.TemplateFilter{IDs: ids}, Active: &active}, "alice")
+	if err != nil {
+		t.Fatalf("Status error: %v", err)
+	}
+	want := []model.BulkItemStatus{model.BulkItemSucceeded, model.BulkItemFailed, model.BulkItemFailed}
+	if got := bulkStatuses(resp); !reflect.DeepEqual(got, want) {
+		t.Fatalf("statuses = %v, want %v", got, want)
+	}
+	if msg := resp.Results[1].Error; !strings.Contains(msg, "environment protected is protected") {
+		t.Errorf("protected error = %q", msg)
+	}
+	if msg := resp.Results[2].Error; !strings.Contains(msg, `environment frozen is frozen by window "release"`) {
+		t.Errorf("frozen error = %q", msg)
+	}
+
+	err = db.Transaction(ctx, func(tx *sql.Tx) error {
+		return s.checkWritable(ctx, tx, open.ID, frozen.ID, protected.ID)
+	})
+	var protectedErr *ProtectedError
+	if !errors.As(err, &protectedErr) || protectedErr.Environment != "protected" {
+		t.Errorf("checkWritable error = %v, want protection checked before freezes", err)
+	}
+	err = db.Transaction(ctx, func(tx *sql.Tx) error {
+		return s.checkWritable(ctx, tx, open.ID, frozen.ID)
+	})
+	var frozenErr *FrozenError
+	if !errors.As(err, &frozenErr) || frozenErr.Environment != "frozen" || frozenErr.Window != "release" {
+		t.Errorf("checkWritable error = %v, want frozen", err)
+	}
+
+	for i, wantActive := range []bool{false, true, true} {
+		tpl, err := repository.NewTemplateRepository(db).GetByID(ctx, ids[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tpl.Active != wantActive {
+			t.Errorf("template %s active = %v, want %v", tpl.Name, tpl.Active, wantActive)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/company/config-service/internal/auth"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/render"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/secrets"
+	"github.com/company/config-service/internal/selector"
+	"github.com/company/config-service/pkg/metrics"
+)
+
+// RenderedTemplate is a template together with its rendered output. Variant
+// is set for templates under rollout to the version the client received.
+type RenderedTemplate struct {
+	Template model.Template
+	FileName string
+	Content  []byte
+	Checksum string
+	Variant  string
+}
+
+// BundleService renders the active templates of an environment
+type BundleService struct {
+	environments *repository.EnvironmentRepository
+	templates    *repository.TemplateRepository
+	candidates   *repository.CandidateRepository
+	options      render.Options
+	cipher       *secrets.Cipher
+	asOf         *time.Time
+}
+
+// NewBundleService creates a new bundle service rendering with the given
+// limits and options. cipher decrypts secret values and may be nil when no
+// master key is configured.
+func NewBundleService(environments *repository.EnvironmentRepository, templates *repository.TemplateRepository,
+	candidates *repository.CandidateRepository, options render.Options, cipher *secrets.Cipher) *BundleService {
+	return &BundleService{
+		environments: environments,
+		templates:    templates,
+		candidates:   candidates,
+		options:      options,
+		cipher:       cipher,
+	}
+}
+
+// AsOf returns a copy of the service rendering the state recorded in the
+// history at the given moment, including the candidates under rollout then,
+// or the service itself for nil. Past renders are not counted in metrics.
+func (s *BundleService) AsOf(at *time.Time) *BundleService {
+	if at == nil {
+		return s
+	}
+	c := *s
+	c.environments = s.environments.AsOf(*at)
+	c.templates = s.templates.AsOf(*at)
+	c.candidates = s.candidates.AsOf(*at)
+	c.asOf = at
+	return &c
+}
+
+// Render resolves the environment by slug and renders every active template
+// matched by the tag selector; a nil selector matches all templates. Partials
+// are left out but can be included by the rendered templates. Secret values
+// are decrypted for callers holding the secrets:read permission. Templates
+// under rollout are rendered in the variant assigned to instance, the ID of
+// the requesting client, which may be empty. It returns
+// repository.ErrNotFound for an unknown environment, a *render.Error for
+// templates that fail to render and a *PermissionError wrapped in it when a
+// template uses secret values the caller may not read.
+func (s *BundleService) Render(ctx context.Context, slug string, expr selector.Expr, instance string) (*model.Environment, []RenderedTemplate, error) {
+	env, err := s.environments.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	templates, err := s.templates.ListActiveByEnvironment(ctx, env.ID, expr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list templates of %s: %w", slug, err)
+	}
+
+	candidates, err := s.candidates.ListByEnvironment(ctx, env.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	variants := assignVariants(templates, candidates, instance)
+
+	partials, err := s.partials(ctx, env.ID, templates, candidates, instance)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	options := s.options
+	options.Reveal = s.reveal(auth.Allowed(ctx, auth.SecretsRead))
+
+	rendered := make([]RenderedTemplate, 0, len(templates))
+	for i := range templates {
+		tpl := &templates[i]
+		if tpl.IsPartial() {
+			continue
+		}
+		content, err := render.Render(tpl, partials, options)
+		if err != nil {
+			return nil, nil, err
+		}
+		variant := variants[tpl.ID]
+		if variant != "" && s.asOf == nil {
+			metrics.RecordRolloutAssignment(env.Slug, tpl.Name, variant, tpl.Version)
+		}
+		rendered = append(rendered, RenderedTemplate{
+			Template: *tpl,
+			FileName: render.FileName(tpl),
+			Content:  content,
+			Checksum: render.Checksum(content),
+			Variant:  variant,
+		})
+	}
+
+	return env, rendered, nil
+}
+
+// ExpectedTemplate is the version and content checksum of a template as it
+// is currently served to a client. Checksum is empty when the template
+// fails to render.
+type ExpectedTemplate struct {
+	Name     string
+	Version  string
+	Checksum string
+}
+
+// Expected returns, for each of instances, the active templates of an
+// environment by ID in the version and with the checksum currently served to
+// it. Secret values are decrypted whatever the permissions of the caller, as
+// only checksums leave the service. Instances assigned the same variants
+// share a render.
+func (s *BundleService) Expected(ctx context.Context, env *model.Environment, instances []string) (map[string]map[int64]ExpectedTemplate, error) {
+	templates, err := s.templates.ListActiveByEnvironment(ctx, env.ID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates of %s: %w", env.Slug, err)
+	}
+	candidates, err := s.candidates.ListByEnvironment(ctx, env.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	options := s.options
+	options.Reveal = s.reveal(true)
+
+	expected := make(map[string]map[int64]ExpectedTemplate, len(instances))
+	renders := make(map[string]map[int64]ExpectedTemplate)
+	for _, instance := range instances {
+		assigned := slices.Clone(templates)
+		key := variantKey(assignVariants(assigned, candidates, instance))
+		if e, ok := renders[key]; ok {
+			expected[instance] = e
+			continue
+		}
+
+		partials, err := s.partials(ctx, env.ID, assigned, candidates, instance)
+		if err != nil {
+			return nil, err
+		}
+		e := make(map[int64]ExpectedTemplate, len(assigned))
+		for i := range assigned {
+			tpl := &assigned[i]
+			if tpl.IsPartial() {
+				continue
+			}
+			exp := ExpectedTemplate{Name: tpl.Name, Version: tpl.Version}
+			if content, err := render.Render(tpl, partials, options); err == nil {
+				exp.Checksum = render.Checksum(content)
+			}
+			e[tpl.ID] = exp
+		}
+		renders[key] = e
+		expected[instance] = e
+	}
+	return expected, nil
+}
+
+// variantKey identifies the variants assigned to a client, so that clients
+// assigned the same ones are served the same bundle
+func variantKey(variants map[int64]string) string {
+	keys := make([]string, 0, len(variants))
+	for id, variant := range variants {
+		keys = append(keys, fmt.Sprintf("%d=%s", id, variant))
+	}
+	slices.Sort(keys)
+	return strings.Join(keys, ",")
+}
+
+// partials loads the templates of the environment that can be included or
+// looked up, in the variants assigned to instance, or nil when none of
+// templates refers to another
+func (s *BundleService) partials(ctx context.Context, environmentID int64, templates []model.Template,
+	candidates map[int64]*model.TemplateCandidate, instance string) (render.Partials, error) {
+	for i := range templates {
+		// Templates that fail to parse report the error when rendered
+		refs, err := render.Refs(templates[i].Content)
+		if err != nil || len(refs.Includes)+len(refs.Lookups) == 0 {
+			continue
+		}
+		all, err := s.templates.ListByEnvironment(ctx, environmentID, repository.TemplateLoad{
+			Fields: model.Fieldset{"name": true, "content": true, "default_values": true},
+		})
+		if err != nil {
+			return nil, err
+		}
+		assignVariants(all, candidates, instance)
+		return render.NewPartials(all), nil
+	}
+	return nil, nil
+}
+
+// reveal returns the render hook decrypting secret values, or refusing them
+// when allowed is false
+func (s *BundleService) reveal(allowed bool) func(model.JSONMap) (model.JSONMap, error) {
+	return func(values model.JSONMap) (model.JSONMap, error) {
+		if !secrets.Contains(values) {
+			return values, nil
+		}
+		if !allowed {
+			return nil, &PermissionError{
+				Permission: string(auth.SecretsRead),
+				Message:    "template uses secret values",
+			}
+		}
+		return s.cipher.Reveal(values)
+	}
+}
+
+// assignVariants replaces the templates under rollout assigned the candidate
+// for instance by their candidate version and returns the variant of each of
+// them by template ID
+func assignVariants(templates []model.Template, candidates map[int64]*model.TemplateCandidate, instance string) map[int64]string {
+	if len(candidates) == 0 {
+		return nil
+	}
+	variants := make(map[int64]string)
+	for i := range templates {
+		c, ok := candidates[templates[i].ID]
+		if !ok {
+			continue
+		}
+		variant := rolloutVariant(c, instance)
+		if variant == model.VariantCandidate {
+			templates[i].Version = c.Version
+			templates[i].Content = c.Content
+			templates[i].DefaultValues = c.DefaultValues
+		}
+		variants[templates[i].ID] = variant
+	}
+	return variants
+}
@@ -0,0 +1,460 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/company/config-service/internal/auth"
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/secrets"
+	"github.com/company/config-service/pkg/metrics"
+)
+
+// ChangeService proposes template changes in protected environments and
+// applies them once enough users other than the author approved them
+type ChangeService struct {
+	db           *database.Connection
+	environments *repository.EnvironmentRepository
+	templates    *repository.TemplateRepository
+	changes      *repository.ChangeRequestRepository
+	freezes      *repository.FreezeWindowRepository
+	audit        *repository.AuditRepository
+	cipher       *secrets.Cipher
+}
+
+// NewChangeService creates a new change request service encrypting secret
+// values with cipher, which may be nil when no master key is configured
+func NewChangeService(db *database.Connection, environments *repository.EnvironmentRepository,
+	templates *repository.TemplateRepository, changes *repository.ChangeRequestRepository,
+	freezes *repository.FreezeWindowRepository, audit *repository.AuditRepository, cipher *secrets.Cipher) *ChangeService {
+	return &ChangeService{
+		db:           db,
+		environments: environments,
+		templates:    templates,
+		changes:      changes,
+		freezes:      freezes,
+		audit:        audit,
+		cipher:       cipher,
+	}
+}
+
+// Create opens a change request against a template of a protected
+// environment. The proposed template is validated and its secret values
+// encrypted now, so that approving it writes exactly what was reviewed.
+func (s *ChangeService) Create(ctx context.Context, req model.CreateChangeRequest, author string) (*model.ChangeRequest, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+
+	cr := &model.ChangeRequest{
+		Op:          req.Op,
+		Title:       req.Title,
+		Description: req.Description,
+		Status:      model.ChangeOpen,
+		Author:      author,
+	}
+	var current *model.Template
+	switch req.Op {
+	case model.BulkCreate:
+		if req.Create == nil {
+			return nil, &ValidationError{Field: "create", Message: "is required"}
+		}
+		create := *req.Create
+		create.CreatedBy = author
+		if err := validateStruct(create); err != nil {
+			return nil, err
+		}
+		tpl := &model.Template{
+			Name:          create.Name,
+			Description:   create.Description,
+			Format:        create.Format,
+			Content:       create.Content,
+			Schema:        create.Schema,
+			DefaultValues: create.DefaultValues,
+			Version:       create.Version,
+			EnvironmentID: create.EnvironmentID,
+			TagIDs:        create.TagIDs,
+			Active:        true,
+			CreatedBy:     author,
+			UpdatedBy:     author,
+		}
+		if create.Active != nil {
+			tpl.Active = *create.Active
+		}
+		if err := sealSecrets(s.cipher, tpl, nil); err != nil {
+			return nil, err
+		}
+		cr.Proposed = tpl
+
+	case model.BulkUpdate, model.BulkDelete:
+		if req.TemplateID == 0 {
+			return nil, &ValidationError{Field: "template_id", Message: "is required"}
+		}
+		tpl, err := s.templates.GetByID(ctx, req.TemplateID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return nil, &ValidationError{Field: "template_id", Message: fmt.Sprintf("template %d does not exist", req.TemplateID)}
+			}
+			return nil, err
+		}
+		current = tpl
+		cr.TemplateID = &tpl.ID
+		cr.BaseUpdatedAt = &tpl.UpdatedAt
+		if req.Op == model.BulkDelete {
+			break
+		}
+
+		if req.Update == nil {
+			return nil, &ValidationError{Field: "update", Message: "is required"}
+		}
+		update := *req.Update
+		update.UpdatedBy = author
+		if err := validateStruct(update); err != nil {
+			return nil, err
+		}
+		if update.EnvironmentID != nil && *update.EnvironmentID != tpl.EnvironmentID {
+			return nil, &ValidationError{Field: "update.environment_id", Message: "templates cannot move between environments through change requests"}
+		}
+		proposed := *tpl
+		applyTemplateUpdate(&proposed, &update)
+		// Tags are only written on approval when the change sets them
+		proposed.TagIDs = update.TagIDs
+		if err := sealSecrets(s.cipher, &proposed, tpl.DefaultValues); err != nil {
+			return nil, err
+		}
+		cr.Proposed = &proposed
+	}
+
+	if cr.Proposed != nil {
+		cr.EnvironmentID = cr.Proposed.EnvironmentID
+		cr.Proposed.Tags = nil
+		cr.Proposed.Environment = model.Environment{}
+	} else {
+		cr.EnvironmentID = current.EnvironmentID
+	}
+	cr.Diff = templateDiff(current, cr.Proposed)
+	if req.Op == model.BulkUpdate && len(cr.Diff) == 0 {
+		return nil, &ValidationError{Field: "update", Message: "changes nothing"}
+	}
+
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		env, err := s.environments.WithTx(tx).GetByID(ctx, cr.EnvironmentID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return &ValidationError{Field: "create.environment_id", Message: fmt.Sprintf("environment %d does not exist", cr.EnvironmentID)}
+			}
+			return err
+		}
+		if !env.Protected {
+			return &ValidationError{Message: fmt.Sprintf("environment %s is not protected, change its templates directly", env.Slug)}
+		}
+		cr.RequiredApprovals = env.RequiredApprovals
+
+		if err := s.changes.WithTx(tx).Create(ctx, cr); err != nil {
+			return err
+		}
+		cr.Approvals = []model.ChangeApproval{}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditChangeRequestCreate,
+			EntityType: "change_request",
+			EntityID:   &cr.ID,
+			Actor:      author,
+			Details: model.JSONMap{
+				"environment": env.Slug,
+				"op":          cr.Op,
+				"template_id": cr.TemplateID,
+				"title":       cr.Title,
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// Get returns a change request with its approvals and comment threads
+func (s *ChangeService) Get(ctx context.Context, id int64) (*model.ChangeRequest, error) {
+	cr, err := s.changes.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := s.changes.ListComments(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	cr.Comments = commentThreads(comments)
+	return cr, nil
+}
+
+// List returns one page of change requests matching the filter, newest first
+func (s *ChangeService) List(ctx context.Context, filter model.ChangeRequestFilter, page model.PaginationParams) (*model.ChangeRequestListResponse, error) {
+	if err := validateStruct(filter); err != nil {
+		return nil, err
+	}
+	if err := validateStruct(page); err != nil {
+		return nil, err
+	}
+
+	changes, info, err := s.changes.ListPage(ctx, filter, page)
+	if err != nil {
+		return nil, pageError(err)
+	}
+	if changes == nil {
+		changes = []model.ChangeRequest{}
+	}
+	return &model.ChangeRequestListResponse{
+		ChangeRequests: changes,
+		Total:          info.Total,
+		Page:           pageNumber(page),
+		PageSize:       page.PageSize,
+		HasNext:        info.HasNext,
+		NextCursor:     info.NextCursor,
+		PrevCursor:     info.PrevCursor,
+	}, nil
+}
+
+// Approve records the approval of an open change request. The approval
+// completing the required number applies the change in the same
+// transaction; when the template changed since the request was opened
+// nothing is recorded and a *ConflictError is returned.
+func (s *ChangeService) Approve(ctx context.Context, id int64, approver string) (*model.ChangeRequest, error) {
+	var cr *model.ChangeRequest
+	var slug string
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		changes := s.changes.WithTx(tx)
+		var err error
+		if cr, err = s.lockOpen(ctx, changes, id); err != nil {
+			return err
+		}
+		if cr.Author == approver {
+			return &PermissionError{Permission: string(auth.ChangesApprove), Message: "authors cannot approve their own change requests"}
+		}
+		if slices.ContainsFunc(cr.Approvals, func(a model.ChangeApproval) bool { return a.Approver == approver }) {
+			return &ConflictError{Conflicts: []string{fmt.Sprintf("change request %d is already approved by %s", id, approver)}}
+		}
+
+		if err := changes.AddApproval(ctx, cr, approver); err != nil {
+			return err
+		}
+		audit := s.audit.WithTx(tx)
+		if err := audit.Create(ctx, &model.AuditEntry{
+			Action:     model.AuditChangeRequestApprove,
+			EntityType: "change_request",
+			EntityID:   &cr.ID,
+			Actor:      approver,
+			Details:    model.JSONMap{"approvals": len(cr.Approvals), "required_approvals": cr.RequiredApprovals},
+		}); err != nil {
+			return err
+		}
+		if len(cr.Approvals) < cr.RequiredApprovals {
+			return nil
+		}
+
+		if slug, err = s.apply(ctx, tx, cr); err != nil {
+			return err
+		}
+		cr.Status = model.ChangeApplied
+		cr.ResolvedBy = approver
+		if err := changes.Resolve(ctx, cr); err != nil {
+			return err
+		}
+		return audit.Create(ctx, &model.AuditEntry{
+			Action:     model.AuditChangeRequestApply,
+			EntityType: "change_request",
+			EntityID:   &cr.ID,
+			Actor:      approver,
+			Details: model.JSONMap{
+				"environment": slug,
+				"op":          cr.Op,
+				"template_id": cr.TemplateID,
+				"approvers":   approvers(cr.Approvals),
+				"diff":        cr.Diff,
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cr.Status == model.ChangeApplied {
+		metrics.RecordTemplateOperation("change_request_"+string(cr.Op), slug, "success")
+	}
+	return cr, nil
+}
+
+// Reject closes an open change request without applying it
+func (s *ChangeService) Reject(ctx context.Context, id int64, req model.RejectChangeRequest, actor string) (*model.ChangeRequest, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+
+	var cr *model.ChangeRequest
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		changes := s.changes.WithTx(tx)
+		var err error
+		if cr, err = s.lockOpen(ctx, changes, id); err != nil {
+			return err
+		}
+		cr.Status = model.ChangeRejected
+		cr.ResolvedBy = actor
+		cr.Reason = req.Reason
+		if err := changes.Resolve(ctx, cr); err != nil {
+			return err
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditChangeRequestReject,
+			EntityType: "change_request",
+			EntityID:   &cr.ID,
+			Actor:      actor,
+			Details:    model.JSONMap{"reason": req.Reason},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// Comment adds a comment to a change request, or a reply to one of its
+// comments. Resolved change requests can still be commented on.
+func (s *ChangeService) Comment(ctx context.Context, id int64, req model.CreateChangeCommentRequest, author string) (*model.ChangeComment, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+	if _, err := s.changes.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	if req.ParentID != nil {
+		ok, err := s.changes.CommentBelongs(ctx, id, *req.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, &ValidationError{Field: "parent_id", Message: fmt.Sprintf("comment %d does not belong to change request %d", *req.ParentID, id)}
+		}
+	}
+
+	comment := &model.ChangeComment{ParentID: req.ParentID, Author: author, Body: req.Body}
+	if err := s.changes.AddComment(ctx, id, comment); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// lockOpen locks a change request, failing with a *ConflictError unless it
+// is open
+func (s *ChangeService) lockOpen(ctx context.Context, changes *repository.ChangeRequestRepository, id int64) (*model.ChangeRequest, error) {
+	cr, err := changes.Lock(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if cr.Status != model.ChangeOpen {
+		return nil, &ConflictError{Conflicts: []string{fmt.Sprintf("change request %d is already %s", id, cr.Status)}}
+	}
+	return cr, nil
+}
+
+// apply writes the proposed change within tx and returns the slug of the
+// environment. It fails with a *FrozenError during a freeze window, and for
+// updates and deletions with a *ConflictError when the template changed
+// since the change request was opened.
+func (s *ChangeService) apply(ctx context.Context, tx *sql.Tx, cr *model.ChangeRequest) (string, error) {
+	environments := s.environments.WithTx(tx)
+	env, err := environments.GetByID(ctx, cr.EnvironmentID)
+	if err != nil {
+		return "", err
+	}
+	if err := checkUnfrozen(ctx, environments, s.freezes.WithTx(tx), env.ID); err != nil {
+		return "", err
+	}
+	repo := s.templates.WithTx(tx)
+
+	if cr.Op != model.BulkCreate {
+		if cr.TemplateID == nil {
+			return "", &ConflictError{Conflicts: []string{"the template of the change request was deleted"}}
+		}
+		updatedAt, err := repo.LockUpdatedAt(ctx, *cr.TemplateID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return "", &ConflictError{Conflicts: []string{"the template of the change request was deleted"}}
+			}
+			return "", err
+		}
+		if cr.BaseUpdatedAt == nil || !updatedAt.Equal(*cr.BaseUpdatedAt) {
+			return "", &ConflictError{Conflicts: []string{fmt.Sprintf("template %d changed since the change request was opened", *cr.TemplateID)}}
+		}
+		if cr.Op == model.BulkDelete {
+			return env.Slug, repo.Delete(ctx, *cr.TemplateID)
+		}
+	}
+
+	tpl := cr.Proposed
+	// Secret values sealed before a master key rotation are moved to the
+	// primary key, as the rotation job only rewraps stored templates
+	if s.cipher != nil {
+		if tpl.DefaultValues, _, err = s.cipher.RewrapValues(tpl.DefaultValues); err != nil {
+			return "", err
+		}
+	}
+
+	if cr.Op == model.BulkCreate {
+		if _, err := repo.GetByName(ctx, tpl.EnvironmentID, tpl.Name); err == nil {
+			return "", &ConflictError{Conflicts: []string{fmt.Sprintf("template %s already exists in environment %s", tpl.Name, env.Slug)}}
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return "", err
+		}
+		if err := repo.Create(ctx, tpl); err != nil {
+			return "", err
+		}
+		cr.TemplateID = &tpl.ID
+	} else if err := repo.Update(ctx, tpl); err != nil {
+		return "", err
+	}
+
+	if err := checkIncludes(ctx, repo, tpl); err != nil {
+		return "", err
+	}
+	if tpl.TagIDs != nil {
+		if err := repo.SetTags(ctx, tpl.ID, tpl.TagIDs); err != nil {
+			return "", err
+		}
+	}
+	return env.Slug, nil
+}
+
+// approvers lists the names of the approvers in approval order
+func approvers(approvals []model.ChangeApproval) []string {
+	names := make([]string, len(approvals))
+	for i, a := range approvals {
+		names[i] = a.Approver
+	}
+	return names
+}
+
+// commentThreads nests replies under their parent comments. comments are
+// ordered oldest first, so parents precede their replies.
+func commentThreads(comments []model.ChangeComment) []model.ChangeComment {
+	children := make(map[int64][]model.ChangeComment)
+	var roots []model.ChangeComment
+	for _, c := range comments {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+			continue
+		}
+		children[*c.ParentID] = append(children[*c.ParentID], c)
+	}
+
+	var nest func(list []model.ChangeComment) []model.ChangeComment
+	nest = func(list []model.ChangeComment) []model.ChangeComment {
+		for i := range list {
+			list[i].Replies = nest(children[list[i].ID])
+		}
+		return list
+	}
+	return nest(roots)
+}
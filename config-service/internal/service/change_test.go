@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/database/dbtest"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+)
+
+func newTestChangeService(db *database.Connection) *ChangeService {
+	return NewChangeService(db, repository.NewEnvironmentRepository(db), repository.NewTemplateRepository(db),
+		repository.NewChangeRequestRepository(db), repository.NewFreezeWindowRepository(db),
+		repository.NewAuditRepository(db), nil)
+}
+
+func TestChangeApprove(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t)
+	s := newTestChangeService(db)
+	tags := createTestTags(t, newTestTagService(db), "api", "db")
+	env := createTestEnvironment(t, db, "prod")
+	protectTestEnvironment(t, db, env, 2)
+	tpl := createTestTemplate(t, db, env.ID, "app", model.ConfigFormatYAML, nil, tags["api"].ID)
+
+	content := "a: 2"
+	cr, err := s.Create(ctx, model.CreateChangeRequest{
+		Title:      "bump",
+		Op:         model.BulkUpdate,
+		TemplateID: tpl.ID,
+		Update:     &model.UpdateTemplateRequest{Content: &content, TagIDs: []int64{tags["db"].ID}},
+	}, "alice")
+	if err != nil {
+		t.Fatalf("Create error: %v", err)
+	}
+	if cr.RequiredApprovals != 2 {
+		t.Errorf("RequiredApprovals = %d, want the environment's 2", cr.RequiredApprovals)
+	}
+	var paths []string
+	for _, change := range cr.Diff {
+		paths = append(paths, change.Path)
+	}
+	if !reflect.DeepEqual(paths, []string{"content", "tag_ids"}) {
+		t.Errorf("diff paths = %v, want content and tag_ids", paths)
+	}
+
+	var permissionErr *PermissionError
+	if _, err := s.Approve(ctx, cr.ID, "alice"); !errors.As(err, &permissionErr) {
+		t.Errorf("self-approval error = %v, want a permission error", err)
+	}
+
+	cr, err = s.Approve(ctx, cr.ID, "bob")
+	if err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	if cr.Status != model.ChangeOpen || len(cr.Approvals) != 1 {
+		t.Errorf("after one approval status, approvals = %s, %d, want open, 1", cr.Status, len(cr.Approvals))
+	}
+	if got := templateContent(t, db, tpl.ID); got != tpl.Content {
+		t.Errorf("content after one approval = %q, want it unchanged", got)
+	}
+
+	var conflictErr *ConflictError
+	if _, err := s.Approve(ctx, cr.ID, "bob"); !errors.As(err, &conflictErr) {
+		t.Errorf("repeated approval error = %v, want a conflict", err)
+	}
+
+	cr, err = s.Approve(ctx, cr.ID, "carol")
+	if err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	if cr.Status != model.ChangeApplied || cr.ResolvedBy != "carol" ||
+		!reflect.DeepEqual(approvers(cr.Approvals), []string{"bob", "carol"}) {
+		t.Errorf("applied change request = %+v", cr)
+	}
+	if got := templateContent(t, db, tpl.ID); got != content {
+		t.Errorf("content = %q, want %q", got, content)
+	}
+	if got := templateTagNames(t, db, tpl.ID); !reflect.DeepEqual(got, []string{"db"}) {
+		t.Errorf("tags = %v, want db", got)
+	}
+
+	if _, err := s.Approve(ctx, cr.ID, "dave"); !errors.As(err, &conflictErr) ||
+		!strings.Contains(err.Error(), "is already applied") {
+		t.Errorf("approval of an applied change request error = %v, want a conflict", err)
+	}
+}
+
+func TestChangeApply(t *testing.T) {
+	ctx := context.Background()
+	content := "a: 2"
+
+	tests := []struct {
+		name string
+		// prepare returns the change request to open against the template
+		prepare func(t *testing.T, db *database.Connection, env *model.Environment, tpl *model.Template) model.CreateChangeRequest
+		// edit changes the template after the change request is opened
+		edit  bool
+		err   func(err error) bool
+		check func(t *testing.T, db *database.Connection, env *model.Environment, tpl *model.Template)
+	}{
+		{
+			name: "create",
+			prepare: func(_ *testing.T, _ *database.Connection, env *model.Environment, _ *model.Template) model.CreateChangeRequest {
+				return model.CreateChangeRequest{Op: model.BulkCreate, Create: &model.CreateTemplateRequest{
+					Name: "new", Format: model.ConfigFormatYAML, Content: "b: 1", Version: "1.0.0", EnvironmentID: env.ID,
+				}}
+			},
+			check: func(t *testing.T, db *database.Connection, env *model.Environment, _ *model.Template) {
+				tpl, err := repository.NewTemplateRepository(db).GetByName(context.Background(), env.ID, "new")
+				if err != nil || tpl.Content != "b: 1" || tpl.CreatedBy != "alice" {
+					t.Errorf("created template = %+v, %v", tpl, err)
+				}
+			},
+		},
+		{
+			name: "create existing",
+			prepare: func(_ *testing.T, _ *database.Connection, env *model.Environment, tpl *model.Template) model.CreateChangeRequest {
+				return model.CreateChangeRequest{Op: model.BulkCreate, Create: &model.CreateTemplateRequest{
+					Name: tpl.Name, Format: model.ConfigFormatYAML, Content: "b: 1", Version: "1.0.0", EnvironmentID: env.ID,
+				}}
+			},
+			err: isConflict,
+		},
+		{
+			name: "delete",
+			prepare: func(_ *testing.T, _ *database.Connection, _ *model.Environment, tpl *model.Template) model.CreateChangeRequest {
+				return model.CreateChangeRequest{Op: model.BulkDelete, TemplateID: tpl.ID}
+			},
+			check: func(t *testing.T, db *database.Connection, _ *model.Environment, tpl *model.Template) {
+				if _, err := repository.NewTemplateRepository(db).GetByID(context.Background(), tpl.ID); !errors.Is(err, repository.ErrNotFound) {
+					t.Errorf("GetByID error = %v, want the template deleted", err)
+				}
+			},
+		},
+		{
+			name: "template changed since",
+			prepare: func(_ *testing.T, _ *database.Connection, _ *model.Environment, tpl *model.Template) model.CreateChangeRequest {
+				return model.CreateChangeRequest{Op: model.BulkUpdate, TemplateID: tpl.ID,
+					Update: &model.UpdateTemplateRequest{Content: &content}}
+			},
+			edit: true,
+			err:  isConflict,
+		},
+		{
+			name: "frozen",
+			prepare: func(t *testing.T, db *database.Connection, env *model.Environment, tpl *model.Template) model.CreateChangeRequest {
+				freezeTestEnvironment(t, db, env.ID, "release")
+				return model.CreateChangeRequest{Op: model.BulkUpdate, TemplateID: tpl.ID,
+					Update: &model.UpdateTemplateRequest{Content: &content}}
+			},
+			err: func(err error) bool {
+				var frozenErr *FrozenError
+				return errors.As(err, &frozenErr)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dbtest.New(t)
+			s := newTestChangeService(db)
+			env := createTestEnvironment(t, db, "prod")
+			protectTestEnvironment(t, db, env, 1)
+			tpl := createTestTemplate(t, db, env.ID, "app", model.ConfigFormatYAML, nil)
+
+			req := tt.prepare(t, db, env, tpl)
+			req.Title = tt.name
+			cr, err := s.Create(ctx, req, "alice")
+			if err != nil {
+				t.Fatalf("Create error: %v", err)
+			}
+			if tt.edit {
+				if _, err := db.DB.Exec(`UPDATE templates SET description = 'edited', updated_at = NOW() + INTERVAL '1 second' WHERE id = $1`, tpl.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err = s.Approve(ctx, cr.ID, "bob")
+			if tt.err != nil {
+				if !tt.err(err) {
+					t.Fatalf("Approve error = %v", err)
+				}
+				// Nothing is recorded when applying fails
+				cr, err := s.Get(ctx, cr.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if cr.Status != model.ChangeOpen || len(cr.Approvals) != 0 {
+					t.Errorf("status, approvals = %s, %d, want open, 0", cr.Status, len(cr.Approvals))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Approve error: %v", err)
+			}
+			tt.check(t, db, env, tpl)
+		})
+	}
+}
+
+func isConflict(err error) bool {
+	var conflictErr *ConflictError
+	return errors.As(err, &conflictErr)
+}
+
+func templateContent(t *testing.T, db *database.Connection, id int64) string {
+	t.Helper()
+	tpl, err := repository.NewTemplateRepository(db).GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetByID template error: %v", err)
+	}
+	return tpl.Content
+}
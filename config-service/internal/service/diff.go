@@ -0,0 +1,101 @@
+package service
+
+import (
+	"reflect"
+	"slices"
+	"sort"
+
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/secrets"
+)
+
+// templateDiff lists the fields that differ between two versions of a
+// template. old is nil for creations and new for deletions. Schema and
+// default values are compared key by key; secret values are masked.
+func templateDiff(old, new *model.Template) []model.FieldChange {
+	var a, b model.Template
+	if old != nil {
+		a = *old
+	}
+	if new != nil {
+		b = *new
+	}
+
+	changes := []model.FieldChange{}
+	field := func(path string, x, y interface{}, set bool) {
+		if reflect.DeepEqual(x, y) {
+			return
+		}
+		change := model.FieldChange{Path: path}
+		if old != nil {
+			change.Old = x
+		}
+		if set {
+			change.New = y
+		}
+		changes = append(changes, change)
+	}
+
+	field("name", a.Name, b.Name, new != nil)
+	field("description", a.Description, b.Description, new != nil)
+	field("format", a.Format, b.Format, new != nil)
+	field("content", a.Content, b.Content, new != nil)
+	field("version", a.Version, b.Version, new != nil)
+	field("environment_id", a.EnvironmentID, b.EnvironmentID, new != nil)
+	field("active", a.Active, b.Active, new != nil)
+	if new == nil || b.TagIDs != nil {
+		field("tag_ids", sortedIDs(a.TagIDs), sortedIDs(b.TagIDs), new != nil)
+	}
+	changes = append(changes, diffValues("schema", a.Schema, b.Schema)...)
+	changes = append(changes, diffValues("default_values", a.DefaultValues, b.DefaultValues)...)
+	return changes
+}
+
+// diffValues compares two JSON objects leaf by leaf, in key order. Nested
+// objects are descended into, while arrays and secret envelopes are compared
+// as a whole.
+func diffValues(prefix string, old, new map[string]interface{}) []model.FieldChange {
+	keys := make([]string, 0, len(old)+len(new))
+	for k := range old {
+		keys = append(keys, k)
+	}
+	for k := range new {
+		if _, ok := old[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []model.FieldChange
+	for _, k := range keys {
+		path := prefix + "." + k
+		x, inOld := old[k]
+		y, inNew := new[k]
+		xm, xObj := x.(map[string]interface{})
+		ym, yObj := y.(map[string]interface{})
+		if xObj && yObj && !secrets.IsEnvelope(x) && !secrets.IsEnvelope(y) {
+			changes = append(changes, diffValues(path, xm, ym)...)
+			continue
+		}
+		if inOld && inNew && reflect.DeepEqual(x, y) {
+			continue
+		}
+
+		change := model.FieldChange{Path: path}
+		if inOld {
+			change.Old = secrets.MaskValue(x)
+		}
+		if inNew {
+			change.New = secrets.MaskValue(y)
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// sortedIDs returns a sorted copy of ids, empty rather than nil
+func sortedIDs(ids []int64) []int64 {
+	out := append([]int64{}, ids...)
+	slices.Sort(out)
+	return out
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/company/config-service/internal/model"
+)
+
+func TestTemplateDiff(t *testing.T) {
+	old := &model.Template{
+		Name:          "app",
+		Content:       "a: 1",
+		Version:       "1.0.0",
+		Active:        true,
+		TagIDs:        []int64{2, 1},
+		DefaultValues: model.JSONMap{"b": 1, "db": map[string]interface{}{"host": "a", "port": 5432}, "list": []interface{}{1}},
+	}
+	tests := []struct {
+		name string
+		old  *model.Template
+		new  func(t model.Template) *model.Template
+		want []model.FieldChange
+	}{
+		{
+			name: "unchanged, tags in another order",
+			old:  old,
+			new: func(t model.Template) *model.Template {
+				t.TagIDs = []int64{1, 2}
+				return &t
+			},
+			want: []model.FieldChange{},
+		},
+		{
+			name: "tags left alone",
+			old:  old,
+			new: func(t model.Template) *model.Template {
+				t.TagIDs = nil
+				return &t
+			},
+			want: []model.FieldChange{},
+		},
+		{
+			name: "fields in order, values key by key",
+			old:  old,
+			new: func(t model.Template) *model.Template {
+				t.Version, t.Content, t.Active = "1.1.0", "a: 2", false
+				t.TagIDs = []int64{3}
+				t.DefaultValues = model.JSONMap{
+					"a":    true,
+					"db":   map[string]interface{}{"host": "b", "port": 5432},
+					"list": []interface{}{1, 2},
+				}
+				return &t
+			},
+			want: []model.FieldChange{
+				{Path: "content", Old: "a: 1", New: "a: 2"},
+				{Path: "version", Old: "1.0.0", New: "1.1.0"},
+				{Path: "active", Old: true, New: false},
+				{Path: "tag_ids", Old: []int64{1, 2}, New: []int64{3}},
+				{Path: "default_values.a", New: true},
+				{Path: "default_values.b", Old: 1},
+				{Path: "default_values.db.host", Old: "a", New: "b"},
+				{Path: "default_values.list", Old: []interface{}{1}, New: []interface{}{1, 2}},
+			},
+		},
+		{
+			name: "created",
+			new: func(model.Template) *model.Template {
+				return &model.Template{Name: "app", Version: "1.0.0"}
+			},
+			want: []model.FieldChange{
+				{Path: "name", New: "app"},
+				{Path: "version", New: "1.0.0"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var base model.Template
+			if tt.old != nil {
+				base = *tt.old
+			}
+			if got := templateDiff(tt.old, tt.new(base)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("templateDiff = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	deleted := templateDiff(old, nil)
+	if len(deleted) == 0 || deleted[0] != (model.FieldChange{Path: "name", Old: "app"}) {
+		t.Errorf("templateDiff of a deletion = %+v, want old values only", deleted)
+	}
+}
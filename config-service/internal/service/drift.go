@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/pkg/metrics"
+)
+
+// DriftOptions sets which check-ins count towards drift reports
+type DriftOptions struct {
+	// StaleAfter is how long an instance may go without checking in before
+	// it is only counted as stale
+	StaleAfter time.Duration
+	// Retention is how long the check-in of a silent instance is kept
+	Retention time.Duration
+}
+
+// DriftService records which templates client instances have applied and
+// reports the instances whose configuration differs from what is currently
+// served to them
+type DriftService struct {
+	environments *repository.EnvironmentRepository
+	checkIns     *repository.CheckInRepository
+	bundles      *BundleService
+	options      DriftOptions
+}
+
+// NewDriftService creates a new drift service comparing check-ins with the
+// bundles rendered by bundles
+func NewDriftService(environments *repository.EnvironmentRepository, checkIns *repository.CheckInRepository,
+	bundles *BundleService, options DriftOptions) *DriftService {
+	return &DriftService{
+		environments: environments,
+		checkIns:     checkIns,
+		bundles:      bundles,
+		options:      options,
+	}
+}
+
+// CheckIn records the templates an instance of an environment has applied,
+// replacing its previous check-in
+func (s *DriftService) CheckIn(ctx context.Context, slug string, req model.CheckInRequest) (*model.CheckIn, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+	env, err := s.environments.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &model.CheckIn{
+		EnvironmentID:  env.ID,
+		InstanceID:     req.InstanceID,
+		AgentVersion:   req.AgentVersion,
+		BundleChecksum: req.BundleChecksum,
+		Templates:      req.Templates,
+	}
+	if err := s.checkIns.Upsert(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Report compares the latest check-in of every instance of an environment
+// with the bundle currently served to it, taking rollouts into account, and
+// updates the drifted instance gauge. Only the templates an instance reported
+// are compared, so instances fetching a subset by selector are not drifted
+// by the templates they leave out. With all, instances in sync are listed
+// too.
+func (s *DriftService) Report(ctx context.Context, slug string, all bool) (*model.DriftReport, error) {
+	env, err := s.environments.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	return s.report(ctx, env, all)
+}
+
+func (s *DriftService) report(ctx context.Context, env *model.Environment, all bool) (*model.DriftReport, error) {
+	checkIns, err := s.checkIns.ListByEnvironment(ctx, env.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	resp := &model.DriftReport{
+		Environment: env.Slug,
+		GeneratedAt: now.UTC(),
+		Drifted:     []model.InstanceDrift{},
+	}
+	fresh, stale := freshCheckIns(checkIns, now, s.options.StaleAfter)
+	resp.Instances, resp.StaleInstances = len(fresh), stale
+
+	if len(fresh) > 0 {
+		instances := make([]string, len(fresh))
+		for i, c := range fresh {
+			instances[i] = c.InstanceID
+		}
+		expected, err := s.bundles.Expected(ctx, env, instances)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range fresh {
+			d := instanceDrift(c, expected[c.InstanceID])
+			switch {
+			case d.Drifted:
+				resp.DriftedInstances++
+				resp.Drifted = append(resp.Drifted, d)
+			case all:
+				resp.InSync = append(resp.InSync, d)
+			}
+		}
+	}
+
+	metrics.UpdateDriftedInstances(env.Slug, resp.DriftedInstances)
+	return resp, nil
+}
+
+// RefreshMetrics forgets instances that have not checked in within the
+// retention and recomputes the drifted instance gauge of every environment
+func (s *DriftService) RefreshMetrics(ctx context.Context) error {
+	if s.options.Retention > 0 {
+		if _, err := s.checkIns.DeleteBefore(ctx, time.Now().Add(-s.options.Retention)); err != nil {
+			return err
+		}
+	}
+
+	environments, err := s.environments.List(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range environments {
+		if _, err := s.report(ctx, &environments[i], false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// freshCheckIns returns the check-ins made within staleAfter of now and the
+// number of older ones; a zero staleAfter keeps all
+func freshCheckIns(checkIns []model.CheckIn, now time.Time, staleAfter time.Duration) ([]model.CheckIn, int) {
+	var fresh []model.CheckIn
+	for _, c := range checkIns {
+		if staleAfter > 0 && now.Sub(c.CheckedInAt) > staleAfter {
+			continue
+		}
+		fresh = append(fresh, c)
+	}
+	return fresh, len(checkIns) - len(fresh)
+}
+
+// instanceDrift compares the templates applied by an instance with those
+// expected for it. Checksums are not compared for templates that fail to
+// render.
+func instanceDrift(c model.CheckIn, expected map[int64]ExpectedTemplate) model.InstanceDrift {
+	d := model.InstanceDrift{
+		InstanceID:   c.InstanceID,
+		AgentVersion: c.AgentVersion,
+		CheckedInAt:  c.CheckedInAt,
+		Applied:      len(c.Templates),
+		Templates:    []model.TemplateDrift{},
+	}
+	for _, applied := range c.Templates {
+		td := model.TemplateDrift{
+			TemplateID:      applied.TemplateID,
+			AppliedVersion:  applied.Version,
+			AppliedChecksum: applied.Checksum,
+		}
+		e, ok := expected[applied.TemplateID]
+		switch {
+		case !ok:
+			td.Reason = model.DriftUnknownTemplate
+		case applied.Version != e.Version:
+			td.Reason = model.DriftBehind
+		case e.Checksum != "" && applied.Checksum != e.Checksum:
+			td.Reason = model.DriftUnexpectedChecksum
+		default:
+			continue
+		}
+		if ok {
+			td.Name, td.ExpectedVersion = e.Name, e.Version
+		}
+		d.Templates = append(d.Templates, td)
+	}
+	d.Drifted = len(d.Templates) > 0
+	return d
+}
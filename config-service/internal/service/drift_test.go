@@ -0,0 +1,109 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/company/config-service/internal/model"
+)
+
+func TestInstanceDrift(t *testing.T) {
+	expected := map[int64]ExpectedTemplate{
+		1: {Name: "app.yaml", Version: "1.2.0", Checksum: "aaa"},
+		2: {Name: "db.env", Version: "3", Checksum: "bbb"},
+		// Failed to render, so its checksum is unknown
+		3: {Name: "broken.json", Version: "7"},
+	}
+
+	tests := []struct {
+		name    string
+		applied []model.AppliedTemplate
+		want    map[int64]string
+	}{
+		{
+			name:    "in sync",
+			applied: []model.AppliedTemplate{{TemplateID: 1, Version: "1.2.0", Checksum: "aaa"}, {TemplateID: 2, Version: "3", Checksum: "bbb"}},
+			want:    map[int64]string{},
+		},
+		{
+			name:    "subset in sync",
+			applied: []model.AppliedTemplate{{TemplateID: 2, Version: "3", Checksum: "bbb"}},
+			want:    map[int64]string{},
+		},
+		{
+			name:    "behind",
+			applied: []model.AppliedTemplate{{TemplateID: 1, Version: "1.1.0", Checksum: "aaa"}},
+			want:    map[int64]string{1: model.DriftBehind},
+		},
+		{
+			name:    "behind wins over checksum",
+			applied: []model.AppliedTemplate{{TemplateID: 1, Version: "1.1.0", Checksum: "zzz"}},
+			want:    map[int64]string{1: model.DriftBehind},
+		},
+		{
+			name:    "edited locally",
+			applied: []model.AppliedTemplate{{TemplateID: 2, Version: "3", Checksum: "zzz"}},
+			want:    map[int64]string{2: model.DriftUnexpectedChecksum},
+		},
+		{
+			name:    "no longer served",
+			applied: []model.AppliedTemplate{{TemplateID: 9, Version: "1", Checksum: "ccc"}, {TemplateID: 1, Version: "1.2.0", Checksum: "aaa"}},
+			want:    map[int64]string{9: model.DriftUnknownTemplate},
+		},
+		{
+			name:    "render failure skips checksum",
+			applied: []model.AppliedTemplate{{TemplateID: 3, Version: "7", Checksum: "ddd"}},
+			want:    map[int64]string{},
+		},
+		{
+			name:    "nothing applied",
+			applied: nil,
+			want:    map[int64]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := model.CheckIn{InstanceID: "web-1", AgentVersion: "1.0.0", Templates: tt.applied}
+			d := instanceDrift(c, expected)
+			if d.InstanceID != "web-1" || d.Applied != len(tt.applied) {
+				t.Errorf("instance %s with %d applied, want web-1 with %d", d.InstanceID, d.Applied, len(tt.applied))
+			}
+			if d.Drifted != (len(tt.want) > 0) {
+				t.Errorf("Drifted = %v, want %v", d.Drifted, len(tt.want) > 0)
+			}
+			if len(d.Templates) != len(tt.want) {
+				t.Fatalf("%d drifted templates, want %d: %+v", len(d.Templates), len(tt.want), d.Templates)
+			}
+			for _, td := range d.Templates {
+				if td.Reason != tt.want[td.TemplateID] {
+					t.Errorf("template %d drifted as %q, want %q", td.TemplateID, td.Reason, tt.want[td.TemplateID])
+				}
+				// Unknown templates have no expected name or version
+				e := expected[td.TemplateID]
+				if td.Name != e.Name || td.ExpectedVersion != e.Version {
+					t.Errorf("template %d expected as %q %q, want %q %q", td.TemplateID, td.Name, td.ExpectedVersion, e.Name, e.Version)
+				}
+			}
+		})
+	}
+}
+
+func TestFreshCheckIns(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	checkIns := []model.CheckIn{
+		{InstanceID: "a", CheckedInAt: now.Add(-time.Minute)},
+		{InstanceID: "b", CheckedInAt: now.Add(-10 * time.Minute)},
+		{InstanceID: "c", CheckedInAt: now.Add(-11 * time.Minute)},
+		{InstanceID: "d", CheckedInAt: now.Add(-48 * time.Hour)},
+	}
+
+	fresh, stale := freshCheckIns(checkIns, now, 10*time.Minute)
+	if len(fresh) != 2 || fresh[0].InstanceID != "a" || fresh[1].InstanceID != "b" || stale != 2 {
+		t.Errorf("freshCheckIns = %v, %d stale, want a and b, 2 stale", fresh, stale)
+	}
+
+	// Without a threshold no instance is stale
+	if fresh, stale := freshCheckIns(checkIns, now, 0); len(fresh) != 4 || stale != 0 {
+		t.Errorf("freshCheckIns without threshold = %d fresh, %d stale", len(fresh), stale)
+	}
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/lib/pq"
+)
+
+// EnvironmentService provides access to environments, their protection and
+// cloning
+type EnvironmentService struct {
+	db           *database.Connection
+	environments *repository.EnvironmentRepository
+	templates    *repository.TemplateRepository
+	audit        *repository.AuditRepository
+}
+
+// NewEnvironmentService creates a new environment service
+func NewEnvironmentService(db *database.Connection, environments *repository.EnvironmentRepository,
+	templates *repository.TemplateRepository, audit *repository.AuditRepository) *EnvironmentService {
+	return &EnvironmentService{db: db, environments: environments, templates: templates, audit: audit}
+}
+
+// AsOf returns a copy of the service reading the state recorded in the
+// history at the given moment, or the service itself for nil. The copy only
+// serves reads.
+func (s *EnvironmentService) AsOf(at *time.Time) *EnvironmentService {
+	if at == nil {
+		return s
+	}
+	c := *s
+	c.environments = s.environments.AsOf(*at)
+	c.templates = s.templates.AsOf(*at)
+	return &c
+}
+
+// List returns all environments ordered by priority
+func (s *EnvironmentService) List(ctx context.Context) (*model.EnvironmentListResponse, error) {
+	environments, err := s.environments.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &model.EnvironmentListResponse{
+		Environments: make([]model.EnvironmentResponse, 0, len(environments)),
+		Total:        int64(len(environments)),
+	}
+	for _, env := range environments {
+		resp.Environments = append(resp.Environments, model.NewEnvironmentResponse(env))
+	}
+	return resp, nil
+}
+
+// ListPage returns one page of environments
+func (s *EnvironmentService) ListPage(ctx context.Context, page model.PaginationParams, sort model.SortParams) (*model.EnvironmentListResponse, error) {
+	if err := validateStruct(page); err != nil {
+		return nil, err
+	}
+	if err := validateStruct(sort); err != nil {
+		return nil, err
+	}
+
+	environments, info, err := s.environments.ListPage(ctx, page, sort)
+	if err != nil {
+		return nil, pageError(err)
+	}
+
+	resp := &model.EnvironmentListResponse{
+		Environments: make([]model.EnvironmentResponse, 0, len(environments)),
+		Total:        info.Total,
+		Page:         pageNumber(page),
+		PageSize:     page.PageSize,
+		HasNext:      info.HasNext,
+		NextCursor:   info.NextCursor,
+		PrevCursor:   info.PrevCursor,
+	}
+	for _, env := range environments {
+		resp.Environments = append(resp.Environments, model.NewEnvironmentResponse(env))
+	}
+	return resp, nil
+}
+
+// SetProtection marks an environment protected or lifts its protection and
+// records the change in the audit log. Change requests already open keep
+// the number of approvals they were opened with.
+func (s *EnvironmentService) SetProtection(ctx context.Context, slug string, req model.EnvironmentProtectionRequest, actor string) (*model.EnvironmentResponse, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+
+	var env *model.Environment
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		repo := s.environments.WithTx(tx)
+		var err error
+		if env, err = repo.GetBySlug(ctx, slug); err != nil {
+			return err
+		}
+		from := model.JSONMap{"protected": env.Protected, "required_approvals": env.RequiredApprovals}
+
+		env.Protected = *req.Protected
+		if req.RequiredApprovals != nil {
+			env.RequiredApprovals = *req.RequiredApprovals
+		}
+		if err := repo.SetProtection(ctx, env); err != nil {
+			return err
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditEnvironmentProtect,
+			EntityType: "environment",
+			EntityID:   &env.ID,
+			Actor:      actor,
+			Details: model.JSONMap{
+				"from": from,
+				"to":   model.JSONMap{"protected": env.Protected, "required_approvals": env.RequiredApprovals},
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := model.NewEnvironmentResponse(*env)
+	return &resp, nil
+}
+
+// Clone creates an environment under a new slug holding a copy of every
+// template of the source environment with its tag links. Default values are
+// copied as stored, so secret values are carried over without being
+// decrypted. The new environment starts unprotected; candidates, flags and
+// freeze windows of the source are not copied.
+func (s *EnvironmentService) Clone(ctx context.Context, slug string, req model.CloneEnvironmentRequest, actor string) (*model.CloneEnvironmentResponse, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+
+	resp := &model.CloneEnvironmentResponse{Source: slug}
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		environments := s.environments.WithTx(tx)
+		source, err := environments.GetBySlug(ctx, slug)
+		if err != nil {
+			return err
+		}
+
+		env := &model.Environment{
+			Name:        req.Name,
+			Slug:        req.Slug,
+			Description: source.Description,
+			Active:      source.Active,
+			Priority:    source.Priority,
+		}
+		if req.Description != nil {
+			env.Description = *req.Description
+		}
+		if req.Active != nil {
+			env.Active = *req.Active
+		}
+		if req.Priority != nil {
+			env.Priority = *req.Priority
+		}
+		if err := environments.Create(ctx, env); err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+				return &ConflictError{Conflicts: []string{fmt.Sprintf("environment %s (%s) already exists", env.Slug, env.Name)}}
+			}
+			return err
+		}
+		if env, err = environments.GetByID(ctx, env.ID); err != nil {
+			return err
+		}
+
+		templates := s.templates.WithTx(tx)
+		sources, err := templates.ListByEnvironment(ctx, source.ID, repository.TemplateLoad{Tags: true})
+		if err != nil {
+			return err
+		}
+		for _, tpl := range sources {
+			tagIDs := tpl.TagIDs
+			tpl.EnvironmentID = env.ID
+			tpl.CreatedBy, tpl.UpdatedBy = actor, actor
+			if err := templates.Create(ctx, &tpl); err != nil {
+				return err
+			}
+			if err := templates.SetTags(ctx, tpl.ID, tagIDs); err != nil {
+				return err
+			}
+			resp.TagLinks += len(tagIDs)
+		}
+		resp.Templates = len(sources)
+		resp.Environment = model.NewEnvironmentResponse(*env)
+
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditEnvironmentClone,
+			EntityType: "environment",
+			EntityID:   &env.ID,
+			Actor:      actor,
+			Details: model.JSONMap{
+				"source":    source.Slug,
+				"slug":      env.Slug,
+				"templates": resp.Templates,
+				"tag_links": resp.TagLinks,
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ValidationError reports input that cannot be processed
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ConflictError reports records that already exist and may not be replaced
+type ConflictError struct {
+	Conflicts []string
+}
+
+func (e *ConflictError) Error() string {
+	return "conflicting records: " + strings.Join(e.Conflicts, ", ")
+}
+
+// PermissionError reports a caller lacking the permission an operation needs
+type PermissionError struct {
+	Permission string
+	Message    string
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("permission %s required: %s", e.Permission, e.Message)
+}
+
+// ProtectedError reports a direct change to the templates of a protected
+// environment, which only change through approved change requests
+type ProtectedError struct {
+	Environment string
+}
+
+func (e *ProtectedError) Error() string {
+	return fmt.Sprintf("environment %s is protected, its templates change through change requests", e.Environment)
+}
+
+// FrozenError reports a template write in an environment during one of its
+// freeze windows
+type FrozenError struct {
+	Environment string
+	Window      string
+	Reason      string
+	Until       time.Time
+}
+
+func (e *FrozenError) Error() string {
+	msg := fmt.Sprintf("environment %s is frozen by window %q until %s", e.Environment, e.Window, e.Until.UTC().Format(time.RFC3339))
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+)
+
+// createTestEnvironment creates an active environment named after its slug
+func createTestEnvironment(t *testing.T, db *database.Connection, slug string) *model.Environment {
+	t.Helper()
+	env := &model.Environment{Name: slug, Slug: slug, Active: true}
+	if err := repository.NewEnvironmentRepository(db).Create(context.Background(), env); err != nil {
+		t.Fatalf("Create environment error: %v", err)
+	}
+	return env
+}
+
+// createTestTemplate creates an active template linked to the given tags
+func createTestTemplate(t *testing.T, db *database.Connection, envID int64, name string, format model.ConfigFormat,
+	values model.JSONMap, tagIDs ...int64) *model.Template {
+	t.Helper()
+	ctx := context.Background()
+	templates := repository.NewTemplateRepository(db)
+	tpl := &model.Template{
+		Name:          name,
+		Format:        format,
+		Content:       "{{ . }}",
+		DefaultValues: values,
+		Version:       "1.0.0",
+		EnvironmentID: envID,
+		Active:        true,
+		CreatedBy:     "alice",
+		UpdatedBy:     "alice",
+	}
+	if err := templates.Create(ctx, tpl); err != nil {
+		t.Fatalf("Create template error: %v", err)
+	}
+	if err := templates.SetTags(ctx, tpl.ID, tagIDs); err != nil {
+		t.Fatalf("SetTags error: %v", err)
+	}
+	tpl.TagIDs = tagIDs
+	return tpl
+}
+
+// protectTestEnvironment requires approvals for template changes in env
+func protectTestEnvironment(t *testing.T, db *database.Connection, env *model.Environment, approvals int) {
+	t.Helper()
+	env.Protected, env.RequiredApprovals = true, approvals
+	if err := repository.NewEnvironmentRepository(db).SetProtection(context.Background(), env); err != nil {
+		t.Fatalf("SetProtection error: %v", err)
+	}
+}
+
+// freezeTestEnvironment opens a freeze window on env from an hour ago until
+// an hour from now
+func freezeTestEnvironment(t *testing.T, db *database.Connection, envID int64, name string) *model.FreezeWindow {
+	t.Helper()
+	starts, ends := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	w := &model.FreezeWindow{
+		EnvironmentID: envID,
+		Name:          name,
+		Reason:        "release",
+		Timezone:      "UTC",
+		StartsAt:      &starts,
+		EndsAt:        &ends,
+		CreatedBy:     "alice",
+	}
+	if err := repository.NewFreezeWindowRepository(db).Create(context.Background(), w); err != nil {
+		t.Fatalf("Create freeze window error: %v", err)
+	}
+	return w
+}
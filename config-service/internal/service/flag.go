@@ -0,0 +1,394 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/flags"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/selector"
+	"github.com/company/config-service/pkg/metrics"
+	"github.com/lib/pq"
+)
+
+// flagKeyPattern restricts flag keys to names usable in URLs and code
+var flagKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// maxEvaluatedFlags bounds the flags requested by key in one evaluation
+const maxEvaluatedFlags = 500
+
+// FlagService manages the feature flags of environments and evaluates them
+type FlagService struct {
+	db           *database.Connection
+	environments *repository.EnvironmentRepository
+	tags         *repository.TagRepository
+	flags        *repository.FlagRepository
+	freezes      *repository.FreezeWindowRepository
+	audit        *repository.AuditRepository
+}
+
+// NewFlagService creates a new feature flag service
+func NewFlagService(db *database.Connection, environments *repository.EnvironmentRepository,
+	tags *repository.TagRepository, flags *repository.FlagRepository,
+	freezes *repository.FreezeWindowRepository, audit *repository.AuditRepository) *FlagService {
+	return &FlagService{
+		db:           db,
+		environments: environments,
+		tags:         tags,
+		flags:        flags,
+		freezes:      freezes,
+		audit:        audit,
+	}
+}
+
+// List returns the flags of an environment matched by a tag selector; a nil
+// selector matches all flags
+func (s *FlagService) List(ctx context.Context, slug string, expr selector.Expr) (*model.FlagListResponse, error) {
+	env, all, err := s.load(ctx, slug, expr)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &model.FlagListResponse{
+		Environment: env.Slug,
+		Flags:       make([]model.FlagResponse, 0, len(all)),
+	}
+	if expr != nil {
+		resp.Selector = expr.String()
+	}
+	for i := range all {
+		resp.Flags = append(resp.Flags, flagResponse(&all[i], env))
+	}
+	return resp, nil
+}
+
+// Get returns a flag of an environment by key
+func (s *FlagService) Get(ctx context.Context, slug, key string) (*model.FlagResponse, error) {
+	env, err := s.environments.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.flags.GetByKey(ctx, env.ID, key)
+	if err != nil {
+		return nil, err
+	}
+	resp := flagResponse(f, env)
+	return &resp, nil
+}
+
+// Create adds a flag to an environment. It returns a *ConflictError when the
+// key is taken and a *FrozenError during a freeze window.
+func (s *FlagService) Create(ctx context.Context, slug string, req model.CreateFlagRequest, actor string) (*model.FlagResponse, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+	if !flagKeyPattern.MatchString(req.Key) {
+		return nil, &ValidationError{Field: "key", Message: "may only contain letters, digits, '.', '_' and '-'"}
+	}
+
+	f := &model.Flag{
+		Key:          req.Key,
+		Description:  req.Description,
+		Type:         req.Type,
+		Variations:   req.Variations,
+		Enabled:      req.Enabled,
+		OffVariation: req.OffVariation,
+		Rules:        req.Rules,
+		BucketBy:     req.BucketBy,
+		CreatedBy:    actor,
+		UpdatedBy:    actor,
+	}
+	if f.Type == model.FlagBoolean && len(f.Variations) == 0 {
+		f.Variations = []model.FlagVariation{{Name: "true", Value: true}, {Name: "false", Value: false}}
+	}
+	if f.OffVariation == "" {
+		f.OffVariation = defaultVariation(f)
+	}
+	if req.Fallthrough != nil {
+		f.Fallthrough = *req.Fallthrough
+	} else {
+		f.Fallthrough = model.FlagServe{Variation: f.OffVariation}
+	}
+	if f.BucketBy == "" {
+		f.BucketBy = flags.KeyAttribute
+	}
+	if err := validateFlag(f); err != nil {
+		return nil, err
+	}
+
+	var env *model.Environment
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		if env, err = s.writable(ctx, tx, slug); err != nil {
+			return err
+		}
+		tagIDs, err := resolveTags(ctx, s.tags.WithTx(tx), "tags", req.Tags)
+		if err != nil {
+			return err
+		}
+
+		repo := s.flags.WithTx(tx)
+		f.EnvironmentID = env.ID
+		if err := repo.Create(ctx, f); err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+				return &ConflictError{Conflicts: []string{fmt.Sprintf("flag %q already exists in %s", f.Key, env.Slug)}}
+			}
+			return err
+		}
+		if err := repo.SetTags(ctx, f.ID, tagIDs); err != nil {
+			return err
+		}
+		if f, err = repo.GetByKey(ctx, env.ID, f.Key); err != nil {
+			return err
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditFlagCreate,
+			EntityType: "feature_flag",
+			EntityID:   &f.ID,
+			Actor:      actor,
+			Details:    model.JSONMap{"environment": env.Slug, "key": f.Key, "enabled": f.Enabled},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := flagResponse(f, env)
+	return &resp, nil
+}
+
+// Update changes the fields of a flag set in req. It returns a *FrozenError
+// during a freeze window.
+func (s *FlagService) Update(ctx context.Context, slug, key string, req model.UpdateFlagRequest, actor string) (*model.FlagResponse, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+
+	var env *model.Environment
+	var f *model.Flag
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		if env, err = s.writable(ctx, tx, slug); err != nil {
+			return err
+		}
+		repo := s.flags.WithTx(tx)
+		if f, err = repo.GetByKey(ctx, env.ID, key); err != nil {
+			return err
+		}
+
+		previous := *f
+		applyFlagUpdate(f, &req)
+		f.UpdatedBy = actor
+		if err := validateFlag(f); err != nil {
+			return err
+		}
+		if err := repo.Update(ctx, f); err != nil {
+			return err
+		}
+		if req.Tags != nil {
+			tagIDs, err := resolveTags(ctx, s.tags.WithTx(tx), "tags", req.Tags)
+			if err != nil {
+				return err
+			}
+			if err := repo.SetTags(ctx, f.ID, tagIDs); err != nil {
+				return err
+			}
+			if f, err = repo.GetByKey(ctx, env.ID, key); err != nil {
+				return err
+			}
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditFlagUpdate,
+			EntityType: "feature_flag",
+			EntityID:   &f.ID,
+			Actor:      actor,
+			Details: model.JSONMap{
+				"environment": env.Slug,
+				"key":         f.Key,
+				"previous":    previous,
+				"current":     *f,
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := flagResponse(f, env)
+	return &resp, nil
+}
+
+// Delete removes a flag of an environment. It returns a *FrozenError during
+// a freeze window.
+func (s *FlagService) Delete(ctx context.Context, slug, key, actor string) error {
+	return s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		env, err := s.writable(ctx, tx, slug)
+		if err != nil {
+			return err
+		}
+		repo := s.flags.WithTx(tx)
+		f, err := repo.GetByKey(ctx, env.ID, key)
+		if err != nil {
+			return err
+		}
+		if err := repo.Delete(ctx, f.ID); err != nil {
+			return err
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditFlagDelete,
+			EntityType: "feature_flag",
+			EntityID:   &f.ID,
+			Actor:      actor,
+			Details:    model.JSONMap{"environment": env.Slug, "key": f.Key},
+		})
+	})
+}
+
+// Evaluate evaluates the flags of an environment for a context: those named
+// in req, or otherwise all matched by its tag selector. Unknown keys are
+// left out of the response.
+func (s *FlagService) Evaluate(ctx context.Context, slug string, req model.EvaluateFlagsRequest) (*model.EvaluateFlagsResponse, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+	if len(req.Flags) > maxEvaluatedFlags {
+		return nil, &ValidationError{Field: "flags", Message: fmt.Sprintf("at most %d flags can be evaluated at once", maxEvaluatedFlags)}
+	}
+	expr, err := selector.Parse(req.Selector)
+	if err != nil {
+		return nil, &ValidationError{Field: "selector", Message: err.Error()}
+	}
+
+	env, all, err := s.load(ctx, slug, expr)
+	if err != nil {
+		return nil, err
+	}
+
+	var wanted map[string]bool
+	if len(req.Flags) > 0 {
+		wanted = make(map[string]bool, len(req.Flags))
+		for _, key := range req.Flags {
+			wanted[key] = true
+		}
+	}
+	resp := &model.EvaluateFlagsResponse{
+		Environment: env.Slug,
+		Flags:       make(map[string]model.FlagEvaluation, len(all)),
+	}
+	for i := range all {
+		f := &all[i]
+		if wanted != nil && !wanted[f.Key] {
+			continue
+		}
+		result := flags.Evaluate(f, req.Context)
+		metrics.RecordFlagEvaluation(env.Slug, f.Key, result.Variation)
+		resp.Flags[f.Key] = result
+	}
+	return resp, nil
+}
+
+// load resolves the environment by slug and returns its flags matched by a
+// tag selector
+func (s *FlagService) load(ctx context.Context, slug string, expr selector.Expr) (*model.Environment, []model.Flag, error) {
+	env, err := s.environments.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, nil, err
+	}
+	all, err := s.flags.ListByEnvironment(ctx, env.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if expr == nil {
+		return env, all, nil
+	}
+
+	matched := all[:0]
+	for _, f := range all {
+		if expr.Matches(f.Tags) {
+			matched = append(matched, f)
+		}
+	}
+	return env, matched, nil
+}
+
+// writable resolves the environment by slug within tx, failing with a
+// *FrozenError during one of its freeze windows
+func (s *FlagService) writable(ctx context.Context, tx *sql.Tx, slug string) (*model.Environment, error) {
+	environments := s.environments.WithTx(tx)
+	env, err := environments.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUnfrozen(ctx, environments, s.freezes.WithTx(tx), env.ID); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// applyFlagUpdate copies the fields set in req onto f
+func applyFlagUpdate(f *model.Flag, req *model.UpdateFlagRequest) {
+	if req.Description != nil {
+		f.Description = *req.Description
+	}
+	if req.Variations != nil {
+		f.Variations = req.Variations
+	}
+	if req.Enabled != nil {
+		f.Enabled = *req.Enabled
+	}
+	if req.OffVariation != nil {
+		f.OffVariation = *req.OffVariation
+	}
+	if req.Rules != nil {
+		f.Rules = req.Rules
+	}
+	if req.Fallthrough != nil {
+		f.Fallthrough = *req.Fallthrough
+	}
+	if req.BucketBy != nil {
+		f.BucketBy = *req.BucketBy
+		if f.BucketBy == "" {
+			f.BucketBy = flags.KeyAttribute
+		}
+	}
+}
+
+// defaultVariation returns the variation served by default: false for
+// boolean flags, otherwise the first one
+func defaultVariation(f *model.Flag) string {
+	if f.Type == model.FlagBoolean {
+		return "false"
+	}
+	if len(f.Variations) > 0 {
+		return f.Variations[0].Name
+	}
+	return ""
+}
+
+// validateFlag converts targeting errors into a *ValidationError
+func validateFlag(f *model.Flag) error {
+	if err := flags.Validate(f); err != nil {
+		var flagErr *flags.Error
+		if errors.As(err, &flagErr) {
+			return &ValidationError{Field: flagErr.Field, Message: flagErr.Msg}
+		}
+		return err
+	}
+	return nil
+}
+
+// flagResponse prepares a flag of env for API responses
+func flagResponse(f *model.Flag, env *model.Environment) model.FlagResponse {
+	tags := make([]model.TagResponse, 0, len(f.Tags))
+	for _, tag := range f.Tags {
+		tags = append(tags, model.NewTagResponse(tag))
+	}
+	if f.Rules == nil {
+		f.Rules = []model.FlagRule{}
+	}
+	return model.FlagResponse{Flag: *f, Environment: env.Slug, Tags: tags}
+}
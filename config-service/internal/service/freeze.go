@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/company/config-service/internal/cron"
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+)
+
+// maxFreezeDuration bounds how long a recurring freeze window stays open
+const maxFreezeDuration = 7 * 24 * time.Hour
+
+// FreezeService manages the freeze windows blocking template writes
+type FreezeService struct {
+	db           *database.Connection
+	environments *repository.EnvironmentRepository
+	freezes      *repository.FreezeWindowRepository
+	audit        *repository.AuditRepository
+}
+
+// NewFreezeService creates a new freeze window service
+func NewFreezeService(db *database.Connection, environments *repository.EnvironmentRepository,
+	freezes *repository.FreezeWindowRepository, audit *repository.AuditRepository) *FreezeService {
+	return &FreezeService{
+		db:           db,
+		environments: environments,
+		freezes:      freezes,
+		audit:        audit,
+	}
+}
+
+// List returns the freeze windows of an environment and whether each is open
+func (s *FreezeService) List(ctx context.Context, slug string) (*model.FreezeWindowListResponse, error) {
+	env, err := s.environments.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	windows, err := s.freezes.ListByEnvironment(ctx, env.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	resp := &model.FreezeWindowListResponse{
+		Environment: env.Slug,
+		Windows:     make([]model.FreezeWindowResponse, 0, len(windows)),
+	}
+	for _, w := range windows {
+		r := freezeWindowResponse(w, now)
+		resp.Frozen = resp.Frozen || r.Active
+		resp.Windows = append(resp.Windows, r)
+	}
+	return resp, nil
+}
+
+// Create adds a freeze window to an environment
+func (s *FreezeService) Create(ctx context.Context, slug string, req model.CreateFreezeWindowRequest, actor string) (*model.FreezeWindowResponse, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+	w := &model.FreezeWindow{
+		Name:      req.Name,
+		Reason:    req.Reason,
+		Timezone:  req.Timezone,
+		CreatedBy: actor,
+	}
+	if w.Timezone == "" {
+		w.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(w.Timezone); err != nil {
+		return nil, &ValidationError{Field: "timezone", Message: "unknown time zone " + w.Timezone}
+	}
+
+	switch {
+	case req.Cron != "":
+		if req.StartsAt != nil || req.EndsAt != nil {
+			return nil, &ValidationError{Message: "cron cannot be combined with starts_at and ends_at"}
+		}
+		schedule, err := cron.Parse(req.Cron)
+		if err != nil {
+			return nil, &ValidationError{Field: "cron", Message: err.Error()}
+		}
+		duration, err := time.ParseDuration(req.Duration)
+		if err != nil || duration < time.Minute || duration > maxFreezeDuration {
+			return nil, &ValidationError{Field: "duration", Message: fmt.Sprintf("must be a duration between 1m and %s", maxFreezeDuration)}
+		}
+		w.Cron = schedule.String()
+		w.DurationSeconds = int(duration / time.Second)
+	case req.StartsAt != nil && req.EndsAt != nil:
+		if req.Duration != "" {
+			return nil, &ValidationError{Field: "duration", Message: "only applies to cron windows"}
+		}
+		if !req.EndsAt.After(*req.StartsAt) {
+			return nil, &ValidationError{Field: "ends_at", Message: "must be after starts_at"}
+		}
+		w.StartsAt, w.EndsAt = req.StartsAt, req.EndsAt
+	default:
+		return nil, &ValidationError{Message: "either cron with duration or starts_at with ends_at is required"}
+	}
+
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		env, err := s.environments.WithTx(tx).GetBySlug(ctx, slug)
+		if err != nil {
+			return err
+		}
+		w.EnvironmentID = env.ID
+
+		freezes := s.freezes.WithTx(tx)
+		if _, err := freezes.GetByName(ctx, env.ID, w.Name); err == nil {
+			return &ConflictError{Conflicts: []string{fmt.Sprintf("freeze window %q already exists in environment %s", w.Name, env.Slug)}}
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		if err := freezes.Create(ctx, w); err != nil {
+			return err
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditFreezeWindowCreate,
+			EntityType: "freeze_window",
+			EntityID:   &w.ID,
+			Actor:      actor,
+			Details:    model.JSONMap{"environment": env.Slug, "name": w.Name, "reason": w.Reason},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := freezeWindowResponse(*w, time.Now())
+	return &resp, nil
+}
+
+// Delete removes a freeze window of an environment
+func (s *FreezeService) Delete(ctx context.Context, slug string, id int64, actor string) error {
+	return s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		env, err := s.environments.WithTx(tx).GetBySlug(ctx, slug)
+		if err != nil {
+			return err
+		}
+		if err := s.freezes.WithTx(tx).Delete(ctx, env.ID, id); err != nil {
+			return err
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditFreezeWindowDelete,
+			EntityType: "freeze_window",
+			EntityID:   &id,
+			Actor:      actor,
+			Details:    model.JSONMap{"environment": env.Slug},
+		})
+	})
+}
+
+// checkUnfrozen fails with a *FrozenError when one of the environments is
+// within a freeze window
+func checkUnfrozen(ctx context.Context, environments *repository.EnvironmentRepository, freezes *repository.FreezeWindowRepository, ids ...int64) error {
+	now := time.Now()
+	for _, id := range ids {
+		windows, err := freezes.ListByEnvironment(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		var frozen *FrozenError
+		for _, w := range windows {
+			active, until, _ := windowState(w, now)
+			if active && (frozen == nil || until.After(frozen.Until)) {
+				frozen = &FrozenError{Window: w.Name, Reason: w.Reason, Until: until}
+			}
+		}
+		if frozen == nil {
+			continue
+		}
+		env, err := environments.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		frozen.Environment = env.Slug
+		return frozen
+	}
+	return nil
+}
+
+// windowState reports whether a freeze window is open at now and until when,
+// or otherwise when it opens next within maxFreezeDuration
+func windowState(w model.FreezeWindow, now time.Time) (active bool, until, next time.Time) {
+	if w.Cron == "" {
+		switch {
+		case w.StartsAt == nil || w.EndsAt == nil:
+			return false, time.Time{}, time.Time{}
+		case now.Before(*w.StartsAt):
+			return false, time.Time{}, *w.StartsAt
+		case now.Before(*w.EndsAt):
+			return true, *w.EndsAt, time.Time{}
+		}
+		return false, time.Time{}, time.Time{}
+	}
+
+	schedule, err := cron.Parse(w.Cron)
+	if err != nil {
+		return false, time.Time{}, time.Time{}
+	}
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	duration := time.Duration(w.DurationSeconds) * time.Second
+	local := now.In(loc)
+
+	// A window opened at start covers [start, start+duration)
+	if start, ok := schedule.Prev(local, duration-time.Nanosecond); ok {
+		return true, start.Add(duration), time.Time{}
+	}
+	if start, ok := schedule.Next(local, maxFreezeDuration); ok {
+		return false, time.Time{}, start
+	}
+	return false, time.Time{}, time.Time{}
+}
+
+// freezeWindowResponse describes a freeze window and its state at now
+func freezeWindowResponse(w model.FreezeWindow, now time.Time) model.FreezeWindowResponse {
+	if w.DurationSeconds > 0 {
+		w.Duration = (time.Duration(w.DurationSeconds) * time.Second).String()
+	}
+	resp := model.FreezeWindowResponse{FreezeWindow: w}
+	active, until, next := windowState(w, now)
+	resp.Active = active
+	if !until.IsZero() {
+		resp.ActiveUntil = &until
+	}
+	if !next.IsZero() {
+		resp.NextStart = &next
+	}
+	return resp
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/company/config-service/internal/model"
+)
+
+func TestWindowState(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.UTC)
+	}
+	starts, ends := at(16, 12, 0), at(17, 0, 0)
+	once := model.FreezeWindow{Timezone: "UTC", StartsAt: &starts, EndsAt: &ends}
+	// Fridays from noon for twelve hours
+	weekly := model.FreezeWindow{Timezone: "UTC", Cron: "0 12 * * FRI", DurationSeconds: 12 * 3600}
+	berlin := model.FreezeWindow{Timezone: "Europe/Berlin", Cron: "0 9 * * *", DurationSeconds: 3600}
+
+	tests := []struct {
+		name   string
+		window model.FreezeWindow
+		now    time.Time
+		active bool
+		until  time.Time
+		next   time.Time
+	}{
+		{name: "before a one-off window", window: once, now: at(16, 11, 0), next: starts},
+		{name: "in a one-off window", window: once, now: starts, active: true, until: ends},
+		{name: "at the end of a one-off window", window: once, now: ends},
+		{name: "one-off window without end", window: model.FreezeWindow{StartsAt: &starts}, now: at(16, 13, 0)},
+		{name: "in a recurring window", window: weekly, now: at(16, 23, 59), active: true, until: ends},
+		{name: "at the end of a recurring window", window: weekly, now: ends, next: at(23, 12, 0)},
+		{name: "before a recurring window", window: weekly, now: at(16, 11, 59), next: starts},
+		{name: "in a recurring window of a time zone", window: berlin, now: at(16, 7, 30), active: true, until: at(16, 8, 0)},
+		{name: "after a recurring window of a time zone", window: berlin, now: at(16, 8, 0), next: at(17, 7, 0)},
+		{name: "invalid cron", window: model.FreezeWindow{Cron: "every friday", DurationSeconds: 60}, now: starts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			active, until, next := windowState(tt.window, tt.now)
+			if active != tt.active || !until.Equal(tt.until) || !next.Equal(tt.next) {
+				t.Errorf("windowState = %v, %v, %v, want %v, %v, %v", active, until, next, tt.active, tt.until, tt.next)
+			}
+		})
+	}
+}
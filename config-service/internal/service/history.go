@@ -0,0 +1,24 @@
+package service
+
+import (
+	"strings"
+	"time"
+)
+
+// ParseAsOf reads an as_of parameter selecting the moment whose recorded
+// state a read returns. It must be an RFC 3339 timestamp that is not in the
+// future; an empty parameter yields nil, the current state.
+func ParseAsOf(raw string) (*time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	at, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return nil, &ValidationError{Field: "as_of", Message: "must be an RFC 3339 timestamp such as 2024-05-01T14:03:00Z"}
+	}
+	if at.After(time.Now()) {
+		return nil, &ValidationError{Field: "as_of", Message: "must not be in the future"}
+	}
+	return &at, nil
+}
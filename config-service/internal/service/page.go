@@ -0,0 +1,28 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+)
+
+// pageNumber returns the page number reported for a page request; pages
+// addressed by cursor have none and report 0
+func pageNumber(page model.PaginationParams) int {
+	if page.Cursor != "" {
+		return 0
+	}
+	return page.Page
+}
+
+// pageError converts a rejected cursor or sort column into a *ValidationError
+func pageError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrInvalidCursor):
+		return &ValidationError{Field: "cursor", Message: "is invalid or belongs to another listing"}
+	case errors.Is(err, repository.ErrUnsupportedSort):
+		return &ValidationError{Field: "sort_by", Message: err.Error()}
+	}
+	return err
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"strconv"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/secrets"
+)
+
+// RolloutService manages candidate versions of templates, served to a
+// percentage of clients before being promoted or aborted
+type RolloutService struct {
+	db           *database.Connection
+	environments *repository.EnvironmentRepository
+	templates    *repository.TemplateRepository
+	candidates   *repository.CandidateRepository
+	freezes      *repository.FreezeWindowRepository
+	audit        *repository.AuditRepository
+	cipher       *secrets.Cipher
+}
+
+// NewRolloutService creates a new rollout service. cipher encrypts secret
+// values of candidates and may be nil when no master key is configured.
+func NewRolloutService(db *database.Connection, environments *repository.EnvironmentRepository,
+	templates *repository.TemplateRepository, candidates *repository.CandidateRepository,
+	freezes *repository.FreezeWindowRepository, audit *repository.AuditRepository, cipher *secrets.Cipher) *RolloutService {
+	return &RolloutService{
+		db:           db,
+		environments: environments,
+		templates:    templates,
+		candidates:   candidates,
+		freezes:      freezes,
+		audit:        audit,
+		cipher:       cipher,
+	}
+}
+
+// Get returns the candidate of a template with its secret values masked
+func (s *RolloutService) Get(ctx context.Context, templateID int64) (*model.TemplateCandidate, error) {
+	tpl, err := s.templates.GetByID(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.candidates.Get(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+	return candidateResponse(c, tpl), nil
+}
+
+// Set creates or replaces the candidate of a template. Like other template
+// writes it fails with a *ProtectedError or *FrozenError in protected or
+// frozen environments.
+func (s *RolloutService) Set(ctx context.Context, templateID int64, req model.SetCandidateRequest, actor string) (*model.TemplateCandidate, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+
+	var c *model.TemplateCandidate
+	var tpl *model.Template
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		repo := s.templates.WithTx(tx)
+		var err error
+		if tpl, err = repo.GetByID(ctx, templateID); err != nil {
+			return err
+		}
+		if err := s.checkWritable(ctx, tx, tpl.EnvironmentID); err != nil {
+			return err
+		}
+		if req.Version == tpl.Version {
+			return &ValidationError{Field: "version", Message: "must differ from the stable version " + tpl.Version}
+		}
+
+		proposed := *tpl
+		proposed.Version = req.Version
+		if req.Content != nil {
+			proposed.Content = *req.Content
+		}
+		if req.DefaultValues != nil {
+			proposed.DefaultValues = req.DefaultValues
+			if err := sealSecrets(s.cipher, &proposed, tpl.DefaultValues); err != nil {
+				return err
+			}
+		}
+		if err := checkIncludes(ctx, repo, &proposed); err != nil {
+			return err
+		}
+
+		c = &model.TemplateCandidate{
+			TemplateID:        tpl.ID,
+			Version:           proposed.Version,
+			Content:           proposed.Content,
+			DefaultValues:     proposed.DefaultValues,
+			RolloutPercentage: req.RolloutPercentage,
+			UpdatedBy:         actor,
+		}
+		if err := s.candidates.WithTx(tx).Upsert(ctx, c); err != nil {
+			return err
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditCandidateSet,
+			EntityType: "template",
+			EntityID:   &tpl.ID,
+			Actor:      actor,
+			Details: model.JSONMap{
+				"version":            c.Version,
+				"stable_version":     tpl.Version,
+				"rollout_percentage": c.RolloutPercentage,
+				"diff":               templateDiff(tpl, &proposed),
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return candidateResponse(c, tpl), nil
+}
+
+// Rollout changes the percentage of clients served the candidate of a
+// template. Clients keep their variant when the percentage grows.
+func (s *RolloutService) Rollout(ctx context.Context, templateID int64, req model.RolloutRequest, actor string) (*model.TemplateCandidate, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+
+	var c *model.TemplateCandidate
+	var tpl *model.Template
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		if tpl, err = s.templates.WithTx(tx).GetByID(ctx, templateID); err != nil {
+			return err
+		}
+		if err := s.checkWritable(ctx, tx, tpl.EnvironmentID); err != nil {
+			return err
+		}
+		candidates := s.candidates.WithTx(tx)
+		if c, err = candidates.Get(ctx, templateID); err != nil {
+			return err
+		}
+
+		previous := c.RolloutPercentage
+		c.RolloutPercentage = *req.RolloutPercentage
+		c.UpdatedBy = actor
+		if err := candidates.SetRollout(ctx, c); err != nil {
+			return err
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditCandidateRollout,
+			EntityType: "template",
+			EntityID:   &tpl.ID,
+			Actor:      actor,
+			Details: model.JSONMap{
+				"version":  c.Version,
+				"previous": previous,
+				"current":  c.RolloutPercentage,
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return candidateResponse(c, tpl), nil
+}
+
+// Promote makes the candidate of a template its stable version, served to
+// every client, and removes the candidate
+func (s *RolloutService) Promote(ctx context.Context, templateID int64, actor string) (*model.TemplateResponse, error) {
+	var tpl *model.Template
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		repo := s.templates.WithTx(tx)
+		var err error
+		if tpl, err = repo.Load(ctx, templateID, repository.TemplateLoad{Environment: true, Tags: true}); err != nil {
+			return err
+		}
+		if err := s.checkWritable(ctx, tx, tpl.EnvironmentID); err != nil {
+			return err
+		}
+		candidates := s.candidates.WithTx(tx)
+		c, err := candidates.Get(ctx, templateID)
+		if err != nil {
+			return err
+		}
+
+		previous := *tpl
+		tpl.Version = c.Version
+		tpl.Content = c.Content
+		tpl.DefaultValues = c.DefaultValues
+		tpl.UpdatedBy = actor
+		// Secret values sealed before a master key rotation are moved to the
+		// primary key, as the rotation job only rewraps stored templates
+		if s.cipher != nil {
+			if tpl.DefaultValues, _, err = s.cipher.RewrapValues(tpl.DefaultValues); err != nil {
+				return err
+			}
+		}
+		if err := repo.Update(ctx, tpl); err != nil {
+			return err
+		}
+		if err := checkIncludes(ctx, repo, tpl); err != nil {
+			return err
+		}
+		if err := candidates.Delete(ctx, templateID); err != nil {
+			return err
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditCandidatePromote,
+			EntityType: "template",
+			EntityID:   &tpl.ID,
+			Actor:      actor,
+			Details: model.JSONMap{
+				"version":            c.Version,
+				"previous_version":   previous.Version,
+				"rollout_percentage": c.RolloutPercentage,
+				"diff":               templateDiff(&previous, tpl),
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := templateResponse(tpl)
+	return &resp, nil
+}
+
+// Abort removes the candidate of a template, returning every client to the
+// stable version. As a rollback it is allowed in protected and frozen
+// environments.
+func (s *RolloutService) Abort(ctx context.Context, templateID int64, actor string) error {
+	return s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		candidates := s.candidates.WithTx(tx)
+		c, err := candidates.Get(ctx, templateID)
+		if err != nil {
+			return err
+		}
+		if err := candidates.Delete(ctx, templateID); err != nil {
+			return err
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditCandidateAbort,
+			EntityType: "template",
+			EntityID:   &templateID,
+			Actor:      actor,
+			Details: model.JSONMap{
+				"version":            c.Version,
+				"rollout_percentage": c.RolloutPercentage,
+			},
+		})
+	})
+}
+
+// checkWritable fails when the environment is protected or frozen
+func (s *RolloutService) checkWritable(ctx context.Context, tx *sql.Tx, environmentID int64) error {
+	environments := s.environments.WithTx(tx)
+	if err := checkUnprotected(ctx, environments, environmentID); err != nil {
+		return err
+	}
+	return checkUnfrozen(ctx, environments, s.freezes.WithTx(tx), environmentID)
+}
+
+// candidateResponse prepares a candidate of tpl for API responses
+func candidateResponse(c *model.TemplateCandidate, tpl *model.Template) *model.TemplateCandidate {
+	resp := *c
+	resp.DefaultValues = secrets.Masked(c.DefaultValues)
+	resp.StableVersion = tpl.Version
+	return &resp
+}
+
+// rolloutBucket places a client in one of 100 buckets for a template. The
+// bucket depends only on the template and instance ID, so a client keeps its
+// variant across requests and replicas, and as a rollout grows.
+func rolloutBucket(templateID int64, instance string) int {
+	sum := sha256.Sum256([]byte(strconv.FormatInt(templateID, 10) + "/" + instance))
+	return int(binary.BigEndian.Uint64(sum[:8]) % 100)
+}
+
+// rolloutVariant returns the variant of a template served to a client.
+// Clients without an instance ID always receive the stable version.
+func rolloutVariant(c *model.TemplateCandidate, instance string) string {
+	if instance == "" || rolloutBucket(c.TemplateID, instance) >= c.RolloutPercentage {
+		return model.VariantStable
+	}
+	return model.VariantCandidate
+}
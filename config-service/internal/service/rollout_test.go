@@ -0,0 +1,49 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/company/config-service/internal/model"
+)
+
+func TestRolloutVariant(t *testing.T) {
+	candidate := &model.TemplateCandidate{TemplateID: 7}
+	served := make(map[string]bool)
+	for _, percentage := range []int{0, 10, 50, 100} {
+		candidate.RolloutPercentage = percentage
+		count := 0
+		for i := 0; i < 1000; i++ {
+			instance := fmt.Sprintf("instance-%d", i)
+			got := rolloutVariant(candidate, instance) == model.VariantCandidate
+			// Instances keep the candidate as the rollout grows
+			if served[instance] && !got {
+				t.Fatalf("%s lost the candidate at %d%%", instance, percentage)
+			}
+			served[instance] = got
+			if got {
+				count++
+			}
+		}
+		if count < percentage*10-60 || count > percentage*10+60 {
+			t.Errorf("%d of 1000 instances got the candidate at %d%%", count, percentage)
+		}
+	}
+
+	if got := rolloutVariant(candidate, ""); got != model.VariantStable {
+		t.Errorf("client without instance ID got %s", got)
+	}
+
+	// Buckets depend on the template, so the same instances are not always
+	// first to receive candidates
+	same := 0
+	for i := 0; i < 1000; i++ {
+		instance := fmt.Sprintf("instance-%d", i)
+		if rolloutBucket(7, instance) == rolloutBucket(8, instance) {
+			same++
+		}
+	}
+	if same > 50 {
+		t.Errorf("%d of 1000 instances share a bucket across templates", same)
+	}
+}
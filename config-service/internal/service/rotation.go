@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/secrets"
+	"github.com/company/config-service/pkg/metrics"
+)
+
+// errRewrapFailed marks rotation errors that retrying cannot fix, such as a
+// value wrapped by a key missing from the keyring
+var errRewrapFailed = errors.New("failed to rewrap secret values")
+
+// errKeyMismatch is returned by a replica whose primary key differs from
+// the key a rotation targets, typically during a rolling deploy of a new
+// keyring
+var errKeyMismatch = errors.New("rotation targets another primary key")
+
+// RotationOptions paces the secret re-encryption job
+type RotationOptions struct {
+	// BatchSize is the number of templates or recorded versions rewrapped per
+	// transaction
+	BatchSize int
+	// Lease is how long a replica may go silent before another one takes
+	// over its job
+	Lease time.Duration
+}
+
+// SecretRotationService rewraps the data keys of secret values with the
+// primary master key after it was rotated. The job runs in the background of
+// every replica; replicas compete for it through a lease on the rotation
+// record, so that one works it at a time and another one continues when it
+// goes away. Templates are rewrapped first, then the versions recorded in
+// the configuration history, so that past states stay reproducible once the
+// old key is removed, and last the sealed webhook secrets.
+type SecretRotationService struct {
+	db        *database.Connection
+	rotations *repository.SecretRotationRepository
+	templates *repository.TemplateRepository
+	history   *repository.HistoryRepository
+	webhooks  *repository.WebhookRepository
+	cipher    *secrets.Cipher
+	options   RotationOptions
+	logger    *logger.Logger
+	owner     string
+	wake      chan struct{}
+}
+
+// NewSecretRotationService creates a new secret rotation service. cipher
+// may be nil when no master key is configured.
+func NewSecretRotationService(db *database.Connection, rotations *repository.SecretRotationRepository,
+	templates *repository.TemplateRepository, history *repository.HistoryRepository, webhooks *repository.WebhookRepository,
+	cipher *secrets.Cipher, options RotationOptions, log *logger.Logger) *SecretRotationService {
+	host, _ := os.Hostname()
+	return &SecretRotationService{
+		db:        db,
+		rotations: rotations,
+		templates: templates,
+		history:   history,
+		webhooks:  webhooks,
+		cipher:    cipher,
+		options:   options,
+		logger:    log,
+		owner:     fmt.Sprintf("%s/%d", host, os.Getpid()),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Status reports the master keys with the number of templates whose secret
+// values each wraps, and the latest rotation
+func (s *SecretRotationService) Status(ctx context.Context) (*model.SecretRotationResponse, error) {
+	counts, err := s.templates.CountSecretValuesByKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &model.SecretRotationResponse{Keys: []model.SecretKey{}}
+	if s.cipher != nil {
+		for _, key := range s.cipher.Keys() {
+			resp.Keys = append(resp.Keys, model.SecretKey{
+				Version:   key.Version,
+				ID:        key.ID,
+				Primary:   key.Primary,
+				Templates: counts[key.ID],
+			})
+			delete(counts, key.ID)
+		}
+	}
+	// Keys missing from the keyring cannot be rotated and are listed last
+	unknown := make([]string, 0, len(counts))
+	for id := range counts {
+		unknown = append(unknown, id)
+	}
+	sort.Strings(unknown)
+	for _, id := range unknown {
+		resp.Keys = append(resp.Keys, model.SecretKey{ID: id, Templates: counts[id]})
+	}
+
+	rotation, err := s.rotations.Latest(ctx)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+	if rotation != nil {
+		resp.Rotation = rotation
+		resp.Progress = rotation.Progress()
+	}
+	return resp, nil
+}
+
+// Start begins a rotation to the primary master key, or resumes the paused
+// one. A paused rotation targeting a former primary key is failed and
+// replaced. It returns a *ConflictError when a rotation is already running.
+func (s *SecretRotationService) Start(ctx context.Context, actor string) (*model.SecretRotation, error) {
+	if s.cipher == nil {
+		return nil, &ValidationError{Message: "no master key is configured"}
+	}
+	primary := s.primaryKey()
+
+	var rotation *model.SecretRotation
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		rotations := s.rotations.WithTx(tx)
+		current, err := rotations.Unfinished(ctx)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+
+		if current != nil {
+			if current.Status == model.RotationRunning {
+				return &ConflictError{Conflicts: []string{fmt.Sprintf("secret rotation %d is already running", current.ID)}}
+			}
+			if current.KeyID == primary.ID {
+				current.Status = model.RotationRunning
+				rotation = current
+				return rotations.SetStatus(ctx, current)
+			}
+			current.Status = model.RotationFailed
+			current.Error = "superseded: the primary master key changed while the rotation was paused"
+			if err := rotations.SetStatus(ctx, current); err != nil {
+				return err
+			}
+		}
+
+		templates, err := s.templates.WithTx(tx).CountWithSecretValues(ctx)
+		if err != nil {
+			return err
+		}
+		versions, err := s.history.WithTx(tx).CountWithSecretValues(ctx)
+		if err != nil {
+			return err
+		}
+		rotation = &model.SecretRotation{
+			KeyID:      primary.ID,
+			KeyVersion: primary.Version,
+			Status:     model.RotationRunning,
+			Total:      templates + versions,
+			StartedBy:  actor,
+		}
+		return rotations.Create(ctx, rotation)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return rotation, nil
+}
+
+// Pause stops the running rotation after its current batch. It returns
+// repository.ErrNotFound when no rotation is running or paused.
+func (s *SecretRotationService) Pause(ctx context.Context) (*model.SecretRotation, error) {
+	var rotation *model.SecretRotation
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		rotations := s.rotations.WithTx(tx)
+		var err error
+		if rotation, err = rotations.Unfinished(ctx); err != nil {
+			return err
+		}
+		if rotation.Status == model.RotationPaused {
+			return nil
+		}
+		rotation.Status = model.RotationPaused
+		return rotations.SetStatus(ctx, rotation)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rotation, nil
+}
+
+// Run works on the running rotation until ctx is cancelled, checking for
+// one every interval and right after Start
+func (s *SecretRotationService) Run(ctx context.Context, interval time.Duration) {
+	if s.cipher == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.work(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// work rewraps batches of templates and recorded versions until the rotation completes, is
+// paused or fails
+func (s *SecretRotationService) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		rotation, done, err := s.batch(ctx)
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			// No rotation is running or another replica holds it
+			return
+		case errors.Is(err, errKeyMismatch):
+			s.logger.Warn().Int64("rotation", rotation.ID).Str("key_id", rotation.KeyID).
+				Msg("Skipping secret rotation targeting another primary key")
+			return
+		case errors.Is(err, errRewrapFailed):
+			s.fail(ctx, rotation, err)
+			return
+		case err != nil:
+			if ctx.Err() == nil {
+				s.logger.Error().Err(err).Msg("Secret rotation batch failed, retrying")
+			}
+			return
+		}
+
+		metrics.UpdateSecretRotationProgress(rotation.Progress())
+		if done {
+			s.logger.Info().
+				Int64("rotation", rotation.ID).
+				Int("processed", rotation.Processed).
+				Int("rewrapped", rotation.Rewrapped).
+				Msg("Secret rotation completed")
+			return
+		}
+	}
+}
+
+// batch claims the running rotation and rewraps the next batch of templates,
+// or of recorded versions once all templates are done, in one transaction,
+// completing the rotation when none is left
+func (s *SecretRotationService) batch(ctx context.Context) (*model.SecretRotation, bool, error) {
+	var rotation *model.SecretRotation
+	done := false
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		rotations := s.rotations.WithTx(tx)
+		templates := s.templates.WithTx(tx)
+
+		var err error
+		if rotation, err = rotations.Claim(ctx, s.owner, s.options.Lease); err != nil {
+			return err
+		}
+		if rotation.KeyID != s.cipher.KeyID() {
+			return errKeyMismatch
+		}
+
+		batch, err := templates.ListSecretValuesAfter(ctx, rotation.LastTemplateID, s.options.BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			if done, err = s.historyBatch(ctx, tx, rotation); err != nil || !done {
+				return err
+			}
+			if err := s.rewrapWebhookSecrets(ctx, tx, rotation); err != nil {
+				return err
+			}
+			rotation.Status = model.RotationCompleted
+			return rotations.SetStatus(ctx, rotation)
+		}
+
+		for _, tpl := range batch {
+			values, rewrapped, err := s.cipher.RewrapValues(tpl.DefaultValues)
+			if err != nil {
+				return fmt.Errorf("%w of template %d %q: %v", errRewrapFailed, tpl.ID, tpl.Name, err)
+			}
+			if rewrapped > 0 {
+				if err := templates.UpdateDefaultValues(ctx, tpl.ID, values); err != nil {
+					return err
+				}
+				rotation.Rewrapped++
+			}
+		}
+		rotation.Processed += len(batch)
+		rotation.LastTemplateID = batch[len(batch)-1].ID
+		return rotations.Advance(ctx, rotation)
+	})
+	return rotation, done, err
+}
+
+// historyBatch rewraps the next batch of recorded versions, reporting
+// whether none was left
+func (s *SecretRotationService) historyBatch(ctx context.Context, tx *sql.Tx, rotation *model.SecretRotation) (bool, error) {
+	history := s.history.WithTx(tx)
+	batch, err := history.ListSecretValuesAfter(ctx, rotation.LastHistoryID, s.options.BatchSize)
+	if err != nil {
+		return false, err
+	}
+	if len(batch) == 0 {
+		return true, nil
+	}
+
+	for _, version := range batch {
+		data, rewrapped, err := s.cipher.RewrapValues(version.Data)
+		if err != nil {
+			return false, fmt.Errorf("%w of %s history version %d: %v", errRewrapFailed, version.Table, version.ID, err)
+		}
+		if rewrapped > 0 {
+			if err := history.UpdateData(ctx, version.ID, data); err != nil {
+				return false, err
+			}
+			rotation.Rewrapped++
+		}
+	}
+	rotation.Processed += len(batch)
+	rotation.LastHistoryID = batch[len(batch)-1].ID
+	return false, s.rotations.WithTx(tx).Advance(ctx, rotation)
+}
+
+// rewrapWebhookSecrets rewraps the sealed secrets of all webhook
+// subscriptions. There are few, so they are done in the last batch and not
+// counted as processed.
+func (s *SecretRotationService) rewrapWebhookSecrets(ctx context.Context, tx *sql.Tx, rotation *model.SecretRotation) error {
+	webhooks := s.webhooks.WithTx(tx)
+	sealed, err := webhooks.ListSealed(ctx)
+	if err != nil {
+		return err
+	}
+	for _, w := range sealed {
+		envelope, changed, err := s.cipher.Rewrap(w.SecretEnvelope)
+		if err != nil {
+			return fmt.Errorf("%w of the secret of webhook %s: %v", errRewrapFailed, w.Name, err)
+		}
+		if changed {
+			if err := webhooks.UpdateSecretEnvelope(ctx, w.ID, envelope); err != nil {
+				return err
+			}
+			rotation.Rewrapped++
+		}
+	}
+	return nil
+}
+
+// fail records an error the rotation cannot recover from
+func (s *SecretRotationService) fail(ctx context.Context, rotation *model.SecretRotation, cause error) {
+	s.logger.Error().Err(cause).Int64("rotation", rotation.ID).Msg("Secret rotation failed")
+	rotation.Status = model.RotationFailed
+	rotation.Error = cause.Error()
+	if err := s.rotations.SetStatus(ctx, rotation); err != nil {
+		s.logger.Error().Err(err).Int64("rotation", rotation.ID).Msg("Failed to record secret rotation failure")
+	}
+}
+
+// primaryKey describes the primary master key
+func (s *SecretRotationService) primaryKey() secrets.KeyInfo {
+	keys := s.cipher.Keys()
+	return keys[len(keys)-1]
+}
@@ -0,0 +1,388 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/secrets"
+	"github.com/company/config-service/pkg/metrics"
+)
+
+// schedulerLease names the lease electing the replica that applies
+// scheduled deployments
+const schedulerLease = "scheduled-deployments"
+
+// maxDueDeployments bounds the deployments applied per scheduler tick
+const maxDueDeployments = 100
+
+// errDeploymentFailed marks deployment errors that retrying cannot fix
+var errDeploymentFailed = errors.New("deployment cannot be applied")
+
+// SchedulerOptions paces the scheduler applying deployments
+type SchedulerOptions struct {
+	// Interval is how often due deployments are looked for
+	Interval time.Duration
+	// Lease is how long the elected replica may go silent before another
+	// one takes over
+	Lease time.Duration
+}
+
+// ScheduleService schedules template updates to go live at a later time and
+// applies them when due. Every replica runs the scheduler; a lease elects the
+// one applying deployments, and pending deployments wait out freeze windows.
+type ScheduleService struct {
+	db           *database.Connection
+	environments *repository.EnvironmentRepository
+	templates    *repository.TemplateRepository
+	deployments  *repository.ScheduledDeploymentRepository
+	freezes      *repository.FreezeWindowRepository
+	leases       *repository.LeaseRepository
+	audit        *repository.AuditRepository
+	cipher       *secrets.Cipher
+	options      SchedulerOptions
+	logger       *logger.Logger
+	owner        string
+}
+
+// NewScheduleService creates a new schedule service encrypting secret values
+// with cipher, which may be nil when no master key is configured
+func NewScheduleService(db *database.Connection, environments *repository.EnvironmentRepository,
+	templates *repository.TemplateRepository, deployments *repository.ScheduledDeploymentRepository,
+	freezes *repository.FreezeWindowRepository, leases *repository.LeaseRepository,
+	audit *repository.AuditRepository, cipher *secrets.Cipher, options SchedulerOptions, log *logger.Logger) *ScheduleService {
+	host, _ := os.Hostname()
+	return &ScheduleService{
+		db:           db,
+		environments: environments,
+		templates:    templates,
+		deployments:  deployments,
+		freezes:      freezes,
+		leases:       leases,
+		audit:        audit,
+		cipher:       cipher,
+		options:      options,
+		logger:       log,
+		owner:        fmt.Sprintf("%s/%d", host, os.Getpid()),
+	}
+}
+
+// Create schedules an update setting the version of a template. The update
+// is validated and its secret values encrypted now; templates of protected
+// environments change through change requests instead.
+func (s *ScheduleService) Create(ctx context.Context, req model.CreateScheduledDeploymentRequest, author string) (*model.ScheduledDeployment, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+	update := req.Update
+	update.UpdatedBy = author
+	if err := validateStruct(update); err != nil {
+		return nil, err
+	}
+	if update.Version == nil {
+		return nil, &ValidationError{Field: "update.version", Message: "is required"}
+	}
+	if !req.RunAt.After(time.Now()) {
+		return nil, &ValidationError{Field: "run_at", Message: "must be in the future"}
+	}
+
+	tpl, err := s.templates.GetByID(ctx, req.TemplateID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, &ValidationError{Field: "template_id", Message: fmt.Sprintf("template %d does not exist", req.TemplateID)}
+		}
+		return nil, err
+	}
+	if update.EnvironmentID != nil && *update.EnvironmentID != tpl.EnvironmentID {
+		return nil, &ValidationError{Field: "update.environment_id", Message: "scheduled deployments cannot move templates between environments"}
+	}
+
+	proposed := *tpl
+	applyTemplateUpdate(&proposed, &update)
+	proposed.TagIDs = update.TagIDs
+	if err := sealSecrets(s.cipher, &proposed, tpl.DefaultValues); err != nil {
+		return nil, err
+	}
+	if update.DefaultValues != nil {
+		update.DefaultValues = proposed.DefaultValues
+	}
+
+	d := &model.ScheduledDeployment{
+		TemplateID:    &tpl.ID,
+		EnvironmentID: tpl.EnvironmentID,
+		Version:       *update.Version,
+		Diff:          templateDiff(tpl, &proposed),
+		RunAt:         req.RunAt,
+		Status:        model.DeploymentPending,
+		CreatedBy:     author,
+		Changes:       &update,
+	}
+	err = s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		if err := checkUnprotected(ctx, s.environments.WithTx(tx), d.EnvironmentID); err != nil {
+			return err
+		}
+		if err := s.deployments.WithTx(tx).Create(ctx, d); err != nil {
+			return err
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditDeploymentSchedule,
+			EntityType: "scheduled_deployment",
+			EntityID:   &d.ID,
+			Actor:      author,
+			Details: model.JSONMap{
+				"template_id": tpl.ID,
+				"version":     d.Version,
+				"run_at":      d.RunAt,
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Get returns a scheduled deployment
+func (s *ScheduleService) Get(ctx context.Context, id int64) (*model.ScheduledDeployment, error) {
+	return s.deployments.GetByID(ctx, id)
+}
+
+// List returns one page of scheduled deployments matching the filter,
+// earliest run first
+func (s *ScheduleService) List(ctx context.Context, filter model.ScheduledDeploymentFilter, page model.PaginationParams) (*model.ScheduledDeploymentListResponse, error) {
+	if err := validateStruct(filter); err != nil {
+		return nil, err
+	}
+	if err := validateStruct(page); err != nil {
+		return nil, err
+	}
+
+	deployments, info, err := s.deployments.ListPage(ctx, filter, page)
+	if err != nil {
+		return nil, pageError(err)
+	}
+	if deployments == nil {
+		deployments = []model.ScheduledDeployment{}
+	}
+	return &model.ScheduledDeploymentListResponse{
+		Deployments: deployments,
+		Total:       info.Total,
+		Page:        pageNumber(page),
+		PageSize:    page.PageSize,
+		HasNext:     info.HasNext,
+		NextCursor:  info.NextCursor,
+		PrevCursor:  info.PrevCursor,
+	}, nil
+}
+
+// Cancel withdraws a pending deployment. It returns a *ConflictError once
+// the deployment was applied, failed or canceled.
+func (s *ScheduleService) Cancel(ctx context.Context, id int64, actor string) (*model.ScheduledDeployment, error) {
+	var d *model.ScheduledDeployment
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		deployments := s.deployments.WithTx(tx)
+		var err error
+		if d, err = deployments.Lock(ctx, id); err != nil {
+			return err
+		}
+		if d.Status != model.DeploymentPending {
+			return &ConflictError{Conflicts: []string{fmt.Sprintf("scheduled deployment %d is already %s", id, d.Status)}}
+		}
+		d.Status = model.DeploymentCanceled
+		d.CanceledBy = actor
+		if err := deployments.SetStatus(ctx, d); err != nil {
+			return err
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditDeploymentCancel,
+			EntityType: "scheduled_deployment",
+			EntityID:   &d.ID,
+			Actor:      actor,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Run applies due deployments every interval until ctx is cancelled, on the
+// replica holding the scheduler lease. The lease is released on return.
+func (s *ScheduleService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.options.Interval)
+	defer ticker.Stop()
+	defer func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.leases.Release(releaseCtx, schedulerLease, s.owner); err != nil {
+			s.logger.Warn().Err(err).Msg("Failed to release scheduler lease")
+		}
+	}()
+
+	for {
+		s.tick(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick renews the scheduler lease and, while holding it, applies the due
+// deployments
+func (s *ScheduleService) tick(ctx context.Context) {
+	leader, err := s.leases.Acquire(ctx, schedulerLease, s.owner, s.options.Lease)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Error().Err(err).Msg("Failed to acquire scheduler lease")
+		}
+		return
+	}
+	if !leader {
+		return
+	}
+
+	ids, err := s.deployments.Due(ctx, maxDueDeployments)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Error().Err(err).Msg("Failed to list due deployments")
+		}
+		return
+	}
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+		s.deploy(ctx, id)
+	}
+}
+
+// deploy applies a due deployment in one transaction. A deployment in a
+// frozen environment stays pending with the freeze as its error; one that
+// cannot be applied is failed, and other errors are retried next tick.
+func (s *ScheduleService) deploy(ctx context.Context, id int64) {
+	var d *model.ScheduledDeployment
+	var slug string
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		deployments := s.deployments.WithTx(tx)
+		var err error
+		if d, err = deployments.Lock(ctx, id); err != nil {
+			return err
+		}
+		if d.Status != model.DeploymentPending {
+			return nil
+		}
+		if slug, err = s.apply(ctx, tx, d); err != nil {
+			return err
+		}
+
+		d.Status = model.DeploymentApplied
+		d.Error = ""
+		if err := deployments.SetStatus(ctx, d); err != nil {
+			return err
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditDeploymentApply,
+			EntityType: "scheduled_deployment",
+			EntityID:   &d.ID,
+			Actor:      d.CreatedBy,
+			Details: model.JSONMap{
+				"environment": slug,
+				"template_id": d.TemplateID,
+				"version":     d.Version,
+				"diff":        d.Diff,
+			},
+		})
+	})
+
+	var frozenErr *FrozenError
+	var validationErr *ValidationError
+	var protectedErr *ProtectedError
+	switch {
+	case err == nil:
+		if d.Status == model.DeploymentApplied {
+			metrics.RecordTemplateOperation("scheduled_update", slug, "success")
+			s.logger.Info().
+				Int64("deployment", d.ID).
+				Str("environment", slug).
+				Str("version", d.Version).
+				Msg("Scheduled deployment applied")
+		}
+		return
+	case errors.As(err, &frozenErr):
+		// Stay pending until the window closes
+		if d.Error == frozenErr.Error() {
+			return
+		}
+		d.Error = frozenErr.Error()
+	case errors.Is(err, errDeploymentFailed), errors.As(err, &validationErr), errors.As(err, &protectedErr):
+		d.Status = model.DeploymentFailed
+		d.Error = err.Error()
+		metrics.RecordTemplateOperation("scheduled_update", slug, "error")
+		s.logger.Warn().Err(err).Int64("deployment", d.ID).Msg("Scheduled deployment failed")
+	default:
+		if ctx.Err() == nil {
+			s.logger.Error().Err(err).Int64("deployment", id).Msg("Failed to apply scheduled deployment, retrying")
+		}
+		return
+	}
+	if err := s.deployments.SetStatus(ctx, d); err != nil {
+		s.logger.Error().Err(err).Int64("deployment", d.ID).Msg("Failed to record scheduled deployment state")
+	}
+}
+
+// apply writes the update of a deployment to its template within tx and
+// returns the slug of the environment
+func (s *ScheduleService) apply(ctx context.Context, tx *sql.Tx, d *model.ScheduledDeployment) (string, error) {
+	environments := s.environments.WithTx(tx)
+	env, err := environments.GetByID(ctx, d.EnvironmentID)
+	if err != nil {
+		return "", err
+	}
+	if err := checkUnprotected(ctx, environments, env.ID); err != nil {
+		return env.Slug, err
+	}
+	if err := checkUnfrozen(ctx, environments, s.freezes.WithTx(tx), env.ID); err != nil {
+		return env.Slug, err
+	}
+	if d.TemplateID == nil {
+		return env.Slug, fmt.Errorf("%w: the template was deleted", errDeploymentFailed)
+	}
+
+	repo := s.templates.WithTx(tx)
+	tpl, err := repo.GetByID(ctx, *d.TemplateID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return env.Slug, fmt.Errorf("%w: the template was deleted", errDeploymentFailed)
+		}
+		return env.Slug, err
+	}
+	applyTemplateUpdate(tpl, d.Changes)
+	// Secret values sealed before a master key rotation are moved to the
+	// primary key, as the rotation job only rewraps stored templates
+	if s.cipher != nil {
+		if tpl.DefaultValues, _, err = s.cipher.RewrapValues(tpl.DefaultValues); err != nil {
+			return env.Slug, fmt.Errorf("%w: %v", errDeploymentFailed, err)
+		}
+	}
+	if err := repo.Update(ctx, tpl); err != nil {
+		return env.Slug, err
+	}
+	if err := checkIncludes(ctx, repo, tpl); err != nil {
+		return env.Slug, err
+	}
+	if d.Changes.TagIDs != nil {
+		if err := repo.SetTags(ctx, tpl.ID, d.Changes.TagIDs); err != nil {
+			return env.Slug, err
+		}
+	}
+	return env.Slug, nil
+}
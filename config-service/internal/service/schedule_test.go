@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/database/dbtest"
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/rs/zerolog"
+)
+
+func newTestScheduleService(db *database.Connection) *ScheduleService {
+	nop := zerolog.Nop()
+	return NewScheduleService(db, repository.NewEnvironmentRepository(db), repository.NewTemplateRepository(db),
+		repository.NewScheduledDeploymentRepository(db), repository.NewFreezeWindowRepository(db),
+		repository.NewLeaseRepository(db), repository.NewAuditRepository(db), nil,
+		SchedulerOptions{Interval: time.Minute, Lease: time.Minute}, &logger.Logger{Logger: &nop})
+}
+
+func TestScheduledDeploy(t *testing.T) {
+	ctx := context.Background()
+	version := "2.0.0"
+
+	tests := []struct {
+		name string
+		// content, when set, is included in the scheduled update
+		content string
+		// prepare runs between scheduling the deployment and applying it
+		prepare func(t *testing.T, db *database.Connection, env *model.Environment, tpl *model.Template)
+		status  model.ScheduledDeploymentStatus
+		err     string
+		version string
+	}{
+		{
+			name:    "applied",
+			status:  model.DeploymentApplied,
+			version: version,
+		},
+		{
+			name: "frozen",
+			prepare: func(t *testing.T, db *database.Connection, env *model.Environment, _ *model.Template) {
+				freezeTestEnvironment(t, db, env.ID, "release")
+			},
+			status:  model.DeploymentPending,
+			err:     `environment prod is frozen by window "release"`,
+			version: "1.0.0",
+		},
+		{
+			name: "protected since",
+			prepare: func(t *testing.T, db *database.Connection, env *model.Environment, _ *model.Template) {
+				protectTestEnvironment(t, db, env, 1)
+			},
+			status:  model.DeploymentFailed,
+			err:     "environment prod is protected",
+			version: "1.0.0",
+		},
+		{
+			name: "template deleted",
+			prepare: func(t *testing.T, db *database.Connection, _ *model.Environment, tpl *model.Template) {
+				if err := repository.NewTemplateRepository(db).Delete(context.Background(), tpl.ID); err != nil {
+					t.Fatal(err)
+				}
+			},
+			status: model.DeploymentFailed,
+			err:    "the template was deleted",
+		},
+		{
+			name:    "invalid content",
+			content: `{{ include "app" }}`,
+			status:  model.DeploymentFailed,
+			err:     "content: include cycle",
+			version: "1.0.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dbtest.New(t)
+			s := newTestScheduleService(db)
+			env := createTestEnvironment(t, db, "prod")
+			tpl := createTestTemplate(t, db, env.ID, "app", model.ConfigFormatYAML, nil)
+
+			update := model.UpdateTemplateRequest{Version: &version}
+			if tt.content != "" {
+				update.Content = &tt.content
+			}
+			d, err := s.Create(ctx, model.CreateScheduledDeploymentRequest{
+				TemplateID: tpl.ID,
+				RunAt:      time.Now().Add(time.Hour),
+				Update:     update,
+			}, "alice")
+			if err != nil {
+				t.Fatalf("Create error: %v", err)
+			}
+			if tt.prepare != nil {
+				tt.prepare(t, db, env, tpl)
+			}
+
+			// A frozen deployment is retried on every tick without change
+			for i := 0; i < 2; i++ {
+				s.deploy(ctx, d.ID)
+				got, err := s.Get(ctx, d.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Status != tt.status || !strings.Contains(got.Error, tt.err) || (tt.err == "") != (got.Error == "") {
+					t.Fatalf("deployment status, error = %s, %q, want %s, %q", got.Status, got.Error, tt.status, tt.err)
+				}
+			}
+
+			if tt.version == "" {
+				return
+			}
+			current, err := repository.NewTemplateRepository(db).GetByID(ctx, tpl.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if current.Version != tt.version {
+				t.Errorf("template version = %s, want %s", current.Version, tt.version)
+			}
+		})
+	}
+}
+
+func TestScheduleCreateRejectsProtected(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t)
+	s := newTestScheduleService(db)
+	env := createTestEnvironment(t, db, "prod")
+	protectTestEnvironment(t, db, env, 1)
+	tpl := createTestTemplate(t, db, env.ID, "app", model.ConfigFormatYAML, nil)
+
+	version := "2.0.0"
+	_, err := s.Create(ctx, model.CreateScheduledDeploymentRequest{
+		TemplateID: tpl.ID,
+		RunAt:      time.Now().Add(time.Hour),
+		Update:     model.UpdateTemplateRequest{Version: &version},
+	}, "alice")
+	var protectedErr *ProtectedError
+	if !errors.As(err, &protectedErr) {
+		t.Errorf("Create error = %v, want a protected error", err)
+	}
+}
@@ -0,0 +1,306 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/secrets"
+)
+
+// SnapshotService takes named snapshots of environments and compares
+// environments and snapshots with each other
+type SnapshotService struct {
+	db           *database.Connection
+	environments *repository.EnvironmentRepository
+	templates    *repository.TemplateRepository
+	snapshots    *repository.SnapshotRepository
+	audit        *repository.AuditRepository
+}
+
+// NewSnapshotService creates a new snapshot service
+func NewSnapshotService(db *database.Connection, environments *repository.EnvironmentRepository,
+	templates *repository.TemplateRepository, snapshots *repository.SnapshotRepository,
+	audit *repository.AuditRepository) *SnapshotService {
+	return &SnapshotService{
+		db:           db,
+		environments: environments,
+		templates:    templates,
+		snapshots:    snapshots,
+		audit:        audit,
+	}
+}
+
+// List returns the snapshots of an environment, newest first
+func (s *SnapshotService) List(ctx context.Context, slug string) (*model.SnapshotListResponse, error) {
+	env, err := s.environments.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := s.snapshots.ListByEnvironment(ctx, env.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &model.SnapshotListResponse{
+		Environment: env.Slug,
+		Snapshots:   make([]model.SnapshotResponse, 0, len(snapshots)),
+	}
+	for _, snap := range snapshots {
+		resp.Snapshots = append(resp.Snapshots, snapshotResponse(snap, env.Slug))
+	}
+	return resp, nil
+}
+
+// Get returns a snapshot of an environment with its templates
+func (s *SnapshotService) Get(ctx context.Context, slug, name string) (*model.SnapshotResponse, error) {
+	env, err := s.environments.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := s.snapshots.GetByName(ctx, env.ID, name)
+	if err != nil {
+		return nil, err
+	}
+	resp := snapshotResponse(*snap, env.Slug)
+	return &resp, nil
+}
+
+// Create takes a snapshot of the current templates of an environment
+func (s *SnapshotService) Create(ctx context.Context, slug string, req model.CreateSnapshotRequest, actor string) (*model.SnapshotResponse, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+
+	snap := &model.Snapshot{
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedBy:   actor,
+	}
+	var env *model.Environment
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		if env, err = s.environments.WithTx(tx).GetBySlug(ctx, slug); err != nil {
+			return err
+		}
+		snap.EnvironmentID = env.ID
+
+		snapshots := s.snapshots.WithTx(tx)
+		if _, err := snapshots.GetByName(ctx, env.ID, snap.Name); err == nil {
+			return &ConflictError{Conflicts: []string{fmt.Sprintf("snapshot %q already exists in environment %s", snap.Name, env.Slug)}}
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+
+		templates, err := s.templates.WithTx(tx).ListByEnvironment(ctx, env.ID, repository.TemplateLoad{Tags: true})
+		if err != nil {
+			return err
+		}
+		snap.Templates = snapshotTemplates(templates)
+		if err := snapshots.Create(ctx, snap); err != nil {
+			return err
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditSnapshotCreate,
+			EntityType: "snapshot",
+			EntityID:   &snap.ID,
+			Actor:      actor,
+			Details: model.JSONMap{
+				"environment": env.Slug,
+				"name":        snap.Name,
+				"templates":   snap.TemplateCount,
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := snapshotResponse(*snap, env.Slug)
+	return &resp, nil
+}
+
+// Delete removes a snapshot of an environment
+func (s *SnapshotService) Delete(ctx context.Context, slug, name, actor string) error {
+	return s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		env, err := s.environments.WithTx(tx).GetBySlug(ctx, slug)
+		if err != nil {
+			return err
+		}
+		snapshots := s.snapshots.WithTx(tx)
+		snap, err := snapshots.GetByName(ctx, env.ID, name)
+		if err != nil {
+			return err
+		}
+		if err := snapshots.Delete(ctx, env.ID, snap.ID); err != nil {
+			return err
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditSnapshotDelete,
+			EntityType: "snapshot",
+			EntityID:   &snap.ID,
+			Actor:      actor,
+			Details:    model.JSONMap{"environment": env.Slug, "name": snap.Name},
+		})
+	})
+}
+
+// Compare lists the templates added, removed and changed from one
+// environment or snapshot to another. Each side is an environment slug for
+// its current templates or slug@snapshot for a snapshot of it. Templates are
+// matched by name and compared field by field, with schema and default
+// values compared key by key and secret values masked. Secret values are
+// compared in their encrypted form, so a secret set separately on both
+// sides shows as changed even when the plaintext is the same.
+func (s *SnapshotService) Compare(ctx context.Context, from, to string) (*model.EnvironmentComparison, error) {
+	a, err := s.side(ctx, "from", from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.side(ctx, "to", to)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &model.EnvironmentComparison{
+		From:    from,
+		To:      to,
+		Added:   []model.ComparedTemplate{},
+		Removed: []model.ComparedTemplate{},
+		Changed: []model.TemplateComparison{},
+	}
+	byName := make(map[string]model.SnapshotTemplate, len(b))
+	for _, tpl := range b {
+		byName[tpl.Name] = tpl
+	}
+	for _, x := range a {
+		y, ok := byName[x.Name]
+		if !ok {
+			resp.Removed = append(resp.Removed, model.ComparedTemplate{Name: x.Name, Version: x.Version})
+			continue
+		}
+		delete(byName, x.Name)
+
+		changes := compareTemplates(x, y)
+		if len(changes) == 0 {
+			resp.Unchanged++
+			continue
+		}
+		resp.Changed = append(resp.Changed, model.TemplateComparison{
+			Name:        x.Name,
+			FromVersion: x.Version,
+			ToVersion:   y.Version,
+			Changes:     changes,
+		})
+	}
+	for _, y := range b {
+		if _, ok := byName[y.Name]; ok {
+			resp.Added = append(resp.Added, model.ComparedTemplate{Name: y.Name, Version: y.Version})
+		}
+	}
+	return resp, nil
+}
+
+// side loads the templates a comparison reference names, ordered by name.
+// Both kinds of side are sorted here rather than by the database, whose
+// collation may order names differently, so that a comparison lists
+// templates in the same order whichever side is a snapshot.
+func (s *SnapshotService) side(ctx context.Context, field, ref string) ([]model.SnapshotTemplate, error) {
+	if ref == "" {
+		return nil, &ValidationError{Field: field, Message: "an environment slug or slug@snapshot is required"}
+	}
+	slug, name, isSnapshot := strings.Cut(ref, "@")
+	env, err := s.environments.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	if isSnapshot {
+		snap, err := s.snapshots.GetByName(ctx, env.ID, name)
+		if err != nil {
+			return nil, err
+		}
+		return sortedByName(snap.Templates), nil
+	}
+	templates, err := s.templates.ListByEnvironment(ctx, env.ID, repository.TemplateLoad{Tags: true})
+	if err != nil {
+		return nil, err
+	}
+	return sortedByName(snapshotTemplates(templates)), nil
+}
+
+// sortedByName sorts templates by name in byte order
+func sortedByName(templates []model.SnapshotTemplate) []model.SnapshotTemplate {
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates
+}
+
+// compareTemplates lists the differences between two templates of the same
+// name, comparing tags by name
+func compareTemplates(x, y model.SnapshotTemplate) []model.FieldChange {
+	changes := templateDiff(snapshotTemplate(x), snapshotTemplate(y))
+	if !reflect.DeepEqual(x.Tags, y.Tags) {
+		changes = append(changes, model.FieldChange{Path: "tags", Old: x.Tags, New: y.Tags})
+	}
+	return changes
+}
+
+// snapshotTemplate converts a snapshot template for templateDiff, leaving
+// out environment and tag IDs, which differ between environments
+func snapshotTemplate(t model.SnapshotTemplate) *model.Template {
+	return &model.Template{
+		Name:          t.Name,
+		Description:   t.Description,
+		Format:        t.Format,
+		Content:       t.Content,
+		Schema:        t.Schema,
+		DefaultValues: t.DefaultValues,
+		Version:       t.Version,
+		Active:        t.Active,
+	}
+}
+
+// snapshotTemplates copies templates, loaded with their tags, into the form
+// snapshots keep them in
+func snapshotTemplates(templates []model.Template) []model.SnapshotTemplate {
+	out := make([]model.SnapshotTemplate, 0, len(templates))
+	for _, tpl := range templates {
+		tags := make([]string, 0, len(tpl.Tags))
+		for _, tag := range tpl.Tags {
+			tags = append(tags, tag.Name)
+		}
+		sort.Strings(tags)
+		out = append(out, model.SnapshotTemplate{
+			Name:          tpl.Name,
+			Description:   tpl.Description,
+			Format:        tpl.Format,
+			Content:       tpl.Content,
+			Schema:        tpl.Schema,
+			DefaultValues: tpl.DefaultValues,
+			Version:       tpl.Version,
+			Active:        tpl.Active,
+			Tags:          tags,
+		})
+	}
+	return out
+}
+
+// snapshotResponse converts a snapshot into its API representation with
+// secret values masked
+func snapshotResponse(snap model.Snapshot, slug string) model.SnapshotResponse {
+	if snap.Templates != nil {
+		templates := make([]model.SnapshotTemplate, len(snap.Templates))
+		for i, tpl := range snap.Templates {
+			tpl.DefaultValues = secrets.Masked(tpl.DefaultValues)
+			templates[i] = tpl
+		}
+		snap.Templates = templates
+	}
+	return model.SnapshotResponse{Snapshot: snap, Environment: slug}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/database/dbtest"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+)
+
+func newTestSnapshotService(db *database.Connection) *SnapshotService {
+	return NewSnapshotService(db, repository.NewEnvironmentRepository(db), repository.NewTemplateRepository(db),
+		repository.NewSnapshotRepository(db), repository.NewAuditRepository(db))
+}
+
+func newTestEnvironmentService(db *database.Connection) *EnvironmentService {
+	return NewEnvironmentService(db, repository.NewEnvironmentRepository(db), repository.NewTemplateRepository(db),
+		repository.NewAuditRepository(db))
+}
+
+func TestEnvironmentClone(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t)
+	s := newTestEnvironmentService(db)
+	tags := createTestTags(t, newTestTagService(db), "api", "db")
+	prod := createTestEnvironment(t, db, "prod")
+	protectTestEnvironment(t, db, prod, 1)
+	createTestTemplate(t, db, prod.ID, "app", model.ConfigFormatYAML, model.JSONMap{"a": 1}, tags["api"].ID, tags["db"].ID)
+	createTestTemplate(t, db, prod.ID, "worker", model.ConfigFormatJSON, nil, tags["db"].ID)
+	createTestTemplate(t, db, prod.ID, "plain", model.ConfigFormatEnv, nil)
+
+	resp, err := s.Clone(ctx, "prod", model.CloneEnvironmentRequest{Name: "Staging", Slug: "staging"}, "bob")
+	if err != nil {
+		t.Fatalf("Clone error: %v", err)
+	}
+	if resp.Templates != 3 || resp.TagLinks != 3 || resp.Source != "prod" {
+		t.Errorf("Clone response = %+v, want 3 templates and 3 tag links", resp)
+	}
+	if resp.Environment.Protected {
+		t.Error("clone is protected, want it to start unprotected")
+	}
+
+	templates := repository.NewTemplateRepository(db)
+	want := map[string][]string{"app": {"api", "db"}, "plain": nil, "worker": {"db"}}
+	for _, envID := range []int64{prod.ID, resp.Environment.ID} {
+		list, err := templates.ListByEnvironment(ctx, envID, repository.TemplateLoad{Tags: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != len(want) {
+			t.Fatalf("environment %d has %d templates, want %d", envID, len(list), len(want))
+		}
+		for _, tpl := range list {
+			if got := templateTagNames(t, db, tpl.ID); !reflect.DeepEqual(got, want[tpl.Name]) {
+				t.Errorf("tags of %s in environment %d = %v, want %v", tpl.Name, envID, got, want[tpl.Name])
+			}
+			if envID != prod.ID && tpl.CreatedBy != "bob" {
+				t.Errorf("cloned %s created by %s, want bob", tpl.Name, tpl.CreatedBy)
+			}
+		}
+	}
+
+	comparison, err := newTestSnapshotService(db).Compare(ctx, "prod", "staging")
+	if err != nil {
+		t.Fatalf("Compare error: %v", err)
+	}
+	if comparison.Unchanged != 3 || len(comparison.Added)+len(comparison.Removed)+len(comparison.Changed) != 0 {
+		t.Errorf("comparison with the clone = %+v, want everything unchanged", comparison)
+	}
+
+	var conflictErr *ConflictError
+	if _, err := s.Clone(ctx, "prod", model.CloneEnvironmentRequest{Name: "Staging", Slug: "staging"}, "bob"); !errors.As(err, &conflictErr) {
+		t.Errorf("second Clone error = %v, want a conflict", err)
+	}
+}
+
+func TestSnapshotCompare(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t)
+	s := newTestSnapshotService(db)
+	tags := createTestTags(t, newTestTagService(db), "api", "db")
+	prod := createTestEnvironment(t, db, "prod")
+	// Mixed case names, which byte order and most collations sort differently
+	createTestTemplate(t, db, prod.ID, "beta", model.ConfigFormatYAML, model.JSONMap{"x": 1})
+	createTestTemplate(t, db, prod.ID, "Cache", model.ConfigFormatYAML, nil, tags["api"].ID)
+	alpha := createTestTemplate(t, db, prod.ID, "alpha", model.ConfigFormatYAML, model.JSONMap{"x": 1})
+	removed := createTestTemplate(t, db, prod.ID, "Old", model.ConfigFormatYAML, nil)
+	createTestTemplate(t, db, prod.ID, "same", model.ConfigFormatYAML, nil)
+
+	if _, err := s.Create(ctx, "prod", model.CreateSnapshotRequest{Name: "before"}, "alice"); err != nil {
+		t.Fatalf("Create snapshot error: %v", err)
+	}
+	var conflictErr *ConflictError
+	if _, err := s.Create(ctx, "prod", model.CreateSnapshotRequest{Name: "before"}, "alice"); !errors.As(err, &conflictErr) {
+		t.Errorf("second Create snapshot error = %v, want a conflict", err)
+	}
+
+	if _, err := db.DB.Exec(`UPDATE templates SET content = 'changed', default_values = '{"x": 2}' WHERE id = $1`, alpha.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.DB.Exec(`UPDATE templates SET default_values = '{"x": 2}' WHERE name = 'beta'`); err != nil {
+		t.Fatal(err)
+	}
+	cache, err := repository.NewTemplateRepository(db).GetByName(ctx, prod.ID, "Cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.NewTemplateRepository(db).SetTags(ctx, cache.ID, []int64{tags["db"].ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.NewTemplateRepository(db).Delete(ctx, removed.ID); err != nil {
+		t.Fatal(err)
+	}
+	createTestTemplate(t, db, prod.ID, "New", model.ConfigFormatYAML, nil)
+	createTestTemplate(t, db, prod.ID, "extra", model.ConfigFormatYAML, nil)
+
+	changed := []model.TemplateComparison{
+		{Name: "Cache", FromVersion: "1.0.0", ToVersion: "1.0.0", Changes: []model.FieldChange{
+			{Path: "tags", Old: []string{"api"}, New: []string{"db"}},
+		}},
+		{Name: "alpha", FromVersion: "1.0.0", ToVersion: "1.0.0", Changes: []model.FieldChange{
+			{Path: "content", Old: "{{ . }}", New: "changed"},
+			{Path: "default_values.x", Old: float64(1), New: float64(2)},
+		}},
+		{Name: "beta", FromVersion: "1.0.0", ToVersion: "1.0.0", Changes: []model.FieldChange{
+			{Path: "default_values.x", Old: float64(1), New: float64(2)},
+		}},
+	}
+	comparison, err := s.Compare(ctx, "prod@before", "prod")
+	if err != nil {
+		t.Fatalf("Compare error: %v", err)
+	}
+	want := &model.EnvironmentComparison{
+		From:      "prod@before",
+		To:        "prod",
+		Added:     []model.ComparedTemplate{{Name: "New", Version: "1.0.0"}, {Name: "extra", Version: "1.0.0"}},
+		Removed:   []model.ComparedTemplate{{Name: "Old", Version: "1.0.0"}},
+		Changed:   changed,
+		Unchanged: 1,
+	}
+	if !reflect.DeepEqual(comparison, want) {
+		t.Errorf("Compare(snapshot, live) = %+v, want %+v", comparison, want)
+	}
+
+	// The other way round lists templates in the same order
+	comparison, err = s.Compare(ctx, "prod", "prod@before")
+	if err != nil {
+		t.Fatalf("Compare error: %v", err)
+	}
+	var names []string
+	for _, c := range comparison.Changed {
+		names = append(names, c.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"Cache", "alpha", "beta"}) ||
+		!reflect.DeepEqual(comparison.Removed, want.Added) || !reflect.DeepEqual(comparison.Added, want.Removed) {
+		t.Errorf("Compare(live, snapshot) = %+v", comparison)
+	}
+
+	if _, err := s.Compare(ctx, "prod@missing", "prod"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Compare with a missing snapshot error = %v, want ErrNotFound", err)
+	}
+}
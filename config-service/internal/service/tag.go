@@ -0,0 +1,784 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/selector"
+	"github.com/company/config-service/pkg/metrics"
+)
+
+// labelKeyPattern accepts keys such as team or example.com/team
+var labelKeyPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9.-]*[a-z0-9])?/)?[a-z0-9]([a-z0-9._-]*[a-z0-9])?$`)
+
+// TagService manages tags and key/value labels
+type TagService struct {
+	db        *database.Connection
+	tags      *repository.TagRepository
+	audit     *repository.AuditRepository
+	webhooks  *repository.WebhookRepository
+	labelKeys map[string]bool
+}
+
+// NewTagService creates a new tag service. labelKeys restricts the keys of
+// label tags; an empty list allows any well-formed key. Webhook selectors
+// follow renamed and merged tags, and keep the tags they name from cleanup.
+func NewTagService(db *database.Connection, tags *repository.TagRepository, audit *repository.AuditRepository,
+	webhooks *repository.WebhookRepository, labelKeys []string) *TagService {
+	s := &TagService{db: db, tags: tags, audit: audit, webhooks: webhooks}
+	if len(labelKeys) > 0 {
+		s.labelKeys = make(map[string]bool, len(labelKeys))
+		for _, key := range labelKeys {
+			s.labelKeys[strings.TrimSpace(key)] = true
+		}
+	}
+	return s
+}
+
+// AsOf returns a copy of the service reading the state recorded in the
+// history at the given moment, or the service itself for nil. The copy only
+// serves List, ListPage, Get and Tree.
+func (s *TagService) AsOf(at *time.Time) *TagService {
+	if at == nil {
+		return s
+	}
+	c := *s
+	c.tags = s.tags.AsOf(*at)
+	return &c
+}
+
+// List returns all tags ordered by name
+func (s *TagService) List(ctx context.Context) ([]model.Tag, error) {
+	return s.tags.List(ctx)
+}
+
+// ListPage returns one page of tags
+func (s *TagService) ListPage(ctx context.Context, page model.PaginationParams, sort model.SortParams) (*model.TagListResponse, error) {
+	if err := validateStruct(page); err != nil {
+		return nil, err
+	}
+	if err := validateStruct(sort); err != nil {
+		return nil, err
+	}
+
+	tags, info, err := s.tags.ListPage(ctx, page, sort)
+	if err != nil {
+		return nil, pageError(err)
+	}
+
+	resp := &model.TagListResponse{
+		Tags:       make([]model.TagResponse, 0, len(tags)),
+		Total:      info.Total,
+		Page:       pageNumber(page),
+		PageSize:   page.PageSize,
+		HasNext:    info.HasNext,
+		NextCursor: info.NextCursor,
+		PrevCursor: info.PrevCursor,
+	}
+	for _, tag := range tags {
+		resp.Tags = append(resp.Tags, model.NewTagResponse(tag))
+	}
+	return resp, nil
+}
+
+// Get returns the tag with the given ID
+func (s *TagService) Get(ctx context.Context, id int64) (*model.Tag, error) {
+	return s.tags.GetByID(ctx, id)
+}
+
+// Create creates a tag. A name of the form key=value without explicit key
+// and value creates a label, as does a key and value without a name.
+func (s *TagService) Create(ctx context.Context, req model.CreateTagRequest) (*model.Tag, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+
+	tag := &model.Tag{
+		Name:        req.Name,
+		Key:         req.Key,
+		Value:       req.Value,
+		ParentID:    req.ParentID,
+		Description: req.Description,
+		Color:       req.Color,
+	}
+	if err := s.normalize(tag); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		repo := s.tags.WithTx(tx)
+		if err := checkUnique(ctx, repo, tag); err != nil {
+			return err
+		}
+		if err := checkParent(ctx, repo, tag); err != nil {
+			return err
+		}
+		return repo.Create(ctx, tag)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// Update applies the set fields of req to a tag
+func (s *TagService) Update(ctx context.Context, id int64, req model.UpdateTagRequest) (*model.Tag, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+
+	var tag *model.Tag
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		repo := s.tags.WithTx(tx)
+		var err error
+		if tag, err = repo.GetByID(ctx, id); err != nil {
+			return err
+		}
+		before := *tag
+		if err := s.applyUpdate(tag, req); err != nil {
+			return err
+		}
+		if err := checkUnique(ctx, repo, tag); err != nil {
+			return err
+		}
+		if err := checkParent(ctx, repo, tag); err != nil {
+			return err
+		}
+		if err := repo.Update(ctx, tag); err != nil {
+			return err
+		}
+		if tag.Name == before.Name && tag.Key == before.Key && tag.Value == before.Value {
+			return nil
+		}
+		_, err = s.renameInSelectors(ctx, tx, []model.Tag{before}, *tag)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// Tree returns all tags arranged by parent, siblings ordered by name
+func (s *TagService) Tree(ctx context.Context) ([]model.TagNode, error) {
+	tags, err := s.tags.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[int64][]model.Tag)
+	known := make(map[int64]bool, len(tags))
+	for _, tag := range tags {
+		known[tag.ID] = true
+	}
+	var roots []model.Tag
+	for _, tag := range tags {
+		if tag.ParentID == nil || !known[*tag.ParentID] {
+			roots = append(roots, tag)
+			continue
+		}
+		children[*tag.ParentID] = append(children[*tag.ParentID], tag)
+	}
+
+	visited := make(map[int64]bool, len(tags))
+	var build func(tags []model.Tag) []model.TagNode
+	build = func(tags []model.Tag) []model.TagNode {
+		nodes := make([]model.TagNode, 0, len(tags))
+		for _, tag := range tags {
+			if visited[tag.ID] {
+				continue
+			}
+			visited[tag.ID] = true
+			nodes = append(nodes, model.TagNode{
+				TagResponse: model.NewTagResponse(tag),
+				Children:    build(children[tag.ID]),
+			})
+		}
+		return nodes
+	}
+	return build(roots), nil
+}
+
+// Usage returns template counts per tag broken down by environment and
+// format, and refreshes the tag usage metrics
+func (s *TagService) Usage(ctx context.Context) (*model.TagUsageResponse, error) {
+	tags, err := s.tags.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.tags.TemplateCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &model.TagUsageResponse{Tags: make([]model.TagUsage, 0, len(tags)), Total: len(tags)}
+	index := make(map[int64]int, len(tags))
+	for i, tag := range tags {
+		index[tag.ID] = i
+		resp.Tags = append(resp.Tags, model.TagUsage{
+			TagID:         tag.ID,
+			Name:          tag.Name,
+			ByEnvironment: map[string]int{},
+			ByFormat:      map[string]int{},
+			CreatedAt:     tag.CreatedAt,
+		})
+	}
+	for _, c := range counts {
+		i, ok := index[c.TagID]
+		if !ok {
+			continue
+		}
+		usage := &resp.Tags[i]
+		usage.Templates += c.Templates
+		usage.ByEnvironment[c.Environment] += c.Templates
+		usage.ByFormat[string(c.Format)] += c.Templates
+		if usage.LastUsedAt == nil || c.LastUsedAt.After(*usage.LastUsedAt) {
+			lastUsed := c.LastUsedAt
+			usage.LastUsedAt = &lastUsed
+		}
+	}
+
+	byTag := make(map[string]int, len(resp.Tags))
+	for _, usage := range resp.Tags {
+		byTag[usage.Name] = usage.Templates
+		if usage.Templates == 0 {
+			resp.Unused++
+		}
+	}
+	metrics.UpdateTagUsage(byTag)
+
+	return resp, nil
+}
+
+// RefreshMetrics recomputes the tag usage metrics
+func (s *TagService) RefreshMetrics(ctx context.Context) error {
+	_, err := s.Usage(ctx)
+	return err
+}
+
+// Cleanup lists, or unless DryRun deletes, tags without template links and
+// child tags that were created more than OlderThanDays days ago. Tags named
+// by a webhook selector are kept.
+func (s *TagService) Cleanup(ctx context.Context, req model.TagCleanupRequest) (*model.TagCleanupResponse, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+
+	before := time.Now().AddDate(0, 0, -req.OlderThanDays)
+	var tags []model.Tag
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		repo := s.tags.WithTx(tx)
+		keep, err := s.selectedTags(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if req.DryRun {
+			tags, err = repo.ListUnused(ctx, before, keep)
+		} else {
+			tags, err = repo.DeleteUnused(ctx, before, keep)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &model.TagCleanupResponse{
+		DryRun: req.DryRun,
+		Tags:   make([]model.TagResponse, 0, len(tags)),
+		Total:  len(tags),
+	}
+	for _, tag := range tags {
+		resp.Tags = append(resp.Tags, model.NewTagResponse(tag))
+	}
+	return resp, nil
+}
+
+// selectedTags returns the IDs of the tags named by webhook selectors. The
+// subscriptions are locked so that no selector starts naming a tag being
+// deleted.
+func (s *TagService) selectedTags(ctx context.Context, tx *sql.Tx) ([]int64, error) {
+	webhooks, err := s.webhooks.WithTx(tx).ListSelecting(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(webhooks) == 0 {
+		return nil, nil
+	}
+	tags, err := s.tags.WithTx(tx).List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int64
+	for _, w := range webhooks {
+		expr, err := selector.Parse(w.Selector)
+		if err != nil {
+			// Selectors are validated when written; an unparsable one
+			// selects nothing
+			continue
+		}
+		for _, tag := range tags {
+			if selector.References(expr, tag) {
+				ids = append(ids, tag.ID)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// renameInSelectors rewrites the terms of webhook selectors naming one of
+// the from tags to name to instead, returning the number of subscriptions
+// changed
+func (s *TagService) renameInSelectors(ctx context.Context, tx *sql.Tx, from []model.Tag, to model.Tag) (int, error) {
+	repo := s.webhooks.WithTx(tx)
+	webhooks, err := repo.ListSelecting(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	for _, w := range webhooks {
+		expr, err := selector.Parse(w.Selector)
+		if err != nil {
+			continue
+		}
+		renamed := false
+		for _, tag := range from {
+			var ok bool
+			expr, ok = selector.Rename(expr, tag, to)
+			renamed = renamed || ok
+		}
+		if !renamed {
+			continue
+		}
+		if err := repo.UpdateSelector(ctx, w.ID, expr.String()); err != nil {
+			return 0, err
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+// Merge moves all template links of the source tags to the target tag,
+// dropping links the target already has, moves their child tags under the
+// target and deletes the sources. A missing target with a single source
+// renames that source instead. Both write an audit entry attributed to actor;
+// everything happens in one transaction.
+func (s *TagService) Merge(ctx context.Context, req model.TagMergeRequest, actor string) (*model.TagMergeResponse, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+
+	var resp *model.TagMergeResponse
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		repo := s.tags.WithTx(tx)
+		if err := repo.LockHierarchy(ctx); err != nil {
+			return err
+		}
+
+		sources, err := repo.ListByNames(ctx, req.Sources)
+		if err != nil {
+			return err
+		}
+		byID := make(map[int64]model.Tag, len(sources))
+		found := make(map[string]bool, len(sources))
+		for _, tag := range sources {
+			byID[tag.ID] = tag
+			found[tag.Name] = true
+		}
+		for _, name := range req.Sources {
+			if !found[name] {
+				return &ValidationError{Field: "sources", Message: "tag " + name + " does not exist"}
+			}
+			if name == req.Target {
+				return &ValidationError{Field: "target", Message: "must not be one of the sources"}
+			}
+		}
+
+		target, err := repo.GetByName(ctx, req.Target)
+		switch {
+		case errors.Is(err, repository.ErrNotFound) && len(sources) == 1:
+			resp, err = s.rename(ctx, tx, sources[0], req, actor)
+			return err
+		case errors.Is(err, repository.ErrNotFound):
+			return &ValidationError{Field: "target", Message: "tag " + req.Target + " does not exist"}
+		case err != nil:
+			return err
+		}
+
+		sourceIDs := make([]int64, 0, len(sources))
+		for _, tag := range sources {
+			sourceIDs = append(sourceIDs, tag.ID)
+		}
+		// outside resolves a parent to its nearest ancestor that is not merged away
+		outside := func(parentID *int64) *int64 {
+			for parentID != nil {
+				source, ok := byID[*parentID]
+				if !ok {
+					break
+				}
+				parentID = source.ParentID
+			}
+			return parentID
+		}
+
+		moved, duplicates, err := repo.MoveLinks(ctx, sourceIDs, target.ID)
+		if err != nil {
+			return err
+		}
+
+		if parentID := outside(target.ParentID); !equalIDs(parentID, target.ParentID) {
+			if err := repo.SetParent(ctx, target.ID, parentID); err != nil {
+				return err
+			}
+			target.ParentID = parentID
+		}
+
+		ancestors, err := repo.Ancestors(ctx, target.ID)
+		if err != nil {
+			return err
+		}
+		aboveTarget := make(map[int64]bool, len(ancestors))
+		for _, id := range ancestors {
+			aboveTarget[id] = true
+		}
+
+		children, err := repo.ListChildren(ctx, sourceIDs)
+		if err != nil {
+			return err
+		}
+		childrenMoved := 0
+		for _, child := range children {
+			if _, isSource := byID[child.ID]; isSource || child.ID == target.ID {
+				continue
+			}
+			parentID := &target.ID
+			if aboveTarget[child.ID] {
+				// Moving an ancestor of the target below it would create a cycle
+				parentID = outside(child.ParentID)
+			}
+			if err := repo.SetParent(ctx, child.ID, parentID); err != nil {
+				return err
+			}
+			childrenMoved++
+		}
+
+		selectorsUpdated, err := s.renameInSelectors(ctx, tx, sources, *target)
+		if err != nil {
+			return err
+		}
+
+		if err := repo.Delete(ctx, sourceIDs); err != nil {
+			return err
+		}
+
+		entry := &model.AuditEntry{
+			Action:     model.AuditTagMerge,
+			EntityType: "tag",
+			EntityID:   &target.ID,
+			Actor:      actor,
+			Details: model.JSONMap{
+				"sources":            req.Sources,
+				"source_ids":         sourceIDs,
+				"target":             target.Name,
+				"links_moved":        moved,
+				"duplicates_removed": duplicates,
+				"children_moved":     childrenMoved,
+				"selectors_updated":  selectorsUpdated,
+			},
+		}
+		if err := s.audit.WithTx(tx).Create(ctx, entry); err != nil {
+			return err
+		}
+
+		resp = &model.TagMergeResponse{
+			Target:            model.NewTagResponse(*target),
+			Sources:           req.Sources,
+			LinksMoved:        moved,
+			DuplicatesRemoved: duplicates,
+			ChildrenMoved:     childrenMoved,
+			SelectorsUpdated:  selectorsUpdated,
+			AuditID:           entry.ID,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// rename gives a single source tag the target name
+func (s *TagService) rename(ctx context.Context, tx *sql.Tx, tag model.Tag, req model.TagMergeRequest, actor string) (*model.TagMergeResponse, error) {
+	repo := s.tags.WithTx(tx)
+	old := tag
+
+	// The key and value of a label follow its new name
+	tag.Name = req.Target
+	tag.Key, tag.Value = "", ""
+	if err := s.normalize(&tag); err != nil {
+		return nil, err
+	}
+	if err := checkUnique(ctx, repo, &tag); err != nil {
+		return nil, err
+	}
+	if err := repo.Update(ctx, &tag); err != nil {
+		return nil, err
+	}
+	selectorsUpdated, err := s.renameInSelectors(ctx, tx, []model.Tag{old}, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &model.AuditEntry{
+		Action:     model.AuditTagRename,
+		EntityType: "tag",
+		EntityID:   &tag.ID,
+		Actor:      actor,
+		Details:    model.JSONMap{"from": old.Name, "to": tag.Name, "selectors_updated": selectorsUpdated},
+	}
+	if err := s.audit.WithTx(tx).Create(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return &model.TagMergeResponse{
+		Target:           model.NewTagResponse(tag),
+		Sources:          req.Sources,
+		Renamed:          true,
+		SelectorsUpdated: selectorsUpdated,
+		AuditID:          entry.ID,
+	}, nil
+}
+
+// applyUpdate copies the set fields of req onto tag. A renamed tag takes its
+// key and value from the new name unless they are set too, and a label
+// named after its key and value is renamed along with them.
+func (s *TagService) applyUpdate(tag *model.Tag, req model.UpdateTagRequest) error {
+	defaultName := tag.IsLabel() && tag.Name == tag.Key+model.LabelSeparator+tag.Value
+	if req.Name != nil && *req.Name != tag.Name {
+		tag.Name = *req.Name
+		tag.Key, tag.Value = "", ""
+	} else if defaultName && (req.Key != nil || req.Value != nil) {
+		tag.Name = ""
+	}
+	if req.Key != nil {
+		tag.Key = *req.Key
+	}
+	if req.Value != nil {
+		tag.Value = *req.Value
+	}
+	if req.Description != nil {
+		tag.Description = *req.Description
+	}
+	if req.Color != nil {
+		tag.Color = *req.Color
+	}
+	if req.ParentID != nil {
+		tag.ParentID = req.ParentID
+		if *req.ParentID == 0 {
+			tag.ParentID = nil
+		}
+	}
+	return s.normalize(tag)
+}
+
+// MigrateLabels converts plain tags named <key><separator><value> whose key
+// is allowed into key/value labels, keeping their names. Tags whose label
+// already exists are skipped. With DryRun nothing is written.
+func (s *TagService) MigrateLabels(ctx context.Context, req model.MigrateLabelsRequest) (*model.MigrateLabelsResponse, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+
+	keys := s.labelKeys
+	if len(req.Keys) > 0 {
+		keys = make(map[string]bool, len(req.Keys))
+		for _, key := range req.Keys {
+			if err := s.validateKey(key); err != nil {
+				return nil, err
+			}
+			keys[key] = true
+		}
+	}
+	if len(keys) == 0 {
+		return nil, &ValidationError{Field: "keys", Message: "required when no label keys are configured"}
+	}
+
+	resp := &model.MigrateLabelsResponse{
+		DryRun:   req.DryRun,
+		Migrated: []model.MigratedLabel{},
+		Skipped:  []model.MigratedLabel{},
+	}
+
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		repo := s.tags.WithTx(tx)
+		tags, err := repo.List(ctx)
+		if err != nil {
+			return err
+		}
+
+		taken := make(map[[2]string]bool)
+		for _, tag := range tags {
+			if tag.IsLabel() {
+				taken[[2]string{tag.Key, tag.Value}] = true
+			}
+		}
+
+		for _, tag := range tags {
+			if tag.IsLabel() {
+				continue
+			}
+			key, value, ok := strings.Cut(tag.Name, req.Separator)
+			key = strings.ToLower(key)
+			if !ok || value == "" || !keys[key] {
+				continue
+			}
+
+			label := model.MigratedLabel{TagID: tag.ID, Name: tag.Name, Key: key, Value: value}
+			if taken[[2]string{key, value}] {
+				label.Reason = "label already exists"
+				resp.Skipped = append(resp.Skipped, label)
+				continue
+			}
+			taken[[2]string{key, value}] = true
+			resp.Migrated = append(resp.Migrated, label)
+
+			if req.DryRun {
+				continue
+			}
+			tag.Key, tag.Value = key, value
+			if err := repo.Update(ctx, &tag); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// normalize derives label fields from the name, defaults the name of labels
+// and validates the label key
+func (s *TagService) normalize(tag *model.Tag) error {
+	if tag.Key == "" && tag.Value == "" {
+		if key, value, ok := model.ParseLabel(tag.Name); ok {
+			tag.Key, tag.Value = key, value
+		}
+	}
+	if tag.Key == "" && tag.Value == "" {
+		if tag.Name == "" {
+			return &ValidationError{Field: "name", Message: "is required"}
+		}
+		return nil
+	}
+
+	if tag.Key == "" || tag.Value == "" {
+		return &ValidationError{Field: "key", Message: "labels need both key and value"}
+	}
+	if err := s.validateKey(tag.Key); err != nil {
+		return err
+	}
+	if tag.Value == "*" || strings.ContainsAny(tag.Value, "\"'()") {
+		return &ValidationError{Field: "value", Message: "must not be * or contain quotes or parentheses"}
+	}
+	if tag.Name == "" {
+		tag.Name = tag.Key + model.LabelSeparator + tag.Value
+	}
+	if len(tag.Name) > 100 {
+		return &ValidationError{Field: "name", Message: "failed on the 'max=100' rule"}
+	}
+	return nil
+}
+
+// validateKey checks the format of a label key and that it is allowed
+func (s *TagService) validateKey(key string) error {
+	if !labelKeyPattern.MatchString(key) {
+		return &ValidationError{Field: "key", Message: "must be lower case alphanumeric with optional prefix/, '.', '_' or '-'"}
+	}
+	if s.labelKeys != nil && !s.labelKeys[key] {
+		allowed := make([]string, 0, len(s.labelKeys))
+		for k := range s.labelKeys {
+			allowed = append(allowed, k)
+		}
+		sort.Strings(allowed)
+		return &ValidationError{Field: "key", Message: "must be one of " + strings.Join(allowed, ", ")}
+	}
+	return nil
+}
+
+// checkUnique reports a conflict when another tag has the same name or label
+func checkUnique(ctx context.Context, repo *repository.TagRepository, tag *model.Tag) error {
+	var conflicts []string
+
+	existing, err := repo.GetByName(ctx, tag.Name)
+	switch {
+	case err == nil && existing.ID != tag.ID:
+		conflicts = append(conflicts, "tag "+tag.Name)
+	case err != nil && !errors.Is(err, repository.ErrNotFound):
+		return err
+	}
+
+	if tag.IsLabel() {
+		existing, err := repo.GetByLabel(ctx, tag.Key, tag.Value)
+		switch {
+		case err == nil && existing.ID != tag.ID:
+			conflicts = append(conflicts, "label "+tag.Key+model.LabelSeparator+tag.Value)
+		case err != nil && !errors.Is(err, repository.ErrNotFound):
+			return err
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return &ConflictError{Conflicts: conflicts}
+	}
+	return nil
+}
+
+// checkParent verifies that the parent of a tag exists and that linking to
+// it does not create a cycle. It takes the hierarchy lock so that concurrent
+// parent changes cannot combine into a cycle.
+func checkParent(ctx context.Context, repo *repository.TagRepository, tag *model.Tag) error {
+	if tag.ParentID == nil {
+		return nil
+	}
+	if err := repo.LockHierarchy(ctx); err != nil {
+		return err
+	}
+
+	parentID := *tag.ParentID
+	if parentID == tag.ID {
+		return &ValidationError{Field: "parent_id", Message: "a tag cannot be its own parent"}
+	}
+	if _, err := repo.GetByID(ctx, parentID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return &ValidationError{Field: "parent_id", Message: fmt.Sprintf("tag %d does not exist", parentID)}
+		}
+		return err
+	}
+
+	if tag.ID == 0 {
+		return nil
+	}
+	ancestors, err := repo.Ancestors(ctx, parentID)
+	if err != nil {
+		return err
+	}
+	for _, id := range ancestors {
+		if id == tag.ID {
+			return &ValidationError{Field: "parent_id", Message: fmt.Sprintf("tag %d is a descendant of this tag", parentID)}
+		}
+	}
+	return nil
+}
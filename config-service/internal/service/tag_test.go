@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/database/dbtest"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+)
+
+func newTestTagService(db *database.Connection) *TagService {
+	return NewTagService(db, repository.NewTagRepository(db), repository.NewAuditRepository(db),
+		repository.NewWebhookRepository(db), nil)
+}
+
+// createTestTags creates tags by name, returning them by name
+func createTestTags(t *testing.T, s *TagService, names ...string) map[string]*model.Tag {
+	t.Helper()
+	tags := make(map[string]*model.Tag, len(names))
+	for _, name := range names {
+		tag, err := s.Create(context.Background(), model.CreateTagRequest{Name: name, Color: "#112233"})
+		if err != nil {
+			t.Fatalf("Create tag %s error: %v", name, err)
+		}
+		tags[name] = tag
+	}
+	return tags
+}
+
+// createTestWebhook subscribes to the templates matching a selector
+func createTestWebhook(t *testing.T, db *database.Connection, name, selector string) *model.WebhookSubscription {
+	t.Helper()
+	w := &model.WebhookSubscription{
+		Name:      name,
+		URL:       "https://example.com/" + name,
+		Secret:    "0123456789abcdef",
+		Selector:  selector,
+		Active:    true,
+		CreatedBy: "alice",
+	}
+	if err := repository.NewWebhookRepository(db).Create(context.Background(), w); err != nil {
+		t.Fatalf("Create webhook error: %v", err)
+	}
+	return w
+}
+
+func webhookSelector(t *testing.T, db *database.Connection, id int64) string {
+	t.Helper()
+	w, err := repository.NewWebhookRepository(db).GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetByID webhook error: %v", err)
+	}
+	return w.Selector
+}
+
+func TestTagMergeRewritesSelectors(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t)
+	s := newTestTagService(db)
+	tags := createTestTags(t, s, "db", "postgres", "database", "api")
+	merged := createTestWebhook(t, db, "merged", "(db OR postgres) AND NOT api")
+	other := createTestWebhook(t, db, "other", "api")
+
+	resp, err := s.Merge(ctx, model.TagMergeRequest{Sources: []string{"db", "postgres"}, Target: "database"}, "alice")
+	if err != nil {
+		t.Fatalf("Merge error: %v", err)
+	}
+	if resp.SelectorsUpdated != 1 {
+		t.Errorf("SelectorsUpdated = %d, want 1", resp.SelectorsUpdated)
+	}
+	if got, want := webhookSelector(t, db, merged.ID), "(database OR database) AND NOT api"; got != want {
+		t.Errorf("merged selector = %s, want %s", got, want)
+	}
+
+	resp, err = s.Merge(ctx, model.TagMergeRequest{Sources: []string{"database"}, Target: "team=data"}, "alice")
+	if err != nil {
+		t.Fatalf("rename error: %v", err)
+	}
+	if !resp.Renamed || resp.SelectorsUpdated != 1 {
+		t.Errorf("rename response = %+v", resp)
+	}
+	if got, want := webhookSelector(t, db, merged.ID), `("team=data" OR "team=data") AND NOT api`; got != want {
+		t.Errorf("renamed selector = %s, want %s", got, want)
+	}
+
+	name := "public"
+	if _, err := s.Update(ctx, tags["api"].ID, model.UpdateTagRequest{Name: &name}); err != nil {
+		t.Fatalf("Update error: %v", err)
+	}
+	if got, want := webhookSelector(t, db, other.ID), "public"; got != want {
+		t.Errorf("selector after update = %s, want %s", got, want)
+	}
+}
+
+func TestTagCleanupKeepsSelectedTags(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t)
+	s := newTestTagService(db)
+	createTestTags(t, s, "legacy", "watched", "team=core")
+	createTestWebhook(t, db, "watcher", "watched OR team=*")
+	if _, err := db.DB.Exec(`UPDATE tags SET created_at = NOW() - INTERVAL '10 days'`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		resp, err := s.Cleanup(ctx, model.TagCleanupRequest{OlderThanDays: 1, DryRun: dryRun})
+		if err != nil {
+			t.Fatalf("Cleanup error: %v", err)
+		}
+		if resp.Total != 1 || resp.Tags[0].Name != "legacy" {
+			t.Errorf("Cleanup(dry_run=%v) = %+v, want only legacy", dryRun, resp.Tags)
+		}
+	}
+
+	remaining, err := s.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 2 {
+		t.Errorf("remaining tags = %+v, want watched and team=core", remaining)
+	}
+}
+
+func TestTagUsage(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t)
+	s := newTestTagService(db)
+	tags := createTestTags(t, s, "api", "db", "idle")
+	prod := createTestEnvironment(t, db, "prod")
+	staging := createTestEnvironment(t, db, "staging")
+	a := createTestTemplate(t, db, prod.ID, "a", model.ConfigFormatYAML, nil, tags["api"].ID, tags["db"].ID)
+	b := createTestTemplate(t, db, staging.ID, "b", model.ConfigFormatJSON, nil, tags["api"].ID)
+
+	linked := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	relinked := linked.AddDate(0, 1, 0)
+	if _, err := db.DB.Exec(`UPDATE template_tags SET created_at = $1`, linked); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.DB.Exec(`UPDATE template_tags SET created_at = $1 WHERE template_id = $2`, relinked, b.ID); err != nil {
+		t.Fatal(err)
+	}
+	// Editing a template or setting the links it has does not count as a use
+	if _, err := db.DB.Exec(`UPDATE templates SET description = 'edited'`); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.NewTemplateRepository(db).SetTags(ctx, a.ID, []int64{tags["db"].ID, tags["api"].ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := s.Usage(ctx)
+	if err != nil {
+		t.Fatalf("Usage error: %v", err)
+	}
+	if resp.Total != 3 || resp.Unused != 1 {
+		t.Errorf("Total, Unused = %d, %d, want 3, 1", resp.Total, resp.Unused)
+	}
+	byName := make(map[string]model.TagUsage, len(resp.Tags))
+	for _, usage := range resp.Tags {
+		byName[usage.Name] = usage
+	}
+
+	api := byName["api"]
+	if api.Templates != 2 ||
+		!reflect.DeepEqual(api.ByEnvironment, map[string]int{"prod": 1, "staging": 1}) ||
+		!reflect.DeepEqual(api.ByFormat, map[string]int{"yaml": 1, "json": 1}) {
+		t.Errorf("api usage = %+v", api)
+	}
+	if api.LastUsedAt == nil || !api.LastUsedAt.Equal(relinked) {
+		t.Errorf("api last used = %v, want %v", api.LastUsedAt, relinked)
+	}
+	if usage := byName["db"]; usage.Templates != 1 || usage.LastUsedAt == nil || !usage.LastUsedAt.Equal(linked) {
+		t.Errorf("db usage = %+v, want last used %v", usage, linked)
+	}
+	if idle := byName["idle"]; idle.Templates != 0 || idle.LastUsedAt != nil {
+		t.Errorf("idle usage = %+v", idle)
+	}
+}
+
+func TestTagCleanupThresholds(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t)
+	s := newTestTagService(db)
+	tags := createTestTags(t, s, "old", "recent", "linked", "parent")
+	parentID := tags["parent"].ID
+	if _, err := s.Create(ctx, model.CreateTagRequest{Name: "child", Color: "#112233", ParentID: &parentID}); err != nil {
+		t.Fatal(err)
+	}
+	env := createTestEnvironment(t, db, "prod")
+	createTestTemplate(t, db, env.ID, "app", model.ConfigFormatYAML, nil, tags["linked"].ID)
+
+	if _, err := db.DB.Exec(`UPDATE tags SET created_at = NOW() - INTERVAL '10 days' WHERE name IN ('old', 'linked', 'parent')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.DB.Exec(`UPDATE tags SET created_at = NOW() - INTERVAL '2 days' WHERE name IN ('recent', 'child')`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		olderThanDays int
+		want          []string
+	}{
+		{olderThanDays: 30},
+		{olderThanDays: 5, want: []string{"old"}},
+		{olderThanDays: 1, want: []string{"child", "old", "recent"}},
+	}
+	for _, tt := range tests {
+		resp, err := s.Cleanup(ctx, model.TagCleanupRequest{OlderThanDays: tt.olderThanDays, DryRun: true})
+		if err != nil {
+			t.Fatalf("Cleanup error: %v", err)
+		}
+		var got []string
+		for _, tag := range resp.Tags {
+			got = append(got, tag.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Cleanup(older_than_days=%d) = %v, want %v", tt.olderThanDays, got, tt.want)
+		}
+	}
+}
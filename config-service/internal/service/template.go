@@ -0,0 +1,437 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/render"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/secrets"
+)
+
+// TemplateService provides read access to templates
+type TemplateService struct {
+	environments *repository.EnvironmentRepository
+	templates    *repository.TemplateRepository
+	asOf         *time.Time
+}
+
+// NewTemplateService creates a new template service
+func NewTemplateService(environments *repository.EnvironmentRepository, templates *repository.TemplateRepository) *TemplateService {
+	return &TemplateService{
+		environments: environments,
+		templates:    templates,
+	}
+}
+
+// AsOf returns a copy of the service reading the state recorded in the
+// history at the given moment, or the service itself for nil. Full-text
+// search is not available in the past.
+func (s *TemplateService) AsOf(at *time.Time) *TemplateService {
+	if at == nil {
+		return s
+	}
+	return &TemplateService{
+		environments: s.environments.AsOf(*at),
+		templates:    s.templates.AsOf(*at),
+		asOf:         at,
+	}
+}
+
+// List returns one page of templates matching the filter. Without field
+// parameters each template carries all fields, its environment and tags.
+func (s *TemplateService) List(ctx context.Context, filter model.TemplateFilter, page model.PaginationParams, sort model.SortParams, params model.FieldParams) (*model.TemplateListResponse, error) {
+	fields, load, err := templateFieldset(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateStruct(filter); err != nil {
+		return nil, err
+	}
+	if err := validateStruct(page); err != nil {
+		return nil, err
+	}
+	if err := validateStruct(sort); err != nil {
+		return nil, err
+	}
+	// A cursor carries its own sort, which takes precedence
+	if page.Cursor == "" && !repository.IsTemplateSortColumn(sort.SortBy) {
+		return nil, &ValidationError{Field: "sort_by", Message: "unsupported sort column " + sort.SortBy}
+	}
+	if err := validateSelector("selector", filter.Selector); err != nil {
+		return nil, err
+	}
+	if s.asOf != nil && filter.Search != "" {
+		return nil, &ValidationError{Field: "search", Message: "cannot be combined with as_of"}
+	}
+
+	templates, info, err := s.templates.ListPage(ctx, filter, page, sort, load)
+	if err != nil {
+		return nil, pageError(err)
+	}
+
+	resp := &model.TemplateListResponse{
+		Templates:  make([]model.TemplateResponse, 0, len(templates)),
+		Total:      info.Total,
+		Page:       pageNumber(page),
+		PageSize:   page.PageSize,
+		HasNext:    info.HasNext,
+		NextCursor: info.NextCursor,
+		PrevCursor: info.PrevCursor,
+	}
+	for i := range templates {
+		resp.Templates = append(resp.Templates, templateResponse(&templates[i]).WithFields(fields))
+	}
+	return resp, nil
+}
+
+// Get returns a template. Field parameters select fields and relations as
+// for List.
+func (s *TemplateService) Get(ctx context.Context, id int64, params model.FieldParams) (*model.TemplateResponse, error) {
+	fields, load, err := templateFieldset(params)
+	if err != nil {
+		return nil, err
+	}
+
+	tpl, err := s.templates.Load(ctx, id, load)
+	if err != nil {
+		return nil, err
+	}
+	resp := templateResponse(tpl).WithFields(fields)
+	return &resp, nil
+}
+
+// Dependencies returns the include relations of a template within its
+// environment, including every template affected by changing it
+func (s *TemplateService) Dependencies(ctx context.Context, id int64) (*model.TemplateDependenciesResponse, error) {
+	tpl, err := s.templates.Load(ctx, id, repository.TemplateLoad{Fields: model.Fieldset{"name": true}})
+	if err != nil {
+		return nil, err
+	}
+	env, err := s.environments.GetByID(ctx, tpl.EnvironmentID)
+	if err != nil {
+		return nil, err
+	}
+	templates, err := s.templates.ListByEnvironment(ctx, env.ID, repository.TemplateLoad{
+		Fields: model.Fieldset{"name": true, "content": true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	graph := render.NewGraph(templates)
+	refs := func(names []string) []model.TemplateRef {
+		out := make([]model.TemplateRef, 0, len(names))
+		for _, name := range names {
+			if t := graph.Template(name); t != nil {
+				out = append(out, model.TemplateRef{ID: t.ID, Name: t.Name, Partial: t.IsPartial()})
+			}
+		}
+		return out
+	}
+
+	resp := &model.TemplateDependenciesResponse{
+		Template:    model.TemplateRef{ID: tpl.ID, Name: tpl.Name, Partial: tpl.IsPartial()},
+		Environment: env.Slug,
+		Includes:    refs(graph.Includes(tpl.Name)),
+		Lookups:     refs(graph.Lookups(tpl.Name)),
+		Missing:     graph.Missing(tpl.Name),
+		IncludedBy:  refs(graph.IncludedBy(tpl.Name)),
+		LookedUpBy:  refs(graph.LookedUpBy(tpl.Name)),
+		Affected:    refs(graph.Affected(tpl.Name)),
+		Depth:       graph.Depth(tpl.Name),
+		Cycle:       graph.Cycle(tpl.Name),
+	}
+	if err := graph.Err(tpl.Name); err != nil {
+		resp.Error = err.Error()
+	}
+	return resp, nil
+}
+
+// checkIncludes verifies that the includes of a template saved within tx,
+// or of the content proposed for it, parse and neither form a cycle nor nest
+// deeper than render.MaxIncludeDepth
+func checkIncludes(ctx context.Context, repo *repository.TemplateRepository, tpl *model.Template) error {
+	refs, err := render.Refs(tpl.Content)
+	if err != nil {
+		return &ValidationError{Field: "content", Message: err.Error()}
+	}
+	if len(refs.Includes) == 0 {
+		return nil
+	}
+
+	templates, err := repo.ListByEnvironment(ctx, tpl.EnvironmentID, repository.TemplateLoad{
+		Fields: model.Fieldset{"name": true, "content": true},
+	})
+	if err != nil {
+		return err
+	}
+	for i := range templates {
+		if templates[i].Name == tpl.Name {
+			templates[i].Content = tpl.Content
+		}
+	}
+	graph := render.NewGraph(templates)
+	if cycle := graph.Cycle(tpl.Name); cycle != nil {
+		return &ValidationError{Field: "content", Message: "include cycle " + strings.Join(cycle, " -> ")}
+	}
+	if depth := graph.Depth(tpl.Name); depth > render.MaxIncludeDepth {
+		return &ValidationError{Field: "content", Message: fmt.Sprintf("includes nest %d levels deep, at most %d are allowed", depth, render.MaxIncludeDepth)}
+	}
+	return nil
+}
+
+// sealSecrets encrypts the plaintext secret values of tpl declared by its
+// schema. previous holds the stored values the template replaces, whose
+// envelopes are kept for masked values.
+func sealSecrets(cipher *secrets.Cipher, tpl *model.Template, previous model.JSONMap) error {
+	values, err := cipher.Seal(tpl.DefaultValues, tpl.Schema, previous)
+	if err != nil {
+		var secretErr *secrets.Error
+		if errors.As(err, &secretErr) {
+			return &ValidationError{Field: "default_values." + secretErr.Path, Message: secretErr.Msg}
+		}
+		return err
+	}
+	tpl.DefaultValues = values
+	return nil
+}
+
+// templateFieldset resolves field parameters into the fields to serialize
+// and what to load. Without parameters the full template with environment
+// and tags is returned; otherwise relations are embedded only when listed in
+// include, and the ID is always selected.
+func templateFieldset(params model.FieldParams) (model.Fieldset, repository.TemplateLoad, error) {
+	if err := validateStruct(params); err != nil {
+		return nil, repository.TemplateLoad{}, err
+	}
+	if strings.TrimSpace(params.Fields) == "" && strings.TrimSpace(params.Include) == "" {
+		return nil, repository.TemplateLoad{Environment: true, Tags: true}, nil
+	}
+
+	fields := model.Fieldset{"id": true}
+	if names := splitList(params.Fields); len(names) > 0 {
+		for _, name := range names {
+			switch {
+			case name == model.TemplateIncludeEnvironment || name == model.TemplateIncludeTags:
+				return nil, repository.TemplateLoad{}, &ValidationError{Field: "fields", Message: name + " is a relation, use include=" + name}
+			case !slices.Contains(model.TemplateResponseFields, name):
+				return nil, repository.TemplateLoad{}, &ValidationError{Field: "fields", Message: "unknown field " + name}
+			}
+			fields[name] = true
+		}
+	} else {
+		for _, name := range model.TemplateResponseFields {
+			fields[name] = true
+		}
+	}
+	for _, name := range splitList(params.Include) {
+		if name != model.TemplateIncludeEnvironment && name != model.TemplateIncludeTags {
+			return nil, repository.TemplateLoad{}, &ValidationError{Field: "include", Message: "unknown relation " + name}
+		}
+		fields[name] = true
+	}
+
+	return fields, repository.TemplateLoad{
+		Fields:      fields,
+		Environment: fields[model.TemplateIncludeEnvironment],
+		Tags:        fields[model.TemplateIncludeTags],
+	}, nil
+}
+
+// splitList splits a comma separated parameter, dropping blank items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Search runs a full-text search over template name, description and
+// content, narrowed by the other filter criteria
+func (s *TemplateService) Search(ctx context.Context, filter model.TemplateFilter, page model.PaginationParams) (*model.TemplateSearchResponse, error) {
+	if strings.TrimSpace(filter.Search) == "" {
+		return nil, &ValidationError{Field: "q", Message: "is required"}
+	}
+	if err := validateStruct(filter); err != nil {
+		return nil, err
+	}
+	if err := validateStruct(page); err != nil {
+		return nil, err
+	}
+	if err := validateSelector("selector", filter.Selector); err != nil {
+		return nil, err
+	}
+
+	matches, total, err := s.templates.Search(ctx, filter, page)
+	if err != nil {
+		return nil, err
+	}
+	slugs, err := s.environmentSlugs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &model.TemplateSearchResponse{
+		Query:    filter.Search,
+		Results:  make([]model.TemplateSearchHit, 0, len(matches)),
+		Total:    total,
+		Page:     page.Page,
+		PageSize: page.PageSize,
+		HasNext:  int64(page.Page*page.PageSize) < total,
+	}
+	for _, m := range matches {
+		tpl := m.Template
+		tags := make([]string, 0, len(tpl.Tags))
+		for _, tag := range tpl.Tags {
+			tags = append(tags, tag.Name)
+		}
+		snippets := map[string]string{}
+		if m.DescriptionSnippet != "" {
+			snippets["description"] = m.DescriptionSnippet
+		}
+		if m.ContentSnippet != "" {
+			snippets["content"] = m.ContentSnippet
+		}
+		resp.Results = append(resp.Results, model.TemplateSearchHit{
+			ID:          tpl.ID,
+			Name:        tpl.Name,
+			Description: tpl.Description,
+			Format:      tpl.Format,
+			Version:     tpl.Version,
+			Environment: slugs[tpl.EnvironmentID],
+			Tags:        tags,
+			Active:      tpl.Active,
+			UpdatedAt:   tpl.UpdatedAt,
+			Rank:        m.Rank,
+			Snippets:    snippets,
+		})
+	}
+	return resp, nil
+}
+
+// maxJSONPathLength bounds JSON path expressions
+const maxJSONPathLength = 1000
+
+// QueryPath finds templates whose default values or schema contain items
+// selected by a Postgres JSON path, e.g. `$.max_connections ? (@ > 100)`,
+// and returns the selected values. fields defaults to both documents. Paths
+// are evaluated with encrypted secrets replaced by the mask, so wildcards and
+// recursive accessors cannot reach envelopes.
+func (s *TemplateService) QueryPath(ctx context.Context, filter model.TemplateFilter, path string, fields []string, page model.PaginationParams) (*model.TemplatePathQueryResponse, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, &ValidationError{Field: "path", Message: "is required"}
+	}
+	if len(path) > maxJSONPathLength {
+		return nil, &ValidationError{Field: "path", Message: fmt.Sprintf("must be at most %d characters", maxJSONPathLength)}
+	}
+	if strings.Contains(path, secrets.Marker) {
+		return nil, &ValidationError{Field: "path", Message: "cannot address encrypted secret values"}
+	}
+	if len(fields) == 0 {
+		fields = []string{model.TemplateFieldDefaultValues, model.TemplateFieldSchema}
+	}
+	for _, field := range fields {
+		if field != model.TemplateFieldDefaultValues && field != model.TemplateFieldSchema {
+			return nil, &ValidationError{Field: "in", Message: "must be default_values or schema"}
+		}
+	}
+	if err := validateStruct(filter); err != nil {
+		return nil, err
+	}
+	if err := validateStruct(page); err != nil {
+		return nil, err
+	}
+	if err := validateSelector("selector", filter.Selector); err != nil {
+		return nil, err
+	}
+
+	matches, total, err := s.templates.QueryPath(ctx, filter, path, fields, page)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidJSONPath) {
+			return nil, &ValidationError{Field: "path", Message: err.Error()}
+		}
+		return nil, err
+	}
+	slugs, err := s.environmentSlugs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &model.TemplatePathQueryResponse{
+		Path:     path,
+		Fields:   fields,
+		Results:  make([]model.TemplatePathMatch, 0, len(matches)),
+		Total:    total,
+		Page:     page.Page,
+		PageSize: page.PageSize,
+		HasNext:  int64(page.Page*page.PageSize) < total,
+	}
+	for _, m := range matches {
+		for field, items := range m.Values {
+			for i, item := range items {
+				items[i] = secrets.MaskValue(item)
+			}
+			m.Values[field] = items
+		}
+		resp.Results = append(resp.Results, model.TemplatePathMatch{
+			ID:          m.Template.ID,
+			Name:        m.Template.Name,
+			Environment: slugs[m.Template.EnvironmentID],
+			Version:     m.Template.Version,
+			Active:      m.Template.Active,
+			Matches:     m.Values,
+		})
+	}
+	return resp, nil
+}
+
+// environmentSlugs maps environment IDs to slugs
+func (s *TemplateService) environmentSlugs(ctx context.Context) (map[int64]string, error) {
+	environments, err := s.environments.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slugs := make(map[int64]string, len(environments))
+	for _, env := range environments {
+		slugs[env.ID] = env.Slug
+	}
+	return slugs, nil
+}
+
+// templateResponse converts a template and its loaded relations into the API
+// representation
+func templateResponse(tpl *model.Template) model.TemplateResponse {
+	tags := make([]model.TagResponse, 0, len(tpl.Tags))
+	for _, tag := range tpl.Tags {
+		tags = append(tags, model.NewTagResponse(tag))
+	}
+
+	return model.TemplateResponse{
+		ID:            tpl.ID,
+		Name:          tpl.Name,
+		Description:   tpl.Description,
+		Format:        tpl.Format,
+		Content:       tpl.Content,
+		Schema:        tpl.Schema,
+		DefaultValues: secrets.Masked(tpl.DefaultValues),
+		Version:       tpl.Version,
+		EnvironmentID: tpl.EnvironmentID,
+		Environment:   model.NewEnvironmentResponse(tpl.Environment),
+		Tags:          tags,
+		Active:        tpl.Active,
+		CreatedAt:     tpl.CreatedAt,
+		UpdatedAt:     tpl.UpdatedAt,
+		CreatedBy:     tpl.CreatedBy,
+		UpdatedBy:     tpl.UpdatedBy,
+	}
+}
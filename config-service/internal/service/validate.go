@@ -0,0 +1,60 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/company/config-service/internal/selector"
+	"github.com/go-playground/validator/v10"
+)
+
+// validate checks the `validate` struct tags declared on request models
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	// Report JSON field names rather than Go field names
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+// validateStruct validates a request model and converts the first failure
+// into a *ValidationError
+func validateStruct(s interface{}) error {
+	err := validate.Struct(s)
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if errors.As(err, &fieldErrs) && len(fieldErrs) > 0 {
+		fe := fieldErrs[0]
+		msg := fmt.Sprintf("failed on the '%s' rule", fe.Tag())
+		if fe.Param() != "" {
+			msg = fmt.Sprintf("failed on the '%s=%s' rule", fe.Tag(), fe.Param())
+		}
+		// Drop the top-level struct name, keeping nested paths such as filter.format
+		field := fe.Namespace()
+		if i := strings.Index(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+		return &ValidationError{Field: field, Message: msg}
+	}
+	return &ValidationError{Message: err.Error()}
+}
+
+// validateSelector checks that a tag selector expression parses
+func validateSelector(field, expr string) error {
+	if _, err := selector.Parse(expr); err != nil {
+		return &ValidationError{Field: field, Message: err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,848 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/company/config-service/internal/database"
+	"github.com/company/config-service/internal/logger"
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/repository"
+	"github.com/company/config-service/internal/secrets"
+	"github.com/company/config-service/internal/selector"
+	"github.com/company/config-service/pkg/metrics"
+	"github.com/lib/pq"
+)
+
+// webhookLease names the lease electing the replica that delivers webhooks
+const webhookLease = "webhooks"
+
+const (
+	// maxDispatchedEvents bounds the events matched per webhook tick
+	maxDispatchedEvents = 500
+	// maxDueDeliveries bounds the deliveries claimed per webhook tick
+	maxDueDeliveries = 100
+	// maxLoggedResponse bounds the response body kept per attempt
+	maxLoggedResponse = 1024
+	// webhookPruneInterval is how often expired events are removed
+	webhookPruneInterval = time.Hour
+)
+
+// Limits of delivery listings
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+// WebhookOptions paces webhook deliveries and sets when failing
+// subscriptions are disabled
+type WebhookOptions struct {
+	// Interval is how often new events are matched and due deliveries sent
+	Interval time.Duration
+	// Lease is how long the elected replica may go silent before another
+	// one takes over
+	Lease time.Duration
+	// Timeout bounds each delivery request
+	Timeout time.Duration
+	// Concurrency is the number of deliveries sent at once
+	Concurrency int
+	// MaxAttempts is the number of attempts after which a delivery fails
+	MaxAttempts int
+	// RetryBase is the wait before the first retry, doubled for every
+	// further one up to RetryMax
+	RetryBase time.Duration
+	RetryMax  time.Duration
+	// DisableAfter is how long a subscription may fail every delivery
+	// before it is disabled; zero never disables
+	DisableAfter time.Duration
+	// Retention is how long events and their delivery logs are kept
+	Retention time.Duration
+}
+
+// WebhookService manages webhook subscriptions and delivers the template
+// events queued by the database to them. Every replica runs the worker; a
+// lease elects the one delivering. Deliveries are signed with the secret of
+// their subscription, sealed with the master key when one is configured, and
+// retried with exponential backoff.
+type WebhookService struct {
+	db           *database.Connection
+	environments *repository.EnvironmentRepository
+	tags         *repository.TagRepository
+	webhooks     *repository.WebhookRepository
+	leases       *repository.LeaseRepository
+	audit        *repository.AuditRepository
+	cipher       *secrets.Cipher
+	client       *http.Client
+	options      WebhookOptions
+	logger       *logger.Logger
+	owner        string
+	lastPrune    time.Time
+}
+
+// NewWebhookService creates a new webhook service. cipher may be nil when
+// no master key is configured.
+func NewWebhookService(db *database.Connection, environments *repository.EnvironmentRepository,
+	tags *repository.TagRepository, webhooks *repository.WebhookRepository, leases *repository.LeaseRepository, audit *repository.AuditRepository,
+	cipher *secrets.Cipher, options WebhookOptions, log *logger.Logger) *WebhookService {
+	if options.Concurrency < 1 {
+		options.Concurrency = 1
+	}
+	host, _ := os.Hostname()
+	return &WebhookService{
+		db:           db,
+		environments: environments,
+		tags:         tags,
+		webhooks:     webhooks,
+		leases:       leases,
+		audit:        audit,
+		cipher:       cipher,
+		client: &http.Client{
+			Timeout: options.Timeout,
+			// A redirect would turn the POST into a GET; report it instead
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		options: options,
+		logger:  log,
+		owner:   fmt.Sprintf("%s/%d", host, os.Getpid()),
+	}
+}
+
+// List returns all webhook subscriptions
+func (s *WebhookService) List(ctx context.Context) (*model.WebhookListResponse, error) {
+	webhooks, err := s.webhooks.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if webhooks == nil {
+		webhooks = []model.WebhookSubscription{}
+	}
+	return &model.WebhookListResponse{Webhooks: webhooks}, nil
+}
+
+// Get returns a webhook subscription without its secret
+func (s *WebhookService) Get(ctx context.Context, id int64) (*model.WebhookSubscription, error) {
+	return s.webhooks.GetByID(ctx, id)
+}
+
+// Create subscribes a URL to events, generating its secret when none is
+// given. The response holds the secret. It returns a *ConflictError when the
+// name is taken.
+func (s *WebhookService) Create(ctx context.Context, req model.CreateWebhookRequest, actor string) (*model.WebhookResponse, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+
+	w := &model.WebhookSubscription{
+		Name:       req.Name,
+		URL:        req.URL,
+		Secret:     req.Secret,
+		Selector:   strings.TrimSpace(req.Selector),
+		EventTypes: req.EventTypes,
+		Active:     req.Active == nil || *req.Active,
+		CreatedBy:  actor,
+	}
+	if w.Secret == "" {
+		var err error
+		if w.Secret, err = generateWebhookSecret(); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateWebhook(w); err != nil {
+		return nil, err
+	}
+	if err := s.sealSecret(w); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		if err := s.resolveEnvironment(ctx, tx, w, req.Environment); err != nil {
+			return err
+		}
+		repo := s.webhooks.WithTx(tx)
+		if err := repo.Create(ctx, w); err != nil {
+			return webhookConflict(err, w.Name)
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditWebhookCreate,
+			EntityType: "webhook",
+			EntityID:   &w.ID,
+			Actor:      actor,
+			Details:    model.JSONMap{"webhook": *w},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.WebhookResponse{WebhookSubscription: *w, Secret: w.Secret}, nil
+}
+
+// Update changes the fields of a subscription set in req. Activating a
+// subscription clears its failures; the response holds the secret when it
+// was changed.
+func (s *WebhookService) Update(ctx context.Context, id int64, req model.UpdateWebhookRequest, actor string) (*model.WebhookResponse, error) {
+	if err := validateStruct(req); err != nil {
+		return nil, err
+	}
+
+	var w *model.WebhookSubscription
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		repo := s.webhooks.WithTx(tx)
+		var err error
+		if w, err = repo.GetByID(ctx, id); err != nil {
+			return err
+		}
+		previous := *w
+
+		applyWebhookUpdate(w, &req, actor)
+		if err := validateWebhook(w); err != nil {
+			return err
+		}
+		if err := s.sealSecret(w); err != nil {
+			return err
+		}
+		if req.Environment != nil {
+			if err := s.resolveEnvironment(ctx, tx, w, *req.Environment); err != nil {
+				return err
+			}
+		}
+		if err := repo.Update(ctx, w); err != nil {
+			return webhookConflict(err, w.Name)
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditWebhookUpdate,
+			EntityType: "webhook",
+			EntityID:   &w.ID,
+			Actor:      actor,
+			Details: model.JSONMap{
+				"previous":       previous,
+				"current":        *w,
+				"secret_changed": req.Secret != nil,
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := &model.WebhookResponse{WebhookSubscription: *w}
+	if req.Secret != nil {
+		resp.Secret = w.Secret
+	}
+	return resp, nil
+}
+
+// Delete removes a subscription with its deliveries
+func (s *WebhookService) Delete(ctx context.Context, id int64, actor string) error {
+	return s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		repo := s.webhooks.WithTx(tx)
+		w, err := repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := repo.Delete(ctx, id); err != nil {
+			return err
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditWebhookDelete,
+			EntityType: "webhook",
+			EntityID:   &w.ID,
+			Actor:      actor,
+			Details:    model.JSONMap{"name": w.Name, "url": w.URL},
+		})
+	})
+}
+
+// Ping queues a ping event for a subscription alone, to test its endpoint.
+// It returns a *ConflictError when the subscription is disabled.
+func (s *WebhookService) Ping(ctx context.Context, id int64, actor string) (*model.WebhookDelivery, error) {
+	var d *model.WebhookDelivery
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		repo := s.webhooks.WithTx(tx)
+		w, err := activeWebhook(ctx, repo, id)
+		if err != nil {
+			return err
+		}
+		ev := &model.WebhookEvent{
+			Type:    model.WebhookPing,
+			Payload: model.JSONMap{"webhook": w.Name, "requested_by": actor},
+		}
+		if err := repo.CreateEvent(ctx, ev, true); err != nil {
+			return err
+		}
+		d = &model.WebhookDelivery{SubscriptionID: w.ID, EventID: ev.ID, EventType: ev.Type}
+		return repo.CreateDelivery(ctx, d)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Deliveries returns the latest deliveries of a subscription, newest first,
+// optionally restricted to a status
+func (s *WebhookService) Deliveries(ctx context.Context, id int64, status string, limit int) (*model.WebhookDeliveryListResponse, error) {
+	switch status {
+	case "", model.DeliveryPending, model.DeliverySucceeded, model.DeliveryFailed:
+	default:
+		return nil, &ValidationError{Field: "status", Message: "must be one of pending, succeeded or failed"}
+	}
+	if limit == 0 {
+		limit = defaultDeliveryLimit
+	}
+	if limit < 1 || limit > maxDeliveryLimit {
+		return nil, &ValidationError{Field: "limit", Message: fmt.Sprintf("must be between 1 and %d", maxDeliveryLimit)}
+	}
+
+	w, err := s.webhooks.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := s.webhooks.ListDeliveries(ctx, id, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	if deliveries == nil {
+		deliveries = []model.WebhookDelivery{}
+	}
+	return &model.WebhookDeliveryListResponse{Webhook: w.Name, Deliveries: deliveries}, nil
+}
+
+// Delivery returns a delivery of a subscription with the log of its attempts
+func (s *WebhookService) Delivery(ctx context.Context, id, deliveryID int64) (*model.WebhookDelivery, error) {
+	return s.webhooks.GetDelivery(ctx, id, deliveryID)
+}
+
+// Redeliver queues a new delivery of the event of a finished delivery,
+// due now. It returns a *ConflictError while the delivery is pending or the
+// subscription is disabled.
+func (s *WebhookService) Redeliver(ctx context.Context, id, deliveryID int64, actor string) (*model.WebhookDelivery, error) {
+	var d *model.WebhookDelivery
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		repo := s.webhooks.WithTx(tx)
+		w, err := activeWebhook(ctx, repo, id)
+		if err != nil {
+			return err
+		}
+		original, err := repo.GetDelivery(ctx, id, deliveryID)
+		if err != nil {
+			return err
+		}
+		if original.Status == model.DeliveryPending {
+			return &ConflictError{Conflicts: []string{fmt.Sprintf("delivery %d is still pending", original.ID)}}
+		}
+
+		d = &model.WebhookDelivery{
+			SubscriptionID: w.ID,
+			EventID:        original.EventID,
+			EventType:      original.EventType,
+			RedeliveryOf:   &original.ID,
+		}
+		if err := repo.CreateDelivery(ctx, d); err != nil {
+			return err
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditWebhookRedeliver,
+			EntityType: "webhook",
+			EntityID:   &w.ID,
+			Actor:      actor,
+			Details:    model.JSONMap{"delivery_id": original.ID, "event_id": original.EventID},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Run matches new events and sends due deliveries every interval until ctx
+// is cancelled, on the replica holding the webhook lease. The lease is
+// released on return.
+func (s *WebhookService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.options.Interval)
+	defer ticker.Stop()
+	defer func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.leases.Release(releaseCtx, webhookLease, s.owner); err != nil {
+			s.logger.Warn().Err(err).Msg("Failed to release webhook lease")
+		}
+	}()
+
+	for {
+		s.tick(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick renews the webhook lease and, while holding it, prunes expired
+// events, matches new ones and sends the due deliveries
+func (s *WebhookService) tick(ctx context.Context) {
+	leader, err := s.leases.Acquire(ctx, webhookLease, s.owner, s.options.Lease)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Error().Err(err).Msg("Failed to acquire webhook lease")
+		}
+		return
+	}
+	if !leader {
+		return
+	}
+
+	if s.options.Retention > 0 && time.Since(s.lastPrune) > webhookPruneInterval {
+		if _, err := s.webhooks.DeleteBefore(ctx, time.Now().Add(-s.options.Retention)); err != nil {
+			if ctx.Err() == nil {
+				s.logger.Warn().Err(err).Msg("Failed to prune webhook events")
+			}
+		} else {
+			s.lastPrune = time.Now()
+		}
+	}
+
+	if err := s.dispatch(ctx); err != nil {
+		if ctx.Err() == nil {
+			s.logger.Error().Err(err).Msg("Failed to dispatch webhook events")
+		}
+		return
+	}
+
+	limit, claim := s.dueBatch()
+	due, err := s.webhooks.Due(ctx, limit, claim)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Error().Err(err).Msg("Failed to list due webhook deliveries")
+		}
+		return
+	}
+	sem := make(chan struct{}, s.options.Concurrency)
+	var wg sync.WaitGroup
+	for i := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(d *repository.DueDelivery) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			s.deliver(ctx, d)
+		}(&due[i])
+	}
+	wg.Wait()
+}
+
+// dispatch queues a delivery of every new event to each active subscription
+// matching it, in one transaction
+func (s *WebhookService) dispatch(ctx context.Context) error {
+	return s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		repo := s.webhooks.WithTx(tx)
+		events, err := repo.LockPending(ctx, maxDispatchedEvents)
+		if err != nil || len(events) == 0 {
+			return err
+		}
+		webhooks, err := repo.ListActive(ctx)
+		if err != nil {
+			return err
+		}
+
+		exprs := make([]selector.Expr, len(webhooks))
+		var hierarchy *tagHierarchy
+		for i, w := range webhooks {
+			if w.Selector == "" {
+				continue
+			}
+			if exprs[i], err = selector.Parse(w.Selector); err != nil {
+				return fmt.Errorf("invalid selector of webhook %s: %w", w.Name, err)
+			}
+			if hierarchy == nil {
+				tags, err := s.tags.WithTx(tx).List(ctx)
+				if err != nil {
+					return err
+				}
+				hierarchy = newTagHierarchy(tags)
+			}
+		}
+
+		ids := make([]int64, 0, len(events))
+		for i := range events {
+			ev := &events[i]
+			if hierarchy != nil {
+				// Selectors match the ancestors of the tags too, as they do
+				// when listing templates
+				ev.Tags = hierarchy.withAncestors(ev.Tags)
+			}
+			for j := range webhooks {
+				if !webhookMatches(&webhooks[j], exprs[j], ev) {
+					continue
+				}
+				d := &model.WebhookDelivery{SubscriptionID: webhooks[j].ID, EventID: ev.ID}
+				if err := repo.CreateDelivery(ctx, d); err != nil {
+					return err
+				}
+			}
+			ids = append(ids, ev.ID)
+		}
+		return repo.MarkDispatched(ctx, ids)
+	})
+}
+
+// deliver makes one attempt of a due delivery and records its outcome:
+// success, a retry after backoff or, out of attempts, failure. Failures
+// extend the failure streak of the subscription, which is disabled once the
+// streak lasts DisableAfter.
+func (s *WebhookService) deliver(ctx context.Context, d *repository.DueDelivery) {
+	a, ok := s.send(ctx, d)
+	if ctx.Err() != nil {
+		// Interrupted by shutdown; the attempt is made again once the claim
+		// on the delivery expires
+		return
+	}
+
+	d.Attempts++
+	a.Attempt = d.Attempts
+	d.LastResponseCode, d.LastError = a.ResponseCode, a.Error
+	outcome := model.DeliverySucceeded
+	switch {
+	case ok:
+		d.Status = model.DeliverySucceeded
+	case d.Attempts >= s.options.MaxAttempts:
+		d.Status = model.DeliveryFailed
+		outcome = model.DeliveryFailed
+	default:
+		next := time.Now().Add(webhookBackoff(d.Attempts, s.options.RetryBase, s.options.RetryMax))
+		d.NextAttemptAt = &next
+		outcome = "retrying"
+	}
+
+	var disabled bool
+	err := s.db.Transaction(ctx, func(tx *sql.Tx) error {
+		repo := s.webhooks.WithTx(tx)
+		if err := repo.RecordAttempt(ctx, &d.WebhookDelivery, &a); err != nil {
+			return err
+		}
+		if ok {
+			return repo.RecordSuccess(ctx, d.SubscriptionID)
+		}
+		reason := fmt.Sprintf("every delivery failed for %s, last: %s", s.options.DisableAfter, a.Error)
+		var err error
+		if disabled, err = repo.RecordFailure(ctx, d.SubscriptionID, s.options.DisableAfter, reason); err != nil || !disabled {
+			return err
+		}
+		return s.audit.WithTx(tx).Create(ctx, &model.AuditEntry{
+			Action:     model.AuditWebhookDisable,
+			EntityType: "webhook",
+			EntityID:   &d.SubscriptionID,
+			Actor:      "system",
+			Details:    model.JSONMap{"reason": reason},
+		})
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Error().Err(err).Int64("delivery_id", d.ID).Msg("Failed to record webhook delivery")
+		}
+		return
+	}
+
+	metrics.RecordWebhookDelivery(outcome)
+	if !ok {
+		s.logger.Warn().
+			Int64("webhook_id", d.SubscriptionID).
+			Int64("delivery_id", d.ID).
+			Int("attempt", d.Attempts).
+			Str("status", d.Status).
+			Str("error", a.Error).
+			Msg("Webhook delivery failed")
+	}
+	if disabled {
+		s.logger.Warn().Int64("webhook_id", d.SubscriptionID).Msg("Webhook disabled after sustained failures")
+	}
+}
+
+// send POSTs the event of a delivery to its subscription. Any 2xx response
+// is a success; other responses, redirects included, are failures.
+func (s *WebhookService) send(ctx context.Context, d *repository.DueDelivery) (model.WebhookDeliveryAttempt, bool) {
+	var a model.WebhookDeliveryAttempt
+	secret, err := s.revealSecret(d.Secret, d.SecretEnvelope)
+	if err != nil {
+		a.Error = fmt.Sprintf("failed to decrypt webhook secret: %v", err)
+		return a, false
+	}
+	body, err := json.Marshal(d.Payload)
+	if err != nil {
+		a.Error = fmt.Sprintf("failed to encode payload: %v", err)
+		return a, false
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		a.Error = err.Error()
+		return a, false
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "config-service-webhooks")
+	req.Header.Set("X-Webhook-Event", d.EventType)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(d.ID, 10))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", signWebhook(secret, timestamp, body))
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	a.DurationMS = int(time.Since(start).Milliseconds())
+	if err != nil {
+		a.Error = err.Error()
+		return a, false
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxLoggedResponse))
+	code := resp.StatusCode
+	a.ResponseCode = &code
+	a.ResponseBody = strings.ReplaceAll(strings.ToValidUTF8(string(data), ""), "\x00", "")
+	if code < 200 || code > 299 {
+		a.Error = fmt.Sprintf("unexpected response status %d", code)
+		return a, false
+	}
+	return a, true
+}
+
+// sealSecret seals the plaintext secret of a subscription when a master key
+// is configured. Secret is kept for the response. Without a master key a
+// secret already sealed stays as is.
+func (s *WebhookService) sealSecret(w *model.WebhookSubscription) error {
+	if w.Secret == "" {
+		return nil
+	}
+	if s.cipher == nil {
+		w.SecretEnvelope = nil
+		return nil
+	}
+	envelope, err := s.cipher.Encrypt(w.Secret)
+	if err != nil {
+		return fmt.Errorf("failed to seal webhook secret: %w", err)
+	}
+	w.SecretEnvelope = envelope
+	return nil
+}
+
+// revealSecret returns a signing secret, opening its envelope when sealed
+func (s *WebhookService) revealSecret(secret string, envelope model.JSONMap) (string, error) {
+	if envelope == nil {
+		return secret, nil
+	}
+	if s.cipher == nil {
+		return "", secrets.ErrNotConfigured
+	}
+	value, err := s.cipher.Decrypt(envelope)
+	if err != nil {
+		return "", err
+	}
+	secret, ok := value.(string)
+	if !ok {
+		return "", secrets.ErrInvalidEnvelope
+	}
+	return secret, nil
+}
+
+// dueBatch returns how many due deliveries a tick claims and for how long.
+// The batch is what the workers can send within the lease, at least one
+// round, so that the tick is done before another replica may take over; the
+// claim covers the whole batch either way.
+func (s *WebhookService) dueBatch() (int, time.Duration) {
+	rounds := 1
+	if s.options.Timeout > 0 {
+		rounds = max(1, int(s.options.Lease/s.options.Timeout))
+	}
+	limit := min(maxDueDeliveries, rounds*s.options.Concurrency)
+	return limit, max(s.options.Lease, time.Duration(rounds)*s.options.Timeout)
+}
+
+// resolveEnvironment restricts a subscription to the environment with the
+// given slug, or lifts the restriction for an empty slug
+func (s *WebhookService) resolveEnvironment(ctx context.Context, tx *sql.Tx, w *model.WebhookSubscription, slug string) error {
+	if slug == "" {
+		w.EnvironmentID, w.Environment = nil, ""
+		return nil
+	}
+	env, err := s.environments.WithTx(tx).GetBySlug(ctx, slug)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return &ValidationError{Field: "environment", Message: fmt.Sprintf("environment %s does not exist", slug)}
+		}
+		return err
+	}
+	w.EnvironmentID, w.Environment = &env.ID, env.Slug
+	return nil
+}
+
+// activeWebhook returns a subscription, or a *ConflictError when it is
+// disabled
+func activeWebhook(ctx context.Context, repo *repository.WebhookRepository, id int64) (*model.WebhookSubscription, error) {
+	w, err := repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !w.Active {
+		return nil, &ConflictError{Conflicts: []string{fmt.Sprintf("webhook %s is disabled", w.Name)}}
+	}
+	return w, nil
+}
+
+// applyWebhookUpdate copies the fields set in req onto w; environments are
+// resolved by the caller
+func applyWebhookUpdate(w *model.WebhookSubscription, req *model.UpdateWebhookRequest, actor string) {
+	if req.Name != nil {
+		w.Name = *req.Name
+	}
+	if req.URL != nil {
+		w.URL = *req.URL
+	}
+	if req.Secret != nil {
+		w.Secret, w.SecretEnvelope = *req.Secret, nil
+	}
+	if req.Selector != nil {
+		w.Selector = strings.TrimSpace(*req.Selector)
+	}
+	if req.EventTypes != nil {
+		w.EventTypes = req.EventTypes
+	}
+	if req.Active != nil && *req.Active != w.Active {
+		w.Active = *req.Active
+		if w.Active {
+			w.DisabledReason = ""
+			w.ConsecutiveFailures, w.FailingSince = 0, nil
+		} else {
+			w.DisabledReason = "disabled by " + actor
+		}
+	}
+}
+
+// validateWebhook checks the URL and selector of a subscription
+func validateWebhook(w *model.WebhookSubscription) error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return &ValidationError{Field: "url", Message: "must be an absolute http or https URL"}
+	}
+	if w.Selector != "" {
+		if _, err := selector.Parse(w.Selector); err != nil {
+			return &ValidationError{Field: "selector", Message: err.Error()}
+		}
+	}
+	return nil
+}
+
+// webhookConflict maps a unique violation on the name of a subscription to
+// a *ConflictError
+func webhookConflict(err error, name string) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return &ConflictError{Conflicts: []string{fmt.Sprintf("webhook %q already exists", name)}}
+	}
+	return err
+}
+
+// webhookMatches reports whether an event is delivered to a subscription:
+// its environment, type and template tags must match the filters set. The
+// event tags are expected to include their ancestors. Pings are delivered
+// directly, never matched.
+func webhookMatches(w *model.WebhookSubscription, expr selector.Expr, ev *model.WebhookEvent) bool {
+	if ev.Type == model.WebhookPing {
+		return false
+	}
+	if w.EnvironmentID != nil && (ev.EnvironmentID == nil || *ev.EnvironmentID != *w.EnvironmentID) {
+		return false
+	}
+	if len(w.EventTypes) > 0 && !slices.Contains(w.EventTypes, ev.Type) {
+		return false
+	}
+	return expr == nil || expr.Matches(ev.Tags)
+}
+
+// tagHierarchy looks up the parent chains of tags
+type tagHierarchy struct {
+	byName map[string]model.Tag
+	byID   map[int64]model.Tag
+}
+
+func newTagHierarchy(tags []model.Tag) *tagHierarchy {
+	h := &tagHierarchy{byName: make(map[string]model.Tag, len(tags)), byID: make(map[int64]model.Tag, len(tags))}
+	for _, tag := range tags {
+		h.byName[tag.Name] = tag
+		h.byID[tag.ID] = tag
+	}
+	return h
+}
+
+// withAncestors returns tags followed by the ancestors of each one not
+// already present. Tags unknown to the hierarchy, such as ones deleted since
+// the event was queued, are kept without ancestors.
+func (h *tagHierarchy) withAncestors(tags []model.Tag) []model.Tag {
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		seen[tag.Name] = true
+	}
+	out := slices.Clip(tags)
+	for _, tag := range tags {
+		current, ok := h.byName[tag.Name]
+		// The depth bound guards against a cycle written concurrently
+		for depth := 0; ok && current.ParentID != nil && depth < len(h.byID); depth++ {
+			if current, ok = h.byID[*current.ParentID]; ok && !seen[current.Name] {
+				seen[current.Name] = true
+				out = append(out, current)
+			}
+		}
+	}
+	return out
+}
+
+// signWebhook returns the X-Webhook-Signature of a body: the hex encoded
+// HMAC-SHA256, keyed with the secret, of the timestamp, a dot and the body
+func signWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff returns the wait after the given number of failed attempts:
+// base, doubled for every further attempt, up to max
+func webhookBackoff(attempts int, base, max time.Duration) time.Duration {
+	wait := base
+	for i := 1; i < attempts && wait < max; i++ {
+		wait *= 2
+	}
+	if max > 0 && wait > max {
+		return max
+	}
+	return wait
+}
+
+// generateWebhookSecret returns a random secret for signing deliveries
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/company/config-service/internal/model"
+	"github.com/company/config-service/internal/secrets"
+	"github.com/company/config-service/internal/selector"
+)
+
+func TestWebhookMatchesHierarchy(t *testing.T) {
+	id := func(i int64) *int64 { return &i }
+	hierarchy := newTagHierarchy([]model.Tag{
+		{ID: 1, Name: "backend"},
+		{ID: 2, Name: "payments", ParentID: id(1)},
+		{ID: 3, Name: "payments-api", ParentID: id(2)},
+		{ID: 4, Name: "team=core", Key: "team", Value: "core"},
+		{ID: 5, Name: "billing", ParentID: id(4)},
+		{ID: 6, Name: "frontend"},
+	})
+
+	tests := []struct {
+		selector string
+		tags     []string
+		want     bool
+	}{
+		{"payments-api", []string{"payments-api"}, true},
+		{"backend", []string{"payments-api"}, true},
+		{"payments", []string{"payments-api", "frontend"}, true},
+		{"payments-api", []string{"payments"}, false},
+		{"team=core", []string{"billing"}, true},
+		{"team=*", []string{"billing"}, true},
+		{"NOT backend", []string{"payments"}, false},
+		{"frontend AND backend", []string{"frontend", "payments-api"}, true},
+		{"backend", []string{"unknown"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			expr, err := selector.Parse(tt.selector)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.selector, err)
+			}
+			ev := &model.WebhookEvent{Type: model.WebhookTemplateUpdated}
+			for _, name := range tt.tags {
+				ev.Tags = append(ev.Tags, model.Tag{Name: name})
+			}
+			ev.Tags = hierarchy.withAncestors(ev.Tags)
+			if got := webhookMatches(&model.WebhookSubscription{}, expr, ev); got != tt.want {
+				t.Errorf("%q matches %v = %v, want %v", tt.selector, tt.tags, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookSecretSealing(t *testing.T) {
+	key := make([]byte, secrets.KeySize)
+	cipher, err := secrets.NewCipher([]secrets.MasterKey{{Version: 1, Key: key}})
+	if err != nil {
+		t.Fatalf("NewCipher error: %v", err)
+	}
+	sealing := &WebhookService{cipher: cipher}
+	plain := &WebhookService{}
+
+	w := &model.WebhookSubscription{Secret: "0123456789abcdef"}
+	if err := sealing.sealSecret(w); err != nil {
+		t.Fatalf("sealSecret error: %v", err)
+	}
+	if !secrets.IsEnvelope(map[string]interface{}(w.SecretEnvelope)) {
+		t.Fatalf("secret not sealed: %v", w.SecretEnvelope)
+	}
+	if got, err := sealing.revealSecret("", w.SecretEnvelope); err != nil || got != w.Secret {
+		t.Errorf("revealSecret = %q, %v, want %q", got, err, w.Secret)
+	}
+	if _, err := plain.revealSecret("", w.SecretEnvelope); !errors.Is(err, secrets.ErrNotConfigured) {
+		t.Errorf("revealSecret without master key error = %v, want %v", err, secrets.ErrNotConfigured)
+	}
+
+	// Without a master key a new secret replaces the sealed one in plaintext
+	w.Secret = "fedcba9876543210"
+	if err := plain.sealSecret(w); err != nil || w.SecretEnvelope != nil {
+		t.Errorf("sealSecret without master key = %v, %v", w.SecretEnvelope, err)
+	}
+	if got, _ := plain.revealSecret(w.Secret, w.SecretEnvelope); got != w.Secret {
+		t.Errorf("revealSecret = %q, want %q", got, w.Secret)
+	}
+}
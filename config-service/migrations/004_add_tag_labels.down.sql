@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_tags_key_value;
+ALTER TABLE tags
+    DROP CONSTRAINT IF EXISTS tags_label_check,
+    DROP COLUMN IF EXISTS value,
+    DROP COLUMN IF EXISTS key;
@@ -0,0 +1,8 @@
+-- Key/value labels on tags. Plain tags leave both columns NULL; existing
+-- tags named key=value are converted with POST /tags/labels/migrate.
+ALTER TABLE tags
+    ADD COLUMN key VARCHAR(63),
+    ADD COLUMN value VARCHAR(100),
+    ADD CONSTRAINT tags_label_check CHECK ((key IS NULL) = (value IS NULL));
+
+CREATE UNIQUE INDEX idx_tags_key_value ON tags(key, value) WHERE key IS NOT NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_tags_parent_id;
+ALTER TABLE tags
+    DROP CONSTRAINT IF EXISTS tags_parent_check,
+    DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,7 @@
+-- Hierarchical tags: a tag may have a parent tag. Cycles are rejected by the
+-- service; deleting a parent detaches its children.
+ALTER TABLE tags
+    ADD COLUMN parent_id BIGINT REFERENCES tags(id) ON DELETE SET NULL,
+    ADD CONSTRAINT tags_parent_check CHECK (parent_id <> id);
+
+CREATE INDEX idx_tags_parent_id ON tags(parent_id);
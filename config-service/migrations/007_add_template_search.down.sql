@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_templates_search_vector;
+ALTER TABLE templates DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,10 @@
+-- Full-text search over template name, description and content. Matches in
+-- the name rank above matches in the description, which rank above content.
+ALTER TABLE templates
+    ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('english'::regconfig, COALESCE(name, '')), 'A') ||
+        setweight(to_tsvector('english'::regconfig, COALESCE(description, '')), 'B') ||
+        setweight(to_tsvector('english'::regconfig, COALESCE(content, '')), 'C')
+    ) STORED;
+
+CREATE INDEX idx_templates_search_vector ON templates USING GIN (search_vector);
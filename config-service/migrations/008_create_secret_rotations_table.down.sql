@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS update_secret_rotations_updated_at ON secret_rotations;
+DROP TABLE IF EXISTS secret_rotations;
@@ -0,0 +1,30 @@
+-- Progress of the jobs rewrapping secret data keys with the primary master
+-- key. The replica working a running job holds it through owner and renews
+-- heartbeat_at with every batch; another replica takes over once the
+-- heartbeat is older than the lease.
+CREATE TABLE IF NOT EXISTS secret_rotations (
+    id BIGSERIAL PRIMARY KEY,
+    key_id VARCHAR(32) NOT NULL,
+    key_version INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    total INTEGER NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    rewrapped INTEGER NOT NULL DEFAULT 0,
+    last_template_id BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    started_by VARCHAR(100) NOT NULL,
+    owner VARCHAR(255) NOT NULL DEFAULT '',
+    heartbeat_at TIMESTAMP WITH TIME ZONE,
+    started_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    completed_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT secret_rotations_status_check CHECK (status IN ('running', 'paused', 'completed', 'failed'))
+);
+
+-- At most one rotation is unfinished at a time
+CREATE UNIQUE INDEX idx_secret_rotations_unfinished ON secret_rotations ((true))
+    WHERE status IN ('running', 'paused');
+
+CREATE TRIGGER update_secret_rotations_updated_at
+    BEFORE UPDATE ON secret_rotations
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
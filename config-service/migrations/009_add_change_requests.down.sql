@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS change_request_comments;
+DROP TABLE IF EXISTS change_request_approvals;
+DROP TRIGGER IF EXISTS update_change_requests_updated_at ON change_requests;
+DROP TABLE IF EXISTS change_requests;
+ALTER TABLE environments
+    DROP COLUMN IF EXISTS required_approvals,
+    DROP COLUMN IF EXISTS protected;
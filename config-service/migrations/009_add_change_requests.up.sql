@@ -0,0 +1,58 @@
+-- Templates of protected environments only change through change requests
+-- approved by required_approvals users other than the author
+ALTER TABLE environments
+    ADD COLUMN protected BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN required_approvals INTEGER NOT NULL DEFAULT 1
+        CHECK (required_approvals >= 1 AND required_approvals <= 10);
+
+-- A change request proposes creating, updating or deleting one template.
+-- proposed holds the complete template to write, with secret values already
+-- encrypted, and base_updated_at the updated_at of the template it was
+-- proposed against so that changes made in the meantime are detected.
+CREATE TABLE IF NOT EXISTS change_requests (
+    id BIGSERIAL PRIMARY KEY,
+    environment_id BIGINT NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    template_id BIGINT REFERENCES templates(id) ON DELETE SET NULL,
+    operation VARCHAR(10) NOT NULL CHECK (operation IN ('create', 'update', 'delete')),
+    title VARCHAR(200) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    proposed JSONB,
+    base_updated_at TIMESTAMP WITH TIME ZONE,
+    diff JSONB NOT NULL DEFAULT '[]',
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'applied', 'rejected')),
+    required_approvals INTEGER NOT NULL,
+    author VARCHAR(100) NOT NULL,
+    resolved_by VARCHAR(100) NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_change_requests_environment_id ON change_requests(environment_id);
+CREATE INDEX idx_change_requests_template_id ON change_requests(template_id);
+CREATE INDEX idx_change_requests_status ON change_requests(status);
+CREATE INDEX idx_change_requests_created_at ON change_requests(created_at);
+
+CREATE TRIGGER update_change_requests_updated_at
+    BEFORE UPDATE ON change_requests
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS change_request_approvals (
+    change_request_id BIGINT NOT NULL REFERENCES change_requests(id) ON DELETE CASCADE,
+    approver VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (change_request_id, approver)
+);
+
+-- Comments form threads through parent_id
+CREATE TABLE IF NOT EXISTS change_request_comments (
+    id BIGSERIAL PRIMARY KEY,
+    change_request_id BIGINT NOT NULL REFERENCES change_requests(id) ON DELETE CASCADE,
+    parent_id BIGINT REFERENCES change_request_comments(id) ON DELETE CASCADE,
+    author VARCHAR(100) NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_change_request_comments_change_request_id ON change_request_comments(change_request_id);
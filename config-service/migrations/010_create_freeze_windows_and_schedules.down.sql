@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS leader_leases;
+DROP TRIGGER IF EXISTS update_scheduled_deployments_updated_at ON scheduled_deployments;
+DROP TABLE IF EXISTS scheduled_deployments;
+DROP TABLE IF EXISTS freeze_windows;
@@ -0,0 +1,60 @@
+-- Freeze windows block template writes in an environment. A window either
+-- spans a fixed range (starts_at, ends_at) or recurs: it opens at every
+-- minute matching the cron expression, in the given time zone, and stays
+-- open for duration_seconds.
+CREATE TABLE IF NOT EXISTS freeze_windows (
+    id BIGSERIAL PRIMARY KEY,
+    environment_id BIGINT NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    cron VARCHAR(100),
+    duration_seconds INTEGER,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    starts_at TIMESTAMP WITH TIME ZONE,
+    ends_at TIMESTAMP WITH TIME ZONE,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (environment_id, name),
+    CONSTRAINT freeze_windows_kind_check CHECK (
+        (cron IS NOT NULL AND duration_seconds > 0 AND starts_at IS NULL AND ends_at IS NULL)
+        OR (cron IS NULL AND duration_seconds IS NULL AND starts_at < ends_at)
+    )
+);
+
+CREATE INDEX idx_freeze_windows_environment_id ON freeze_windows(environment_id);
+
+-- A scheduled deployment applies an update to a template once run_at has
+-- passed. changes holds the update with secret values already encrypted.
+CREATE TABLE IF NOT EXISTS scheduled_deployments (
+    id BIGSERIAL PRIMARY KEY,
+    template_id BIGINT REFERENCES templates(id) ON DELETE SET NULL,
+    environment_id BIGINT NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    version VARCHAR(50) NOT NULL,
+    changes JSONB NOT NULL,
+    diff JSONB NOT NULL DEFAULT '[]',
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'applied', 'failed', 'canceled')),
+    error TEXT NOT NULL DEFAULT '',
+    created_by VARCHAR(100) NOT NULL,
+    canceled_by VARCHAR(100) NOT NULL DEFAULT '',
+    applied_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_scheduled_deployments_template_id ON scheduled_deployments(template_id);
+CREATE INDEX idx_scheduled_deployments_environment_id ON scheduled_deployments(environment_id);
+CREATE INDEX idx_scheduled_deployments_due ON scheduled_deployments(run_at) WHERE status = 'pending';
+
+CREATE TRIGGER update_scheduled_deployments_updated_at
+    BEFORE UPDATE ON scheduled_deployments
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Leases elect the replica running a background job. The holder renews
+-- expires_at while it is alive; any replica may take over an expired lease.
+CREATE TABLE IF NOT EXISTS leader_leases (
+    name VARCHAR(100) PRIMARY KEY,
+    holder VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS update_template_candidates_updated_at ON template_candidates;
+DROP TABLE IF EXISTS template_candidates;
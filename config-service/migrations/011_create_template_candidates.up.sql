@@ -0,0 +1,20 @@
+-- A candidate is a new version of a template served to rollout_percentage
+-- percent of clients, picked by a hash of their instance ID, until it is
+-- promoted to replace the stable version or aborted. default_values holds
+-- secret values already encrypted, as on templates.
+CREATE TABLE IF NOT EXISTS template_candidates (
+    template_id BIGINT PRIMARY KEY REFERENCES templates(id) ON DELETE CASCADE,
+    version VARCHAR(50) NOT NULL,
+    content TEXT NOT NULL,
+    default_values JSONB NOT NULL DEFAULT '{}',
+    rollout_percentage INTEGER NOT NULL DEFAULT 0
+        CHECK (rollout_percentage >= 0 AND rollout_percentage <= 100),
+    created_by VARCHAR(100) NOT NULL,
+    updated_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TRIGGER update_template_candidates_updated_at
+    BEFORE UPDATE ON template_candidates
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
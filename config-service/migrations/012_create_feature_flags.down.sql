@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS update_feature_flags_updated_at ON feature_flags;
+DROP TABLE IF EXISTS feature_flag_tags;
+DROP TABLE IF EXISTS feature_flags;
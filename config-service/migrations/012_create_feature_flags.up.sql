@@ -0,0 +1,34 @@
+-- Feature flags of an environment. variations, rules and fallthrough_serve hold
+-- the JSON encoded targeting configuration evaluated by the service.
+CREATE TABLE IF NOT EXISTS feature_flags (
+    id BIGSERIAL PRIMARY KEY,
+    environment_id BIGINT NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    key VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    type VARCHAR(20) NOT NULL CHECK (type IN ('boolean', 'multivariate')),
+    variations JSONB NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT false,
+    off_variation VARCHAR(100) NOT NULL,
+    rules JSONB NOT NULL DEFAULT '[]',
+    fallthrough_serve JSONB NOT NULL,
+    bucket_by VARCHAR(100) NOT NULL DEFAULT 'key',
+    created_by VARCHAR(100) NOT NULL,
+    updated_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    UNIQUE(environment_id, key)
+);
+
+-- Flags are grouped by the same tags as templates
+CREATE TABLE IF NOT EXISTS feature_flag_tags (
+    flag_id BIGINT NOT NULL REFERENCES feature_flags(id) ON DELETE CASCADE,
+    tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (flag_id, tag_id)
+);
+
+CREATE INDEX idx_feature_flag_tags_tag_id ON feature_flag_tags(tag_id);
+
+CREATE TRIGGER update_feature_flags_updated_at
+    BEFORE UPDATE ON feature_flags
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
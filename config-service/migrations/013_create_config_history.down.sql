@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS record_template_candidates_history ON template_candidates;
+DROP TRIGGER IF EXISTS record_template_tags_history ON template_tags;
+DROP TRIGGER IF EXISTS record_templates_history ON templates;
+DROP TRIGGER IF EXISTS record_tags_history ON tags;
+DROP TRIGGER IF EXISTS record_environments_history ON environments;
+DROP FUNCTION IF EXISTS record_history();
+DROP TABLE IF EXISTS config_history;
@@ -0,0 +1,94 @@
+-- Every version of the rows of the tables served to clients, valid from the
+-- transaction that wrote it until the one that replaced or deleted it.
+-- row_key holds the primary key of the row and data the row itself, so that
+-- the state at any moment can be rebuilt with jsonb_populate_record.
+CREATE TABLE IF NOT EXISTS config_history (
+    id BIGSERIAL PRIMARY KEY,
+    table_name VARCHAR(63) NOT NULL,
+    row_key JSONB NOT NULL,
+    data JSONB NOT NULL,
+    valid_from TIMESTAMP WITH TIME ZONE NOT NULL,
+    valid_to TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_config_history_valid ON config_history(table_name, valid_from, valid_to);
+CREATE INDEX idx_config_history_current ON config_history(table_name, row_key) WHERE valid_to IS NULL;
+
+-- Records the new version of a row and closes the previous one. The trigger
+-- arguments name the primary key columns. Versions written and replaced in
+-- the same transaction were never visible and are dropped. The generated
+-- search vector of templates is left out.
+CREATE OR REPLACE FUNCTION record_history()
+RETURNS TRIGGER AS $$
+DECLARE
+    old_key JSONB := '{}';
+    new_key JSONB := '{}';
+BEGIN
+    FOR i IN 0 .. TG_NARGS - 1 LOOP
+        IF TG_OP <> 'INSERT' THEN
+            old_key := old_key || jsonb_build_object(TG_ARGV[i], to_jsonb(OLD) -> TG_ARGV[i]);
+        END IF;
+        IF TG_OP <> 'DELETE' THEN
+            new_key := new_key || jsonb_build_object(TG_ARGV[i], to_jsonb(NEW) -> TG_ARGV[i]);
+        END IF;
+    END LOOP;
+
+    IF TG_OP <> 'INSERT' THEN
+        DELETE FROM config_history
+        WHERE table_name = TG_TABLE_NAME AND row_key = old_key
+          AND valid_to IS NULL AND valid_from = NOW();
+        UPDATE config_history SET valid_to = NOW()
+        WHERE table_name = TG_TABLE_NAME AND row_key = old_key AND valid_to IS NULL;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        INSERT INTO config_history (table_name, row_key, data, valid_from)
+        VALUES (TG_TABLE_NAME, new_key, to_jsonb(NEW) - 'search_vector', NOW());
+    END IF;
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER record_environments_history
+    AFTER INSERT OR UPDATE OR DELETE ON environments
+    FOR EACH ROW EXECUTE FUNCTION record_history('id');
+
+CREATE TRIGGER record_tags_history
+    AFTER INSERT OR UPDATE OR DELETE ON tags
+    FOR EACH ROW EXECUTE FUNCTION record_history('id');
+
+CREATE TRIGGER record_templates_history
+    AFTER INSERT OR UPDATE OR DELETE ON templates
+    FOR EACH ROW EXECUTE FUNCTION record_history('id');
+
+CREATE TRIGGER record_template_tags_history
+    AFTER INSERT OR UPDATE OR DELETE ON template_tags
+    FOR EACH ROW EXECUTE FUNCTION record_history('template_id', 'tag_id');
+
+CREATE TRIGGER record_template_candidates_history
+    AFTER INSERT OR UPDATE OR DELETE ON template_candidates
+    FOR EACH ROW EXECUTE FUNCTION record_history('template_id');
+
+-- Seed the history with the current rows, valid since their last update.
+-- Earlier versions are unknown, so rows do not exist before that; tag links
+-- carry no timestamps and are dated from the creation of their template.
+INSERT INTO config_history (table_name, row_key, data, valid_from)
+SELECT 'environments', jsonb_build_object('id', e.id), to_jsonb(e), COALESCE(e.updated_at, NOW())
+FROM environments e;
+
+INSERT INTO config_history (table_name, row_key, data, valid_from)
+SELECT 'tags', jsonb_build_object('id', g.id), to_jsonb(g), COALESCE(g.updated_at, NOW())
+FROM tags g;
+
+INSERT INTO config_history (table_name, row_key, data, valid_from)
+SELECT 'templates', jsonb_build_object('id', t.id), to_jsonb(t) - 'search_vector', COALESCE(t.updated_at, NOW())
+FROM templates t;
+
+INSERT INTO config_history (table_name, row_key, data, valid_from)
+SELECT 'template_tags', jsonb_build_object('template_id', tt.template_id, 'tag_id', tt.tag_id),
+    to_jsonb(tt), COALESCE(t.created_at, NOW())
+FROM template_tags tt
+JOIN templates t ON t.id = tt.template_id;
+
+INSERT INTO config_history (table_name, row_key, data, valid_from)
+SELECT 'template_candidates', jsonb_build_object('template_id', c.template_id), to_jsonb(c), COALESCE(c.updated_at, NOW())
+FROM template_candidates c;
@@ -0,0 +1,13 @@
+-- Named snapshots of an environment. templates holds a copy of every
+-- template at the time the snapshot was taken, with default values as
+-- stored (secret values stay encrypted) and tags by name.
+CREATE TABLE IF NOT EXISTS environment_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    environment_id BIGINT NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    templates JSONB NOT NULL DEFAULT '[]',
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (environment_id, name)
+);
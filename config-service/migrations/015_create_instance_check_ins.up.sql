@@ -0,0 +1,14 @@
+-- The latest check-in of every client instance of an environment. templates
+-- lists the templates the instance has applied with their versions and the
+-- SHA-256 of their content.
+CREATE TABLE IF NOT EXISTS instance_check_ins (
+    environment_id BIGINT NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    instance_id VARCHAR(255) NOT NULL,
+    agent_version VARCHAR(100) NOT NULL DEFAULT '',
+    bundle_checksum VARCHAR(64) NOT NULL DEFAULT '',
+    templates JSONB NOT NULL DEFAULT '[]',
+    checked_in_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (environment_id, instance_id)
+);
+
+CREATE INDEX idx_instance_check_ins_checked_in_at ON instance_check_ins(checked_in_at);
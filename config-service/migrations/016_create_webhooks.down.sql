@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS queue_template_deleted_event ON templates;
+DROP TRIGGER IF EXISTS queue_template_tags_event ON template_tags;
+DROP TRIGGER IF EXISTS queue_template_event ON templates;
+DROP FUNCTION IF EXISTS queue_template_deleted_event();
+DROP FUNCTION IF EXISTS queue_template_event();
+DROP FUNCTION IF EXISTS template_event_payload(templates);
+DROP FUNCTION IF EXISTS template_event_tags(BIGINT);
+DROP TABLE IF EXISTS webhook_delivery_attempts;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_events;
+DROP TABLE IF EXISTS webhook_subscriptions;
@@ -0,0 +1,174 @@
+-- Webhook subscriptions receive the events matching their environment, tag
+-- selector and event types. A subscription failing for too long is
+-- disabled; failing_since marks the start of its current failure streak.
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    url TEXT NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    environment_id BIGINT REFERENCES environments(id) ON DELETE CASCADE,
+    selector TEXT NOT NULL DEFAULT '',
+    event_types TEXT[] NOT NULL DEFAULT '{}',
+    active BOOLEAN NOT NULL DEFAULT true,
+    disabled_reason TEXT NOT NULL DEFAULT '',
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    failing_since TIMESTAMP WITH TIME ZONE,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TRIGGER update_webhook_subscriptions_updated_at
+    BEFORE UPDATE ON webhook_subscriptions
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Outbox of events, written by the triggers below in the transaction making
+-- the change and matched against subscriptions by the webhook worker. tags
+-- holds the tags of the template for selector matching.
+CREATE TABLE IF NOT EXISTS webhook_events (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(100) NOT NULL,
+    environment_id BIGINT,
+    template_id BIGINT,
+    tags JSONB NOT NULL DEFAULT '[]',
+    payload JSONB NOT NULL,
+    txid BIGINT NOT NULL DEFAULT txid_current(),
+    dispatched_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_events_pending ON webhook_events(id) WHERE dispatched_at IS NULL;
+CREATE INDEX idx_webhook_events_txid ON webhook_events(txid, template_id);
+
+-- A delivery sends one event to one subscription until it succeeds or runs
+-- out of attempts. Redeliveries are new deliveries of the same event.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id BIGINT NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+    event_id BIGINT NOT NULL REFERENCES webhook_events(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_response_code INTEGER,
+    last_error TEXT NOT NULL DEFAULT '',
+    redelivery_of BIGINT REFERENCES webhook_deliveries(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_subscription_id ON webhook_deliveries(subscription_id, id DESC);
+CREATE INDEX idx_webhook_deliveries_event_id ON webhook_deliveries(event_id);
+
+CREATE TRIGGER update_webhook_deliveries_updated_at
+    BEFORE UPDATE ON webhook_deliveries
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Every attempt of a delivery with the response received
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    delivery_id BIGINT NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL,
+    response_code INTEGER,
+    response_body TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    duration_ms INTEGER NOT NULL,
+    attempted_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_delivery_attempts_delivery_id ON webhook_delivery_attempts(delivery_id);
+
+-- The tags of a template as event tags, for selector matching
+CREATE OR REPLACE FUNCTION template_event_tags(tid BIGINT)
+RETURNS JSONB AS $$
+    SELECT COALESCE(jsonb_agg(jsonb_build_object(
+        'name', g.name, 'key', COALESCE(g.key, ''), 'value', COALESCE(g.value, '')) ORDER BY g.name), '[]')
+    FROM template_tags tt
+    JOIN tags g ON g.id = tt.tag_id
+    WHERE tt.template_id = tid
+$$ LANGUAGE sql STABLE;
+
+-- The payload of a template event. Content and values are left out; the
+-- receiver fetches what it needs.
+CREATE OR REPLACE FUNCTION template_event_payload(tpl templates)
+RETURNS JSONB AS $$
+    SELECT jsonb_build_object(
+        'id', tpl.id,
+        'name', tpl.name,
+        'version', tpl.version,
+        'format', tpl.format,
+        'active', tpl.active,
+        'environment_id', tpl.environment_id,
+        'environment', (SELECT slug FROM environments WHERE id = tpl.environment_id),
+        'tags', (SELECT COALESCE(jsonb_agg(t -> 'name'), '[]') FROM jsonb_array_elements(template_event_tags(tpl.id)) t),
+        'updated_by', tpl.updated_by,
+        'updated_at', tpl.updated_at)
+$$ LANGUAGE sql STABLE;
+
+-- Queues template.created or template.updated for a template written or
+-- retagged by the transaction. It runs when the transaction commits, so the
+-- event carries the tag links written after the template, and queues one
+-- event per template and transaction. Transactions setting
+-- config_service.suppress_events, such as master key rotations re-encrypting
+-- values, queue none.
+CREATE OR REPLACE FUNCTION queue_template_event()
+RETURNS TRIGGER AS $$
+DECLARE
+    tid BIGINT;
+    tpl templates%ROWTYPE;
+BEGIN
+    IF current_setting('config_service.suppress_events', true) = 'on' THEN
+        RETURN NULL;
+    END IF;
+    IF TG_TABLE_NAME = 'templates' THEN
+        tid := NEW.id;
+    ELSIF TG_OP = 'DELETE' THEN
+        tid := OLD.template_id;
+    ELSE
+        tid := NEW.template_id;
+    END IF;
+
+    IF EXISTS (SELECT 1 FROM webhook_events WHERE txid = txid_current() AND template_id = tid) THEN
+        RETURN NULL;
+    END IF;
+    SELECT * INTO tpl FROM templates WHERE id = tid;
+    IF NOT FOUND THEN
+        -- Deleted by the transaction, which queued template.deleted
+        RETURN NULL;
+    END IF;
+
+    INSERT INTO webhook_events (type, environment_id, template_id, tags, payload)
+    VALUES (
+        CASE WHEN tpl.created_at = NOW() THEN 'template.created' ELSE 'template.updated' END,
+        tpl.environment_id, tpl.id, template_event_tags(tpl.id), template_event_payload(tpl));
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+-- Queues template.deleted before the tag links of the template are removed
+CREATE OR REPLACE FUNCTION queue_template_deleted_event()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF current_setting('config_service.suppress_events', true) = 'on' THEN
+        RETURN OLD;
+    END IF;
+    INSERT INTO webhook_events (type, environment_id, template_id, tags, payload)
+    VALUES ('template.deleted', OLD.environment_id, OLD.id, template_event_tags(OLD.id),
+        template_event_payload(OLD));
+    RETURN OLD;
+END;
+$$ language 'plpgsql';
+
+CREATE CONSTRAINT TRIGGER queue_template_event
+    AFTER INSERT OR UPDATE ON templates
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION queue_template_event();
+
+CREATE CONSTRAINT TRIGGER queue_template_tags_event
+    AFTER INSERT OR UPDATE OR DELETE ON template_tags
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION queue_template_event();
+
+CREATE TRIGGER queue_template_deleted_event
+    BEFORE DELETE ON templates
+    FOR EACH ROW EXECUTE FUNCTION queue_template_deleted_event();
@@ -0,0 +1 @@
+DROP FUNCTION IF EXISTS mask_secrets(JSONB);
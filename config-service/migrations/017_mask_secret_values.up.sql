@@ -0,0 +1,20 @@
+-- Replaces every encrypted secret envelope in a JSON document with the mask
+-- returned by the API, so that JSON path queries cannot select envelopes or
+-- their fields, whatever accessors they use.
+CREATE OR REPLACE FUNCTION mask_secrets(doc JSONB)
+RETURNS JSONB AS $$
+BEGIN
+    IF NOT doc @? '$.**."$secret"' THEN
+        RETURN doc;
+    END IF;
+    IF jsonb_typeof(doc) = 'object' THEN
+        IF jsonb_typeof(doc -> '$secret') = 'object'
+            AND (SELECT count(*) FROM jsonb_object_keys(doc)) = 1 THEN
+            RETURN '"******"';
+        END IF;
+        RETURN (SELECT jsonb_object_agg(key, mask_secrets(value)) FROM jsonb_each(doc));
+    END IF;
+    RETURN (SELECT jsonb_agg(mask_secrets(value) ORDER BY i)
+        FROM jsonb_array_elements(doc) WITH ORDINALITY AS e(value, i));
+END;
+$$ LANGUAGE plpgsql IMMUTABLE STRICT;
@@ -0,0 +1 @@
+ALTER TABLE secret_rotations DROP COLUMN IF EXISTS last_history_id;
@@ -0,0 +1,3 @@
+-- Rotations rewrap the secret values of recorded row versions after those of
+-- templates; last_history_id is the config_history row reached
+ALTER TABLE secret_rotations ADD COLUMN IF NOT EXISTS last_history_id BIGINT NOT NULL DEFAULT 0;
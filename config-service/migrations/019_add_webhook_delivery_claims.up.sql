@@ -0,0 +1,4 @@
+-- A due delivery is claimed by the worker sending it until locked_until, so
+-- that a replica taking over the webhook lease meanwhile does not send it
+-- again
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;
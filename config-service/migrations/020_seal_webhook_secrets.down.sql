@@ -0,0 +1,4 @@
+-- Sealed secrets cannot be opened here; they are kept as their JSON encoding
+-- and have to be set again
+ALTER TABLE webhook_subscriptions ALTER COLUMN secret TYPE TEXT
+    USING CASE WHEN jsonb_typeof(secret) = 'string' THEN secret #>> '{}' ELSE secret::text END;
@@ -0,0 +1,3 @@
+-- Webhook signing secrets are sealed in a secret envelope when a master key
+-- is configured; secrets stored without one are kept as JSON strings
+ALTER TABLE webhook_subscriptions ALTER COLUMN secret TYPE JSONB USING to_jsonb(secret);
@@ -0,0 +1 @@
+ALTER TABLE template_tags DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,6 @@
+-- Tag links record when they were made, so that tag usage reports when a tag
+-- was last linked rather than when a linked template was last edited. Links
+-- made before this migration carry no time and are dated from the creation of
+-- their template, the earliest they can have been made.
+ALTER TABLE template_tags ADD COLUMN IF NOT EXISTS created_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE template_tags ALTER COLUMN created_at SET DEFAULT NOW();
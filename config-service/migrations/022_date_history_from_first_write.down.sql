@@ -0,0 +1,29 @@
+CREATE OR REPLACE FUNCTION record_history()
+RETURNS TRIGGER AS $$
+DECLARE
+    old_key JSONB := '{}';
+    new_key JSONB := '{}';
+BEGIN
+    FOR i IN 0 .. TG_NARGS - 1 LOOP
+        IF TG_OP <> 'INSERT' THEN
+            old_key := old_key || jsonb_build_object(TG_ARGV[i], to_jsonb(OLD) -> TG_ARGV[i]);
+        END IF;
+        IF TG_OP <> 'DELETE' THEN
+            new_key := new_key || jsonb_build_object(TG_ARGV[i], to_jsonb(NEW) -> TG_ARGV[i]);
+        END IF;
+    END LOOP;
+
+    IF TG_OP <> 'INSERT' THEN
+        DELETE FROM config_history
+        WHERE table_name = TG_TABLE_NAME AND row_key = old_key
+          AND valid_to IS NULL AND valid_from = NOW();
+        UPDATE config_history SET valid_to = NOW()
+        WHERE table_name = TG_TABLE_NAME AND row_key = old_key AND valid_to IS NULL;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        INSERT INTO config_history (table_name, row_key, data, valid_from)
+        VALUES (TG_TABLE_NAME, new_key, to_jsonb(NEW) - 'search_vector', NOW());
+    END IF;
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
@@ -0,0 +1,44 @@
+-- Versions are dated from the first history write of their transaction,
+-- taken with clock_timestamp(), rather than from NOW(), the start of the
+-- transaction: a transaction that ran for a while before writing recorded
+-- versions as valid long before any client could see them. All versions of
+-- one transaction still share a moment, so that as_of reads never see part
+-- of a transaction. The time between that first write and the commit
+-- remains: a version becomes visible when its transaction commits, which
+-- may be somewhat after the moment it is recorded as valid from.
+CREATE OR REPLACE FUNCTION record_history()
+RETURNS TRIGGER AS $$
+DECLARE
+    old_key JSONB := '{}';
+    new_key JSONB := '{}';
+    valid_at TIMESTAMP WITH TIME ZONE;
+BEGIN
+    valid_at := NULLIF(current_setting('config_history.valid_at', true), '')::timestamptz;
+    IF valid_at IS NULL THEN
+        valid_at := clock_timestamp();
+        PERFORM set_config('config_history.valid_at', valid_at::text, true);
+    END IF;
+
+    FOR i IN 0 .. TG_NARGS - 1 LOOP
+        IF TG_OP <> 'INSERT' THEN
+            old_key := old_key || jsonb_build_object(TG_ARGV[i], to_jsonb(OLD) -> TG_ARGV[i]);
+        END IF;
+        IF TG_OP <> 'DELETE' THEN
+            new_key := new_key || jsonb_build_object(TG_ARGV[i], to_jsonb(NEW) -> TG_ARGV[i]);
+        END IF;
+    END LOOP;
+
+    IF TG_OP <> 'INSERT' THEN
+        DELETE FROM config_history
+        WHERE table_name = TG_TABLE_NAME AND row_key = old_key
+          AND valid_to IS NULL AND valid_from = valid_at;
+        UPDATE config_history SET valid_to = valid_at
+        WHERE table_name = TG_TABLE_NAME AND row_key = old_key AND valid_to IS NULL;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        INSERT INTO config_history (table_name, row_key, data, valid_from)
+        VALUES (TG_TABLE_NAME, new_key, to_jsonb(NEW) - 'search_vector', valid_at);
+    END IF;
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
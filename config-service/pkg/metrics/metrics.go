@@ -115,6 +115,61 @@ var (
 		[]string{"operation", "environment", "status"},
 	)
 
+	ConfigTagsTotal = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "config_tags_total",
+			Help: "Total number of tags by usage state",
+		},
+		[]string{"state"},
+	)
+
+	ConfigTagTemplates = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "config_tag_templates",
+			Help: "Number of templates linked to each tag",
+		},
+		[]string{"tag"},
+	)
+
+	ConfigSecretRotationProgress = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "config_secret_rotation_progress",
+			Help: "Share of templates processed by the running secret rotation, between 0 and 1",
+		},
+	)
+
+	ConfigRolloutAssignments = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "config_rollout_assignments_total",
+			Help: "Number of times a template under rollout was served, by variant and version",
+		},
+		[]string{"environment", "template", "variant", "version"},
+	)
+
+	ConfigFlagEvaluations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "config_flag_evaluations_total",
+			Help: "Number of feature flag evaluations, by variation served",
+		},
+		[]string{"environment", "flag", "variation"},
+	)
+
+	ConfigDriftedInstances = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "config_drifted_instances",
+			Help: "Number of client instances whose applied templates differ from what is served to them",
+		},
+		[]string{"environment"},
+	)
+
+	ConfigWebhookDeliveries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "config_webhook_deliveries_total",
+			Help: "Total number of webhook delivery attempts by outcome",
+		},
+		[]string{"status"},
+	)
+
 	ConfigTemplateSize = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "config_template_size_bytes",
@@ -135,6 +190,35 @@ func RecordTemplateSize(environment, format string, size int) {
 	ConfigTemplateSize.WithLabelValues(environment, format).Observe(float64(size))
 }
 
+// RecordRolloutAssignment records the variant of a template under rollout
+// served to a client
+func RecordRolloutAssignment(environment, template, variant, version string) {
+	ConfigRolloutAssignments.WithLabelValues(environment, template, variant, version).Inc()
+}
+
+// RecordFlagEvaluation records the variation of a feature flag served to a
+// context
+func RecordFlagEvaluation(environment, flag, variation string) {
+	ConfigFlagEvaluations.WithLabelValues(environment, flag, variation).Inc()
+}
+
+// UpdateDriftedInstances records the number of drifted instances of an
+// environment
+func UpdateDriftedInstances(environment string, count int) {
+	ConfigDriftedInstances.WithLabelValues(environment).Set(float64(count))
+}
+
+// RecordWebhookDelivery records the outcome of a webhook delivery attempt:
+// succeeded, retrying or failed
+func RecordWebhookDelivery(status string) {
+	ConfigWebhookDeliveries.WithLabelValues(status).Inc()
+}
+
+// UpdateSecretRotationProgress records the progress of the secret rotation
+func UpdateSecretRotationProgress(progress float64) {
+	ConfigSecretRotationProgress.Set(progress)
+}
+
 // UpdateTemplateCount updates template count metrics
 func UpdateTemplateCount(environment, format string, active bool, count int) {
 	activeStr := "false"
@@ -143,3 +227,20 @@ func UpdateTemplateCount(environment, format string, active bool, count int) {
 	}
 	ConfigTemplatesTotal.WithLabelValues(environment, format, activeStr).Set(float64(count))
 }
+
+// UpdateTagUsage replaces the tag usage metrics with the given per-tag
+// template counts
+func UpdateTagUsage(templatesByTag map[string]int) {
+	ConfigTagTemplates.Reset()
+	used, unused := 0, 0
+	for tag, count := range templatesByTag {
+		ConfigTagTemplates.WithLabelValues(tag).Set(float64(count))
+		if count > 0 {
+			used++
+		} else {
+			unused++
+		}
+	}
+	ConfigTagsTotal.WithLabelValues("used").Set(float64(used))
+	ConfigTagsTotal.WithLabelValues("unused").Set(float64(unused))
+}